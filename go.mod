@@ -1,6 +1,6 @@
 module mcp-mowen
 
-go 1.23.3
+go 1.24
 
 require (
 	github.com/bytedance/gopkg v0.1.0
@@ -8,4 +8,20 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.28
 )
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	github.com/go-ego/gse v1.1.0
+	github.com/google/uuid v1.6.0
+	modernc.org/sqlite v1.34.5
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/vcaesar/cedar v0.50.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
+)
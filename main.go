@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"mcp-mowen/service"
 
 	"github.com/bytedance/gopkg/util/logger"
@@ -8,20 +11,54 @@ import (
 )
 
 func main() {
+	// bench子命令：压测创建和搜索操作
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := service.InitLogging(); err != nil {
+			logger.Fatalf("日志初始化失败: %v", err)
+		}
+		if err := service.RunBench(os.Args[2:], os.Stdout); err != nil {
+			logger.Fatalf("压测失败: %v", err)
+		}
+		return
+	}
+
+	transport := flag.String("transport", "stdio", "传输方式：stdio 或 http（多租户SSE）")
+	addr := flag.String("addr", ":8080", "http模式下的监听地址")
+	baseURL := flag.String("base-url", "", "http模式下对外暴露的基础URL，默认 http://localhost<addr>")
+	flag.Parse()
+
+	if err := service.InitLogging(); err != nil {
+		logger.Fatalf("日志初始化失败: %v", err)
+	}
+
 	s := server.NewMCPServer(
 		"mcp-mowen",
-		"1.0.0",
+		service.Version,
+		server.WithResourceCapabilities(true, false),
 	)
 	logger.Info("初始化数据库...")
-	if err := service.InitSQLite(); err != nil {
-		logger.Fatalf("数据库初始化失败: %v", err)
-	}
+	// 数据库不可用时不退出，以降级模式启动并在后台重试
+	service.StartSQLite()
+	// 退出前提交尚未写入的数据
+	defer service.CloseSQLite()
 
 	logger.Info("开始注册工具...")
 	service.RegisterAllTools(s)
+	service.RegisterAllResources(s)
 
-	logger.Info("启动墨问MCP服务器...")
-	if err := server.ServeStdio(s); err != nil {
-		logger.Errorf("服务器错误: %v", err)
+	switch *transport {
+	case "http":
+		if *baseURL == "" {
+			*baseURL = "http://localhost" + *addr
+		}
+		logger.Infof("启动墨问MCP服务器（HTTP多租户模式），监听: %s", *addr)
+		if err := service.NewHTTPServer(s, *baseURL).Start(*addr); err != nil {
+			logger.Errorf("服务器错误: %v", err)
+		}
+	default:
+		logger.Info("启动墨问MCP服务器...")
+		if err := service.ServeStdio(s); err != nil {
+			logger.Errorf("服务器错误: %v", err)
+		}
 	}
 }
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 图片替代文本环境变量
+const (
+	// 替代文本生成方式：off(默认), sampling(请求客户端的大模型), endpoint(调用配置的视觉服务)
+	AltTextModeEnvVar = "MOWEN_ALT_TEXT"
+	// 视觉服务地址，endpoint模式下使用
+	AltTextURLEnvVar = "MOWEN_ALT_TEXT_URL"
+	// 视觉服务的Bearer令牌（可选）
+	AltTextTokenEnvVar = "MOWEN_ALT_TEXT_TOKEN"
+	// 生成替代文本的超时时间，默认30秒
+	AltTextTimeoutEnvVar = "MOWEN_ALT_TEXT_TIMEOUT"
+)
+
+// 用于生成替代文本的图片最大大小，更大的图片跳过
+const maxAltTextImageBytes = 5 << 20
+
+// 替代文本的最大长度（字符数）
+const maxAltTextRunes = 200
+
+// altTextRequest 提交给视觉服务的请求
+type altTextRequest struct {
+	Image    string `json:"image"` // base64编码的图片
+	MIMEType string `json:"mime_type"`
+	URL      string `json:"url,omitempty"`
+}
+
+// altTextResponse 视觉服务的返回
+type altTextResponse struct {
+	AltText string `json:"alt_text"`
+}
+
+// ensureImageAltText 图片没有替代文本时按配置自动生成，写入元数据的alt字段
+// 生成失败不影响上传，只记录日志
+func ensureImageAltText(ctx context.Context, block *ContentBlock) {
+	if block.FileType != "image" {
+		return
+	}
+	if alt, _ := block.Metadata["alt"].(string); strings.TrimSpace(alt) != "" {
+		return
+	}
+	mode := strings.ToLower(envString(AltTextModeEnvVar, "off"))
+	if mode != "sampling" && mode != "endpoint" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, envDuration(AltTextTimeoutEnvVar, 30*time.Second))
+	defer cancel()
+
+	data, mimeType, err := readImageForAltText(ctx, block)
+	if err != nil {
+		logger.Warnf("读取图片失败，跳过替代文本生成: %v", err)
+		return
+	}
+
+	var alt string
+	if mode == "sampling" {
+		alt, err = samplingAltText(ctx, data, mimeType)
+	} else {
+		alt, err = endpointAltText(ctx, data, mimeType, block)
+	}
+	if err != nil {
+		logger.Warnf("生成图片替代文本失败: %v", err)
+		return
+	}
+	if alt = truncateRunes(strings.TrimSpace(alt), maxAltTextRunes); alt == "" {
+		return
+	}
+	if block.Metadata == nil {
+		block.Metadata = make(map[string]interface{})
+	}
+	block.Metadata["alt"] = alt
+}
+
+// readImageForAltText 读取本地或远程图片内容
+func readImageForAltText(ctx context.Context, block *ContentBlock) ([]byte, string, error) {
+	var data []byte
+	if block.SourceType == "url" {
+		// 已有file_id时不会再经过上传时的URL校验，这里同样按访问策略校验后再下载
+		var err error
+		if data, err = fetchRemoteBody(ctx, block.SourcePath, maxAltTextImageBytes); err != nil {
+			return nil, "", err
+		}
+	} else {
+		path, err := checkLocalPath(ctx, block.SourcePath)
+		if err != nil {
+			return nil, "", err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if info.Size() > maxAltTextImageBytes {
+			return nil, "", fmt.Errorf("图片超过 %d MB", maxAltTextImageBytes>>20)
+		}
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, "", err
+		}
+	}
+	if len(data) > maxAltTextImageBytes {
+		return nil, "", fmt.Errorf("图片超过 %d MB", maxAltTextImageBytes>>20)
+	}
+
+	mimeType := mediaType(http.DetectContentType(data))
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, "", fmt.Errorf("不是图片: %s", mimeType)
+	}
+	return data, mimeType, nil
+}
+
+// samplingAltText 通过MCP sampling请求客户端的大模型描述图片
+func samplingAltText(ctx context.Context, data []byte, mimeType string) (string, error) {
+	session := sessionFromContext(ctx)
+	if !session.SupportsSampling() {
+		return "", fmt.Errorf("客户端不支持sampling")
+	}
+
+	params := map[string]interface{}{
+		"messages": []mcp.SamplingMessage{{
+			Role: mcp.RoleUser,
+			Content: mcp.ImageContent{
+				Type:     "image",
+				Data:     base64.StdEncoding.EncodeToString(data),
+				MIMEType: mimeType,
+			},
+		}},
+		"systemPrompt": fmt.Sprintf("请用一句不超过%d个字的中文描述这张图片的内容，作为图片的替代文本，只输出描述本身。", maxAltTextRunes/2),
+		"maxTokens":    maxAltTextRunes,
+	}
+	raw, err := session.Request(ctx, "sampling/createMessage", params)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Content struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("解析sampling结果失败: %w", err)
+	}
+	if result.Content.Type != "text" {
+		return "", fmt.Errorf("sampling未返回文本内容")
+	}
+	return result.Content.Text, nil
+}
+
+// endpointAltText 调用配置的视觉服务描述图片
+func endpointAltText(ctx context.Context, data []byte, mimeType string, block *ContentBlock) (string, error) {
+	endpoint := envString(AltTextURLEnvVar, "")
+	if endpoint == "" {
+		return "", fmt.Errorf("未配置 %s", AltTextURLEnvVar)
+	}
+
+	payload := altTextRequest{
+		Image:    base64.StdEncoding.EncodeToString(data),
+		MIMEType: mimeType,
+	}
+	if block.SourceType == "url" {
+		payload.URL = block.SourcePath
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := envString(AltTextTokenEnvVar, ""); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求视觉服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("读取视觉服务结果失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("视觉服务返回状态码 %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result altTextResponse
+	if err = json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("解析视觉服务结果失败: %w", err)
+	}
+	return result.AltText, nil
+}
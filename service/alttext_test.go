@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestReadImageForAltTextURLPolicy 生成替代文字时下载远程图片同样遵守URL访问策略
+func TestReadImageForAltTextURLPolicy(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	}))
+	defer server.Close()
+	// 测试服务器在回环地址上，允许私有地址后只由禁止列表决定
+	t.Setenv(URLAllowPrivateEnvVar, "true")
+	block := &ContentBlock{Type: "file", FileType: "image", SourceType: "url", SourcePath: server.URL + "/photo.png", FileID: "f1"}
+
+	if _, mimeType, err := readImageForAltText(context.Background(), block); err != nil || mimeType != "image/png" {
+		t.Fatalf("未禁止时应能下载图片，mimeType: %q, error: %v", mimeType, err)
+	}
+
+	t.Setenv(URLDenyEnvVar, "127.0.0.1")
+	hits = 0
+	if _, _, err := readImageForAltText(context.Background(), block); err == nil {
+		t.Error("禁止访问的主机不应下载")
+	}
+	if hits != 0 {
+		t.Errorf("禁止访问的主机仍被请求了 %d 次", hits)
+	}
+}
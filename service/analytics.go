@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// noteCreation 笔记的创建信息：同一笔记的第一条本地记录的时间即创建时间
+type noteCreation struct {
+	NoteID    string
+	CreatedAt time.Time // 本地时区
+}
+
+// 中文星期名称
+var weekdayNames = []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// parseDBTime 解析数据库返回的时间，兼容驱动格式化后的RFC3339和SQLite原始格式
+func parseDBTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, sqliteTimeLayout} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s", value)
+}
+
+// ListNoteCreations 查询租户全部笔记的创建时间，按时间升序
+// 从墨问拉取的记录只有拉取时间，不知道真实的创建时间，不计入
+func ListNoteCreations(tenantID string) ([]noteCreation, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	query := fmt.Sprintf(`SELECT note_id, MIN(created_at) AS first_at FROM %s
+		WHERE tenant_id = ? AND fetched = 0 GROUP BY note_id ORDER BY first_at`, dbTable)
+	rows, err := sqliteDB.Query(query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var creations []noteCreation
+	for rows.Next() {
+		var noteID, createdAt string
+		if err = rows.Scan(&noteID, &createdAt); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		t, err := parseDBTime(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		creations = append(creations, noteCreation{NoteID: noteID, CreatedAt: t.Local()})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return creations, nil
+}
+
+// ListArchive 按月或按年汇总笔记数量
+func ListArchive(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	now := time.Now()
+	year := now.Year()
+	if v, ok := args["year"].(float64); ok {
+		year = int(v)
+	}
+	month := 0
+	if v, ok := args["month"].(float64); ok {
+		month = int(v)
+	}
+	if month < 0 || month > 12 {
+		return mcp.NewToolResultText("❌ 月份必须在1到12之间"), nil
+	}
+
+	creations, err := ListNoteCreations(tenantFromContext(ctx))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	var sb strings.Builder
+	if month == 0 {
+		// 按月汇总全年
+		counts := make([]int, 13)
+		total := 0
+		for _, c := range creations {
+			if c.CreatedAt.Year() == year {
+				counts[c.CreatedAt.Month()]++
+				total++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("📅 %d 年共 %d 篇笔记\n\n", year, total))
+		for m := 1; m <= 12; m++ {
+			sb.WriteString(fmt.Sprintf("%2d月: %d 篇\n", m, counts[m]))
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+
+	// 按天汇总指定月份
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	days := first.AddDate(0, 1, -1).Day()
+	counts := make([]int, days+1)
+	total := 0
+	for _, c := range creations {
+		if c.CreatedAt.Year() == year && int(c.CreatedAt.Month()) == month {
+			counts[c.CreatedAt.Day()]++
+			total++
+		}
+	}
+
+	activeDays := 0
+	var lines strings.Builder
+	for d := 1; d <= days; d++ {
+		if counts[d] == 0 {
+			continue
+		}
+		activeDays++
+		date := first.AddDate(0, 0, d-1)
+		lines.WriteString(fmt.Sprintf("%s（%s）: %d 篇\n", date.Format("2006-01-02"), weekdayNames[date.Weekday()], counts[d]))
+	}
+
+	sb.WriteString(fmt.Sprintf("📅 %d 年 %d 月共 %d 篇笔记，%d 天有记录\n\n", year, month, total, activeDays))
+	sb.WriteString(lines.String())
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 归档汇总工具
+var ListArchiveTool = mcp.NewTool("list_archive",
+	mcp.WithDescription("按日历汇总本地笔记：指定月份时列出当月每天的笔记数量，只指定年份时列出当年每月的笔记数量。例如回答\"我十月哪几天写了笔记\"。按笔记的创建时间（本地时区）统计。"),
+	mcp.WithNumber("year",
+		mcp.Description("年份，默认今年"),
+	),
+	mcp.WithNumber("month",
+		mcp.Description("月份（1-12），不传时按月汇总全年"),
+	),
+)
+
+func listArchiveHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ListArchive(ctx, request)
+}
+
+// heatmapDay 热力图中的一天
+type heatmapDay struct {
+	Date    string `json:"date"`
+	Weekday int    `json:"weekday"` // 0为周日
+	Count   int    `json:"count"`
+	Level   int    `json:"level"` // 0~4，与GitHub贡献图的颜色深浅对应
+}
+
+// activityHeatmap 热力图数据
+type activityHeatmap struct {
+	Start      string       `json:"start"`
+	End        string       `json:"end"`
+	Total      int          `json:"total"`
+	ActiveDays int          `json:"active_days"`
+	MaxCount   int          `json:"max_count"`
+	Days       []heatmapDay `json:"days"`
+}
+
+// heatmapLevel 按当日数量占最大值的比例划分等级
+func heatmapLevel(count, maxCount int) int {
+	if count == 0 || maxCount == 0 {
+		return 0
+	}
+	level := (count*4 + maxCount - 1) / maxCount
+	if level > 4 {
+		level = 4
+	}
+	return level
+}
+
+// ActivityHeatmap 导出每天的笔记数量
+func ActivityHeatmap(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// 默认为截至今天的最近一年，指定年份时为该自然年
+	end := dayOf(time.Now())
+	start := end.AddDate(-1, 0, 1)
+	if v, ok := request.Params.Arguments["year"].(float64); ok {
+		start = time.Date(int(v), 1, 1, 0, 0, 0, 0, time.Local)
+		end = time.Date(int(v), 12, 31, 0, 0, 0, 0, time.Local)
+	}
+
+	creations, err := ListNoteCreations(tenantFromContext(ctx))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	counts := make(map[string]int)
+	for _, c := range creations {
+		day := dayOf(c.CreatedAt)
+		if !day.Before(start) && !day.After(end) {
+			counts[day.Format("2006-01-02")]++
+		}
+	}
+
+	heatmap := activityHeatmap{
+		Start: start.Format("2006-01-02"),
+		End:   end.Format("2006-01-02"),
+	}
+	for _, n := range counts {
+		heatmap.Total += n
+		heatmap.ActiveDays++
+		heatmap.MaxCount = max(heatmap.MaxCount, n)
+	}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		heatmap.Days = append(heatmap.Days, heatmapDay{
+			Date:    date,
+			Weekday: int(day.Weekday()),
+			Count:   counts[date],
+			Level:   heatmapLevel(counts[date], heatmap.MaxCount),
+		})
+	}
+
+	data, err := json.Marshal(heatmap)
+	if err != nil {
+		return errorResult("序列化失败", err), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// 活跃度热力图工具
+var ActivityHeatmapTool = mcp.NewTool("activity_heatmap",
+	mcp.WithDescription("以JSON返回一年中每天创建的笔记数量（类似GitHub贡献图），包含每天的数量和0~4的深浅等级，供客户端绘制活跃度热力图"),
+	mcp.WithNumber("year",
+		mcp.Description("自然年，不传时返回截至今天的最近一年"),
+	),
+)
+
+func activityHeatmapHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ActivityHeatmap(ctx, request)
+}
+
+// histogramBar 用方块字符画出直方图的一行
+func histogramBar(count, maxCount int) string {
+	const width = 20
+	if maxCount == 0 || count == 0 {
+		return ""
+	}
+	n := count * width / maxCount
+	if n == 0 {
+		n = 1
+	}
+	return strings.Repeat("█", n)
+}
+
+// NoteStats 统计本地笔记的写作习惯
+func NoteStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tenantID := tenantFromContext(ctx)
+	creations, err := ListNoteCreations(tenantID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if len(creations) == 0 {
+		return mcp.NewToolResultText("📝 暂无本地笔记记录"), nil
+	}
+	records, err := ListLatestNotes(tenantID, len(creations))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	// 按笔记的最新内容统计字数
+	lengths := make(map[string]int, len(records))
+	var longest NoteRecord
+	totalLength := 0
+	for _, record := range records {
+		n := len([]rune(notePlainText(record.Content)))
+		lengths[record.NoteID] = n
+		totalLength += n
+		if longest.NoteID == "" || n > lengths[longest.NoteID] {
+			longest = record
+		}
+	}
+
+	hours := make([]int, 24)
+	weekdays := make([]int, 7)
+	type monthStat struct {
+		notes  int
+		length int
+	}
+	months := make(map[string]*monthStat)
+	var monthKeys []string
+	for _, c := range creations {
+		hours[c.CreatedAt.Hour()]++
+		weekdays[c.CreatedAt.Weekday()]++
+		key := c.CreatedAt.Format("2006-01")
+		if months[key] == nil {
+			months[key] = &monthStat{}
+			monthKeys = append(monthKeys, key)
+		}
+		months[key].notes++
+		months[key].length += lengths[c.NoteID]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📊 笔记统计\n\n")
+	sb.WriteString(fmt.Sprintf("笔记总数: %d 篇\n", len(creations)))
+	sb.WriteString(fmt.Sprintf("总字数: %d，平均每篇 %d 字\n", totalLength, totalLength/len(creations)))
+	sb.WriteString(fmt.Sprintf("最长的笔记: %s「%s」%d 字\n", longest.NoteID, noteTitle(longest.Content), lengths[longest.NoteID]))
+	sb.WriteString(fmt.Sprintf("统计区间: %s ~ %s\n", creations[0].CreatedAt.Format("2006-01-02"), creations[len(creations)-1].CreatedAt.Format("2006-01-02")))
+
+	maxHour := 0
+	for _, n := range hours {
+		maxHour = max(maxHour, n)
+	}
+	sb.WriteString("\n按时段（创建时间）:\n")
+	for h, n := range hours {
+		if n > 0 {
+			sb.WriteString(fmt.Sprintf("%02d:00 %-20s %d\n", h, histogramBar(n, maxHour), n))
+		}
+	}
+
+	maxWeekday := 0
+	for _, n := range weekdays {
+		maxWeekday = max(maxWeekday, n)
+	}
+	sb.WriteString("\n按星期:\n")
+	// 从周一开始排列
+	for i := 1; i <= 7; i++ {
+		d := i % 7
+		sb.WriteString(fmt.Sprintf("%s %-20s %d\n", weekdayNames[d], histogramBar(weekdays[d], maxWeekday), weekdays[d]))
+	}
+
+	sb.WriteString("\n每月趋势:\n")
+	for _, key := range monthKeys {
+		m := months[key]
+		sb.WriteString(fmt.Sprintf("%s: %d 篇，平均 %d 字\n", key, m.notes, m.length/m.notes))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 笔记统计工具
+var NoteStatsTool = mcp.NewTool("note_stats",
+	mcp.WithDescription("统计本地笔记的写作习惯：笔记总数和字数、最长的笔记、按时段和星期的创建分布，以及每月的篇数和平均字数趋势"),
+)
+
+func noteStatsHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return NoteStats(ctx, request)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,7 +13,11 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
 )
 
 // API接口路径常量
@@ -23,6 +28,8 @@ const (
 	APIEditNote = "/api/open/api/v1/note/edit"
 	// 设置笔记接口
 	APISetNote = "/api/open/api/v1/note/set"
+	// 获取笔记内容接口
+	APIGetNote = "/api/open/api/v1/note/get"
 	// 获取上传授权信息接口
 	APIUploadPrepare = "/api/open/api/v1/upload/prepare"
 	// 上传文件接口
@@ -35,13 +42,45 @@ const (
 	BaseURL = "https://open.mowen.cn"
 	// 环境变量名称
 	APIKeyEnvVar = "MOWEN_API_KEY"
+	// 可选的墨问API地址，用于预发环境等，默认为BaseURL
+	BaseURLEnvVar = "MOWEN_BASE_URL"
+	// 可选的客户端标识，设置后通过 X-Client-Id 请求头发送，便于排查问题时定位调用方
+	ClientIDEnvVar = "MOWEN_CLIENT_ID"
 )
 
+// Version 服务版本号，用于MCP握手和User-Agent
+const Version = "1.0.0"
+
+// userAgent 所有API请求使用的User-Agent
+var userAgent = fmt.Sprintf("mcp-mowen/%s (go/%s)", Version, strings.TrimPrefix(runtime.Version(), "go"))
+
+// logContext 携带请求ID的日志上下文
+func (c *MowenClient) logContext() context.Context {
+	return context.WithValue(context.Background(), requestIDContextKey{}, c.RequestID)
+}
+
+// setClientHeaders 设置标识本集成和本次调用的请求头
+func (c *MowenClient) setClientHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", userAgent)
+	if clientID := envString(ClientIDEnvVar, ""); clientID != "" {
+		req.Header.Set("X-Client-Id", clientID)
+	}
+	if c.RequestID != "" {
+		req.Header.Set("X-Request-Id", c.RequestID)
+	}
+}
+
 // MowenClient 墨问API客户端
 type MowenClient struct {
-	APIKey  string
-	BaseURL string
-	Client  *http.Client
+	APIKey    string
+	BaseURL   string
+	Client    *http.Client
+	RequestID string // 发起调用的工具请求ID，通过 X-Request-Id 请求头发送
+	TenantID  string // 发起调用的租户，用于统计API用量
+
+	UploadRateLimit int64 // 上传文件的限速（字节/秒），0表示不限速
+
+	recorder *apiRecorder // 调试模式下记录API交互，为nil表示未开启
 }
 
 // NewMowenClient 创建新的墨问客户端
@@ -61,13 +100,47 @@ func NewMowenClient() (client *MowenClient, err error) {
 		return nil, fmt.Errorf("加载API密钥失败: %w", err)
 	}
 
-	return &MowenClient{
+	return newMowenClientWithKey(apiKey), nil
+}
+
+// NewMowenClientFromContext 根据请求上下文创建墨问客户端
+// HTTP多租户模式下只使用会话绑定的API密钥；只有stdio模式的默认会话回退到环境变量，
+// 否则任何能连上端口的客户端都能以运营者的身份读写笔记
+func NewMowenClientFromContext(ctx context.Context) (*MowenClient, error) {
+	session := sessionFromContext(ctx)
+	client := newMowenClientWithKey(session.APIKey())
+	if client.APIKey == "" {
+		if session != defaultSession {
+			return nil, withErrorCode(ErrAuthFailed, fmt.Errorf("会话 %s 未绑定墨问API密钥", session.ID))
+		}
+		var err error
+		if client, err = NewMowenClient(); err != nil {
+			return nil, err
+		}
+	}
+	client.RequestID = requestIDFromContext(ctx)
+	client.TenantID = tenantFromContext(ctx)
+	client.recorder = apiRecorderFromContext(ctx)
+	client.UploadRateLimit = uploadRateLimit(ctx)
+	return client, nil
+}
+
+// newMowenClientWithKey 使用指定的API密钥创建客户端
+func newMowenClientWithKey(apiKey string) *MowenClient {
+	client := &MowenClient{
 		APIKey:  apiKey,
-		BaseURL: BaseURL,
+		BaseURL: envString(BaseURLEnvVar, BaseURL),
 		Client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-	}, nil
+	}
+	// 模拟模式下回放或录制响应，见mock.go
+	if transport := newMockTransport(); transport != nil {
+		client.Client.Transport = transport
+	}
+	// 测试用的故障注入，见fault.go
+	client.Client.Transport = newFaultTransport(client.Client.Transport)
+	return client
 }
 
 // loadAPIKeyFromEnv 从环境变量加载API密钥
@@ -82,8 +155,11 @@ func loadAPIKeyFromEnv() (apiKey string, err error) {
 
 	// 从环境变量获取API密钥
 	apiKey = os.Getenv(APIKeyEnvVar)
+	if apiKey == "" && mockReplaying() {
+		return mockAPIKey, nil
+	}
 	if apiKey == "" {
-		return "", fmt.Errorf("环境变量 %s 未设置或为空", APIKeyEnvVar)
+		return "", withErrorCode(ErrAuthFailed, fmt.Errorf("环境变量 %s 未设置或为空", APIKeyEnvVar))
 	}
 
 	return apiKey, nil
@@ -94,6 +170,9 @@ type APIResponse struct {
 	StatusCode int                    `json:"status_code"`
 	Body       map[string]interface{} `json:"body"`
 	RawBody    string                 `json:"raw_body"`
+	Header     http.Header            `json:"-"`
+
+	SchemaIssues []string `json:"-"` // 成功响应与预期格式不符的地方，见apiResponseSchemas
 }
 
 // PostRequest 发送POST请求到指定路径
@@ -104,6 +183,32 @@ type APIResponse struct {
 // - APIResponse: 包含状态码和响应体的结构
 // - error: 错误信息
 func (c *MowenClient) PostRequest(path string, payload interface{}) (*APIResponse, error) {
+	return c.PostRequestWithHeader(path, payload, nil)
+}
+
+// PostRequestWithHeader 发送POST请求并附带额外的请求头，例如条件请求的If-None-Match
+func (c *MowenClient) PostRequestWithHeader(path string, payload interface{}, header http.Header) (*APIResponse, error) {
+	resp, err := c.postRequest(path, payload, header)
+	if err == nil {
+		resp.SchemaIssues = checkResponseSchema(c.logContext(), path, resp)
+	}
+	c.recordUsage(path, resp, err)
+	if c.recorder != nil {
+		// 调试模式下记录实际发送的请求体和原始响应
+		requestBody, _ := json.Marshal(payload)
+		exchange := apiExchange{Path: path, Request: string(requestBody)}
+		if err != nil {
+			exchange.Err = err.Error()
+		} else {
+			exchange.StatusCode, exchange.Response = resp.StatusCode, resp.RawBody
+		}
+		c.recorder.record(exchange)
+	}
+	return resp, err
+}
+
+// postRequest 序列化请求体、发送请求并解析响应
+func (c *MowenClient) postRequest(path string, payload interface{}, header http.Header) (*APIResponse, error) {
 	// 构建完整的请求URL
 	apiURL, err := url.JoinPath(c.BaseURL, path)
 	if err != nil {
@@ -117,26 +222,35 @@ func (c *MowenClient) PostRequest(path string, payload interface{}) (*APIRespons
 		if err != nil {
 			return nil, fmt.Errorf("序列化请求体失败: %w", err)
 		}
-		// 打印请求体用于调试
-		fmt.Printf(string(jsonData))
+		// 打印请求体用于调试，写到日志而不是标准输出，避免破坏stdio协议
+		logger.CtxDebugf(c.logContext(), "请求体: %s", jsonData)
 	}
 
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	// 较大的请求体按配置压缩，服务端不支持时回退为不压缩重发
+	compress := shouldGzipRequest(len(jsonData))
+	resp, err := c.doPost(apiURL, jsonData, compress, header)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		return nil, err
 	}
-
-	// 设置请求头
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.APIKey))
-	req.Header.Set("Content-Type", "application/json")
-
-	// 发送请求
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("发送请求失败: %w", err)
+	if compress && isGzipRejected(resp.StatusCode) {
+		resp.Body.Close()
+		rejectedStatus := resp.StatusCode
+		if resp, err = c.doPost(apiURL, jsonData, false, header); err != nil {
+			return nil, err
+		}
+		// 不压缩重发后结果不同，说明服务端不支持压缩
+		if resp.StatusCode != rejectedStatus {
+			gzipRejected.Store(true)
+			logger.CtxWarnf(c.logContext(), "墨问API不接受压缩的请求体（状态码 %d），后续请求不再压缩", rejectedStatus)
+		}
 	}
 	defer resp.Body.Close()
+	noteThrottled(c.RequestID, resp)
+
+	logger.CtxDebugf(c.logContext(), "POST %s 状态码: %d", path, resp.StatusCode)
+	if resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return nil, bodyTooLargeError(len(jsonData))
+	}
 
 	// 读取响应体
 	respBody, err := io.ReadAll(resp.Body)
@@ -148,6 +262,7 @@ func (c *MowenClient) PostRequest(path string, payload interface{}) (*APIRespons
 	apiResponse := &APIResponse{
 		StatusCode: resp.StatusCode,
 		RawBody:    string(respBody),
+		Header:     resp.Header,
 	}
 
 	// 尝试解析JSON响应体
@@ -161,6 +276,49 @@ func (c *MowenClient) PostRequest(path string, payload interface{}) (*APIRespons
 	return apiResponse, nil
 }
 
+// doPost 发送JSON请求，compress为true时使用gzip压缩请求体，header为额外的请求头
+func (c *MowenClient) doPost(apiURL string, jsonData []byte, compress bool, header http.Header) (*http.Response, error) {
+	body := jsonData
+	if compress {
+		compressed, err := gzipBytes(jsonData)
+		if err != nil {
+			return nil, err
+		}
+		body = compressed
+	}
+
+	// 创建HTTP请求
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.APIKey))
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	c.setClientHeaders(req)
+
+	// 发送请求
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, networkError(fmt.Errorf("发送请求失败: %w", err))
+	}
+	return resp, nil
+}
+
+// writerFunc 把函数适配为io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
 // UploadPrepareRequest 获取上传授权信息请求结构
 type UploadPrepareRequest struct {
 	FileType int    `json:"fileType"`           // 文件类型：1-图片 2-音频 3-PDF
@@ -196,7 +354,11 @@ func (c *MowenClient) UploadPrepare(payload *UploadPrepareRequest) (*UploadPrepa
 	}
 
 	if apiResponse.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("获取上传授权信息API请求失败，状态码: %d, 响应: %s", apiResponse.StatusCode, apiResponse.RawBody)
+		return nil, apiStatusError(apiResponse.StatusCode, "获取上传授权信息API请求失败，状态码: %d, 响应: %s", apiResponse.StatusCode, apiResponse.RawBody)
+	}
+
+	if len(apiResponse.SchemaIssues) > 0 {
+		return nil, schemaDriftError(APIUploadPrepare, apiResponse.SchemaIssues)
 	}
 
 	var uploadPrepareResponse UploadPrepareResponse
@@ -209,6 +371,66 @@ func (c *MowenClient) UploadPrepare(payload *UploadPrepareRequest) (*UploadPrepa
 	return &uploadPrepareResponse, nil
 }
 
+// GetNote 从墨问获取笔记的完整内容
+// 参数:
+// - noteID: 笔记ID
+// 返回:
+// - *MowenDocument: 墨问API标准格式的笔记内容
+// - error: 错误信息
+func (c *MowenClient) GetNote(noteID string) (*MowenDocument, error) {
+	doc, _, _, err := c.GetNoteIfChanged(noteID, noteValidators{})
+	return doc, err
+}
+
+// noteValidators 笔记内容的缓存校验信息，来自墨问响应的ETag和Last-Modified
+type noteValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// GetNoteIfChanged 条件获取笔记：带上次保存的校验信息，墨问返回304时modified为false且不返回内容
+// 返回最新的校验信息，服务端不提供时为空
+func (c *MowenClient) GetNoteIfChanged(noteID string, validators noteValidators) (doc *MowenDocument, latest noteValidators, modified bool, err error) {
+	header := http.Header{}
+	if validators.ETag != "" {
+		header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		header.Set("If-Modified-Since", validators.LastModified)
+	}
+	apiResponse, err := c.PostRequestWithHeader(APIGetNote, map[string]string{"noteId": noteID}, header)
+	if err != nil {
+		return nil, validators, false, fmt.Errorf("获取笔记失败: %w", err)
+	}
+
+	latest = noteValidators{ETag: apiResponse.Header.Get("ETag"), LastModified: apiResponse.Header.Get("Last-Modified")}
+	if apiResponse.StatusCode == http.StatusNotModified {
+		if latest == (noteValidators{}) {
+			latest = validators
+		}
+		return nil, latest, false, nil
+	}
+
+	if apiResponse.StatusCode != http.StatusOK {
+		return nil, validators, false, apiStatusError(apiResponse.StatusCode, "获取笔记API请求失败，状态码: %d, 响应: %s", apiResponse.StatusCode, apiResponse.RawBody)
+	}
+
+	if len(apiResponse.SchemaIssues) > 0 {
+		return nil, validators, false, schemaDriftError(APIGetNote, apiResponse.SchemaIssues)
+	}
+
+	// 笔记内容是note.body中的文档对象，格式与创建笔记时提交的相同
+	var response struct {
+		Note struct {
+			Body MowenDocument `json:"body"`
+		} `json:"note"`
+	}
+	if err := json.Unmarshal([]byte(apiResponse.RawBody), &response); err != nil {
+		return nil, validators, false, fmt.Errorf("解析笔记响应失败: %w. 原始响应: %s", err, apiResponse.RawBody)
+	}
+	return &response.Note.Body, latest, true, nil
+}
+
 // UploadFile 上传文件到OSS
 // 参数:
 // - form: 从UploadPrepare获取的表单数据
@@ -217,15 +439,35 @@ func (c *MowenClient) UploadPrepare(payload *UploadPrepareRequest) (*UploadPrepa
 // - *APIResponse: 上传响应
 // - error: 错误信息
 func (c *MowenClient) UploadFile(form UploadPrepareResponseForm, filePath string) (*APIResponse, error) {
+	resp, err := c.uploadFile(form, filePath)
+	c.recordUsage(apiUploadFile, resp, err)
+	return resp, err
+}
+
+// uploadFile 以multipart表单流式上传文件并解析响应
+func (c *MowenClient) uploadFile(form UploadPrepareResponseForm, filePath string) (*APIResponse, error) {
 	// 获取上传URL（endpoint字段）
 	uploadURL, exists := form["endpoint"]
 	if !exists {
 		return nil, fmt.Errorf("form中缺少endpoint字段")
 	}
 
-	// 创建multipart表单
-	payload := &bytes.Buffer{}
-	writer := multipart.NewWriter(payload)
+	// 打开文件
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件信息失败: %w", err)
+	}
+
+	// 创建multipart表单，文件内容不读入内存，而是在发送时从文件流式读取
+	// head为文件之前的表单字段和文件字段头，tail为结束边界
+	var head, tail bytes.Buffer
+	target := &head
+	writer := multipart.NewWriter(writerFunc(func(p []byte) (int, error) { return target.Write(p) }))
 
 	// 添加form中的所有字段（除了endpoint）
 	for key, value := range form {
@@ -234,13 +476,6 @@ func (c *MowenClient) UploadFile(form UploadPrepareResponseForm, filePath string
 		}
 	}
 
-	// 打开文件
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("打开文件失败: %w", err)
-	}
-	defer file.Close()
-
 	mimeType := mime.TypeByExtension(filepath.Ext(filePath))
 	if mimeType == "" {
 		mimeType = "application/octet-stream"
@@ -251,38 +486,39 @@ func (c *MowenClient) UploadFile(form UploadPrepareResponseForm, filePath string
 	h.Set("Content-Type", mimeType)
 
 	// 创建文件表单字段
-	part, err := writer.CreatePart(h)
-	if err != nil {
+	if _, err = writer.CreatePart(h); err != nil {
 		return nil, fmt.Errorf("创建文件表单字段失败: %w", err)
 	}
 
-	// 复制文件内容到表单
-	_, err = io.Copy(part, file)
-	if err != nil {
-		return nil, fmt.Errorf("复制文件内容失败: %w", err)
-	}
-
-	// 关闭writer
-	err = writer.Close()
-	if err != nil {
+	// 关闭writer，写入结束边界
+	target = &tail
+	if err = writer.Close(); err != nil {
 		return nil, fmt.Errorf("关闭multipart writer失败: %w", err)
 	}
 
+	// 按配置限制上传速率
+	body := io.MultiReader(&head, newThrottledReader(file, c.UploadRateLimit), &tail)
+
 	// 创建HTTP请求
-	req, err := http.NewRequest("POST", uploadURL, payload)
+	req, err := http.NewRequest("POST", uploadURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("创建上传请求失败: %w", err)
 	}
+	req.ContentLength = int64(head.Len()) + info.Size() + int64(tail.Len())
 
 	// 设置Content-Type
 	req.Header.Set("Content-Type", writer.FormDataContentType())
+	c.setClientHeaders(req)
 
-	// 发送请求
-	resp, err := c.Client.Do(req)
+	// 发送请求，限速时按文件大小延长超时时间
+	uploadClient := *c.Client
+	uploadClient.Timeout = uploadTimeout(c.Client.Timeout, info.Size(), c.UploadRateLimit)
+	resp, err := uploadClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("发送上传请求失败: %w", err)
 	}
 	defer resp.Body.Close()
+	noteThrottled(c.RequestID, resp)
 
 	// 读取响应体
 	respBody, err := io.ReadAll(resp.Body)
@@ -303,6 +539,7 @@ func (c *MowenClient) UploadFile(form UploadPrepareResponseForm, filePath string
 			apiResponse.Body = jsonBody
 		}
 	}
+	apiResponse.SchemaIssues = checkResponseSchema(c.logContext(), apiUploadFile, apiResponse)
 
 	return apiResponse, nil
 }
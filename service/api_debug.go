@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// debugArgKey 写入类工具的调试参数，为true时在结果中附带API原始交互
+const debugArgKey = "debug"
+
+// withDebugParam 为调用墨问API的工具补充debug参数，注册时通过addTool的选项添加
+func withDebugParam(tool mcp.Tool) mcp.Tool {
+	return withToolParam(tool, debugArgKey, "boolean",
+		"为true时在结果中附带实际发送的请求体和API原始响应（已脱敏），用于排查API拒绝请求的原因")
+}
+
+// 调试信息中每个请求体或响应的最大长度
+const maxDebugBodyRunes = 4000
+
+// apiExchange 一次API请求和响应
+type apiExchange struct {
+	Path       string
+	Request    string
+	StatusCode int
+	Response   string
+	Err        string
+}
+
+// apiRecorder 记录一次工具调用中发出的API请求，批量工具会并发写入
+type apiRecorder struct {
+	mu        sync.Mutex
+	exchanges []apiExchange
+}
+
+// apiRecorderContextKey 上下文中保存API记录器的键
+type apiRecorderContextKey struct{}
+
+func (r *apiRecorder) record(exchange apiExchange) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, exchange)
+}
+
+// apiRecorderFromContext 从上下文中获取API记录器，未开启调试时返回nil
+func apiRecorderFromContext(ctx context.Context) *apiRecorder {
+	recorder, _ := ctx.Value(apiRecorderContextKey{}).(*apiRecorder)
+	return recorder
+}
+
+// withAPIDebug 开启调试时把本次调用的API交互追加到工具结果中，密钥和敏感信息已脱敏
+func withAPIDebug(ctx context.Context, result *mcp.CallToolResult) *mcp.CallToolResult {
+	recorder := apiRecorderFromContext(ctx)
+	if recorder == nil || result == nil {
+		return result
+	}
+
+	var sb strings.Builder
+	recorder.mu.Lock()
+	if len(recorder.exchanges) == 0 {
+		sb.WriteString("\n\n📊 API调试信息: 本次调用没有发出API请求")
+	} else {
+		sb.WriteString(fmt.Sprintf("\n\n📊 API调试信息（%d 次请求）:", len(recorder.exchanges)))
+	}
+	for i, e := range recorder.exchanges {
+		sb.WriteString(fmt.Sprintf("\n\n[%d] POST %s\n请求体: %s\n", i+1, e.Path, truncateRunes(e.Request, maxDebugBodyRunes)))
+		if e.Err != "" {
+			sb.WriteString(fmt.Sprintf("错误: %s", e.Err))
+		} else {
+			sb.WriteString(fmt.Sprintf("状态码: %d\n响应: %s", e.StatusCode, truncateRunes(e.Response, maxDebugBodyRunes)))
+		}
+	}
+	recorder.mu.Unlock()
+
+	result.Content = append(result.Content, mcp.TextContent{Type: "text", Text: redactText(sb.String())})
+	return result
+}
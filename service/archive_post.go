@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// X/Twitter帖子的oEmbed接口，帖子页面需要登录才能抓取
+var twitterOEmbedURL = "https://publish.twitter.com/oembed"
+
+// 抓取帖子页面的大小上限
+const maxPostPageSize = 5 << 20
+
+// 单个帖子最多附加的图片数
+const maxPostImages = 9
+
+var (
+	metaTagPattern  = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	metaAttrPattern = regexp.MustCompile(`(?is)\b(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+	oembedPPattern  = regexp.MustCompile(`(?is)<p\b[^>]*>(.*?)</p>`)
+)
+
+// socialPost 要存档的帖子
+type socialPost struct {
+	Author    string
+	Text      string
+	Published string // 显示用的发布时间
+	URL       string
+	Images    []string
+}
+
+// parseMetaTags 提取网页中的meta标签，键为property或name（小写），同名标签保留全部内容
+func parseMetaTags(page string) map[string][]string {
+	tags := make(map[string][]string)
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		var key, content string
+		for _, m := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			value := html.UnescapeString(strings.Trim(m[2], `"'`))
+			if strings.EqualFold(m[1], "content") {
+				content = value
+			} else {
+				key = strings.ToLower(value)
+			}
+		}
+		if key != "" && content != "" {
+			tags[key] = append(tags[key], content)
+		}
+	}
+	return tags
+}
+
+// isTwitterURL 判断是否为X/Twitter的帖子链接
+func isTwitterURL(u *url.URL) bool {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	host = strings.TrimPrefix(host, "mobile.")
+	return host == "twitter.com" || host == "x.com"
+}
+
+// fetchTwitterPost 通过oEmbed获取X/Twitter帖子的作者、正文和日期，oEmbed不返回图片
+func fetchTwitterPost(ctx context.Context, postURL string) (*socialPost, error) {
+	data, err := fetchRemoteBody(ctx, twitterOEmbedURL+"?omit_script=true&url="+url.QueryEscape(postURL), maxPostPageSize)
+	if err != nil {
+		return nil, err
+	}
+	var embed struct {
+		AuthorName string `json:"author_name"`
+		HTML       string `json:"html"`
+	}
+	if err := json.Unmarshal(data, &embed); err != nil {
+		return nil, fmt.Errorf("解析oEmbed响应失败: %w", err)
+	}
+
+	post := &socialPost{Author: embed.AuthorName, URL: postURL}
+	if m := oembedPPattern.FindStringSubmatch(embed.HTML); m != nil {
+		post.Text = cleanHTMLText(m[1])
+	}
+	// 嵌入代码的最后一个链接文字是发布日期
+	if i := strings.LastIndex(embed.HTML, "</a>"); i >= 0 {
+		if j := strings.LastIndex(embed.HTML[:i], ">"); j >= 0 {
+			post.Published = cleanHTMLText(embed.HTML[j+1 : i])
+		}
+	}
+	if post.Text == "" {
+		return nil, fmt.Errorf("oEmbed没有返回帖子内容")
+	}
+	return post, nil
+}
+
+// fetchPagePost 从网页的Open Graph标签中提取帖子内容
+func fetchPagePost(ctx context.Context, postURL string) (*socialPost, error) {
+	page, err := fetchRemoteBody(ctx, postURL, maxPostPageSize)
+	if err != nil {
+		return nil, err
+	}
+	meta := parseMetaTags(string(page))
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if values := meta[key]; len(values) > 0 {
+				return strings.TrimSpace(values[0])
+			}
+		}
+		return ""
+	}
+
+	post := &socialPost{
+		Author:    first("author", "article:author", "twitter:creator", "og:site_name"),
+		Text:      first("og:description", "twitter:description", "description"),
+		Published: first("article:published_time", "og:updated_time"),
+		URL:       postURL,
+	}
+	if post.Text == "" {
+		post.Text = first("og:title", "twitter:title")
+	}
+	if post.Text == "" {
+		return nil, fmt.Errorf("网页中没有找到帖子内容，请直接传入text")
+	}
+	base, _ := url.Parse(postURL)
+	for _, image := range append(meta["og:image"], meta["twitter:image"]...) {
+		if ref, err := url.Parse(strings.TrimSpace(image)); err == nil && base != nil {
+			image = base.ResolveReference(ref).String()
+		}
+		if !slices.Contains(post.Images, image) {
+			post.Images = append(post.Images, image)
+		}
+	}
+	return post, nil
+}
+
+// formatPostTime 把RFC3339等格式的发布时间显示为本地时间，无法解析时原样返回
+func formatPostTime(value string) string {
+	value = strings.TrimSpace(value)
+	for _, layout := range append([]string{time.RFC3339Nano}, remindTimeLayouts...) {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.Local().Format("2006-01-02 15:04")
+		}
+	}
+	return value
+}
+
+// buildPostBlocks 生成存档笔记：引用块保存正文，下方是作者、时间和来源链接
+func buildPostBlocks(post *socialPost, withImages bool) []ContentBlock {
+	title := "💬 帖子存档"
+	if post.Author != "" {
+		title = "💬 " + post.Author + " 的帖子"
+	}
+	blocks := []ContentBlock{{Texts: []TextNode{{Text: title, Bold: true}}}}
+	for _, line := range splitLines(post.Text) {
+		blocks = append(blocks, ContentBlock{Type: "quote", Texts: []TextNode{{Text: line}}})
+	}
+
+	var attribution []string
+	if post.Author != "" {
+		attribution = append(attribution, post.Author)
+	}
+	if post.Published != "" {
+		attribution = append(attribution, formatPostTime(post.Published))
+	}
+	if len(attribution) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "—— " + strings.Join(attribution, " · ")}}})
+	}
+	if withImages {
+		for i, image := range post.Images {
+			if i >= maxPostImages {
+				break
+			}
+			blocks = append(blocks, ContentBlock{Type: "file", FileType: "image", SourceType: "url", SourcePath: image})
+		}
+	}
+	if post.URL != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "来源: "}, {Text: post.URL, Link: post.URL}}})
+	}
+	blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "存档时间: " + time.Now().Format("2006-01-02 15:04")}}})
+	return blocks
+}
+
+// ArchivePost 存档社交媒体帖子
+func ArchivePost(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	postURL, _ := args["url"].(string)
+	postURL = strings.TrimSpace(postURL)
+	text, _ := args["text"].(string)
+	text = strings.TrimSpace(text)
+	if postURL == "" && text == "" {
+		return mcp.NewToolResultText("❌ url和text至少需要传入一个"), nil
+	}
+	author, _ := args["author"].(string)
+	published, _ := args["timestamp"].(string)
+	withImages, _ := args["with_images"].(bool)
+	var imageURLs []string
+	if imagesStr, _ := args["image_urls"].(string); imagesStr != "" {
+		if err := json.Unmarshal([]byte(imagesStr), &imageURLs); err != nil {
+			return errorResult("image_urls格式错误，应为JSON字符串数组", err), nil
+		}
+		withImages = true
+	}
+	var tags []string
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+
+	// 传入text时不抓取网页，url只作为来源链接
+	post := &socialPost{Text: text, URL: postURL}
+	if text == "" {
+		u, err := url.Parse(postURL)
+		if err != nil {
+			return errorResult("无效的URL", err), nil
+		}
+		if isTwitterURL(u) {
+			post, err = fetchTwitterPost(ctx, postURL)
+		} else {
+			post, err = fetchPagePost(ctx, postURL)
+		}
+		if err != nil {
+			return errorResult("获取帖子内容失败", err), nil
+		}
+	}
+	if author = strings.TrimSpace(author); author != "" {
+		post.Author = author
+	}
+	if published = strings.TrimSpace(published); published != "" {
+		post.Published = published
+	}
+	if len(imageURLs) > 0 {
+		post.Images = imageURLs
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	noteID, err := createNoteFromBlocks(ctx, client, buildPostBlocks(post, withImages), &Settings{Tags: tags})
+	if err != nil {
+		return errorResult("创建存档笔记失败", err), nil
+	}
+	if noteID == "" {
+		noteID = "未知ID"
+	} else {
+		sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	}
+
+	resultText := fmt.Sprintf("✅ 帖子已存档！\n\n笔记ID: %s", noteID)
+	if post.Author != "" {
+		resultText += "\n作者: " + post.Author
+	}
+	if withImages && len(post.Images) > 0 {
+		resultText += fmt.Sprintf("\n图片: %d 张", min(len(post.Images), maxPostImages))
+	} else if len(post.Images) > 0 {
+		resultText += fmt.Sprintf("\n帖子中有 %d 张图片，传入with_images=true可以一并保存", len(post.Images))
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 存档帖子工具
+var ArchivePostTool = mcp.NewTool("archive_post",
+	mcp.WithDescription("存档社交媒体帖子：传入帖子链接时自动获取正文和作者（X/Twitter通过oEmbed，其他网站读取Open Graph信息），也可以直接传入正文、作者和时间。正文保存为引用块，附上作者、时间和来源链接，可选保存帖子中的图片。"),
+	mcp.WithString("url",
+		mcp.Description("帖子链接，与text至少传一个"),
+	),
+	mcp.WithString("text",
+		mcp.Description("帖子正文，传入后不再抓取网页"),
+	),
+	mcp.WithString("author",
+		mcp.Description("作者，覆盖抓取到的作者"),
+	),
+	mcp.WithString("timestamp",
+		mcp.Description("发布时间，如 2024-05-01 12:30 或RFC3339"),
+	),
+	mcp.WithBoolean("with_images",
+		mcp.Description("是否保存帖子中的图片，默认false"),
+	),
+	mcp.WithString("image_urls",
+		mcp.Description("要保存的图片链接，JSON字符串数组，传入后覆盖抓取到的图片"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组"),
+	),
+)
+
+func archivePostHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ArchivePost(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
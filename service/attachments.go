@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 附件下载大小上限
+const maxAttachmentSize = 50 << 20
+
+// blobEmbeddedResource 携带二进制内容的嵌入资源
+// mcp.EmbeddedResource 只支持基础的ResourceContents，这里单独定义
+type blobEmbeddedResource struct {
+	Type     string                   `json:"type"`
+	Resource mcp.BlobResourceContents `json:"resource"`
+}
+
+// noteAttachments 从本地记录的内容块中提取文件附件
+func noteAttachments(content string) ([]ContentBlock, error) {
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(content), &blocks); err != nil {
+		return nil, fmt.Errorf("解析笔记内容失败: %w", err)
+	}
+
+	attachments := make([]ContentBlock, 0)
+	for _, block := range blocks {
+		if block.Type == "file" {
+			attachments = append(attachments, block)
+		}
+	}
+	return attachments, nil
+}
+
+// readAttachment 读取附件的原始内容
+func readAttachment(ctx context.Context, block ContentBlock) ([]byte, error) {
+	if block.SourceType == "url" {
+		if err := checkRemoteURL(block.SourcePath); err != nil {
+			return nil, err
+		}
+		resp, err := newSafeHTTPClient(60 * time.Second).Get(block.SourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("下载附件失败: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("下载附件失败，状态码: %d", resp.StatusCode)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("读取附件内容失败: %w", err)
+		}
+		if len(data) > maxAttachmentSize {
+			return nil, fmt.Errorf("附件超过大小上限 %d MB", maxAttachmentSize>>20)
+		}
+		return data, nil
+	}
+
+	filePath, err := checkLocalPath(ctx, block.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取附件失败: %w", err)
+	}
+	if info.Size() > maxAttachmentSize {
+		return nil, fmt.Errorf("附件超过大小上限 %d MB", maxAttachmentSize>>20)
+	}
+	return os.ReadFile(filePath)
+}
+
+// attachmentFileName 推断附件的文件名
+func attachmentFileName(block ContentBlock) string {
+	if block.SourceType == "url" {
+		if u, err := url.Parse(block.SourcePath); err == nil {
+			if name := path.Base(u.Path); name != "" && name != "/" && name != "." {
+				return name
+			}
+		}
+		return "attachment"
+	}
+	return localFileBase(block.SourcePath)
+}
+
+// DownloadAttachment 下载笔记中的附件
+func DownloadAttachment(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+
+	index := 1
+	if v, ok := args["index"].(float64); ok {
+		index = int(v)
+	}
+
+	record, err := GetNoteCached(tenantFromContext(ctx), noteID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	attachments, err := noteAttachments(record.Content)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if len(attachments) == 0 {
+		return mcp.NewToolResultText("📝 该笔记没有附件"), nil
+	}
+	if index < 1 || index > len(attachments) {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 附件序号超出范围，该笔记共有 %d 个附件", len(attachments))), nil
+	}
+
+	block := attachments[index-1]
+	data, err := readAttachment(ctx, block)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	fileName := attachmentFileName(block)
+	mimeType := mime.TypeByExtension(filepath.Ext(fileName))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	// 指定了目标路径时写入本地文件
+	if destPath, _ := args["dest_path"].(string); destPath != "" {
+		destPath, err = checkWritePath(ctx, destPath)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		if err = os.WriteFile(destPath, data, 0o644); err != nil {
+			return errorResult("写入文件失败", err), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 附件已下载！\n\n笔记ID: %s\n文件类型: %s\n保存路径: %s\n大小: %d 字节",
+			noteID, block.FileType, destPath, len(data))), nil
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	text := fmt.Sprintf("✅ 附件内容已返回\n\n笔记ID: %s\n文件名: %s\n文件类型: %s\n大小: %d 字节",
+		noteID, fileName, block.FileType, len(data))
+	if strings.HasPrefix(mimeType, "image/") {
+		return mcp.NewToolResultImage(text, encoded, mimeType), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []interface{}{
+			mcp.NewTextContent(text),
+			blobEmbeddedResource{
+				Type: "resource",
+				Resource: mcp.BlobResourceContents{
+					ResourceContents: mcp.ResourceContents{
+						URI:      fmt.Sprintf("mowen://notes/%s/attachments/%d", noteID, index),
+						MIMEType: mimeType,
+					},
+					Blob: encoded,
+				},
+			},
+		},
+	}, nil
+}
+
+// 下载附件工具
+var DownloadAttachmentTool = mcp.NewTool("download_attachment",
+	mcp.WithDescription("下载笔记中的图片、音频或PDF附件。指定dest_path时保存到本地（需位于允许访问的目录中），否则以base64内容返回。附件信息来自本地保存的笔记记录。"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记（见set_current_note）"),
+	),
+	mcp.WithNumber("index",
+		mcp.Description("附件序号，从1开始，按笔记中出现的顺序计数，默认为1"),
+	),
+	mcp.WithString("dest_path",
+		mcp.Description("本地保存路径（可选）。不传时直接返回文件内容"),
+	),
+)
+
+func downloadAttachmentHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return DownloadAttachment(ctx, request)
+}
+
+// recordAttachment 记录上传成功的附件大小，供附件统计使用，记录失败只写日志
+func recordAttachment(ctx context.Context, fileID, fileType, fileName string, size int64) {
+	record := AttachmentRecord{FileID: fileID, FileType: fileType, FileName: fileName, Size: size}
+	if err := SaveAttachment(tenantFromContext(ctx), record); err != nil {
+		logger.CtxWarnf(ctx, "记录附件失败: %v", err)
+	}
+}
+
+// formatBytes 将字节数格式化为易读的大小
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exp := float64(size)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
+
+// attachmentUsage 一组附件的数量和大小统计
+type attachmentUsage struct {
+	Count   int
+	Bytes   int64
+	Unknown int // 大小未知的附件数
+}
+
+func (u *attachmentUsage) add(size int64) {
+	u.Count++
+	if size < 0 {
+		u.Unknown++
+		return
+	}
+	u.Bytes += size
+}
+
+func (u attachmentUsage) String() string {
+	text := fmt.Sprintf("%d 个，%s", u.Count, formatBytes(u.Bytes))
+	if u.Unknown > 0 {
+		text += fmt.Sprintf("（%d 个大小未知）", u.Unknown)
+	}
+	return text
+}
+
+// noteAttachmentUsage 单篇笔记的附件统计
+type noteAttachmentUsage struct {
+	NoteID string
+	Title  string
+	Total  attachmentUsage
+	ByType map[string]*attachmentUsage
+}
+
+// NoteAttachmentsReport 统计每篇笔记的附件数量、类型和总大小，帮助找出占用存储空间的笔记
+func NoteAttachmentsReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	tenantID := tenantFromContext(ctx)
+
+	limit := 20
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+
+	var records []NoteRecord
+	if noteID, _ := args["note_id"].(string); noteID != "" {
+		record, err := SearchByNoteID(tenantID, noteID)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		records = []NoteRecord{*record}
+	} else {
+		var err error
+		if records, err = ListLatestNotes(tenantID, maxSearchAllNotes); err != nil {
+			return errorResult("", err), nil
+		}
+	}
+
+	sizes, err := ListAttachments(tenantID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	var total attachmentUsage
+	totalByType := make(map[string]*attachmentUsage)
+	var notes []noteAttachmentUsage
+	for _, record := range records {
+		attachments, err := noteAttachments(record.Content)
+		if err != nil || len(attachments) == 0 {
+			continue
+		}
+		usage := noteAttachmentUsage{
+			NoteID: record.NoteID,
+			Title:  truncateRunes(noteTitle(record.Content), 30),
+			ByType: make(map[string]*attachmentUsage),
+		}
+		for _, block := range attachments {
+			size := int64(-1)
+			if info, ok := sizes[block.FileID]; ok && block.FileID != "" {
+				size = info.Size
+			}
+			usage.Total.add(size)
+			if usage.ByType[block.FileType] == nil {
+				usage.ByType[block.FileType] = &attachmentUsage{}
+			}
+			usage.ByType[block.FileType].add(size)
+			if totalByType[block.FileType] == nil {
+				totalByType[block.FileType] = &attachmentUsage{}
+			}
+			totalByType[block.FileType].add(size)
+			total.add(size)
+		}
+		notes = append(notes, usage)
+	}
+
+	if len(notes) == 0 {
+		return mcp.NewToolResultText("📝 本地记录的笔记中没有附件"), nil
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		if notes[i].Total.Bytes != notes[j].Total.Bytes {
+			return notes[i].Total.Bytes > notes[j].Total.Bytes
+		}
+		return notes[i].Total.Count > notes[j].Total.Count
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 附件统计：%d 篇笔记含附件，共 %s\n", len(notes), total))
+	for _, fileType := range []string{"image", "audio", "pdf"} {
+		if usage := totalByType[fileType]; usage != nil {
+			sb.WriteString(fmt.Sprintf("  %s: %s\n", fileTypeNames[fileType], usage))
+		}
+	}
+
+	sb.WriteString("\n📦 按占用空间排序：\n")
+	for i, usage := range notes {
+		if i >= limit {
+			sb.WriteString(fmt.Sprintf("... 还有 %d 篇笔记未列出\n", len(notes)-limit))
+			break
+		}
+		title := usage.Title
+		if title == "" {
+			title = "（无标题）"
+		}
+		var parts []string
+		for _, fileType := range []string{"image", "audio", "pdf"} {
+			if typeUsage := usage.ByType[fileType]; typeUsage != nil {
+				parts = append(parts, fmt.Sprintf("%s %d", fileTypeNames[fileType], typeUsage.Count))
+			}
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s [%s] %s，%s\n", i+1, title, usage.NoteID, usage.Total, strings.Join(parts, "，")))
+	}
+
+	if total.Unknown > 0 {
+		sb.WriteString("\n大小未知的附件是在记录附件大小之前上传的，或远程文件未返回大小。")
+	}
+	return mcp.NewToolResultText(strings.TrimRight(sb.String(), "\n")), nil
+}
+
+// 附件统计工具
+var NoteAttachmentsReportTool = mcp.NewTool("note_attachments_report",
+	mcp.WithDescription("统计每篇笔记的附件数量、类型和总大小，按占用空间从大到小排列，帮助找出占用墨问存储空间的笔记。数据来自本地保存的笔记和上传记录。"),
+	mcp.WithString("note_id",
+		mcp.Description("只统计指定笔记（可选）"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("最多列出的笔记数，默认为20"),
+	),
+)
+
+func noteAttachmentsReportHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return NoteAttachmentsReport(ctx, request)
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 批量操作的默认并发数和上限
+const (
+	defaultBatchConcurrency = 4
+	maxBatchConcurrency     = 8
+)
+
+// BatchEditItem 批量编辑中的单个条目
+type BatchEditItem struct {
+	NoteID       string         `json:"note_id"`
+	Paragraphs   []ContentBlock `json:"paragraphs,omitempty"`    // 替换全部内容
+	AppendBlocks []ContentBlock `json:"append_blocks,omitempty"` // 追加到末尾
+}
+
+// BatchCreateItem 批量创建中的单篇笔记
+type BatchCreateItem struct {
+	Paragraphs  []ContentBlock `json:"paragraphs"`
+	Tags        []string       `json:"tags,omitempty"`
+	AutoPublish bool           `json:"auto_publish,omitempty"`
+	Key         string         `json:"key,omitempty"` // 去重标识，同一标识只会创建一次，便于失败后整批重试
+}
+
+// 一次批量创建的笔记数上限
+const maxBatchCreateNotes = 200
+
+// batchItemResult 批量操作中单个条目的执行结果
+type batchItemResult struct {
+	Index  int
+	NoteID string
+	Err    error
+	Detail string
+}
+
+// batchConcurrency 解析并发数参数
+func batchConcurrency(args map[string]interface{}) int {
+	concurrency := defaultBatchConcurrency
+	if v, ok := args["concurrency"].(float64); ok && v >= 1 {
+		concurrency = int(v)
+	}
+	if concurrency > maxBatchConcurrency {
+		concurrency = maxBatchConcurrency
+	}
+	return concurrency
+}
+
+// runBatch 以有限并发执行批量任务，结果按输入顺序返回
+func runBatch(n, concurrency int, fn func(i int) batchItemResult) []batchItemResult {
+	results := make([]batchItemResult, n)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// EditNotesBatch 批量编辑笔记
+func EditNotesBatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	args := request.Params.Arguments
+	editsStr, ok := args["edits"].(string)
+	if !ok {
+		return mcp.NewToolResultText("❌ edits参数必须是JSON字符串"), nil
+	}
+
+	var items []BatchEditItem
+	if err = json.Unmarshal([]byte(editsStr), &items); err != nil {
+		return errorResult("edits JSON解析错误", err), nil
+	}
+	if len(items) == 0 {
+		return mcp.NewToolResultText("❌ 编辑列表不能为空"), nil
+	}
+
+	results := runBatch(len(items), batchConcurrency(args), func(i int) batchItemResult {
+		item := items[i]
+		result := batchItemResult{Index: i + 1, NoteID: item.NoteID}
+
+		switch {
+		case item.NoteID == "":
+			result.Err = fmt.Errorf("笔记ID不能为空")
+		case len(item.Paragraphs) > 0 && len(item.AppendBlocks) > 0:
+			result.Err = fmt.Errorf("paragraphs和append_blocks只能二选一")
+		case len(item.Paragraphs) > 0:
+			result.Err = replaceNoteBlocks(ctx, client, item.NoteID, item.Paragraphs)
+			result.Detail = fmt.Sprintf("替换为 %d 个段落", len(item.Paragraphs))
+		case len(item.AppendBlocks) > 0:
+			var total int
+			total, result.Err = appendNoteBlocks(ctx, client, item.NoteID, item.AppendBlocks)
+			result.Detail = fmt.Sprintf("追加 %d 个段落，共 %d 个段落", len(item.AppendBlocks), total)
+		default:
+			result.Err = fmt.Errorf("需要提供paragraphs或append_blocks")
+		}
+		return result
+	})
+
+	return mcp.NewToolResultText(formatBatchResults("批量编辑", results)), nil
+}
+
+// BatchCreateNotes 批量创建笔记
+func BatchCreateNotes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	args := request.Params.Arguments
+	notesStr, ok := args["notes"].(string)
+	if !ok {
+		return mcp.NewToolResultText("❌ notes参数必须是JSON字符串"), nil
+	}
+
+	var items []BatchCreateItem
+	if err = json.Unmarshal([]byte(notesStr), &items); err != nil {
+		return errorResult("notes JSON解析错误", err), nil
+	}
+	if len(items) == 0 {
+		return mcp.NewToolResultText("❌ 笔记列表不能为空"), nil
+	}
+	if len(items) > maxBatchCreateNotes {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 笔记数 %d 超过单次上限 %d，请分批创建", len(items), maxBatchCreateNotes)), nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	results := runBatch(len(items), batchConcurrency(args), func(i int) batchItemResult {
+		item := items[i]
+		result := batchItemResult{Index: i + 1}
+		if len(item.Paragraphs) == 0 {
+			result.Err = fmt.Errorf("段落列表不能为空")
+			return result
+		}
+
+		var name string
+		if item.Key != "" {
+			name = "batch:" + item.Key
+			// 同一标识的条目可能出现在同一批中，加锁避免重复创建
+			unlock := lockNote(tenantID, "named:"+name)
+			defer unlock()
+			if id, err := GetNamedNote(tenantID, name); err == nil && id != "" {
+				result.NoteID, result.Detail = id, "已创建过，跳过"
+				return result
+			}
+		}
+
+		autoPublish := item.AutoPublish
+		noteID, err := createNoteFromBlocks(ctx, client, item.Paragraphs, &Settings{AutoPublish: &autoPublish, Tags: item.Tags})
+		if err == nil && noteID == "" {
+			err = fmt.Errorf("接口未返回笔记ID")
+		}
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		result.NoteID = noteID
+		content, _ := json.Marshal(item.Paragraphs)
+		result.Detail = fmt.Sprintf("%s（%d 个段落）", noteTitle(string(content)), len(item.Paragraphs))
+		if name != "" {
+			if err := SetNamedNote(tenantID, name, noteID); err != nil {
+				result.Detail += fmt.Sprintf("，记录去重标识失败: %v", err)
+			}
+		}
+		return result
+	})
+
+	for _, r := range results {
+		if r.Err == nil && r.NoteID != "" {
+			autoRegenerateIndex(ctx, client)
+			break
+		}
+	}
+	return mcp.NewToolResultText(formatBatchResults("批量创建", results)), nil
+}
+
+// formatBatchResults 格式化批量操作结果，失败条目附带错误码
+func formatBatchResults(title string, results []batchItemResult) string {
+	succeeded := 0
+	for _, r := range results {
+		if r.Err == nil {
+			succeeded++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📦 %s完成：成功 %d 条，失败 %d 条\n\n", title, succeeded, len(results)-succeeded))
+	for _, r := range results {
+		switch {
+		case r.Err != nil && r.NoteID == "":
+			sb.WriteString(fmt.Sprintf("❌ %d. [%s] %v\n", r.Index, errorCodeOf(r.Err), r.Err))
+		case r.Err != nil:
+			sb.WriteString(fmt.Sprintf("❌ %d. [%s] 笔记 %s: %v\n", r.Index, errorCodeOf(r.Err), r.NoteID, r.Err))
+		default:
+			sb.WriteString(fmt.Sprintf("✅ %d. 笔记 %s: %s\n", r.Index, r.NoteID, r.Detail))
+		}
+	}
+	return sb.String()
+}
+
+// 批量编辑笔记工具
+var EditNotesBatchTool = mcp.NewTool("edit_notes_batch",
+	mcp.WithDescription("批量编辑多篇笔记。每个条目可以替换全部内容(paragraphs)或在末尾追加内容(append_blocks)，以有限并发执行并返回每个条目的结果。适合给多篇笔记追加相同的页脚或标签段落。"),
+	mcp.WithString("edits",
+		mcp.Required(),
+		mcp.Description(`编辑条目列表JSON字符串，内容块格式与create_note的paragraphs相同。
+        格式示例：
+        [
+            {"note_id": "笔记ID1", "append_blocks": [{"texts": [{"text": "—— 页脚"}]}]},
+            {"note_id": "笔记ID2", "paragraphs": [{"texts": [{"text": "全新的内容"}]}]}
+        ]
+        追加操作基于近期核对过的本地内容，否则先从墨问拉取当前内容再合并。`),
+	),
+	mcp.WithNumber("concurrency",
+		mcp.Description("并发数，默认4，最大8"),
+	),
+)
+
+// 批量创建笔记工具
+var BatchCreateNotesTool = mcp.NewTool("batch_create_notes",
+	mcp.WithDescription("一次创建多篇笔记，适合迁移或批量整理。以有限并发执行，返回每篇笔记的结果和新建的笔记ID，单篇失败不影响其他笔记。"),
+	mcp.WithString("notes",
+		mcp.Required(),
+		mcp.Description(fmt.Sprintf(`笔记列表JSON字符串，最多%d篇，内容块格式与create_note的paragraphs相同。
+        格式示例：
+        [
+            {"paragraphs": [{"texts": [{"text": "第一篇", "bold": true}]}], "tags": ["迁移"], "key": "old-001"},
+            {"paragraphs": [{"texts": [{"text": "第二篇"}]}], "auto_publish": true}
+        ]
+        key为可选的去重标识，同一key只会创建一次，部分失败后可以原样重试整批。`, maxBatchCreateNotes)),
+	),
+	mcp.WithNumber("concurrency",
+		mcp.Description("并发数，默认4，最大8"),
+	),
+)
+
+func editNotesBatchHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := EditNotesBatch(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
+
+func batchCreateNotesHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := BatchCreateNotes(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
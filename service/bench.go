@@ -0,0 +1,140 @@
+package service
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// benchOps 压测支持的操作，每次调用与工具调用走相同的处理流程
+var benchOps = map[string]func(seq int) (*mcp.CallToolResult, error){
+	"create": func(seq int) (*mcp.CallToolResult, error) {
+		paragraphs := fmt.Sprintf(`[{"texts":[{"text":"压测笔记 %d"}]},{"texts":[{"text":"第 %d 条压测内容，用于验证创建和搜索的性能。"}]}]`, seq, seq)
+		return createNoteHandler(map[string]interface{}{"paragraphs": paragraphs})
+	},
+	"search": func(seq int) (*mcp.CallToolResult, error) {
+		return searchNoteHandler(map[string]interface{}{"query": "压测"})
+	},
+}
+
+// benchResult 一种操作的压测结果
+type benchResult struct {
+	Op        string
+	Latencies []time.Duration
+	Errors    int64
+	Elapsed   time.Duration
+}
+
+// RunBench 执行bench子命令：按配置的次数和并发数调用创建、搜索操作，输出吞吐量和延迟分位数
+// 默认使用MOWEN_MOCK回放模式，不需要API密钥；指定-base-url时请求预发环境
+func RunBench(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	ops := fs.String("ops", "create,search", "要压测的操作，逗号分隔：create, search")
+	count := fs.Int("n", 100, "每种操作的调用次数")
+	concurrency := fs.Int("c", 4, "并发数")
+	mock := fs.Bool("mock", true, "使用模拟模式回放响应，不访问墨问API")
+	baseURL := fs.String("base-url", "", "墨问API地址，例如预发环境；设置后不再使用模拟模式")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *count <= 0 || *concurrency <= 0 {
+		return fmt.Errorf("-n 和 -c 必须大于0")
+	}
+
+	var names []string
+	for _, name := range strings.Split(*ops, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := benchOps[name]; !ok {
+			return fmt.Errorf("不支持的操作: %s", name)
+		}
+		names = append(names, name)
+	}
+
+	switch {
+	case *baseURL != "":
+		os.Setenv(BaseURLEnvVar, *baseURL)
+		os.Unsetenv(MockModeEnvVar)
+	case *mock && mockMode() == "":
+		os.Setenv(MockModeEnvVar, "replay")
+	}
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("数据库初始化失败: %w", err)
+	}
+
+	target := envString(BaseURLEnvVar, BaseURL)
+	if mockReplaying() {
+		target = "模拟模式"
+	}
+	fmt.Fprintf(out, "压测目标: %s，每种操作 %d 次，并发 %d\n\n", target, *count, *concurrency)
+	for _, name := range names {
+		printBenchResult(out, runBenchOp(name, *count, *concurrency))
+	}
+	return nil
+}
+
+// runBenchOp 并发执行一种操作，返回每次调用的耗时
+func runBenchOp(name string, count, concurrency int) benchResult {
+	op := benchOps[name]
+	result := benchResult{Op: name, Latencies: make([]time.Duration, count)}
+
+	// 预热一次，不计入结果，避免分词词典加载等一次性开销影响分位数
+	op(-1)
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				seq := int(next.Add(1)) - 1
+				if seq >= count {
+					return
+				}
+				begin := time.Now()
+				res, err := op(seq)
+				result.Latencies[seq] = time.Since(begin)
+				if err != nil || isErrorResult(res) {
+					atomic.AddInt64(&result.Errors, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+// isErrorResult 工具结果是否为错误（以❌开头）
+func isErrorResult(result *mcp.CallToolResult) bool {
+	if result == nil || len(result.Content) == 0 {
+		return true
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	return ok && strings.HasPrefix(text.Text, "❌")
+}
+
+// printBenchResult 输出吞吐量和延迟分位数
+func printBenchResult(out io.Writer, result benchResult) {
+	latencies := append([]time.Duration(nil), result.Latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	fmt.Fprintf(out, "📊 %s\n", result.Op)
+	fmt.Fprintf(out, "  次数: %d，失败: %d，总耗时: %v\n", len(latencies), result.Errors, result.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(out, "  吞吐量: %.1f 次/秒\n", float64(len(latencies))/result.Elapsed.Seconds())
+	fmt.Fprintf(out, "  延迟: p50 %v，p90 %v，p99 %v，最大 %v\n\n",
+		percentile(0.50).Round(time.Microsecond), percentile(0.90).Round(time.Microsecond),
+		percentile(0.99).Round(time.Microsecond), latencies[len(latencies)-1].Round(time.Microsecond))
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 看板的三个列，顺序即笔记中的显示顺序
+const (
+	boardTodo  = "待办"
+	boardDoing = "进行中"
+	boardDone  = "已完成"
+)
+
+var boardColumns = []string{boardTodo, boardDoing, boardDone}
+
+// 状态标签，笔记打上这些标签时直接归入对应的列
+var boardStatusTags = map[string]string{
+	"待办": boardTodo, "todo": boardTodo,
+	"进行中": boardDoing, "doing": boardDoing, "in-progress": boardDoing,
+	"已完成": boardDone, "done": boardDone,
+}
+
+// boardCard 看板中的一张卡片，对应一篇笔记
+type boardCard struct {
+	NoteID string
+	Open   []string // 未完成的待办
+	Done   int
+}
+
+// noteBoardStatus 判断笔记所在的列：状态标签优先，否则按待办的完成情况推断
+// 待办全部完成为已完成，部分完成为进行中，其余（含没有待办的笔记）为待办
+func noteBoardStatus(tags []string, card boardCard) string {
+	for _, tag := range tags {
+		if column, ok := boardStatusTags[strings.ToLower(tag)]; ok {
+			return column
+		}
+	}
+	switch {
+	case card.Done > 0 && len(card.Open) == 0:
+		return boardDone
+	case card.Done > 0:
+		return boardDoing
+	default:
+		return boardTodo
+	}
+}
+
+// noteBoardCard 统计笔记中待办的完成情况
+func noteBoardCard(record NoteRecord) boardCard {
+	card := boardCard{NoteID: record.NoteID}
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(record.Content), &blocks); err != nil {
+		return card
+	}
+	for _, block := range blocks {
+		if block.Type != "todo" {
+			continue
+		}
+		if block.Checked {
+			card.Done++
+		} else {
+			card.Open = append(card.Open, strings.TrimSpace(blocksText([]ContentBlock{block})))
+		}
+	}
+	return card
+}
+
+// buildBoardBlocks 生成看板笔记的内容块，返回内容块和每列的卡片数
+// 卡片中的待办以普通段落展示，避免看板笔记本身被list_open_tasks重复统计
+func buildBoardBlocks(tenantID, tag string) ([]ContentBlock, map[string]int, error) {
+	tags, err := ListAllTags(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	named, err := ListNamedNoteIDs(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make(map[string][]boardCard)
+	for _, noteID := range tags[tag] {
+		if named[noteID] {
+			continue
+		}
+		record, err := GetNoteCached(tenantID, noteID)
+		if err != nil {
+			continue
+		}
+		noteTags, err := GetNoteTags(tenantID, noteID)
+		if err != nil {
+			return nil, nil, err
+		}
+		card := noteBoardCard(*record)
+		status := noteBoardStatus(noteTags, card)
+		columns[status] = append(columns[status], card)
+	}
+
+	blocks := []ContentBlock{
+		{Texts: []TextNode{{Text: "📋 看板: " + tag, Bold: true}}},
+		{Texts: []TextNode{{Text: "更新时间: " + time.Now().Format("2006-01-02 15:04")}}},
+	}
+	counts := make(map[string]int)
+	for _, column := range boardColumns {
+		cards := columns[column]
+		counts[column] = len(cards)
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: fmt.Sprintf("# %s（%d）", column, len(cards)), Bold: true}}})
+		for _, card := range cards {
+			blocks = append(blocks, ContentBlock{Type: "note", NoteID: card.NoteID})
+			if column == boardDone {
+				continue
+			}
+			for _, text := range card.Open {
+				blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: todoUncheckedMark + text}}})
+			}
+		}
+	}
+	return blocks, counts, nil
+}
+
+// regenerateBoardNote 重建标签对应的看板笔记，不存在时自动创建
+func regenerateBoardNote(ctx context.Context, client *MowenClient, tag string) (string, map[string]int, error) {
+	tenantID := tenantFromContext(ctx)
+	name := "board:" + tag
+
+	unlock := lockNote(tenantID, "named:"+name)
+	defer unlock()
+
+	blocks, counts, err := buildBoardBlocks(tenantID, tag)
+	if err != nil {
+		return "", nil, err
+	}
+
+	noteID, err := GetNamedNote(tenantID, name)
+	if err != nil {
+		return "", nil, err
+	}
+	if noteID != "" {
+		return noteID, counts, replaceNoteBlocks(ctx, client, noteID, blocks)
+	}
+
+	noteID, err = createNoteFromBlocks(ctx, client, blocks, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if noteID == "" {
+		return "", nil, fmt.Errorf("创建看板笔记失败：接口未返回笔记ID")
+	}
+	return noteID, counts, SetNamedNote(tenantID, name, noteID)
+}
+
+// GenerateBoardNote 按标签生成看板笔记
+func GenerateBoardNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tag, _ := request.Params.Arguments["tag"].(string)
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return mcp.NewToolResultText("❌ 标签不能为空"), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	noteID, counts, err := regenerateBoardNote(ctx, client, tag)
+	if err != nil {
+		return errorResult("生成看板笔记失败", err), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✅ 看板笔记已更新！\n\n笔记ID: %s\n标签: %s\n", noteID, tag))
+	for _, column := range boardColumns {
+		sb.WriteString(fmt.Sprintf("%s: %d\n", column, counts[column]))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 生成看板笔记工具
+var GenerateBoardNoteTool = mcp.NewTool("generate_board_note",
+	mcp.WithDescription("把带有指定标签的笔记整理成看板笔记，按待办/进行中/已完成三列分组并附上未完成的待办。笔记带有待办、进行中、已完成（或todo、doing、done）标签时直接归入对应列，否则按其中待办的勾选情况推断。每个标签对应一篇看板笔记，再次调用时原地重新生成。"),
+	mcp.WithString("tag",
+		mcp.Required(),
+		mcp.Description("要汇总的标签"),
+	),
+)
+
+func generateBoardNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return GenerateBoardNote(ctx, request)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 默认旅行日志名称
+const defaultTravelLogName = "旅行日志"
+
+// checkinMapURL 生成地点的OpenStreetMap链接：有坐标时定位到坐标，否则按地名搜索
+func checkinMapURL(place string, lat, lng float64, hasCoords bool) string {
+	if hasCoords {
+		return fmt.Sprintf("https://www.openstreetmap.org/?mlat=%.6f&mlon=%.6f#map=16/%.6f/%.6f", lat, lng, lat, lng)
+	}
+	return "https://www.openstreetmap.org/search?query=" + url.QueryEscape(place)
+}
+
+// checkinPhotoBlock 打卡照片的文件块，http(s)链接按URL上传，其余按本地路径上传
+func checkinPhotoBlock(photo string) ContentBlock {
+	sourceType := "local"
+	if u, err := url.Parse(photo); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		sourceType = "url"
+	}
+	return ContentBlock{Type: "file", FileType: "image", SourceType: sourceType, SourcePath: photo}
+}
+
+// LogCheckin 记录地点打卡，追加到旅行日志笔记
+func LogCheckin(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	place, _ := args["place"].(string)
+	place = strings.TrimSpace(place)
+	lat, hasLat := args["latitude"].(float64)
+	lng, hasLng := args["longitude"].(float64)
+	if hasLat != hasLng {
+		return mcp.NewToolResultText("❌ latitude和longitude需要同时传入"), nil
+	}
+	hasCoords := hasLat && hasLng
+	if hasCoords && (lat < -90 || lat > 90 || lng < -180 || lng > 180) {
+		return mcp.NewToolResultText("❌ 坐标超出范围：纬度应在-90到90之间，经度应在-180到180之间"), nil
+	}
+	if place == "" && !hasCoords {
+		return mcp.NewToolResultText("❌ 地点名称和坐标至少需要传入一个"), nil
+	}
+	if place == "" {
+		place = fmt.Sprintf("%.5f, %.5f", lat, lng)
+	}
+	text, _ := args["text"].(string)
+	text = strings.TrimSpace(text)
+	photo, _ := args["photo"].(string)
+	photo = strings.TrimSpace(photo)
+	logName, _ := args["log_name"].(string)
+	logName = strings.TrimSpace(logName)
+	if logName == "" {
+		logName = defaultTravelLogName
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	now := time.Now()
+	mapURL := checkinMapURL(place, lat, lng, hasCoords)
+	blocks := []ContentBlock{{Texts: []TextNode{
+		{Text: now.Format("2006-01-02 15:04") + " ", Bold: true},
+		{Text: "📍 " + place + " "},
+		{Text: "地图", Link: mapURL},
+	}}}
+	if text != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: text}}})
+	}
+	if photo != "" {
+		blocks = append(blocks, checkinPhotoBlock(photo))
+	}
+
+	noteID, created, err := appendToNamedNote(ctx, client, "travel:"+logName, "🧭 "+logName, blocks)
+	if err != nil {
+		return errorResult("追加打卡记录失败", err), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 打卡已记录！\n\n地点: %s\n地图: %s\n日志: %s\n笔记ID: %s", place, mapURL, logName, noteID)
+	if photo != "" {
+		resultText += "\n照片: 已上传"
+	}
+	if created {
+		resultText += "\n（旅行日志笔记不存在，已自动创建）"
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 地点打卡工具
+var LogCheckinTool = mcp.NewTool("log_checkin",
+	mcp.WithDescription("记录地点打卡：向旅行日志笔记追加一条带时间、地点和地图链接的记录，可附带文字和照片，日志笔记不存在时自动创建。"),
+	mcp.WithString("place",
+		mcp.Description("地点名称，与坐标至少传一个"),
+	),
+	mcp.WithNumber("latitude",
+		mcp.Description("纬度（WGS-84），与longitude同时传入时地图链接定位到坐标"),
+	),
+	mcp.WithNumber("longitude",
+		mcp.Description("经度（WGS-84）"),
+	),
+	mcp.WithString("text",
+		mcp.Description("打卡文字"),
+	),
+	mcp.WithString("photo",
+		mcp.Description("照片的本地路径或http(s)链接"),
+	),
+	mcp.WithString("log_name",
+		mcp.Description("旅行日志名称，不同名称对应不同的笔记，例如按行程区分，默认为\"旅行日志\""),
+	),
+)
+
+func logCheckinHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := LogCheckin(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 剪贴板命令环境变量，设置后替代内置的平台命令
+// 命令把剪贴板内容写到标准输出：文本命令输出UTF-8文本，图片命令输出PNG数据
+const (
+	ClipboardTextCmdEnvVar  = "MOWEN_CLIPBOARD_TEXT_CMD"
+	ClipboardImageCmdEnvVar = "MOWEN_CLIPBOARD_IMAGE_CMD"
+)
+
+// 读取剪贴板命令的超时时间
+const clipboardCommandTimeout = 10 * time.Second
+
+// clipboardCommand 读取剪贴板的外部命令
+type clipboardCommand struct {
+	Name   string
+	Args   []string
+	Env    string                       // 需要设置的环境变量，例如Wayland下的WAYLAND_DISPLAY，为空时不检查
+	Decode func([]byte) ([]byte, error) // 输出不是原始数据时的解码函数
+}
+
+// 各平台读取剪贴板文本的命令，按顺序使用第一个可用的
+var clipboardTextCommands = map[string][]clipboardCommand{
+	"darwin": {
+		{Name: "pbpaste"},
+	},
+	"windows": {
+		{Name: "powershell", Args: []string{"-NoProfile", "-Command", "[Console]::OutputEncoding=[Text.Encoding]::UTF8; Get-Clipboard -Raw"}},
+	},
+	"linux": {
+		{Name: "wl-paste", Args: []string{"--no-newline", "--type", "text"}, Env: "WAYLAND_DISPLAY"},
+		{Name: "xclip", Args: []string{"-selection", "clipboard", "-o"}, Env: "DISPLAY"},
+		{Name: "xsel", Args: []string{"--clipboard", "--output"}, Env: "DISPLAY"},
+	},
+}
+
+// 各平台读取剪贴板图片（PNG）的命令
+var clipboardImageCommands = map[string][]clipboardCommand{
+	"darwin": {
+		{Name: "pngpaste", Args: []string{"-"}},
+		{Name: "osascript", Args: []string{"-e", "get the clipboard as «class PNGf»"}, Decode: decodeAppleScriptData},
+	},
+	"windows": {
+		{Name: "powershell", Args: []string{"-NoProfile", "-STA", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; $img = [System.Windows.Forms.Clipboard]::GetImage(); if ($img -eq $null) { exit 1 }; " +
+				"$ms = New-Object System.IO.MemoryStream; $img.Save($ms, [System.Drawing.Imaging.ImageFormat]::Png); [Convert]::ToBase64String($ms.ToArray())"},
+			Decode: func(data []byte) ([]byte, error) {
+				return base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+			}},
+	},
+	"linux": {
+		{Name: "wl-paste", Args: []string{"--type", "image/png"}, Env: "WAYLAND_DISPLAY"},
+		{Name: "xclip", Args: []string{"-selection", "clipboard", "-t", "image/png", "-o"}, Env: "DISPLAY"},
+	},
+}
+
+// decodeAppleScriptData 解析osascript输出的 «data PNGf89504E47...» 格式
+func decodeAppleScriptData(data []byte) ([]byte, error) {
+	text := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(text, "«data PNGf") || !strings.HasSuffix(text, "»") {
+		return nil, fmt.Errorf("剪贴板中没有图片")
+	}
+	return hex.DecodeString(strings.TrimSuffix(strings.TrimPrefix(text, "«data PNGf"), "»"))
+}
+
+// readClipboard 使用环境变量中配置的命令或当前平台第一个可用的命令读取剪贴板
+// 剪贴板中没有对应类型的内容时返回空数据
+func readClipboard(ctx context.Context, envVar string, commands map[string][]clipboardCommand) ([]byte, error) {
+	if command := envString(envVar, ""); command != "" {
+		fields := strings.Fields(command)
+		return runClipboardCommand(ctx, clipboardCommand{Name: fields[0], Args: fields[1:]})
+	}
+
+	var names []string
+	for _, command := range commands[runtime.GOOS] {
+		names = append(names, command.Name)
+		if command.Env != "" && os.Getenv(command.Env) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(command.Name); err != nil {
+			continue
+		}
+		return runClipboardCommand(ctx, command)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("不支持读取 %s 系统的剪贴板，可以通过 %s 配置读取命令", runtime.GOOS, envVar)
+	}
+	return nil, fmt.Errorf("未找到可用的剪贴板工具，请安装其中之一: %s，或通过 %s 配置读取命令", strings.Join(names, ", "), envVar)
+}
+
+// runClipboardCommand 执行命令并返回标准输出，命令失败视为剪贴板中没有对应内容
+func runClipboardCommand(ctx context.Context, command clipboardCommand) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, clipboardCommandTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, command.Name, command.Args...)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("读取剪贴板超时: %s", command.Name)
+		}
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(&stdout, maxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAttachmentSize {
+		return nil, fmt.Errorf("剪贴板内容超过大小上限 %d MB", maxAttachmentSize>>20)
+	}
+	if command.Decode != nil && len(data) > 0 {
+		if data, err = command.Decode(data); err != nil {
+			return nil, nil
+		}
+	}
+	return data, nil
+}
+
+// clipboardImageBlock 把剪贴板中的PNG图片上传，返回带file_id的图片块
+// 临时文件由服务生成，不经过沙箱校验，上传后删除
+func clipboardImageBlock(ctx context.Context, client *MowenClient, data []byte) (ContentBlock, error) {
+	fileName := "clipboard-" + time.Now().Format("20060102-150405") + ".png"
+	tmp, err := os.CreateTemp("", "mowen-clipboard-*.png")
+	if err != nil {
+		return ContentBlock{}, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ContentBlock{}, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+
+	fileID, err := uploadLocalFile(ctx, client, tmp.Name(), fileName, false)
+	if err != nil {
+		return ContentBlock{}, fmt.Errorf("上传剪贴板图片失败: %w", err)
+	}
+	return ContentBlock{
+		Type:     "file",
+		FileType: "image",
+		FileID:   fileID,
+		Metadata: map[string]interface{}{fileNameMetadataKey: fileName},
+	}, nil
+}
+
+// clipboardTextBlocks 把剪贴板文本按行转换为段落，忽略空行
+func clipboardTextBlocks(text string) []ContentBlock {
+	var blocks []ContentBlock
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimRight(line, " \t"); strings.TrimSpace(line) != "" {
+			blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: line}}})
+		}
+	}
+	return blocks
+}
+
+// CaptureClipboard 读取系统剪贴板中的文本或图片，新建笔记或追加到已有笔记
+func CaptureClipboard(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// 剪贴板属于运行服务的机器，多租户模式下不能读取
+	if sessionFromContext(ctx) != defaultSession {
+		return mcp.NewToolResultText("❌ HTTP模式下不支持读取剪贴板，剪贴板属于运行服务的机器"), nil
+	}
+
+	args := request.Params.Arguments
+	contentType, _ := args["content_type"].(string)
+	if contentType == "" {
+		contentType = "auto"
+	}
+	if contentType != "auto" && contentType != "text" && contentType != "image" {
+		return mcp.NewToolResultText("❌ content_type必须是 'auto', 'text' 或 'image'"), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	// auto模式先尝试图片，没有图片时再读取文本
+	var blocks []ContentBlock
+	kind := ""
+	if contentType != "text" {
+		data, err := readClipboard(ctx, ClipboardImageCmdEnvVar, clipboardImageCommands)
+		if err != nil && contentType == "image" {
+			return errorResult("", err), nil
+		}
+		if len(data) > 0 && strings.HasPrefix(http.DetectContentType(data), "image/") {
+			block, err := clipboardImageBlock(ctx, client, data)
+			if err != nil {
+				return errorResult("", err), nil
+			}
+			blocks, kind = []ContentBlock{block}, "图片"
+		}
+	}
+	if kind == "" && contentType != "image" {
+		data, err := readClipboard(ctx, ClipboardTextCmdEnvVar, clipboardTextCommands)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		if blocks = clipboardTextBlocks(string(data)); len(blocks) > 0 {
+			kind = "文本"
+		}
+	}
+	if kind == "" {
+		switch contentType {
+		case "image":
+			return mcp.NewToolResultText("❌ 剪贴板中没有图片"), nil
+		case "text":
+			return mcp.NewToolResultText("❌ 剪贴板中没有文本"), nil
+		}
+		return mcp.NewToolResultText("❌ 剪贴板为空，或其中的内容不是文本或图片"), nil
+	}
+
+	// 传入note_id时追加到该笔记，否则新建笔记
+	if noteID, _ := args["note_id"].(string); noteID != "" {
+		total, err := appendNoteBlocks(ctx, client, noteID, blocks)
+		if err != nil {
+			return errorResult("追加失败", err), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 剪贴板%s已追加到笔记！\n\n笔记ID: %s\n追加段落数: %d\n段落总数: %d",
+			kind, noteID, len(blocks), total)), nil
+	}
+
+	if title, _ := args["title"].(string); strings.TrimSpace(title) != "" {
+		blocks = append([]ContentBlock{{Texts: []TextNode{{Text: strings.TrimSpace(title), Bold: true}}}}, blocks...)
+	}
+	noteID, err := createNoteFromBlocks(ctx, client, blocks, nil)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if noteID == "" {
+		noteID = "未知ID"
+	} else {
+		sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 已用剪贴板%s创建笔记！\n\n笔记ID: %s\n段落数: %d", kind, noteID, len(blocks))), nil
+}
+
+// 剪贴板收集工具
+var CaptureClipboardTool = mcp.NewTool("capture_clipboard",
+	mcp.WithDescription("读取本机剪贴板中的文本或图片（例如刚复制的段落或截图），新建一篇笔记或追加到已有笔记。仅stdio模式可用。"),
+	mcp.WithString("content_type",
+		mcp.Description("读取的内容类型：auto(默认，有图片时取图片，否则取文本)、text、image"),
+	),
+	mcp.WithString("note_id",
+		mcp.Description("要追加到的笔记ID，不传时新建笔记"),
+	),
+	mcp.WithString("title",
+		mcp.Description("新建笔记时作为第一段的标题，追加时忽略"),
+	),
+)
+
+func captureClipboardHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return CaptureClipboard(ctx, request)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// 请求压缩环境变量
+const (
+	// 是否对较大的请求体使用gzip压缩，默认关闭
+	RequestGzipEnvVar = "MOWEN_REQUEST_GZIP"
+	// 超过该字节数的请求体才压缩，默认8192
+	RequestGzipMinBytesEnvVar = "MOWEN_REQUEST_GZIP_MIN_BYTES"
+)
+
+// 服务端拒绝压缩请求后置为true，本进程后续请求不再压缩
+var gzipRejected atomic.Bool
+
+// shouldGzipRequest 判断请求体是否需要压缩
+func shouldGzipRequest(size int) bool {
+	if gzipRejected.Load() || !envBool(RequestGzipEnvVar, false) {
+		return false
+	}
+	return size >= envInt(RequestGzipMinBytesEnvVar, 8192)
+}
+
+// gzipBytes 压缩请求体
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("压缩请求体失败: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("压缩请求体失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// isGzipRejected 服务端是否因为不支持压缩而拒绝了请求
+func isGzipRejected(statusCode int) bool {
+	return statusCode == http.StatusUnsupportedMediaType || statusCode == http.StatusBadRequest
+}
+
+// bodyTooLargeError 请求体超过服务端限制时的提示
+func bodyTooLargeError(size int) error {
+	return withErrorCode(ErrInvalidBlock, fmt.Errorf("请求体过大（%.1f KB），超过了墨问API的限制，请把内容拆分为多篇笔记，或先创建笔记再用追加的方式分批写入", float64(size)/1024))
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// conversationMessage 对话中的一条消息
+type conversationMessage struct {
+	Role      string `json:"role"`                // 角色：user, assistant, system, tool
+	Content   string `json:"content"`             // 消息内容
+	Name      string `json:"name,omitempty"`      // 发言人名称，设置后代替角色标签
+	Timestamp string `json:"timestamp,omitempty"` // 发送时间
+}
+
+// conversationSpeakers 各角色显示的发言人标签
+var conversationSpeakers = map[string]string{
+	"user":      "🙋 用户",
+	"human":     "🙋 用户",
+	"assistant": "🤖 助手",
+	"ai":        "🤖 助手",
+	"system":    "⚙️ 系统",
+	"tool":      "🔧 工具",
+}
+
+// speaker 消息的发言人标签
+func (m conversationMessage) speaker() string {
+	if name := strings.TrimSpace(m.Name); name != "" {
+		return name
+	}
+	role := strings.ToLower(strings.TrimSpace(m.Role))
+	if label, ok := conversationSpeakers[role]; ok {
+		return label
+	}
+	if role == "" {
+		return "未知"
+	}
+	return m.Role
+}
+
+// buildConversationBlocks 生成对话笔记：每条消息以加粗的发言人和时间开头，内容按行分段
+func buildConversationBlocks(title string, messages []conversationMessage) []ContentBlock {
+	blocks := []ContentBlock{
+		{Texts: []TextNode{{Text: title, Bold: true}}},
+		{Texts: []TextNode{{Text: fmt.Sprintf("保存时间: %s · 共 %d 条消息", time.Now().Format("2006-01-02 15:04"), len(messages))}}},
+	}
+	for _, message := range messages {
+		header := []TextNode{{Text: message.speaker(), Bold: true}}
+		if ts := strings.TrimSpace(message.Timestamp); ts != "" {
+			header = append(header, TextNode{Text: " · " + formatPostTime(ts)})
+		}
+		blocks = append(blocks, ContentBlock{Texts: header})
+		for _, line := range splitLines(message.Content) {
+			blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: line}}})
+		}
+	}
+	return blocks
+}
+
+// SaveConversation 把对话记录保存为笔记
+func SaveConversation(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	messagesStr, ok := args["messages"].(string)
+	if !ok || strings.TrimSpace(messagesStr) == "" {
+		return mcp.NewToolResultText("❌ messages参数必须是JSON字符串"), nil
+	}
+	var all []conversationMessage
+	if err := json.Unmarshal([]byte(messagesStr), &all); err != nil {
+		return errorResult("messages JSON解析错误", err), nil
+	}
+	includeSystem, _ := args["include_system"].(bool)
+
+	// 跳过空消息，默认不保存系统提示
+	var messages []conversationMessage
+	for _, message := range all {
+		if strings.TrimSpace(message.Content) == "" {
+			continue
+		}
+		if !includeSystem && strings.EqualFold(strings.TrimSpace(message.Role), "system") {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return mcp.NewToolResultText("❌ 没有可保存的消息"), nil
+	}
+
+	title, _ := args["title"].(string)
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = "💬 对话记录 " + time.Now().Format("2006-01-02 15:04")
+	}
+	tags := []string{"对话记录"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	noteID, err := createNoteFromBlocks(ctx, client, buildConversationBlocks(title, messages), &Settings{Tags: tags})
+	if err != nil {
+		return errorResult("保存对话失败", err), nil
+	}
+	if noteID == "" {
+		noteID = "未知ID"
+	} else {
+		sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	}
+
+	resultText := fmt.Sprintf("✅ 对话已保存！\n\n标题: %s\n笔记ID: %s\n消息数: %d", title, noteID, len(messages))
+	if skipped := len(all) - len(messages); skipped > 0 {
+		resultText += fmt.Sprintf("\n已跳过 %d 条空消息或系统消息", skipped)
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 保存对话工具
+var SaveConversationTool = mcp.NewTool("save_conversation",
+	mcp.WithDescription("把当前对话保存为笔记：传入消息列表，每条消息以发言人和时间开头，内容按行分段，便于日后回顾。默认不保存系统提示。"),
+	mcp.WithString("messages",
+		mcp.Required(),
+		mcp.Description("消息列表，JSON字符串数组，每项包含role（user/assistant/system/tool）、content，可选name（发言人名称）和timestamp（如 2024-05-01 12:30 或RFC3339）"),
+	),
+	mcp.WithString("title",
+		mcp.Description("笔记标题，默认为\"💬 对话记录\"加当前时间"),
+	),
+	mcp.WithBoolean("include_system",
+		mcp.Description("是否保存系统消息，默认false"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组，默认 [\"对话记录\"]"),
+	),
+)
+
+func saveConversationHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := SaveConversation(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 文件块元数据中控制是否转换格式的键，设为false时按原格式上传
+const convertMetadataKey = "convert"
+
+// 格式转换命令的超时时间
+const convertCommandTimeout = 60 * time.Second
+
+// imageConverter 外部格式转换命令，{in}和{out}替换为输入输出路径
+type imageConverter struct {
+	Name string
+	Args []string
+	// SkipOn 不使用该命令的系统，对应runtime.GOOS
+	SkipOn string
+}
+
+// 各目标格式可用的转换命令，按顺序使用第一个已安装的
+// ImageMagick 7优先使用magick；Windows上的convert是系统自带的磁盘转换工具，不能作为ImageMagick的备选
+var imageConverters = map[string][]imageConverter{
+	".jpg": {
+		{Name: "heif-convert", Args: []string{"-q", "92", "{in}", "{out}"}},
+		{Name: "magick", Args: []string{"{in}", "-auto-orient", "{out}"}},
+		{Name: "convert", Args: []string{"{in}", "-auto-orient", "{out}"}, SkipOn: "windows"},
+		{Name: "sips", Args: []string{"-s", "format", "jpeg", "{in}", "--out", "{out}"}},
+		{Name: "ffmpeg", Args: []string{"-y", "-loglevel", "error", "-i", "{in}", "{out}"}},
+	},
+	".gif": {
+		{Name: "magick", Args: []string{"{in}", "-coalesce", "{out}"}},
+		{Name: "convert", Args: []string{"{in}", "-coalesce", "{out}"}, SkipOn: "windows"},
+	},
+}
+
+// uploadConversion 判断本地文件是否需要转换格式后上传，返回目标扩展名，不需要时返回空字符串
+// 墨问不接受HEIC/HEIF照片和动态WebP，分别转换为JPEG和GIF
+func uploadConversion(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".heic", ".heif":
+		return ".jpg", nil
+	case ".webp":
+		file, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("读取图片失败: %w", err)
+		}
+		defer file.Close()
+		header := make([]byte, 64)
+		n, _ := file.Read(header)
+		if isAnimatedWebP(header[:n]) {
+			return ".gif", nil
+		}
+	}
+	return "", nil
+}
+
+// isAnimatedWebP 根据VP8X扩展头中的动画标记判断是否为动态WebP
+func isAnimatedWebP(header []byte) bool {
+	if len(header) < 21 || string(header[:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return false
+	}
+	return string(header[12:16]) == "VP8X" && header[20]&0x02 != 0
+}
+
+// convertFileForUpload 按需转换本地文件格式，返回上传使用的路径和清理函数
+// 不需要转换时返回原路径
+func convertFileForUpload(ctx context.Context, path string) (string, func(), error) {
+	noop := func() {}
+	ext, err := uploadConversion(path)
+	if err != nil || ext == "" {
+		return path, noop, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "mowen-convert-")
+	if err != nil {
+		return "", noop, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tmpDir) }
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	out := filepath.Join(tmpDir, base+ext)
+
+	if err = runImageConverter(ctx, ext, path, out); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("%s 格式墨问不支持，转换为 %s 失败: %w（可在元数据中设置 \"convert\": false 按原格式上传）",
+			strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), ".")), strings.TrimPrefix(ext, "."), err)
+	}
+	return out, cleanup, nil
+}
+
+// runImageConverter 使用第一个已安装的转换命令转换格式
+func runImageConverter(ctx context.Context, ext, in, out string) error {
+	return runConverters(ctx, imageConverters[ext], in, out)
+}
+
+// runConverters 依次查找转换命令，使用第一个已安装的执行转换
+func runConverters(ctx context.Context, converters []imageConverter, in, out string) error {
+	var names []string
+	for _, converter := range converters {
+		if converter.SkipOn == runtime.GOOS {
+			continue
+		}
+		names = append(names, converter.Name)
+		bin, err := exec.LookPath(converter.Name)
+		if err != nil {
+			continue
+		}
+
+		args := make([]string, len(converter.Args))
+		for i, arg := range converter.Args {
+			args[i] = strings.NewReplacer("{in}", in, "{out}", out).Replace(arg)
+		}
+		cmdCtx, cancel := context.WithTimeout(ctx, convertCommandTimeout)
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(cmdCtx, bin, args...)
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+		cancel()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("%s: %s", converter.Name, msg)
+			}
+			return fmt.Errorf("%s: %w", converter.Name, err)
+		}
+		if _, err = os.Stat(out); err != nil {
+			return fmt.Errorf("%s 未生成输出文件", converter.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("未找到可用的转换工具，请安装其中之一: %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,428 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// 一次最多导入的行数
+	maxCSVImportRows = 2000
+	// CSV文件的大小上限
+	maxCSVImportSize = 50 << 20
+	// 没有标题列时，从正文第一行截取标题的长度
+	csvTitleRunes = 30
+)
+
+// 未指定列时按表头自动识别的列名（小写）
+var csvColumnAliases = map[string][]string{
+	"title": {"title", "name", "subject", "标题", "名称", "主题"},
+	"body":  {"body", "content", "text", "note", "notes", "正文", "内容", "笔记", "备注"},
+	"tags":  {"tags", "tag", "labels", "categories", "标签", "分类"},
+	"date":  {"date", "created", "created_at", "create_time", "time", "日期", "创建时间", "时间"},
+}
+
+// csvRow 待导入的一行
+type csvRow struct {
+	Line      int
+	Title     string
+	Body      string
+	Tags      []string
+	CreatedAt time.Time
+	Extra     [][2]string // 附加列的列名和值
+	NoteID    string
+	Existing  bool
+}
+
+// key 用于去重的标识：标题、正文和日期的摘要
+func (r *csvRow) key() string {
+	sum := sha256.Sum256([]byte(r.Title + "\n" + r.Body + "\n" + r.CreatedAt.UTC().Format(time.RFC3339)))
+	return "csv:" + hex.EncodeToString(sum[:8])
+}
+
+// blocks 笔记内容：加粗标题、可选的日期行、正文和附加列
+func (r *csvRow) blocks(bodyFormat string, dateLine bool) []ContentBlock {
+	blocks := []ContentBlock{{Texts: []TextNode{{Text: r.Title, Bold: true}}}}
+	// 墨问接口不能指定创建时间，原始日期写在标题下方
+	if dateLine && !r.CreatedAt.IsZero() {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "创建于 " + r.CreatedAt.Local().Format("2006-01-02 15:04")}}})
+	}
+	if bodyFormat == "markdown" {
+		blocks = append(blocks, markdownToBlocks(r.Body)...)
+	} else {
+		for _, line := range strings.Split(r.Body, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: line}}})
+			}
+		}
+	}
+	for _, extra := range r.Extra {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: extra[0] + ": ", Bold: true}, {Text: extra[1]}}})
+	}
+	return blocks
+}
+
+// csvColumns 列名到列序号的映射
+type csvColumns struct {
+	header []string
+	index  map[string]int
+}
+
+// resolve 按列名（不区分大小写）或从1开始的序号查找列，找不到返回错误
+func (c *csvColumns) resolve(ref string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if i, ok := c.index[strings.ToLower(ref)]; ok {
+		return i, nil
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 {
+		return n - 1, nil
+	}
+	if c.header == nil {
+		return 0, fmt.Errorf("列 %q 不存在，没有表头时请用从1开始的列序号", ref)
+	}
+	return 0, fmt.Errorf("列 %q 不存在，表头为: %s", ref, strings.Join(c.header, ", "))
+}
+
+// detect 按常见列名自动识别字段对应的列，没有表头或找不到时返回-1
+func (c *csvColumns) detect(field string) int {
+	for _, alias := range csvColumnAliases[field] {
+		if i, ok := c.index[alias]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// name 列的显示名称
+func (c *csvColumns) name(i int) string {
+	if i < len(c.header) && c.header[i] != "" {
+		return c.header[i]
+	}
+	return fmt.Sprintf("第%d列", i+1)
+}
+
+// splitCSVTags 按逗号、分号、竖线和空白拆分标签，去掉开头的#
+func splitCSVTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(",，;；|、", r) || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		if tag = strings.TrimLeft(tag, "#"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// readCSVRecords 读取CSV或TSV文件的全部记录，去掉Excel写入的BOM
+func readCSVRecords(path, delimiter string) ([][]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	if info.Size() > maxCSVImportSize {
+		return nil, fmt.Errorf("文件超过大小上限 %d MB", maxCSVImportSize>>20)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("文件不是UTF-8编码，请在表格软件中另存为UTF-8格式的CSV")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	switch {
+	case delimiter == "\\t" || delimiter == "tab":
+		reader.Comma = '\t'
+	case delimiter != "":
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			return nil, fmt.Errorf("分隔符只能是一个字符")
+		}
+		reader.Comma = r
+	case strings.EqualFold(filepath.Ext(path), ".tsv"):
+		reader.Comma = '\t'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析CSV失败: %w", err)
+	}
+	return records, nil
+}
+
+// ImportCSV 按列映射把CSV文件的每一行导入为一篇笔记
+func ImportCSV(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	filePath, _ := args["file_path"].(string)
+	if filePath == "" {
+		return mcp.NewToolResultText("❌ 文件路径不能为空"), nil
+	}
+	tags := []string{"CSV导入"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	var extraRefs []string
+	if extraStr, _ := args["extra_columns"].(string); extraStr != "" {
+		if err := json.Unmarshal([]byte(extraStr), &extraRefs); err != nil {
+			return errorResult("extra_columns格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	bodyFormat, _ := args["body_format"].(string)
+	if bodyFormat == "" {
+		bodyFormat = "markdown"
+	}
+	if bodyFormat != "markdown" && bodyFormat != "plain" {
+		return mcp.NewToolResultText("❌ body_format必须是 'markdown' 或 'plain'"), nil
+	}
+	hasHeader := true
+	if v, ok := args["has_header"].(bool); ok {
+		hasHeader = v
+	}
+	dateLine := true
+	if v, ok := args["date_line"].(bool); ok {
+		dateLine = v
+	}
+	dryRun, _ := args["dry_run"].(bool)
+	delimiter, _ := args["delimiter"].(string)
+
+	path, err := checkLocalPath(ctx, filePath)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	records, err := readCSVRecords(path, delimiter)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	// 解析列映射：指定的列按列名或序号查找，未指定的按表头自动识别
+	columns := &csvColumns{index: make(map[string]int)}
+	firstLine := 1
+	if hasHeader {
+		if len(records) == 0 {
+			return mcp.NewToolResultText("❌ 文件为空"), nil
+		}
+		columns.header = records[0]
+		for i, name := range records[0] {
+			if key := strings.ToLower(strings.TrimSpace(name)); key != "" {
+				if _, ok := columns.index[key]; !ok {
+					columns.index[key] = i
+				}
+			}
+		}
+		records, firstLine = records[1:], 2
+	}
+	mapping := make(map[string]int)
+	for _, field := range []string{"title", "body", "tags", "date"} {
+		mapping[field] = columns.detect(field)
+		if ref, _ := args[field+"_column"].(string); ref != "" {
+			if mapping[field], err = columns.resolve(ref); err != nil {
+				return mcp.NewToolResultText(fmt.Sprintf("❌ %s_column: %v", field, err)), nil
+			}
+		}
+	}
+	if mapping["title"] < 0 && mapping["body"] < 0 {
+		return mcp.NewToolResultText("❌ 无法识别标题列和正文列，请通过title_column或body_column指定（列名或从1开始的序号）"), nil
+	}
+	extraColumns := make([]int, 0, len(extraRefs))
+	for _, ref := range extraRefs {
+		i, err := columns.resolve(ref)
+		if err != nil {
+			return errorResult("extra_columns", err), nil
+		}
+		extraColumns = append(extraColumns, i)
+	}
+
+	cell := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(strings.ReplaceAll(record[i], "\r\n", "\n"))
+	}
+	var rows []*csvRow
+	var warnings []string
+	for n, record := range records {
+		row := &csvRow{Line: firstLine + n, Title: cell(record, mapping["title"]), Body: cell(record, mapping["body"])}
+		if row.Title == "" && row.Body == "" {
+			continue
+		}
+		if row.Title == "" {
+			row.Title = truncateRunes(strings.SplitN(row.Body, "\n", 2)[0], csvTitleRunes)
+		}
+		row.Tags = splitCSVTags(cell(record, mapping["tags"]))
+		if value := cell(record, mapping["date"]); value != "" {
+			if t, ok := parseHTMLDate(value); ok {
+				row.CreatedAt = t
+			} else {
+				warnings = append(warnings, fmt.Sprintf("第 %d 行: 无法识别日期 %q", row.Line, value))
+			}
+		}
+		for _, i := range extraColumns {
+			if value := cell(record, i); value != "" {
+				row.Extra = append(row.Extra, [2]string{columns.name(i), value})
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return mcp.NewToolResultText("❌ 没有找到可导入的行"), nil
+	}
+	if len(rows) > maxCSVImportRows {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 行数 %d 超过单次导入上限 %d，请拆分文件后分批导入", len(rows), maxCSVImportRows)), nil
+	}
+	// 有日期列时按日期从早到晚导入，没有日期的行保持原顺序排在最后
+	if mapping["date"] >= 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := rows[i].CreatedAt, rows[j].CreatedAt
+			return !a.IsZero() && (b.IsZero() || a.Before(b))
+		})
+	}
+
+	tenantID := tenantFromContext(ctx)
+	existing := 0
+	for _, row := range rows {
+		if id, err := GetNamedNote(tenantID, row.key()); err == nil && id != "" {
+			row.NoteID, row.Existing = id, true
+			existing++
+		}
+	}
+
+	var sb strings.Builder
+	var mapped []string
+	for _, field := range []string{"title", "body", "tags", "date"} {
+		if i := mapping[field]; i >= 0 {
+			mapped = append(mapped, fmt.Sprintf("%s=%s", field, columns.name(i)))
+		}
+	}
+	if dryRun {
+		sb.WriteString(fmt.Sprintf("📝 预览：共 %d 行，%d 行已导入过，将创建 %d 篇笔记\n列映射: %s\n\n", len(rows), existing, len(rows)-existing, strings.Join(mapped, ", ")))
+		for _, row := range rows {
+			sb.WriteString(fmt.Sprintf("- 第 %d 行: %s", row.Line, row.Title))
+			if row.Existing {
+				sb.WriteString("（已导入过）")
+			}
+			if len(row.Tags) > 0 {
+				sb.WriteString(fmt.Sprintf(" [%s]", strings.Join(row.Tags, ", ")))
+			}
+			sb.WriteString("\n")
+		}
+		if len(warnings) > 0 {
+			sb.WriteString(fmt.Sprintf("\n⚠️ 警告:\n%s\n", strings.Join(warnings, "\n")))
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+	if existing == len(rows) {
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 全部 %d 行都已导入过", existing)), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	created := 0
+	var failures []string
+	for _, row := range rows {
+		if row.Existing {
+			continue
+		}
+		noteID, err := createNoteFromBlocks(ctx, client, row.blocks(bodyFormat, dateLine), &Settings{Tags: mergeTags(tags, row.Tags)})
+		if err == nil && noteID == "" {
+			err = fmt.Errorf("接口未返回笔记ID")
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("第 %d 行 %s: %v", row.Line, row.Title, err))
+			continue
+		}
+		row.NoteID = noteID
+		created++
+		if err := SetNamedNote(tenantID, row.key(), noteID); err != nil {
+			warnings = append(warnings, fmt.Sprintf("第 %d 行: 记录导入状态失败: %v", row.Line, err))
+		}
+	}
+
+	if created == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 导入失败:\n%s", strings.Join(failures, "\n"))), nil
+	}
+	sb.WriteString(fmt.Sprintf("✅ CSV导入完成！\n\n新建笔记: %d 篇\n已导入过: %d 篇\n失败: %d 篇\n列映射: %s\n\n",
+		created, existing, len(failures), strings.Join(mapped, ", ")))
+	for _, row := range rows {
+		if row.NoteID != "" && !row.Existing {
+			sb.WriteString(fmt.Sprintf("- 第 %d 行 %s: %s\n", row.Line, row.Title, row.NoteID))
+		}
+	}
+	if len(failures) > 0 {
+		sb.WriteString(fmt.Sprintf("\n❌ 失败的行:\n%s\n", strings.Join(failures, "\n")))
+	}
+	if len(warnings) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ 警告:\n%s\n", strings.Join(warnings, "\n")))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 导入CSV工具
+var ImportCSVTool = mcp.NewTool("import_csv",
+	mcp.WithDescription("按列映射导入CSV/TSV文件（任意工具或表格软件导出的数据），每行一篇笔记。列可以用表头名称或从1开始的序号指定，未指定时按常见列名（title/标题、content/内容、tags/标签、date/日期等）自动识别。有日期列时按日期从早到晚导入，原始日期写在标题下方。同一行内容只会导入一次，建议先用dry_run检查列映射。"),
+	mcp.WithString("file_path",
+		mcp.Required(),
+		mcp.Description("CSV或TSV文件路径，需为UTF-8编码"),
+	),
+	mcp.WithString("title_column",
+		mcp.Description("标题列，不传且无法识别时取正文第一行作为标题"),
+	),
+	mcp.WithString("body_column",
+		mcp.Description("正文列"),
+	),
+	mcp.WithString("tags_column",
+		mcp.Description("标签列，按逗号、分号、竖线或空格拆分为多个标签"),
+	),
+	mcp.WithString("date_column",
+		mcp.Description("日期列，支持 YYYY-MM-DD、YYYY-MM-DD HH:MM:SS、RFC3339 等格式"),
+	),
+	mcp.WithString("extra_columns",
+		mcp.Description("附加列，JSON字符串数组，每列以“列名: 值”的形式追加在正文后，例如 [\"作者\", \"链接\"]"),
+	),
+	mcp.WithString("body_format",
+		mcp.Description("正文格式：markdown(默认，转换标题、列表、链接等), plain(每行一个段落，原样保留)"),
+		mcp.Enum("markdown", "plain"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("所有笔记共同的标签，JSON字符串数组，默认 [\"CSV导入\"]"),
+	),
+	mcp.WithString("delimiter",
+		mcp.Description("分隔符，默认按扩展名：.tsv为制表符（可写作\\t），其他为逗号"),
+	),
+	mcp.WithBoolean("has_header",
+		mcp.Description("第一行是否为表头，默认true；为false时列只能用序号指定"),
+	),
+	mcp.WithBoolean("date_line",
+		mcp.Description("是否在标题下方写入原始日期，默认true"),
+	),
+	mcp.WithBoolean("dry_run",
+		mcp.Description("为true时只显示列映射和将要导入的笔记，不创建笔记"),
+	),
+)
+
+func importCSVHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ImportCSV(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
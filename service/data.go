@@ -1,19 +1,32 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"unicode"
 )
 
 // ContentBlock 表示输入的内容块结构
 type ContentBlock struct {
-	Type       string                 `json:"type,omitempty"`        // 段落类型：quote, note, file
+	Type       string                 `json:"type,omitempty"`        // 段落类型：paragraph(默认), quote, todo, divider, math, note, file，可通过RegisterBlockConverter扩展
 	Texts      []TextNode             `json:"texts,omitempty"`       // 文本节点列表
+	Paragraphs [][]TextNode           `json:"paragraphs,omitempty"`  // 引用的后续段落，type为quote时使用，每组文本节点是一个段落
+	Children   []ContentBlock         `json:"children,omitempty"`    // 嵌套在引用中的引用块，type为quote时使用
 	NoteID     string                 `json:"note_id,omitempty"`     // 内链笔记ID
 	FileType   string                 `json:"file_type,omitempty"`   // 文件类型：image, audio, pdf
-	SourceType string                 `json:"source_type,omitempty"` // 来源类型：local, url
+	SourceType string                 `json:"source_type,omitempty"` // 来源类型：local, url, dir（目录中匹配的全部文件）
 	SourcePath string                 `json:"source_path,omitempty"` // 文件路径
+	Pattern    string                 `json:"pattern,omitempty"`     // source_type为dir时匹配文件名的通配符，默认*
+	FileID     string                 `json:"file_id,omitempty"`     // 已上传文件的ID，设置后不再重复上传
+	Checked    bool                   `json:"checked,omitempty"`     // 待办是否已完成，type为todo时使用
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`    // 元数据
 }
 
@@ -30,6 +43,55 @@ type MowenContentNode struct {
 	Type    string                 `json:"type"`              // 节点类型
 	Content []MowenTextNode        `json:"content,omitempty"` // 文本内容（用于paragraph和quote）
 	Attrs   map[string]interface{} `json:"attrs,omitempty"`   // 属性（用于image、audio、pdf、note）
+	Blocks  []MowenContentNode     `json:"-"`                 // 子节点（用于包含多个段落或嵌套引用的quote），设置后代替Content输出
+}
+
+// MarshalJSON 有子节点时content输出子节点，否则输出文本内容
+func (n MowenContentNode) MarshalJSON() ([]byte, error) {
+	type plain MowenContentNode
+	if len(n.Blocks) == 0 {
+		return json.Marshal(plain(n))
+	}
+	return json.Marshal(struct {
+		Type    string                 `json:"type"`
+		Content []MowenContentNode     `json:"content"`
+		Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	}{n.Type, n.Blocks, n.Attrs})
+}
+
+// UnmarshalJSON content中是文本节点时读入Content，是段落或引用节点时读入Blocks
+func (n *MowenContentNode) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type    string                 `json:"type"`
+		Content []json.RawMessage      `json:"content"`
+		Attrs   map[string]interface{} `json:"attrs"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*n = MowenContentNode{Type: raw.Type, Attrs: raw.Attrs}
+	for _, item := range raw.Content {
+		var head struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(item, &head); err != nil {
+			return err
+		}
+		if head.Type == "text" {
+			var text MowenTextNode
+			if err := json.Unmarshal(item, &text); err != nil {
+				return err
+			}
+			n.Content = append(n.Content, text)
+			continue
+		}
+		var child MowenContentNode
+		if err := json.Unmarshal(item, &child); err != nil {
+			return err
+		}
+		n.Blocks = append(n.Blocks, child)
+	}
+	return nil
 }
 
 // MowenTextNode 表示墨问API标准格式的文本节点
@@ -51,18 +113,78 @@ type MowenDocument struct {
 	Content []MowenContentNode `json:"content"` // 内容节点列表
 }
 
+// BlockConverter 内容块转换器，将一个内容块转换为一个或多个墨问节点
+// block 为指针，转换器可以回写数据（例如上传后的file_id）
+type BlockConverter func(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error)
+
+var (
+	blockConvertersMu sync.RWMutex
+	blockConverters   = make(map[string]BlockConverter)
+)
+
+// RegisterBlockConverter 注册内容块转换器，同名类型会被覆盖
+// 空字符串表示未指定type的普通段落
+func RegisterBlockConverter(blockType string, converter BlockConverter) {
+	blockConvertersMu.Lock()
+	defer blockConvertersMu.Unlock()
+	blockConverters[blockType] = converter
+}
+
+// SupportedBlockTypes 返回已注册的内容块类型，按名称排序
+func SupportedBlockTypes() []string {
+	blockConvertersMu.RLock()
+	defer blockConvertersMu.RUnlock()
+
+	types := make([]string, 0, len(blockConverters))
+	for blockType := range blockConverters {
+		if blockType != "" {
+			types = append(types, blockType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// getBlockConverter 查找内容块类型对应的转换器
+func getBlockConverter(blockType string) (BlockConverter, error) {
+	blockConvertersMu.RLock()
+	converter, ok := blockConverters[blockType]
+	blockConvertersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的段落类型: %s，支持的类型: %s", blockType, strings.Join(SupportedBlockTypes(), ", "))
+	}
+	return converter, nil
+}
+
+func init() {
+	RegisterBlockConverter("", convertParagraphBlock)
+	RegisterBlockConverter("paragraph", convertParagraphBlock)
+	RegisterBlockConverter("quote", convertQuoteBlock)
+	RegisterBlockConverter("todo", convertTodoBlock)
+	RegisterBlockConverter("divider", convertDividerBlock)
+	RegisterBlockConverter("note", convertNoteBlock)
+	RegisterBlockConverter("file", convertFileBlock)
+}
+
 // ConvertToMowenFormat 将简化格式转换为墨问API标准格式
 // 参数:
-// - blocks: 输入的内容块列表
+// - ctx: 请求上下文，用于本地文件沙箱校验
+// - client: 墨问客户端，用于上传文件
+// - blocks: 输入的内容块列表，上传后的文件ID会回写到对应内容块的file_id中
 // 返回:
 // - MowenDocument: 墨问API标准格式的文档
-func ConvertToMowenFormat(client *MowenClient, blocks []ContentBlock) (MowenDocument, error) {
+func ConvertToMowenFormat(ctx context.Context, client *MowenClient, blocks []ContentBlock) (MowenDocument, error) {
 	doc := MowenDocument{
 		Type:    "doc",
 		Content: make([]MowenContentNode, 0),
 	}
 
-	for i, block := range blocks {
+	for i := range blocks {
+		converter, err := getBlockConverter(blocks[i].Type)
+		if err != nil {
+			return doc, fmt.Errorf("第 %d 个段落: %w", i+1, err)
+		}
+
 		// 在每个内容块之间添加空段落（除了第一个）
 		if i > 0 {
 			doc.Content = append(doc.Content, MowenContentNode{
@@ -70,123 +192,341 @@ func ConvertToMowenFormat(client *MowenClient, blocks []ContentBlock) (MowenDocu
 			})
 		}
 
-		switch block.Type {
-		case "quote":
-			// 引用段落
-			doc.Content = append(doc.Content, MowenContentNode{
-				Type:    "quote",
-				Content: convertTextsToMowenFormat(block.Texts),
-			})
+		nodes, err := converter(ctx, client, &blocks[i])
+		if err != nil {
+			return doc, err
+		}
+		doc.Content = append(doc.Content, nodes...)
+	}
 
-		case "note":
-			// 内链笔记
-			doc.Content = append(doc.Content, MowenContentNode{
-				Type: "note",
-				Attrs: map[string]interface{}{
-					"uuid": block.NoteID,
-				},
-			})
+	return doc, nil
+}
+
+// convertParagraphBlock 普通段落（默认）
+func convertParagraphBlock(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error) {
+	// 只有 --- 的段落是Markdown的分隔线
+	if isMarkdownRule(block.Texts) {
+		return convertDividerBlock(ctx, client, block)
+	}
+	// 只有墨问笔记链接的段落转换为内链笔记
+	if envBool(LinkifyEnvVar, false) {
+		if noteID, ok := mowenNoteRef(block.Texts); ok {
+			return convertNoteBlock(ctx, client, &ContentBlock{Type: "note", NoteID: noteID})
+		}
+	}
+	// 整段的 $$...$$ 公式按公式段落处理
+	if _, ok := mathFormula(block.Texts); ok {
+		return convertMathBlock(ctx, client, block)
+	}
+	return []MowenContentNode{{
+		Type:    "paragraph",
+		Content: convertTextsToMowenFormat(block.Texts),
+	}}, nil
+}
+
+// 引用最多嵌套的层数
+const maxQuoteDepth = 5
+
+// convertQuoteBlock 引用段落
+func convertQuoteBlock(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error) {
+	node, err := convertQuote(block, 1)
+	if err != nil {
+		return nil, err
+	}
+	return []MowenContentNode{node}, nil
+}
+
+// convertQuote 转换引用块：只有texts时是单段引用，有多个段落或嵌套引用时每段输出为一个子段落节点
+func convertQuote(block *ContentBlock, depth int) (MowenContentNode, error) {
+	if len(block.Paragraphs) == 0 && len(block.Children) == 0 {
+		return MowenContentNode{
+			Type:    "quote",
+			Content: convertTextsToMowenFormat(block.Texts),
+		}, nil
+	}
+	if depth > maxQuoteDepth {
+		return MowenContentNode{}, fmt.Errorf("引用最多嵌套 %d 层", maxQuoteDepth)
+	}
+
+	node := MowenContentNode{Type: "quote"}
+	for _, texts := range append([][]TextNode{block.Texts}, block.Paragraphs...) {
+		if len(texts) > 0 {
+			node.Blocks = append(node.Blocks, MowenContentNode{Type: "paragraph", Content: convertTextsToMowenFormat(texts)})
+		}
+	}
+	for i := range block.Children {
+		child := &block.Children[i]
+		if child.Type != "quote" && child.Type != "" {
+			return MowenContentNode{}, fmt.Errorf("引用中只能嵌套引用，不支持 %s", child.Type)
+		}
+		nested, err := convertQuote(child, depth+1)
+		if err != nil {
+			return MowenContentNode{}, err
+		}
+		// 嵌套的单段引用也输出为段落子节点，保持结构一致
+		if len(nested.Blocks) == 0 {
+			nested.Blocks = []MowenContentNode{{Type: "paragraph", Content: nested.Content}}
+			nested.Content = nil
+		}
+		node.Blocks = append(node.Blocks, nested)
+	}
+	return node, nil
+}
+
+// blockTextNodes 返回内容块中全部文本节点的指针，包括引用的后续段落和嵌套引用
+func blockTextNodes(block *ContentBlock) []*TextNode {
+	var nodes []*TextNode
+	for i := range block.Texts {
+		nodes = append(nodes, &block.Texts[i])
+	}
+	for _, texts := range block.Paragraphs {
+		for i := range texts {
+			nodes = append(nodes, &texts[i])
+		}
+	}
+	for i := range block.Children {
+		nodes = append(nodes, blockTextNodes(&block.Children[i])...)
+	}
+	return nodes
+}
+
+// cloneBlockTexts 深拷贝内容块中的文本，修改副本的文字不会影响原内容块
+func cloneBlockTexts(block ContentBlock) ContentBlock {
+	block.Texts = append([]TextNode(nil), block.Texts...)
+	if block.Paragraphs != nil {
+		paragraphs := make([][]TextNode, len(block.Paragraphs))
+		for i, texts := range block.Paragraphs {
+			paragraphs[i] = append([]TextNode(nil), texts...)
+		}
+		block.Paragraphs = paragraphs
+	}
+	if block.Children != nil {
+		children := make([]ContentBlock, len(block.Children))
+		for i, child := range block.Children {
+			children[i] = cloneBlockTexts(child)
+		}
+		block.Children = children
+	}
+	return block
+}
+
+// 待办的勾选框前缀，墨问没有待办节点，以带勾选框的段落显示
+const (
+	todoUncheckedMark = "☐ "
+	todoCheckedMark   = "☑ "
+)
+
+// convertTodoBlock 待办段落
+func convertTodoBlock(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error) {
+	mark := todoUncheckedMark
+	if block.Checked {
+		mark = todoCheckedMark
+	}
+	content := append([]MowenTextNode{{Type: "text", Text: mark}}, convertTextsToMowenFormat(block.Texts)...)
+	return []MowenContentNode{{
+		Type:    "paragraph",
+		Content: content,
+	}}, nil
+}
+
+// convertDividerBlock 分隔线
+// 墨问API没有分隔线节点，输出一个空段落，在墨问中显示为空行；
+// 本地记录保留divider类型，导出HTML和Markdown时仍输出为分隔线
+func convertDividerBlock(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error) {
+	return []MowenContentNode{{Type: "paragraph"}}, nil
+}
+
+// isMarkdownRule 判断文本是否为Markdown分隔线：三个及以上的 -、* 或 _，可以夹杂空格
+func isMarkdownRule(texts []TextNode) bool {
+	var sb strings.Builder
+	for _, t := range texts {
+		sb.WriteString(t.Text)
+	}
+	line := strings.ReplaceAll(strings.TrimSpace(sb.String()), " ", "")
+	if len(line) < 3 {
+		return false
+	}
+	for _, mark := range []string{"-", "*", "_"} {
+		if strings.Trim(line, mark) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// convertNoteBlock 内链笔记
+func convertNoteBlock(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error) {
+	return []MowenContentNode{{
+		Type: "note",
+		Attrs: map[string]interface{}{
+			"uuid": block.NoteID,
+		},
+	}}, nil
+}
+
+// convertFileBlock 文件段落
+func convertFileBlock(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error) {
+	fileUUID, err := resolveFileID(ctx, client, block)
+	if err != nil {
+		return nil, err
+	}
+	// 图片没有替代文本时按配置自动生成，并随内容块保存到本地
+	ensureImageAltText(ctx, block)
 
-		case "file":
-			// 文件段落
-			switch block.FileType {
-			case "image":
-				var fileUUID string
-				var err error
-				if block.SourceType == "url" {
-					fileUUID, err = uploadFileFromURL(client, block.SourcePath, block.FileType, block.SourcePath)
-					if err != nil {
-						return doc, fmt.Errorf("通过 URL 上传图片文件失败: %w", err)
-					}
-				} else {
-					fileUUID, err = generateFileUUID(client, block.SourcePath)
-					if err != nil {
-						return doc, fmt.Errorf("上传本地图片文件失败: %w", err)
-					}
-				}
-				attrs := map[string]interface{}{
-					"uuid": fileUUID,
-				}
-				// 添加元数据
-				for key, value := range block.Metadata {
-					attrs[key] = value
-				}
-				doc.Content = append(doc.Content, MowenContentNode{
-					Type:  "image",
-					Attrs: attrs,
-				})
-
-			case "audio":
-				var fileUUID string
-				var err error
-				if block.SourceType == "url" {
-					fileUUID, err = uploadFileFromURL(client, block.SourcePath, block.FileType, block.SourcePath)
-					if err != nil {
-						return doc, fmt.Errorf("通过 URL 上传音频文件失败: %w", err)
-					}
-				} else {
-					fileUUID, err = generateFileUUID(client, block.SourcePath)
-					if err != nil {
-						return doc, fmt.Errorf("上传本地音频文件失败: %w", err)
-					}
-				}
-				attrs := map[string]interface{}{
-					"audio-uuid": fileUUID,
-				}
-				// 添加元数据
-				for key, value := range block.Metadata {
-					if key == "show_note" {
-						attrs["show-note"] = value
-					} else {
-						attrs[key] = value
-					}
-				}
-				doc.Content = append(doc.Content, MowenContentNode{
-					Type:  "audio",
-					Attrs: attrs,
-				})
-
-			case "pdf":
-				var fileUUID string
-				var err error
-				if block.SourceType == "url" {
-					fileUUID, err = uploadFileFromURL(client, block.SourcePath, block.FileType, filepath.Base(block.SourcePath))
-					if err != nil {
-						return doc, fmt.Errorf("通过 URL 上传PDF文件失败: %w", err)
-					}
-				} else {
-					fileUUID, err = generateFileUUID(client, block.SourcePath)
-					if err != nil {
-						return doc, fmt.Errorf("上传本地PDF文件失败: %w", err)
-					}
-				}
-				attrs := map[string]interface{}{
-					"uuid": fileUUID,
-				}
-				// 添加元数据
-				for key, value := range block.Metadata {
-					attrs[key] = value
-				}
-				doc.Content = append(doc.Content, MowenContentNode{
-					Type:  "pdf",
-					Attrs: attrs,
-				})
+	switch block.FileType {
+	case "image":
+		attrs := map[string]interface{}{
+			"uuid": fileUUID,
+		}
+		// 添加元数据
+		for key, value := range block.Metadata {
+			if !uploadOnlyMetadata[key] {
+				attrs[key] = value
 			}
+		}
+		return []MowenContentNode{{Type: "image", Attrs: attrs}}, nil
 
-		default:
-			// 普通段落（默认）
-			doc.Content = append(doc.Content, MowenContentNode{
-				Type:    "paragraph",
-				Content: convertTextsToMowenFormat(block.Texts),
-			})
+	case "audio":
+		attrs := map[string]interface{}{
+			"audio-uuid": fileUUID,
+		}
+		// 添加元数据
+		for key, value := range block.Metadata {
+			switch {
+			case uploadOnlyMetadata[key]:
+			case key == "show_note":
+				attrs["show-note"] = value
+			default:
+				attrs[key] = value
+			}
+		}
+		return []MowenContentNode{{Type: "audio", Attrs: attrs}}, nil
+
+	default:
+		attrs := map[string]interface{}{
+			"uuid": fileUUID,
 		}
+		// 添加元数据
+		for key, value := range block.Metadata {
+			if !uploadOnlyMetadata[key] {
+				attrs[key] = value
+			}
+		}
+		return []MowenContentNode{{Type: "pdf", Attrs: attrs}}, nil
 	}
+}
 
-	return doc, nil
+// 文件类型的中文名称，用于错误提示
+var fileTypeNames = map[string]string{
+	"image": "图片",
+	"audio": "音频",
+	"pdf":   "PDF",
+}
+
+// 文件块元数据中指定上传文件名的键
+const fileNameMetadataKey = "file_name"
+
+// 只用于控制上传过程的元数据，不会作为属性发送
+var uploadOnlyMetadata = map[string]bool{
+	fileNameMetadataKey: true,
+	convertMetadataKey:  true,
+}
+
+// 上传文件名的最大长度（字符数）
+const maxUploadFileNameRunes = 100
+
+// uploadFileName 上传时使用的文件名
+// 优先使用元数据中的file_name，否则取本地文件名或URL路径的最后一段，URL没有路径时使用主机名
+func uploadFileName(block *ContentBlock) string {
+	if name, ok := block.Metadata[fileNameMetadataKey].(string); ok {
+		if name = sanitizeFileName(name); name != "" {
+			return name
+		}
+	}
+
+	if block.SourceType != "url" {
+		return sanitizeFileName(localFileBase(block.SourcePath))
+	}
+	u, err := url.Parse(block.SourcePath)
+	if err != nil {
+		return fileTypeNames[block.FileType]
+	}
+	if name := sanitizeFileName(path.Base(u.Path)); name != "" && name != "." && name != "_" {
+		return name
+	}
+	return sanitizeFileName(u.Hostname() + " " + fileTypeNames[block.FileType])
+}
+
+// sanitizeFileName 替换文件名中的路径分隔符、控制字符和各系统不允许的字符，并限制长度
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.Trim(name, ". ")
+
+	if runes := []rune(name); len(runes) > maxUploadFileNameRunes {
+		// 截断时保留扩展名
+		ext := []rune(filepath.Ext(name))
+		if len(ext) >= maxUploadFileNameRunes {
+			ext = nil
+		}
+		name = string(runes[:maxUploadFileNameRunes-len(ext)]) + string(ext)
+	}
+	return name
+}
+
+// resolveFileID 获取文件块对应的文件ID
+// 已有file_id时直接复用，否则按来源类型上传文件，并把结果回写到内容块中，
+// 这样保存到本地的内容块带有file_id，后续追加、重新编辑时不会重复上传
+func resolveFileID(ctx context.Context, client *MowenClient, block *ContentBlock) (string, error) {
+	if block.FileID != "" {
+		return block.FileID, nil
+	}
+
+	typeName, ok := fileTypeNames[block.FileType]
+	if !ok {
+		return "", withErrorCode(ErrInvalidBlock, fmt.Errorf("不支持的文件类型: %s", block.FileType))
+	}
+
+	var fileUUID string
+	var err error
+	fileName := uploadFileName(block)
+	if block.SourceType == "url" {
+		// 本地文件在uploadLocalFile中执行上传前钩子
+		sourceURL, err := runBeforeUploadHook(ctx, block.FileType, block.SourceType, block.SourcePath)
+		if err != nil {
+			return "", err
+		}
+		fileUUID, err = uploadFileFromURL(ctx, client, sourceURL, block.FileType, fileName)
+		if err != nil {
+			return "", withErrorCode(ErrUploadFailed, fmt.Errorf("通过 URL 上传%s文件失败: %w", typeName, err))
+		}
+	} else {
+		convert, ok := block.Metadata[convertMetadataKey].(bool)
+		fileUUID, err = generateFileUUID(ctx, client, block.SourcePath, fileName, convert || !ok)
+		if err != nil {
+			return "", withErrorCode(ErrUploadFailed, fmt.Errorf("上传本地%s文件失败: %w", typeName, err))
+		}
+	}
+
+	block.FileID = fileUUID
+	return fileUUID, nil
 }
 
 // uploadFileFromURL 通过 URL 上传文件并返回文件 UUID
-func uploadFileFromURL(client *MowenClient, fileURL string, fileTypeStr string, fileName string) (string, error) {
+func uploadFileFromURL(ctx context.Context, client *MowenClient, fileURL string, fileTypeStr string, fileName string) (string, error) {
+	// 校验URL访问策略
+	if err := checkRemoteURL(fileURL); err != nil {
+		return "", err
+	}
+
 	var apiFileType int
 	switch fileTypeStr {
 	case "image":
@@ -199,6 +539,12 @@ func uploadFileFromURL(client *MowenClient, fileURL string, fileTypeStr string,
 		return "", fmt.Errorf("不支持的文件类型: %s", fileTypeStr)
 	}
 
+	// 确认文件可访问，且大小和内容类型与声明的一致，避免墨问服务器抓取后才报错
+	info, err := probeRemoteFile(ctx, fileURL, fileTypeStr)
+	if err != nil {
+		return "", err
+	}
+
 	payload := map[string]interface{}{
 		"fileType": apiFileType,
 		"url":      fileURL,
@@ -214,12 +560,16 @@ func uploadFileFromURL(client *MowenClient, fileURL string, fileTypeStr string,
 	}
 
 	// 从响应体中提取文件ID
-	uploadResp := resp.Body
+	if len(resp.SchemaIssues) > 0 {
+		return "", schemaDriftError(APIUploadFileByURL, resp.SchemaIssues)
+	}
+	fileID := lookupString(resp.Body, "file.fileId")
 
-	fileID, ok := uploadResp["file"].(map[string]interface{})["fileId"].(string)
-	if !ok {
-		return "", fmt.Errorf("上传文件响应中缺少 'fileId' 字段")
+	size := int64(-1)
+	if info != nil {
+		size = info.Size
 	}
+	recordAttachment(ctx, fileID, fileTypeStr, fileName, size)
 
 	return fileID, nil
 }
@@ -227,8 +577,18 @@ func uploadFileFromURL(client *MowenClient, fileURL string, fileTypeStr string,
 // convertTextsToMowenFormat 将文本节点列表转换为墨问格式
 func convertTextsToMowenFormat(texts []TextNode) []MowenTextNode {
 	result := make([]MowenTextNode, 0, len(texts))
+	expandEmoji := envBool(EmojiShortcodesEnvVar, false)
+	if envBool(LinkifyEnvVar, false) {
+		texts = linkifyTexts(texts)
+	}
+	if envBool(TypographyEnvVar, false) {
+		texts = typographyTexts(texts)
+	}
 
 	for _, text := range texts {
+		if expandEmoji {
+			text.Text = expandEmojiShortcodes(text.Text)
+		}
 		mowenText := MowenTextNode{
 			Type:  "text",
 			Text:  text.Text,
@@ -266,17 +626,75 @@ func convertTextsToMowenFormat(texts []TextNode) []MowenTextNode {
 }
 
 // generateFileUUID 上传文件并获取真实的UUID
-func generateFileUUID(client *MowenClient, filePath string) (string, error) {
+// convert为true时，墨问不支持的格式（HEIC、动态WebP）先转换再上传
+func generateFileUUID(ctx context.Context, client *MowenClient, filePath string, fileName string, convert bool) (string, error) {
+	// 校验文件是否位于允许访问的目录中
+	filePath, err := checkLocalPath(ctx, filePath)
+	if err != nil {
+		return "", err
+	}
+	return uploadLocalFile(ctx, client, filePath, fileName, convert)
+}
+
+// runBeforeUploadHook 执行上传前钩子，返回钩子替换后的文件路径或URL（例如先做脱敏处理）
+func runBeforeUploadHook(ctx context.Context, fileType, sourceType, sourcePath string) (string, error) {
+	event := &HookEvent{
+		Hook:       HookBeforeUpload,
+		FileType:   fileType,
+		SourceType: sourceType,
+		SourcePath: sourcePath,
+	}
+	if err := runHooks(ctx, event); err != nil {
+		return "", err
+	}
+	return event.SourcePath, nil
+}
+
+// uploadLocalFile 上传已通过校验的本地文件，返回文件UUID
+// 服务自己生成的临时文件（例如剪贴板图片）不在沙箱目录中，直接调用这里上传
+// 所有本地文件的上传都经过这里，上传前钩子在这里执行
+func uploadLocalFile(ctx context.Context, client *MowenClient, filePath string, fileName string, convert bool) (string, error) {
+	typeKey := ""
+	if fileType, err := getFileTypeFromPath(filePath); err == nil {
+		typeKey = fileTypeKeys[fileType]
+	}
+	filePath, err := runBeforeUploadHook(ctx, typeKey, "local", filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if convert {
+		convertedPath, cleanup, err := convertFileForUpload(ctx, filePath)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		if convertedPath != filePath {
+			fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + filepath.Ext(convertedPath)
+			filePath = convertedPath
+		}
+	}
+
 	// 根据文件扩展名确定文件类型
 	fileType, err := getFileTypeFromPath(filePath)
 	if err != nil {
 		return "", fmt.Errorf("无法确定文件类型: %w", err)
 	}
 
+	// 图片上传前去除元数据并修正方向
+	if fileType == 1 {
+		preparedPath, cleanup, err := prepareImageForUpload(filePath)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		filePath = preparedPath
+	}
+
 	// 获取上传授权信息
 	uploadPrepareReq := &UploadPrepareRequest{
 		FileType: fileType,
-		FileName: filepath.Base(filePath),
+		FileName: fileName,
 	}
 
 	uploadPrepareResp, err := client.UploadPrepare(uploadPrepareReq)
@@ -285,7 +703,7 @@ func generateFileUUID(client *MowenClient, filePath string) (string, error) {
 	}
 
 	// 上传文件
-	uploadResp, err := client.UploadFile(uploadPrepareResp.Form, filePath)
+	uploadResp, err := uploadFileWithRetry(ctx, client, uploadPrepareResp.Form, filePath)
 	if err != nil {
 		return "", fmt.Errorf("文件上传失败: %w", err)
 	}
@@ -296,19 +714,32 @@ func generateFileUUID(client *MowenClient, filePath string) (string, error) {
 	}
 
 	// 从上传响应中提取文件UUID
-	var fileUUID string
-	if uploadResp.Body != nil {
-		fileUUID = uploadResp.Body["file"].(map[string]interface{})["fileId"].(string)
+	if len(uploadResp.SchemaIssues) > 0 {
+		return "", schemaDriftError(apiUploadFile, uploadResp.SchemaIssues)
 	}
+	fileUUID := lookupString(uploadResp.Body, "file.fileId")
 
 	// 如果仍然没有UUID，返回错误
 	if fileUUID == "" {
 		return "", fmt.Errorf("无法从上传响应中获取文件UUID，响应: %s", uploadResp.RawBody)
 	}
 
+	size := int64(-1)
+	if info, err := os.Stat(filePath); err == nil {
+		size = info.Size()
+	}
+	recordAttachment(ctx, fileUUID, fileTypeKeys[fileType], fileName, size)
+
 	return fileUUID, nil
 }
 
+// fileTypeKeys 墨问API文件类型编号对应的文件类型
+var fileTypeKeys = map[int]string{
+	1: "image",
+	2: "audio",
+	3: "pdf",
+}
+
 // getFileTypeFromPath 根据文件路径确定文件类型
 func getFileTypeFromPath(filePath string) (int, error) {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConvertDivider(t *testing.T) {
+	tests := []struct {
+		name  string
+		block ContentBlock
+	}{
+		{"divider段落", ContentBlock{Type: "divider"}},
+		{"只有---的段落", ContentBlock{Texts: []TextNode{{Text: "---"}}}},
+		{"只有***的段落", ContentBlock{Type: "paragraph", Texts: []TextNode{{Text: "* * *"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ConvertToMowenFormat(context.Background(), nil, []ContentBlock{
+				{Texts: []TextNode{{Text: "上一节"}}},
+				tt.block,
+				{Texts: []TextNode{{Text: "下一节"}}},
+			})
+			if err != nil {
+				t.Fatalf("转换失败: %v", err)
+			}
+			// 段落之间各有一个空段落，分隔线本身也是一个空段落
+			if len(doc.Content) != 5 {
+				t.Fatalf("转换出 %d 个节点，期望 5 个: %+v", len(doc.Content), doc.Content)
+			}
+			divider := doc.Content[2]
+			if divider.Type != "paragraph" || len(divider.Content) != 0 {
+				t.Errorf("分隔线转换为 %+v，期望空段落", divider)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// HTTP多租户模式下是否允许生成调试包，调试包包含整个服务的配置和日志，默认关闭
+const DebugBundleEnvVar = "MOWEN_DEBUG_BUNDLE"
+
+// 调试包中读取日志的最大字节数
+const maxBundleLogBytes = 1 << 20
+
+// 进程启动时间，用于统计运行时长
+var processStart = time.Now()
+
+// sensitiveEnvMarkers 名称中包含这些词的环境变量视为密钥
+var sensitiveEnvMarkers = []string{"KEY", "TOKEN", "SECRET", "PASSWORD"}
+
+// redactText 脱敏文本中的密钥、卡号、证件号和邮箱，以及当前配置的API密钥
+func redactText(text string) string {
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if isSensitiveEnv(name) && len(value) >= 8 {
+			text = strings.ReplaceAll(text, value, maskSecret(value))
+		}
+	}
+	text, _ = scanPII(piiDetectors, text, true)
+	return text
+}
+
+// isSensitiveEnv 判断环境变量是否为密钥
+func isSensitiveEnv(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, marker := range sensitiveEnvMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleConfig 本服务相关的环境变量，密钥已脱敏
+func bundleConfig() string {
+	var lines []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, "MOWEN_") {
+			continue
+		}
+		if isSensitiveEnv(name) {
+			value = maskSecret(value)
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		return "未设置任何 MOWEN_ 环境变量\n"
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// bundleSchema 数据库版本和表结构
+func bundleSchema() string {
+	if err := InitSQLite(); err != nil {
+		return fmt.Sprintf("SQLite初始化失败: %v\n", err)
+	}
+
+	var sb strings.Builder
+	var sqliteVersion string
+	var userVersion int
+	sqliteDB.QueryRow("SELECT sqlite_version()").Scan(&sqliteVersion)
+	sqliteDB.QueryRow("PRAGMA user_version").Scan(&userVersion)
+	sb.WriteString(fmt.Sprintf("SQLite版本: %s\nuser_version: %d\n", sqliteVersion, userVersion))
+
+	rows, err := sqliteDB.Query("SELECT name, sql FROM sqlite_master WHERE type = 'table' AND sql IS NOT NULL ORDER BY name")
+	if err != nil {
+		sb.WriteString(fmt.Sprintf("读取表结构失败: %v\n", err))
+		return sb.String()
+	}
+	type table struct{ name, sql string }
+	var tables []table
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.name, &t.sql); err == nil {
+			tables = append(tables, t)
+		}
+	}
+	rows.Close()
+
+	for _, t := range tables {
+		var count int
+		sqliteDB.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, t.name)).Scan(&count)
+		sb.WriteString(fmt.Sprintf("\n-- %s（%d 行）\n%s;\n", t.name, count, t.sql))
+	}
+	return sb.String()
+}
+
+// bundleDiagnostics 运行环境信息
+func bundleDiagnostics() string {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	executable, _ := os.Executable()
+	workDir, _ := os.Getwd()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("生成时间: %s\n", time.Now().Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("服务版本: %s\n", Version))
+	sb.WriteString(fmt.Sprintf("Go版本: %s\n", runtime.Version()))
+	sb.WriteString(fmt.Sprintf("系统: %s/%s，CPU: %d\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU()))
+	sb.WriteString(fmt.Sprintf("运行时长: %s\n", time.Since(processStart).Round(time.Second)))
+	sb.WriteString(fmt.Sprintf("Goroutine数: %d\n", runtime.NumGoroutine()))
+	sb.WriteString(fmt.Sprintf("内存: 已分配 %.1f MB，系统 %.1f MB\n", float64(mem.Alloc)/1024/1024, float64(mem.Sys)/1024/1024))
+	sb.WriteString(fmt.Sprintf("可执行文件: %s\n工作目录: %s\n", executable, workDir))
+	if dbPath, err := databasePath(); err == nil {
+		if info, err := os.Stat(dbPath); err == nil {
+			sb.WriteString(fmt.Sprintf("数据库: %s（%.1f MB）\n", dbPath, float64(info.Size())/1024/1024))
+		} else {
+			sb.WriteString(fmt.Sprintf("数据库: %s（不存在）\n", dbPath))
+		}
+	}
+	sb.WriteString("审计记录: 当前版本没有审计表，未包含\n")
+	return sb.String()
+}
+
+// bundleLogs 读取日志文件末尾的若干行并脱敏
+func bundleLogs(lines int) string {
+	path := envString(LogFileEnvVar, "")
+	if path == "" {
+		return fmt.Sprintf("日志输出到标准错误，未包含。设置 %s 后可以在调试包中附带日志。\n", LogFileEnvVar)
+	}
+	file, err := os.Open(expandPath(context.Background(), path))
+	if err != nil {
+		return fmt.Sprintf("打开日志文件失败: %v\n", err)
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() > maxBundleLogBytes {
+		file.Seek(info.Size()-maxBundleLogBytes, io.SeekStart)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Sprintf("读取日志文件失败: %v\n", err)
+	}
+	all := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return redactText(strings.Join(all, "\n")) + "\n"
+}
+
+// writeDebugBundle 把各部分写入zip文件
+func writeDebugBundle(path string, files map[string]string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建调试包失败: %v", err)
+	}
+	defer out.Close()
+
+	writer := zip.NewWriter(out)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return fmt.Errorf("写入 %s 失败: %v", name, err)
+		}
+		if _, err = io.WriteString(w, files[name]); err != nil {
+			return fmt.Errorf("写入 %s 失败: %v", name, err)
+		}
+	}
+	return writer.Close()
+}
+
+// GenerateDebugBundle 生成用于反馈问题的调试包
+func GenerateDebugBundle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if tenantFromContext(ctx) != "" && !envBool(DebugBundleEnvVar, false) {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 多租户模式下默认不允许生成调试包，请由服务管理员设置 %s=true", DebugBundleEnvVar)), nil
+	}
+
+	args := request.Params.Arguments
+	outputDir, _ := args["output_dir"].(string)
+	sandboxed := outputDir != ""
+	if !sandboxed {
+		outputDir = os.TempDir()
+	} else {
+		// 指定目录时校验沙箱，与导出文件一致
+		dir, err := checkWritePath(ctx, outputDir)
+		if err != nil {
+			return errorResult("输出目录不可用", err), nil
+		}
+		outputDir = dir
+	}
+	logLines := 500
+	if v, ok := args["log_lines"].(float64); ok && v >= 1 {
+		logLines = int(v)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return errorResult("创建目录失败", err), nil
+	}
+	path := filepath.Join(outputDir, fmt.Sprintf("mowen-debug-%s.zip", time.Now().Format("20060102-150405")))
+	if sandboxed {
+		// 目录本身可能是指向沙箱外的符号链接，创建后按最终文件路径再校验一次
+		resolved, err := checkWritePath(ctx, path)
+		if err != nil {
+			return errorResult("输出目录不可用", err), nil
+		}
+		path = resolved
+	}
+	files := map[string]string{
+		"diagnostics.txt": bundleDiagnostics(),
+		"config.txt":      bundleConfig(),
+		"schema.sql":      bundleSchema(),
+		"logs.txt":        bundleLogs(logLines),
+	}
+	if err := writeDebugBundle(path, files); err != nil {
+		return errorResult("", err), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("📦 调试包已生成: %s\n\n包含: 运行环境、配置（密钥已脱敏）、数据库表结构、最近 %d 行日志（已脱敏）。\n提交问题时可以附上该文件，发送前建议先检查内容。", path, logLines)), nil
+}
+
+// 生成调试包工具
+var GenerateDebugBundleTool = mcp.NewTool("generate_debug_bundle",
+	mcp.WithDescription("生成用于反馈问题的调试包（zip），包含运行环境、服务配置（密钥已脱敏）、数据库表结构和最近的日志（已脱敏），返回文件路径"),
+	mcp.WithString("output_dir",
+		mcp.Description("调试包保存目录，默认系统临时目录"),
+	),
+	mcp.WithNumber("log_lines",
+		mcp.Description("附带的日志行数，默认500"),
+	),
+)
+
+func generateDebugBundleHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return GenerateDebugBundle(ctx, request)
+}
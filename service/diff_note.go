@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 抓取diff的大小上限
+const maxDiffSize = 10 << 20
+
+// 笔记中最多保存的diff行数，超出部分只保留文件标题
+const maxDiffLines = 2000
+
+var (
+	githubPullPattern  = regexp.MustCompile(`^/([^/]+)/([^/]+)/pull/(\d+)`)
+	gitlabMergePattern = regexp.MustCompile(`^/(.+)/-/merge_requests/(\d+)`)
+	hunkHeaderPattern  = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+)
+
+// diffFile diff中的一个文件
+type diffFile struct {
+	Path      string
+	Lines     []string // 包括@@开头的hunk标题
+	Additions int
+	Deletions int
+}
+
+// resolveDiffURL 把GitHub PR和GitLab MR的页面地址转换为diff地址，并返回显示用的名称；其他地址原样抓取
+func resolveDiffURL(rawURL string) (diffURL, name string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", fmt.Errorf("无效的URL: %s", rawURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "github.com" {
+		if m := githubPullPattern.FindStringSubmatch(u.Path); m != nil {
+			return fmt.Sprintf("https://github.com/%s/%s/pull/%s.diff", m[1], m[2], m[3]), fmt.Sprintf("%s/%s#%s", m[1], m[2], m[3]), nil
+		}
+	}
+	if m := gitlabMergePattern.FindStringSubmatch(u.Path); m != nil {
+		return fmt.Sprintf("%s://%s/%s/-/merge_requests/%s.diff", u.Scheme, u.Host, m[1], m[2]), fmt.Sprintf("%s!%s", m[1], m[2]), nil
+	}
+	return rawURL, "", nil
+}
+
+// diffPath 从 "--- a/x" 或 "+++ b/x" 行中取出文件路径，/dev/null返回空
+func diffPath(line string) string {
+	path := strings.TrimSpace(line[4:])
+	if i := strings.IndexByte(path, '\t'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
+
+// hunkLineCounts 从hunk标题中读取旧文件和新文件的行数，省略时为1
+func hunkLineCounts(header string) (oldLines, newLines int, ok bool) {
+	m := hunkHeaderPattern.FindStringSubmatch(header)
+	if m == nil {
+		return 0, 0, false
+	}
+	oldLines, newLines = 1, 1
+	if m[1] != "" {
+		oldLines, _ = strconv.Atoi(m[1])
+	}
+	if m[2] != "" {
+		newLines, _ = strconv.Atoi(m[2])
+	}
+	return oldLines, newLines, true
+}
+
+// parseUnifiedDiff 按文件拆分unified diff，统计每个文件的增删行数
+func parseUnifiedDiff(diff string) []*diffFile {
+	var files []*diffFile
+	var current *diffFile
+	// 按hunk标题中的行数判断hunk何时结束，避免把下一个文件的---行算作删除
+	oldLeft, newLeft := 0, 0
+	inHunk := func() bool { return oldLeft > 0 || newLeft > 0 }
+	for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = &diffFile{}
+			if i := strings.LastIndex(line, " b/"); i >= 0 {
+				current.Path = line[i+3:]
+			}
+			files = append(files, current)
+			oldLeft, newLeft = 0, 0
+		case !inHunk() && strings.HasPrefix(line, "--- "):
+			// 没有diff --git行的普通unified diff，以---行开始一个文件
+			if current == nil || len(current.Lines) > 0 {
+				current = &diffFile{}
+				files = append(files, current)
+			}
+			if path := diffPath(line); path != "" {
+				current.Path = path
+			}
+		case !inHunk() && strings.HasPrefix(line, "+++ "):
+			if current != nil {
+				if path := diffPath(line); path != "" {
+					current.Path = path
+				}
+			}
+		case !inHunk() && strings.HasPrefix(line, "@@"):
+			oldCount, newCount, ok := hunkLineCounts(line)
+			if !ok {
+				continue
+			}
+			if current == nil {
+				current = &diffFile{}
+				files = append(files, current)
+			}
+			current.Lines = append(current.Lines, line)
+			oldLeft, newLeft = oldCount, newCount
+		case inHunk():
+			// 部分工具会去掉空上下文行开头的空格
+			if line == "" {
+				line = " "
+			}
+			switch line[0] {
+			case '+':
+				current.Additions++
+				newLeft--
+			case '-':
+				current.Deletions++
+				oldLeft--
+			case ' ':
+				oldLeft--
+				newLeft--
+			case '\\':
+				continue
+			default:
+				oldLeft, newLeft = 0, 0
+				continue
+			}
+			current.Lines = append(current.Lines, line)
+		}
+	}
+
+	var result []*diffFile
+	for _, file := range files {
+		if len(file.Lines) == 0 {
+			continue
+		}
+		if file.Path == "" {
+			file.Path = "未知文件"
+		}
+		result = append(result, file)
+	}
+	return result
+}
+
+// buildDiffBlocks 生成评审笔记：每个文件一个加粗标题，代码行保存为引用块，hunk标题高亮
+func buildDiffBlocks(title, source, summary string, files []*diffFile) ([]ContentBlock, bool) {
+	additions, deletions := 0, 0
+	for _, file := range files {
+		additions += file.Additions
+		deletions += file.Deletions
+	}
+	blocks := []ContentBlock{
+		{Texts: []TextNode{{Text: title, Bold: true}}},
+		{Texts: []TextNode{{Text: fmt.Sprintf("%d 个文件 · +%d −%d · %s", len(files), additions, deletions, time.Now().Format("2006-01-02 15:04"))}}},
+	}
+	if source != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "来源: "}, {Text: source, Link: source}}})
+	}
+	if summary != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "评审摘要", Bold: true}}})
+		for _, line := range splitLines(summary) {
+			blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: line}}})
+		}
+	}
+
+	written, truncated := 0, false
+	for _, file := range files {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: fmt.Sprintf("📄 %s  +%d −%d", file.Path, file.Additions, file.Deletions), Bold: true}}})
+		if written >= maxDiffLines {
+			truncated = true
+			continue
+		}
+		for _, line := range file.Lines {
+			if written >= maxDiffLines {
+				truncated = true
+				break
+			}
+			node := TextNode{Text: line}
+			if strings.HasPrefix(line, "@@") {
+				node.Highlight = true
+			}
+			blocks = append(blocks, ContentBlock{Type: "quote", Texts: []TextNode{node}})
+			written++
+		}
+	}
+	if truncated {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: fmt.Sprintf("（diff超过 %d 行，其余内容未保存）", maxDiffLines)}}})
+	}
+	return blocks, truncated
+}
+
+// SaveDiffNote 把代码diff或PR保存为评审笔记
+func SaveDiffNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	diff, _ := args["diff"].(string)
+	rawURL, _ := args["url"].(string)
+	rawURL = strings.TrimSpace(rawURL)
+	if strings.TrimSpace(diff) == "" && rawURL == "" {
+		return mcp.NewToolResultText("❌ diff和url至少需要传入一个"), nil
+	}
+	summary, _ := args["summary"].(string)
+	title, _ := args["title"].(string)
+	title = strings.TrimSpace(title)
+	tags := []string{"代码评审"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+
+	// 传入diff时不抓取，url只作为来源链接
+	name := ""
+	if strings.TrimSpace(diff) == "" {
+		diffURL, prName, err := resolveDiffURL(rawURL)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		data, err := fetchRemoteBody(ctx, diffURL, maxDiffSize)
+		if err != nil {
+			return errorResult("获取diff失败", err), nil
+		}
+		diff, name = string(data), prName
+	}
+	files := parseUnifiedDiff(diff)
+	if len(files) == 0 {
+		return mcp.NewToolResultText("❌ 没有解析到diff内容，请传入unified diff格式的文本"), nil
+	}
+	if title == "" {
+		if name != "" {
+			title = "🔍 代码评审 " + name
+		} else {
+			title = "🔍 代码评审 " + time.Now().Format("2006-01-02")
+		}
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	blocks, truncated := buildDiffBlocks(title, rawURL, strings.TrimSpace(summary), files)
+	noteID, err := createNoteFromBlocks(ctx, client, blocks, &Settings{Tags: tags})
+	if err != nil {
+		return errorResult("创建评审笔记失败", err), nil
+	}
+	if noteID == "" {
+		noteID = "未知ID"
+	} else {
+		sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	}
+
+	resultText := fmt.Sprintf("✅ 评审笔记已保存！\n\n标题: %s\n笔记ID: %s\n文件数: %d", title, noteID, len(files))
+	for _, file := range files {
+		resultText += fmt.Sprintf("\n  %s  +%d −%d", file.Path, file.Additions, file.Deletions)
+	}
+	if truncated {
+		resultText += fmt.Sprintf("\n⚠️ diff超过 %d 行，其余内容未保存", maxDiffLines)
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 保存代码评审笔记工具
+var SaveDiffNoteTool = mcp.NewTool("save_diff_note",
+	mcp.WithDescription("把代码变更保存为评审笔记：传入unified diff文本或PR链接（GitHub PR、GitLab MR或直接指向diff的地址），按文件生成标题和增删统计，代码行保存为引用块，可附上评审摘要。"),
+	mcp.WithString("diff",
+		mcp.Description("unified diff文本，例如git diff的输出，与url至少传一个"),
+	),
+	mcp.WithString("url",
+		mcp.Description("PR/MR链接或diff文件地址；同时传入diff时只作为来源链接"),
+	),
+	mcp.WithString("title",
+		mcp.Description("笔记标题，默认为\"🔍 代码评审\"加PR名称或日期"),
+	),
+	mcp.WithString("summary",
+		mcp.Description("评审摘要，显示在代码之前"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组，默认 [\"代码评审\"]"),
+	),
+)
+
+func saveDiffNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := SaveDiffNote(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
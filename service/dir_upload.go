@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 目录上传一次最多附加的文件数环境变量，默认20
+const DirUploadMaxFilesEnvVar = "MOWEN_DIR_UPLOAD_MAX_FILES"
+
+// expandDirectoryBlocks 把source_type为dir的文件块展开为目录中每个匹配文件各自的文件块
+// 文件按名称排序，保存到本地的是展开后的内容块，后续编辑时不会重新扫描目录
+func expandDirectoryBlocks(ctx context.Context, blocks []ContentBlock) ([]ContentBlock, error) {
+	expanded := make([]ContentBlock, 0, len(blocks))
+	for i, block := range blocks {
+		if block.Type != "file" || block.SourceType != "dir" {
+			expanded = append(expanded, block)
+			continue
+		}
+		files, err := directoryFileBlocks(ctx, block)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个段落: %w", i+1, err)
+		}
+		expanded = append(expanded, files...)
+	}
+	return expanded, nil
+}
+
+// directoryFileBlocks 列出目录中匹配的文件，生成本地文件块
+// 只匹配目录下一层的文件，不递归；file_type为空时按扩展名推断并跳过不支持的文件，
+// 指定file_type时只保留该类型的文件
+func directoryFileBlocks(ctx context.Context, block ContentBlock) ([]ContentBlock, error) {
+	dir, err := checkLocalPath(ctx, block.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", block.SourcePath)
+	}
+
+	pattern := block.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	if strings.ContainsAny(pattern, `/\`) {
+		return nil, fmt.Errorf("通配符 %s 只能匹配文件名，不能包含路径", pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("通配符 %s 格式错误: %w", pattern, err)
+	}
+
+	// os.ReadDir 返回的条目已按文件名排序
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %w", err)
+	}
+	var files []ContentBlock
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || (strings.HasPrefix(name, ".") && !strings.HasPrefix(pattern, ".")) {
+			continue
+		}
+		// 忽略大小写，*.png 同样匹配 IMG_001.PNG
+		if ok, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(name)); !ok {
+			continue
+		}
+		typeKey, err := getFileTypeFromPath(name)
+		if err != nil {
+			continue
+		}
+		fileType := fileTypeKeys[typeKey]
+		if block.FileType != "" && block.FileType != fileType {
+			continue
+		}
+		files = append(files, ContentBlock{
+			Type:       "file",
+			FileType:   fileType,
+			SourceType: "local",
+			SourcePath: filepath.Join(dir, name),
+			Metadata:   directoryFileMetadata(block.Metadata),
+		})
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("目录 %s 中没有匹配 %s 的图片、音频或PDF文件", block.SourcePath, pattern)
+	}
+	if limit := envInt(DirUploadMaxFilesEnvVar, 20); len(files) > limit {
+		return nil, fmt.Errorf("目录 %s 中匹配 %s 的文件有 %d 个，超过单次上限 %d 个，请缩小匹配范围", block.SourcePath, pattern, len(files), limit)
+	}
+	return files, nil
+}
+
+// directoryFileMetadata 复制目录块的元数据给每个文件，file_name只对单个文件有意义，不复制
+func directoryFileMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(metadata))
+	for key, value := range metadata {
+		if key != fileNameMetadataKey {
+			copied[key] = value
+		}
+	}
+	return copied
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 为true时把文本中 :smile: 形式的表情短代码替换为Unicode表情，默认false
+const EmojiShortcodesEnvVar = "MOWEN_EMOJI_SHORTCODES"
+
+var emojiShortcodePattern = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// emojiShortcodes 常用的GitHub/Slack表情短代码，未收录的短代码保持原样
+var emojiShortcodes = map[string]string{
+	"smile": "😄", "smiley": "😃", "grin": "😁", "grinning": "😀", "laughing": "😆", "joy": "😂",
+	"rofl": "🤣", "sweat_smile": "😅", "wink": "😉", "blush": "😊", "innocent": "😇", "slightly_smiling_face": "🙂",
+	"upside_down_face": "🙃", "heart_eyes": "😍", "star_struck": "🤩", "kissing_heart": "😘", "yum": "😋",
+	"stuck_out_tongue": "😛", "thinking": "🤔", "neutral_face": "😐", "expressionless": "😑", "no_mouth": "😶",
+	"smirk": "😏", "unamused": "😒", "roll_eyes": "🙄", "grimacing": "😬", "relieved": "😌", "pensive": "😔",
+	"sleepy": "😪", "sleeping": "😴", "mask": "😷", "nerd_face": "🤓", "sunglasses": "😎", "confused": "😕",
+	"worried": "😟", "frowning": "☹️", "open_mouth": "😮", "astonished": "😲", "flushed": "😳", "pleading_face": "🥺",
+	"cry": "😢", "sob": "😭", "scream": "😱", "confounded": "😖", "disappointed": "😞", "sweat": "😓",
+	"weary": "😩", "tired_face": "😫", "yawning_face": "🥱", "triumph": "😤", "rage": "😡", "angry": "😠",
+	"skull": "💀", "poop": "💩", "clown_face": "🤡", "ghost": "👻", "alien": "👽", "robot": "🤖",
+	"see_no_evil": "🙈", "hear_no_evil": "🙉", "speak_no_evil": "🙊",
+	"wave": "👋", "raised_hand": "✋", "ok_hand": "👌", "v": "✌️", "crossed_fingers": "🤞", "point_up": "☝️",
+	"point_down": "👇", "point_left": "👈", "point_right": "👉", "+1": "👍", "thumbsup": "👍", "-1": "👎",
+	"thumbsdown": "👎", "fist": "✊", "punch": "👊", "clap": "👏", "raised_hands": "🙌", "pray": "🙏",
+	"handshake": "🤝", "muscle": "💪", "eyes": "👀", "brain": "🧠", "writing_hand": "✍️",
+	"heart": "❤️", "orange_heart": "🧡", "yellow_heart": "💛", "green_heart": "💚", "blue_heart": "💙",
+	"purple_heart": "💜", "black_heart": "🖤", "broken_heart": "💔", "sparkling_heart": "💖", "100": "💯",
+	"boom": "💥", "collision": "💥", "dizzy": "💫", "zzz": "💤", "speech_balloon": "💬", "thought_balloon": "💭",
+	"fire": "🔥", "sparkles": "✨", "star": "⭐", "star2": "🌟", "zap": "⚡", "sunny": "☀️", "cloud": "☁️",
+	"umbrella": "☔", "snowflake": "❄️", "rainbow": "🌈", "ocean": "🌊", "earth_asia": "🌏", "moon": "🌙",
+	"seedling": "🌱", "herb": "🌿", "four_leaf_clover": "🍀", "cherry_blossom": "🌸", "rose": "🌹", "sunflower": "🌻",
+	"tada": "🎉", "confetti_ball": "🎊", "balloon": "🎈", "gift": "🎁", "trophy": "🏆", "medal_sports": "🏅",
+	"dart": "🎯", "game_die": "🎲", "art": "🎨", "musical_note": "🎵", "notes": "🎶", "headphones": "🎧",
+	"coffee": "☕", "tea": "🍵", "beer": "🍺", "wine_glass": "🍷", "pizza": "🍕", "hamburger": "🍔",
+	"cake": "🍰", "apple": "🍎", "rice": "🍚", "ramen": "🍜",
+	"rocket": "🚀", "airplane": "✈️", "car": "🚗", "bike": "🚲", "house": "🏠", "office": "🏢",
+	"computer": "💻", "keyboard": "⌨️", "iphone": "📱", "phone": "☎️", "camera": "📷", "tv": "📺",
+	"bulb": "💡", "battery": "🔋", "electric_plug": "🔌", "gear": "⚙️", "wrench": "🔧", "hammer": "🔨",
+	"hammer_and_wrench": "🛠️", "nut_and_bolt": "🔩", "link": "🔗", "paperclip": "📎", "pushpin": "📌",
+	"round_pushpin": "📍", "lock": "🔒", "unlock": "🔓", "key": "🔑", "bell": "🔔", "mag": "🔍",
+	"book": "📖", "books": "📚", "bookmark": "🔖", "memo": "📝", "pencil": "📝", "pencil2": "✏️",
+	"page_facing_up": "📄", "clipboard": "📋", "calendar": "📅", "date": "📅", "chart_with_upwards_trend": "📈",
+	"chart_with_downwards_trend": "📉", "bar_chart": "📊", "file_folder": "📁", "open_file_folder": "📂",
+	"package": "📦", "email": "📧", "envelope": "✉️", "inbox_tray": "📥", "outbox_tray": "📤", "moneybag": "💰",
+	"dollar": "💵", "credit_card": "💳", "hourglass": "⌛", "alarm_clock": "⏰", "stopwatch": "⏱️", "watch": "⌚",
+	"white_check_mark": "✅", "heavy_check_mark": "✔️", "ballot_box_with_check": "☑️", "x": "❌",
+	"negative_squared_cross_mark": "❎", "warning": "⚠️", "no_entry": "⛔", "no_entry_sign": "🚫",
+	"question": "❓", "grey_question": "❔", "exclamation": "❗", "bangbang": "‼️", "information_source": "ℹ️",
+	"heavy_plus_sign": "➕", "heavy_minus_sign": "➖", "arrow_right": "➡️", "arrow_left": "⬅️", "arrow_up": "⬆️",
+	"arrow_down": "⬇️", "arrows_counterclockwise": "🔄", "repeat": "🔁", "new": "🆕", "ok": "🆗", "sos": "🆘",
+	"red_circle": "🔴", "green_circle": "🟢", "yellow_circle": "🟡", "blue_circle": "🔵",
+	"white_circle": "⚪", "black_circle": "⚫", "triangular_flag_on_post": "🚩", "checkered_flag": "🏁",
+	"construction": "🚧", "bug": "🐛", "lady_beetle": "🐞", "dog": "🐶", "cat": "🐱", "panda_face": "🐼",
+	"penguin": "🐧", "bee": "🐝", "turtle": "🐢", "snake": "🐍", "unicorn": "🦄",
+}
+
+// expandEmojiShortcodes 把文本中已收录的表情短代码替换为Unicode表情
+func expandEmojiShortcodes(text string) string {
+	if !strings.Contains(text, ":") {
+		return text
+	}
+	var sb strings.Builder
+	for {
+		loc := emojiShortcodePattern.FindStringSubmatchIndex(text)
+		if loc == nil {
+			sb.WriteString(text)
+			return sb.String()
+		}
+		if emoji, ok := emojiShortcodes[text[loc[2]:loc[3]]]; ok {
+			sb.WriteString(text[:loc[0]])
+			sb.WriteString(emoji)
+			text = text[loc[1]:]
+			continue
+		}
+		// 未收录时结尾的冒号可能是下一个短代码的开头，例如 12:30:smile:
+		sb.WriteString(text[:loc[1]-1])
+		text = text[loc[1]-1:]
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ErrorCode 工具失败结果的错误码，供调用方按错误类型处理，不必匹配中文提示
+type ErrorCode string
+
+const (
+	ErrAuthFailed       ErrorCode = "AUTH_FAILED"       // API密钥缺失或无效
+	ErrQuotaExceeded    ErrorCode = "QUOTA_EXCEEDED"    // 超出墨问的调用配额或频率限制
+	ErrInvalidBlock     ErrorCode = "INVALID_BLOCK"     // 内容块格式错误或类型不支持
+	ErrInvalidArgument  ErrorCode = "INVALID_ARGUMENT"  // 其他参数缺失或格式错误
+	ErrUploadFailed     ErrorCode = "UPLOAD_FAILED"     // 文件读取或上传失败
+	ErrNotFound         ErrorCode = "NOT_FOUND"         // 笔记、文件或记录不存在
+	ErrConflict         ErrorCode = "CONFLICT"          // 与已有数据冲突
+	ErrPermissionDenied ErrorCode = "PERMISSION_DENIED" // 路径不在允许访问的目录中，或被策略拒绝
+	ErrTimeout          ErrorCode = "TIMEOUT"           // 请求超时
+	ErrNetwork          ErrorCode = "NETWORK_ERROR"     // 无法连接墨问或远程地址
+	ErrAPIChanged       ErrorCode = "API_CHANGED"       // 墨问API响应格式与预期不符
+	ErrUpstream         ErrorCode = "UPSTREAM_ERROR"    // 墨问API返回服务端错误
+	ErrStorage          ErrorCode = "STORAGE_ERROR"     // 本地数据库不可用或读写失败
+	ErrInternal         ErrorCode = "INTERNAL_ERROR"    // 其他未归类的错误
+)
+
+// errorMetaKey 结果元数据中错误码的键名
+const errorMetaKey = "error_code"
+
+// codedError 在创建错误的地方附带错误码，经过多层%w包装后仍可用errors.As取出
+type codedError struct {
+	Code ErrorCode
+	Err  error
+}
+
+func (e *codedError) Error() string { return e.Err.Error() }
+
+func (e *codedError) Unwrap() error { return e.Err }
+
+// withErrorCode 为错误附带错误码；错误已经带有错误码时保留原来的，越靠近源头的判断越准确
+func withErrorCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return err
+	}
+	return &codedError{Code: code, Err: err}
+}
+
+// errorCodeOf 返回错误的错误码：优先使用创建错误时附带的错误码，没有时按错误信息判断
+func errorCodeOf(err error) ErrorCode {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return classifyError(err.Error())
+}
+
+// apiStatusError 墨问API返回非成功状态码时的错误，错误码按状态码判断
+func apiStatusError(status int, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if code, ok := statusErrorCode(status); ok {
+		return &codedError{Code: code, Err: err}
+	}
+	return err
+}
+
+// networkError 发送请求失败时按是否超时附带错误码
+func networkError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return withErrorCode(ErrTimeout, err)
+	}
+	return withErrorCode(ErrNetwork, err)
+}
+
+// apiStatusPattern 错误信息中墨问API返回的HTTP状态码
+var apiStatusPattern = regexp.MustCompile(`状态码: ?(\d{3})`)
+
+// errorCodeRules 按顺序匹配错误信息中的关键字，越具体的规则越靠前
+var errorCodeRules = []struct {
+	Code     ErrorCode
+	Keywords []string
+}{
+	{ErrAuthFailed, []string{"API密钥", "API key", APIKeyEnvVar, "未授权", "unauthorized"}},
+	{ErrQuotaExceeded, []string{"配额", "额度", "quota", "频率限制", "rate limit"}},
+	{ErrAPIChanged, []string{"响应格式与预期不符"}},
+	{ErrPermissionDenied, []string{"不在允许访问的目录", "禁止访问", "禁止写入", "不允许", "未通过审核", "敏感信息"}},
+	{ErrUploadFailed, []string{"上传", "upload"}},
+	{ErrInvalidBlock, []string{"段落", "内容块", "paragraphs", "引用最多嵌套", "引用中只能嵌套"}},
+	{ErrTimeout, []string{"超时", "timeout", "deadline exceeded"}},
+	{ErrNetwork, []string{"发送请求失败", "connection refused", "no such host", "network is unreachable", "connection reset"}},
+	{ErrNotFound, []string{"不存在", "未找到", "没有找到", "找不到", "no rows"}},
+	{ErrConflict, []string{"冲突", "已存在", "已被占用"}},
+	{ErrStorage, []string{"SQLite", "数据库"}},
+	{ErrInvalidArgument, []string{"不能为空", "必须是", "格式错误", "解析错误", "无效", "参数", "超过"}},
+}
+
+// statusErrorCode HTTP状态码对应的错误码
+func statusErrorCode(status int) (ErrorCode, bool) {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuthFailed, true
+	case status == http.StatusNotFound:
+		return ErrNotFound, true
+	case status == http.StatusConflict:
+		return ErrConflict, true
+	case status == http.StatusTooManyRequests:
+		return ErrQuotaExceeded, true
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return ErrInvalidArgument, true
+	case status >= 500 && status < 600:
+		return ErrUpstream, true
+	}
+	return "", false
+}
+
+// classifyError 根据错误信息判断错误码，用于没有附带错误码的错误：优先按API状态码，其次按关键字
+func classifyError(message string) ErrorCode {
+	if m := apiStatusPattern.FindStringSubmatch(message); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		if code, ok := statusErrorCode(status); ok {
+			return code
+		}
+	}
+	lower := strings.ToLower(message)
+	for _, rule := range errorCodeRules {
+		for _, keyword := range rule.Keywords {
+			if strings.Contains(lower, strings.ToLower(keyword)) {
+				return rule.Code
+			}
+		}
+	}
+	return ErrInternal
+}
+
+// errorResultText 返回失败结果的错误信息：标记了IsError，或文本以❌开头时视为失败
+func errorResultText(result *mcp.CallToolResult) (string, bool) {
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok && (result.IsError || strings.HasPrefix(text.Text, "❌")) {
+			return text.Text, true
+		}
+	}
+	return "", false
+}
+
+// errorResult 失败结果，文本为"❌ 前缀: 错误信息"，错误附带的错误码写入结果元数据
+func errorResult(prefix string, err error) *mcp.CallToolResult {
+	text := "❌ " + err.Error()
+	if prefix != "" {
+		text = "❌ " + prefix + ": " + err.Error()
+	}
+	result := mcp.NewToolResultText(text)
+	setResultMeta(result, errorMetaKey, string(errorCodeOf(err)))
+	return result
+}
+
+// setResultMeta 设置结果元数据中的一项
+func setResultMeta(result *mcp.CallToolResult, key string, value interface{}) {
+	if result.Meta == nil {
+		result.Meta = make(map[string]interface{})
+	}
+	result.Meta[key] = value
+}
+
+// annotateErrorResult 为失败结果标记错误码：写入结果元数据并在文本末尾注明，同时标记IsError
+// hint为附加在错误码之后的提示，如建议的重试时间
+// 结果元数据中已有错误码（见errorResult）时直接使用，否则按错误信息判断
+func annotateErrorResult(result *mcp.CallToolResult, message, requestID, hint string) ErrorCode {
+	code, _ := result.Meta[errorMetaKey].(string)
+	if code == "" {
+		code = string(classifyError(message))
+	}
+	setResultMeta(result, errorMetaKey, code)
+	setResultMeta(result, "request_id", requestID)
+	result.IsError = true
+	for i, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			text.Text += fmt.Sprintf("\n（错误码: %s，请求ID: %s%s）", code, requestID, hint)
+			result.Content[i] = text
+			break
+		}
+	}
+	return ErrorCode(code)
+}
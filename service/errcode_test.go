@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	t.Setenv(APIKeyEnvVar, "")
+	t.Setenv(MockModeEnvVar, "")
+	_, missingKey := NewMowenClient()
+
+	moderation := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"allow": false, "reason": "包含违规内容"}`)
+	}))
+	defer moderation.Close()
+	t.Setenv(ModerationURLEnvVar, moderation.URL)
+	_, _, vetoed := moderateContent(context.Background(), "publish", "n1", nil, nil)
+
+	t.Setenv(PIIModeEnvVar, piiModeBlock)
+	piiBlocked := runHooks(context.Background(), &HookEvent{
+		Hook:   HookBeforeCreate,
+		Blocks: []ContentBlock{{Texts: []TextNode{{Text: "联系我 someone@example.com"}}}},
+	})
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"缺少API密钥", missingKey, ErrAuthFailed},
+		{"审核未通过", vetoed, ErrPermissionDenied},
+		{"包含敏感信息", piiBlocked, ErrPermissionDenied},
+		{"API限流", apiStatusError(http.StatusTooManyRequests, "API请求失败，状态码: %d", http.StatusTooManyRequests), ErrQuotaExceeded},
+		{"上传时限流保留内层错误码", withErrorCode(ErrUploadFailed, fmt.Errorf("上传本地图片文件失败: %w",
+			apiStatusError(http.StatusTooManyRequests, "获取上传授权信息API请求失败，状态码: %d", http.StatusTooManyRequests))), ErrQuotaExceeded},
+		{"上传失败", withErrorCode(ErrUploadFailed, errors.New("读取文件失败")), ErrUploadFailed},
+		{"关键字: 缺少API密钥", errors.New("环境变量 MOWEN_API_KEY 未设置或为空"), ErrAuthFailed},
+		{"关键字: 审核未通过", errors.New("内容未通过审核: 包含违规内容"), ErrPermissionDenied},
+		{"关键字: 包含敏感信息", errors.New("内容中包含敏感信息: 邮箱 s***@example.com，请删除后重试"), ErrPermissionDenied},
+		{"关键字: 状态码", errors.New("API请求失败，状态码: 404，响应: {}"), ErrNotFound},
+		{"未归类", errors.New("出了点问题"), ErrInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := errorCodeOf(tt.err); got != tt.want {
+				t.Errorf("errorCodeOf(%q) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnotateErrorResultKeepsAttachedCode(t *testing.T) {
+	result := errorResult("创建客户端失败", withErrorCode(ErrAuthFailed, errors.New("会话未绑定墨问API密钥")))
+	message, failed := errorResultText(result)
+	if !failed {
+		t.Fatal("errorResult should be a failed result")
+	}
+	if code := annotateErrorResult(result, message, "req-1", ""); code != ErrAuthFailed {
+		t.Errorf("annotateErrorResult = %s, want %s", code, ErrAuthFailed)
+	}
+	if result.Meta[errorMetaKey] != string(ErrAuthFailed) || !result.IsError {
+		t.Errorf("unexpected result meta %v, IsError %v", result.Meta, result.IsError)
+	}
+}
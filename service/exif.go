@@ -0,0 +1,273 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 照片处理环境变量
+const (
+	// 上传本地图片前是否去除EXIF、XMP等元数据（含GPS位置），默认开启
+	StripEXIFEnvVar = "MOWEN_STRIP_EXIF"
+	// 上传本地图片前是否按EXIF方向信息旋转图片，默认开启
+	AutoOrientEnvVar = "MOWEN_AUTO_ORIENT"
+)
+
+// 旋转后重新编码JPEG的质量
+const orientJPEGQuality = 92
+
+// 需要从PNG中去除的元数据块
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// prepareImageForUpload 按配置去除本地图片的元数据并修正方向
+// 需要处理时写入临时文件并返回其路径，cleanup用于上传后删除临时文件；无需处理时返回原路径
+// URL图片由墨问服务器直接抓取，不经过本服务，无法处理
+func prepareImageForUpload(path string) (string, func(), error) {
+	noop := func() {}
+	strip := envBool(StripEXIFEnvVar, true)
+	orient := envBool(AutoOrientEnvVar, true)
+	if !strip && !orient {
+		return path, noop, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", noop, fmt.Errorf("读取图片失败: %w", err)
+	}
+
+	var processed []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		processed, err = processJPEG(data, strip, orient)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) && strip:
+		processed, err = stripPNGMetadata(data)
+	}
+	if err != nil {
+		// 图片无法解析时按原文件上传，交给墨问服务器处理
+		logger.Warnf("处理图片元数据失败，按原文件上传: %s: %v", path, err)
+		return path, noop, nil
+	}
+	if processed == nil {
+		return path, noop, nil
+	}
+
+	// 临时文件保留原扩展名，上传时据此判断MIME类型
+	tmp, err := os.CreateTemp("", "mowen-upload-*"+filepath.Ext(path))
+	if err != nil {
+		return "", noop, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+	if _, err = tmp.Write(processed); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", noop, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// jpegSegment JPEG文件中的一个标记段
+type jpegSegment struct {
+	Marker byte
+	Data   []byte // 含标记和长度的完整段
+}
+
+// splitJPEG 拆分JPEG的头部标记段，rest为扫描数据（从SOS开始）
+func splitJPEG(data []byte) (segments []jpegSegment, rest []byte, err error) {
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, nil, fmt.Errorf("无效的JPEG标记，位置 %d", i)
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++ // 填充字节
+			continue
+		}
+		if marker == 0xDA {
+			return segments, data[i:], nil
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			segments = append(segments, jpegSegment{Marker: marker, Data: data[i : i+2]})
+			i += 2
+			continue
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return nil, nil, fmt.Errorf("JPEG标记段长度越界")
+		}
+		segments = append(segments, jpegSegment{Marker: marker, Data: data[i:end]})
+		i = end
+	}
+	return nil, nil, fmt.Errorf("未找到JPEG图像数据")
+}
+
+// isMetadataSegment 是否为EXIF或XMP元数据段（APP1）
+func isMetadataSegment(seg jpegSegment) bool {
+	if seg.Marker != 0xE1 || len(seg.Data) < 4 {
+		return false
+	}
+	payload := seg.Data[4:]
+	return bytes.HasPrefix(payload, []byte("Exif\x00\x00")) || bytes.HasPrefix(payload, []byte("http://ns.adobe.com/xap/"))
+}
+
+// processJPEG 修正方向并去除元数据，无需处理时返回nil
+func processJPEG(data []byte, strip, orient bool) ([]byte, error) {
+	segments, rest, err := splitJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	hasMetadata := false
+	for _, seg := range segments {
+		if isMetadataSegment(seg) {
+			hasMetadata = true
+			if bytes.HasPrefix(seg.Data[4:], []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(seg.Data[10:])
+			}
+		}
+	}
+
+	// 需要旋转时重新编码，编码结果不包含任何元数据
+	if orient && orientation >= 2 && orientation <= 8 {
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err = jpeg.Encode(&buf, applyOrientation(img, orientation), &jpeg.Options{Quality: orientJPEGQuality}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	if !strip || !hasMetadata {
+		return nil, nil
+	}
+	// 无需旋转时只删除元数据段，不重新编码，画质不变
+	var buf bytes.Buffer
+	buf.Write(data[:2])
+	for _, seg := range segments {
+		if !isMetadataSegment(seg) {
+			buf.Write(seg.Data)
+		}
+	}
+	buf.Write(rest)
+	return buf.Bytes(), nil
+}
+
+// exifOrientation 从EXIF的TIFF数据中读取方向标签（0x0112），读取失败返回1
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
+
+// applyOrientation 按EXIF方向值翻转或旋转图片，使其以正确方向显示
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, w-1-x
+			}
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// stripPNGMetadata 删除PNG中的EXIF和文本元数据块，无需处理时返回nil
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(data[:8])
+	stripped := false
+	for i := 8; i < len(data); {
+		if i+8 > len(data) {
+			return nil, fmt.Errorf("PNG数据块不完整")
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, fmt.Errorf("PNG数据块长度越界")
+		}
+		if pngMetadataChunks[string(data[i+4:i+8])] {
+			stripped = true
+		} else {
+			buf.Write(data[i:end])
+		}
+		i = end
+	}
+	if !stripped {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 未指定分类和币种时的默认值
+const (
+	defaultExpenseCategory = "其他"
+	defaultExpenseCurrency = "CNY"
+)
+
+// Expense 一条支出记录，金额以分为单位，避免浮点误差
+type Expense struct {
+	ID          int
+	Amount      int64
+	Currency    string
+	Category    string
+	Description string
+	SpentOn     string // 本地日期 YYYY-MM-DD
+	NoteID      string
+}
+
+// SaveExpense 保存支出记录
+func SaveExpense(tenantID string, expense Expense) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	err := execWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO expenses (tenant_id, amount, currency, category, description, spent_on, note_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			tenantID, expense.Amount, expense.Currency, expense.Category, expense.Description, expense.SpentOn, expense.NoteID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存支出记录失败: %v", err)
+	}
+	return nil
+}
+
+// ListExpenses 查询日期在 [from, to] 内的支出记录
+func ListExpenses(tenantID, from, to string) ([]Expense, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT id, amount, currency, category, description, spent_on, note_id FROM expenses WHERE tenant_id = ? AND spent_on >= ? AND spent_on <= ? ORDER BY spent_on, id",
+		tenantID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("查询支出记录失败: %v", err)
+	}
+	defer rows.Close()
+
+	var expenses []Expense
+	for rows.Next() {
+		var expense Expense
+		if err = rows.Scan(&expense.ID, &expense.Amount, &expense.Currency, &expense.Category, &expense.Description, &expense.SpentOn, &expense.NoteID); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		expenses = append(expenses, expense)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return expenses, nil
+}
+
+// formatAmount 显示金额：人民币为 ¥12.50，其他币种为 12.50 USD
+func formatAmount(cents int64, currency string) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	amount := fmt.Sprintf("%d.%02d", cents/100, cents%100)
+	if currency == defaultExpenseCurrency {
+		return sign + "¥" + amount
+	}
+	return sign + amount + " " + currency
+}
+
+// LogExpense 记录一笔支出，追加到当月的账本笔记
+func LogExpense(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	amount, ok := args["amount"].(float64)
+	if !ok || amount == 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return mcp.NewToolResultText("❌ 金额不能为空或0，退款可以传负数"), nil
+	}
+	category, _ := args["category"].(string)
+	category = strings.TrimSpace(category)
+	if category == "" {
+		category = defaultExpenseCategory
+	}
+	currency, _ := args["currency"].(string)
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = defaultExpenseCurrency
+	}
+	description, _ := args["description"].(string)
+
+	day := dayOf(time.Now())
+	if dateStr, _ := args["date"].(string); dateStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 日期格式错误: %s，应为 YYYY-MM-DD", dateStr)), nil
+		}
+		day = t
+	}
+	expense := Expense{
+		Amount:      int64(math.Round(amount * 100)),
+		Currency:    currency,
+		Category:    category,
+		Description: strings.TrimSpace(description),
+		SpentOn:     day.Format("2006-01-02"),
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	texts := []TextNode{
+		{Text: day.Format("01-02") + " ", Bold: true},
+		{Text: "#" + expense.Category + " ", Highlight: true},
+		{Text: formatAmount(expense.Amount, expense.Currency), Bold: true},
+	}
+	if expense.Description != "" {
+		texts = append(texts, TextNode{Text: " " + expense.Description})
+	}
+
+	month := day.Format("2006-01")
+	noteID, created, err := appendToNamedNote(ctx, client, "expenses:"+month, "💰 账本 "+month, []ContentBlock{{Texts: texts}})
+	if err != nil {
+		return errorResult("追加支出记录失败", err), nil
+	}
+	expense.NoteID = noteID
+	if err = SaveExpense(tenantFromContext(ctx), expense); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 支出已写入笔记 %s，但%v，expense_summary不会统计这笔支出", noteID, err)), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 支出已记录！\n\n日期: %s\n分类: %s\n金额: %s\n笔记ID: %s",
+		expense.SpentOn, expense.Category, formatAmount(expense.Amount, expense.Currency), noteID)
+	if created {
+		resultText += "\n（本月的账本笔记不存在，已自动创建）"
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// ExpenseSummary 按分类汇总支出，不同币种分别统计
+func ExpenseSummary(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	monthStr, _ := args["month"].(string)
+	fromStr, _ := args["from"].(string)
+	toStr, _ := args["to"].(string)
+	categoryFilter, _ := args["category"].(string)
+	categoryFilter = strings.TrimSpace(categoryFilter)
+
+	// 默认统计本月，传入from/to时以日期区间为准
+	month := time.Now()
+	if monthStr != "" {
+		t, err := time.ParseInLocation("2006-01", monthStr, time.Local)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 月份格式错误: %s，应为 YYYY-MM", monthStr)), nil
+		}
+		month = t
+	}
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 1, -1)
+	for _, d := range []struct {
+		value  string
+		target *time.Time
+	}{{fromStr, &from}, {toStr, &to}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", d.value, time.Local)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 日期格式错误: %s，应为 YYYY-MM-DD", d.value)), nil
+		}
+		*d.target = t
+	}
+	if to.Before(from) {
+		return mcp.NewToolResultText("❌ 结束日期不能早于开始日期"), nil
+	}
+
+	expenses, err := ListExpenses(tenantFromContext(ctx), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	type categoryTotal struct {
+		Category string
+		Amount   int64
+		Count    int
+	}
+	// 币种 -> 分类 -> 合计
+	totals := make(map[string]map[string]*categoryTotal)
+	count := 0
+	for _, expense := range expenses {
+		if categoryFilter != "" && expense.Category != categoryFilter {
+			continue
+		}
+		if totals[expense.Currency] == nil {
+			totals[expense.Currency] = make(map[string]*categoryTotal)
+		}
+		total := totals[expense.Currency][expense.Category]
+		if total == nil {
+			total = &categoryTotal{Category: expense.Category}
+			totals[expense.Currency][expense.Category] = total
+		}
+		total.Amount += expense.Amount
+		total.Count++
+		count++
+	}
+
+	period := fmt.Sprintf("%s 至 %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if count == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("📊 %s 没有支出记录", period)), nil
+	}
+
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 支出统计 %s，共 %d 笔\n", period, count))
+	for _, currency := range currencies {
+		var sum int64
+		categories := make([]*categoryTotal, 0, len(totals[currency]))
+		for _, total := range totals[currency] {
+			categories = append(categories, total)
+			sum += total.Amount
+		}
+		sort.Slice(categories, func(i, j int) bool {
+			if categories[i].Amount != categories[j].Amount {
+				return categories[i].Amount > categories[j].Amount
+			}
+			return categories[i].Category < categories[j].Category
+		})
+
+		sb.WriteString(fmt.Sprintf("\n合计 %s\n", formatAmount(sum, currency)))
+		for _, total := range categories {
+			share := ""
+			if sum > 0 && total.Amount > 0 {
+				share = fmt.Sprintf("，占 %.0f%%", float64(total.Amount)*100/float64(sum))
+			}
+			sb.WriteString(fmt.Sprintf("**%s**: %s（%d 笔%s）\n", total.Category, formatAmount(total.Amount, currency), total.Count, share))
+		}
+	}
+	return mcp.NewToolResultText(strings.TrimRight(sb.String(), "\n")), nil
+}
+
+// 记账工具
+var LogExpenseTool = mcp.NewTool("log_expense",
+	mcp.WithDescription("记录一笔支出，追加到当月的\"账本\"笔记并保存金额到本地，之后可以用expense_summary按分类汇总。"),
+	mcp.WithNumber("amount",
+		mcp.Required(),
+		mcp.Description("金额，如 35.5；退款传负数"),
+	),
+	mcp.WithString("category",
+		mcp.Description("分类，如 餐饮、交通、购物，默认\"其他\""),
+	),
+	mcp.WithString("description",
+		mcp.Description("备注，如 午饭"),
+	),
+	mcp.WithString("date",
+		mcp.Description("消费日期 YYYY-MM-DD，默认今天，决定记入哪个月的账本"),
+	),
+	mcp.WithString("currency",
+		mcp.Description("币种代码，默认CNY"),
+	),
+)
+
+// 支出统计工具
+var ExpenseSummaryTool = mcp.NewTool("expense_summary",
+	mcp.WithDescription("按分类汇总log_expense记录的支出，列出每个分类的金额、笔数和占比，不同币种分别统计。数据来自本地记录。"),
+	mcp.WithString("month",
+		mcp.Description("统计的月份 YYYY-MM，默认本月"),
+	),
+	mcp.WithString("from",
+		mcp.Description("开始日期 YYYY-MM-DD，传入后覆盖month的起始日"),
+	),
+	mcp.WithString("to",
+		mcp.Description("结束日期 YYYY-MM-DD（含当天），传入后覆盖month的结束日"),
+	),
+	mcp.WithString("category",
+		mcp.Description("只统计该分类"),
+	),
+)
+
+func logExpenseHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := LogExpense(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
+
+func expenseSummaryHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ExpenseSummary(ctx, request)
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 笔记导出环境变量
+const (
+	// 自定义HTML模板文件路径，模板数据见 exportPage
+	ExportTemplateEnvVar = "MOWEN_EXPORT_TEMPLATE"
+	// HTML转PDF的命令，{in}和{out}替换为输入输出路径，例如 "wkhtmltopdf {in} {out}"
+	// 未配置时依次尝试wkhtmltopdf、weasyprint和Chrome/Chromium的无头模式
+	PDFCommandEnvVar = "MOWEN_PDF_COMMAND"
+)
+
+// 默认的HTML转PDF命令，按顺序使用第一个已安装的
+var pdfConverters = []imageConverter{
+	{Name: "wkhtmltopdf", Args: []string{"--quiet", "--enable-local-file-access", "{in}", "{out}"}},
+	{Name: "weasyprint", Args: []string{"{in}", "{out}"}},
+	{Name: "chromium", Args: []string{"--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf={out}", "{in}"}},
+	{Name: "chromium-browser", Args: []string{"--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf={out}", "{in}"}},
+	{Name: "google-chrome", Args: []string{"--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf={out}", "{in}"}},
+}
+
+// exportPage 导出模板的数据
+type exportPage struct {
+	Title      string
+	NoteID     string
+	CreatedAt  string
+	ExportedAt string
+	Home       template.URL // 站点目录页地址，单篇导出时为空
+	Blocks     []exportBlock
+}
+
+// exportBlock 模板中的一个内容块
+type exportBlock struct {
+	Kind     string // paragraph, quote, todo, divider, note, image, audio, pdf
+	Texts    []TextNode
+	Extra    [][]TextNode  // 引用的后续段落
+	Children []exportBlock // 嵌套引用
+	Checked  bool
+	Src      template.URL // 文件或内链笔记的地址，本地文件为file://地址
+	Name     string       // 文件名或内链笔记标题
+	Alt      string
+}
+
+const defaultExportTemplate = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 720px; margin: 40px auto; padding: 0 20px; font-family: -apple-system, "PingFang SC", "Noto Sans CJK SC", "Microsoft YaHei", sans-serif; line-height: 1.8; color: #222; }
+header { color: #888; font-size: 13px; border-bottom: 1px solid #eee; margin-bottom: 24px; padding-bottom: 8px; }
+blockquote { margin: 12px 0; padding: 4px 16px; border-left: 3px solid #ccc; color: #555; }
+mark { background: #fff3a3; }
+hr { border: none; border-top: 1px solid #ddd; margin: 24px 0; }
+img { max-width: 100%; }
+.attachment { padding: 8px 12px; background: #f6f6f6; border-radius: 4px; }
+</style>
+</head>
+<body>
+<header>{{if .Home}}<a href="{{.Home}}">← 返回目录</a> · {{end}}笔记ID: {{.NoteID}}{{if .CreatedAt}} · 创建于 {{.CreatedAt}}{{end}} · 导出于 {{.ExportedAt}}</header>
+{{range .Blocks}}{{template "block" .}}{{end}}
+</body>
+</html>
+{{define "texts"}}{{range .}}{{if .Link}}<a href="{{.Link}}">{{end}}{{if .Bold}}<strong>{{end}}{{if .Highlight}}<mark>{{end}}{{.Text}}{{if .Highlight}}</mark>{{end}}{{if .Bold}}</strong>{{end}}{{if .Link}}</a>{{end}}{{end}}{{end}}
+{{define "block"}}{{if eq .Kind "quote"}}<blockquote><p>{{template "texts" .Texts}}</p>{{range .Extra}}<p>{{template "texts" .}}</p>{{end}}{{range .Children}}{{template "block" .}}{{end}}</blockquote>
+{{else if eq .Kind "todo"}}<p>{{if .Checked}}☑{{else}}☐{{end}} {{template "texts" .Texts}}</p>
+{{else if eq .Kind "divider"}}<hr>
+{{else if eq .Kind "note"}}<p class="attachment">🔗 {{if .Src}}<a href="{{.Src}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</p>
+{{else if eq .Kind "image"}}<p><img src="{{.Src}}" alt="{{.Alt}}"></p>
+{{else if eq .Kind "audio"}}<p class="attachment">🎧 <a href="{{.Src}}">{{.Name}}</a></p>
+{{else if eq .Kind "pdf"}}<p class="attachment">📄 <a href="{{.Src}}">{{.Name}}</a></p>
+{{else}}<p>{{template "texts" .Texts}}</p>
+{{end}}{{end}}`
+
+// exportFileSrc 文件块在导出页面中的地址，本地文件使用file://地址
+// 标记为可信URL，否则html/template会替换file://地址
+func exportFileSrc(block ContentBlock) template.URL {
+	if block.SourceType == "url" {
+		if u, err := url.Parse(block.SourcePath); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return "#"
+		}
+		return template.URL(block.SourcePath)
+	}
+	path := expandPath(context.Background(), block.SourcePath)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return template.URL("file://" + filepath.ToSlash(path))
+}
+
+// exportLinker 决定导出页面中内链笔记和文件的地址
+type exportLinker struct {
+	// NoteHref 返回内链笔记的地址，返回空字符串时不加链接；为nil时内链笔记都不加链接
+	NoteHref func(noteID string) string
+	// FileSrc 返回文件块的地址，为nil时使用exportFileSrc
+	FileSrc func(block ContentBlock) template.URL
+}
+
+// noteLink 内链笔记的地址
+func (l *exportLinker) noteLink(noteID string) template.URL {
+	if l == nil || l.NoteHref == nil {
+		return ""
+	}
+	return template.URL(l.NoteHref(noteID))
+}
+
+// fileLink 文件块的地址
+func (l *exportLinker) fileLink(block ContentBlock) template.URL {
+	if l == nil || l.FileSrc == nil {
+		return exportFileSrc(block)
+	}
+	return l.FileSrc(block)
+}
+
+// linkTexts 文字中指向墨问笔记的链接改为导出后的地址
+func (l *exportLinker) linkTexts(texts []TextNode) []TextNode {
+	if l == nil || l.NoteHref == nil {
+		return texts
+	}
+	var result []TextNode
+	for _, text := range texts {
+		if m := mowenNoteURLPattern.FindStringSubmatch(text.Link); m != nil {
+			if href := l.NoteHref(m[1]); href != "" {
+				text.Link = href
+			}
+		}
+		result = append(result, text)
+	}
+	return result
+}
+
+// toExportBlocks 把内容块转换为模板数据，目录文件块和未识别的类型按普通段落处理
+func toExportBlocks(tenantID string, blocks []ContentBlock, linker *exportLinker) []exportBlock {
+	result := make([]exportBlock, 0, len(blocks))
+	for _, block := range blocks {
+		item := exportBlock{Kind: "paragraph", Texts: linker.linkTexts(block.Texts)}
+		switch {
+		case block.Type == "quote":
+			item.Kind = "quote"
+			for _, texts := range block.Paragraphs {
+				item.Extra = append(item.Extra, linker.linkTexts(texts))
+			}
+			item.Children = toExportBlocks(tenantID, block.Children, linker)
+			for i := range item.Children {
+				item.Children[i].Kind = "quote"
+			}
+		case block.Type == "todo":
+			item.Kind, item.Checked = "todo", block.Checked
+		case block.Type == "divider" || (block.Type == "" || block.Type == "paragraph") && isMarkdownRule(block.Texts):
+			item.Kind = "divider"
+		case block.Type == "note":
+			item.Kind, item.Name, item.Src = "note", "内链笔记 "+block.NoteID, linker.noteLink(block.NoteID)
+			if record, err := GetNoteCached(tenantID, block.NoteID); err == nil {
+				if title := noteTitle(record.Content); title != "" {
+					item.Name = title
+				}
+			}
+		case block.Type == "file" && block.SourceType != "dir":
+			item.Kind, item.Name, item.Src = block.FileType, uploadFileName(&block), linker.fileLink(block)
+			if item.Kind == "" {
+				item.Kind = "pdf"
+			}
+			item.Alt, _ = block.Metadata["alt"].(string)
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// renderNoteHTML 把笔记渲染为完整的HTML页面，返回页面和标题
+// linker为nil时内链笔记不加链接，本地文件使用file://地址；home非空时页面顶部显示返回目录的链接
+func renderNoteHTML(ctx context.Context, noteID string, linker *exportLinker, home string) (string, string, error) {
+	tenantID := tenantFromContext(ctx)
+	record, err := GetNoteCached(tenantID, noteID)
+	if err != nil {
+		return "", "", err
+	}
+	blocks, err := loadNoteBlocks(tenantID, noteID)
+	if err != nil {
+		return "", "", err
+	}
+
+	text := defaultExportTemplate
+	if path := envString(ExportTemplateEnvVar, ""); path != "" {
+		data, err := os.ReadFile(expandPath(ctx, path))
+		if err != nil {
+			return "", "", fmt.Errorf("读取导出模板失败: %w", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("export").Parse(text)
+	if err != nil {
+		return "", "", fmt.Errorf("解析导出模板失败: %w", err)
+	}
+
+	title := noteTitle(record.Content)
+	if title == "" {
+		title = noteID
+	}
+	page := exportPage{
+		Title:      title,
+		NoteID:     noteID,
+		ExportedAt: time.Now().Format("2006-01-02 15:04"),
+		Home:       template.URL(home),
+		Blocks:     toExportBlocks(tenantID, blocks, linker),
+	}
+	if t, err := parseDBTime(record.CreatedAt); err == nil {
+		page.CreatedAt = t.Local().Format("2006-01-02 15:04")
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		return "", "", fmt.Errorf("渲染导出模板失败: %w", err)
+	}
+	return buf.String(), title, nil
+}
+
+// exportOutputPath 导出文件的保存路径：指定路径时校验沙箱，否则保存到临时目录并以标题命名
+func exportOutputPath(ctx context.Context, output, title, ext string) (string, error) {
+	if output != "" {
+		return checkWritePath(ctx, output)
+	}
+	name := sanitizeFileName(title)
+	if name == "" {
+		name = "note"
+	}
+	return filepath.Join(os.TempDir(), name+ext), nil
+}
+
+// ExportNoteHTML 把笔记导出为HTML文件
+func ExportNoteHTML(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	page, title, err := renderNoteHTML(ctx, noteID, nil, "")
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	output, _ := args["output_path"].(string)
+	path, err := exportOutputPath(ctx, output, title, ".html")
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if err := os.WriteFile(path, []byte(page), 0o644); err != nil {
+		return errorResult("写入文件失败", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 笔记已导出为HTML！\n\n笔记ID: %s\n标题: %s\n保存路径: %s\n大小: %d 字节",
+		noteID, title, path, len(page))), nil
+}
+
+// ExportNotePDF 把笔记渲染为HTML后转换为PDF文件
+func ExportNotePDF(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	page, title, err := renderNoteHTML(ctx, noteID, nil, "")
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	output, _ := args["output_path"].(string)
+	path, err := exportOutputPath(ctx, output, title, ".pdf")
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "mowen-export-")
+	if err != nil {
+		return errorResult("创建临时目录失败", err), nil
+	}
+	defer os.RemoveAll(tmpDir)
+	htmlPath := filepath.Join(tmpDir, "note.html")
+	if err := os.WriteFile(htmlPath, []byte(page), 0o644); err != nil {
+		return errorResult("写入临时文件失败", err), nil
+	}
+
+	converters := pdfConverters
+	if command := strings.Fields(envString(PDFCommandEnvVar, "")); len(command) > 0 {
+		converters = []imageConverter{{Name: command[0], Args: command[1:]}}
+	}
+	if err := runConverters(ctx, converters, htmlPath, path); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 转换PDF失败: %v（可通过 %s 配置转换命令）", err, PDFCommandEnvVar)), nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return errorResult("读取PDF失败", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 笔记已导出为PDF！\n\n笔记ID: %s\n标题: %s\n保存路径: %s\n大小: %d 字节",
+		noteID, title, path, info.Size())), nil
+}
+
+// 导出HTML工具
+var ExportNoteHTMLTool = mcp.NewTool("export_note_html",
+	mcp.WithDescription("把笔记导出为本地HTML文件，便于在墨问之外分享。使用内置模板渲染，可通过MOWEN_EXPORT_TEMPLATE指定自定义模板。"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记"),
+	),
+	mcp.WithString("output_path",
+		mcp.Description("保存路径，例如 ~/Documents/note.html，默认保存到临时目录并以笔记标题命名"),
+	),
+)
+
+// 导出PDF工具
+var ExportNotePDFTool = mcp.NewTool("export_note_pdf",
+	mcp.WithDescription("把笔记导出为本地PDF文件：先按HTML模板渲染，再调用wkhtmltopdf、weasyprint或Chrome无头模式转换，可通过MOWEN_PDF_COMMAND指定转换命令。"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记"),
+	),
+	mcp.WithString("output_path",
+		mcp.Description("保存路径，例如 ~/Documents/note.pdf，默认保存到临时目录并以笔记标题命名"),
+	),
+)
+
+func exportNoteHTMLHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ExportNoteHTML(ctx, request)
+}
+
+func exportNotePDFHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ExportNotePDF(ctx, request)
+}
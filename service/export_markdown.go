@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// markdownSpecialChars 文字中需要转义的Markdown字符
+var markdownSpecialChars = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `==`, `\=\=`, `<`, `\<`,
+)
+
+// escapeLineStart 行首的标题、引用、列表和分隔线标记前加反斜杠，避免普通文字被解析为Markdown块
+func escapeLineStart(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	indent := line[:len(line)-len(trimmed)]
+	switch {
+	case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, ">"),
+		strings.HasPrefix(trimmed, "-"), strings.HasPrefix(trimmed, "+ "):
+		return indent + `\` + trimmed
+	case markdownOrdered.MatchString(trimmed):
+		i := strings.IndexAny(trimmed, ".)")
+		return indent + trimmed[:i] + `\` + trimmed[i:]
+	}
+	return line
+}
+
+// textsToMarkdown 把文本节点转换为Markdown行内格式：加粗为**，高亮为==，链接为[文字](地址)
+// 格式标记内侧不能有空白，首尾空白移到标记之外
+func textsToMarkdown(texts []TextNode) string {
+	var sb strings.Builder
+	for _, text := range texts {
+		for i, line := range strings.Split(text.Text, "\n") {
+			if i > 0 {
+				sb.WriteString("  \n")
+			}
+			content := strings.TrimSpace(line)
+			if content == "" {
+				sb.WriteString(line)
+				continue
+			}
+			lead := line[:strings.Index(line, content)]
+			trail := line[len(lead)+len(content):]
+			content = markdownSpecialChars.Replace(content)
+			if text.Highlight {
+				content = "==" + content + "=="
+			}
+			if text.Bold {
+				content = "**" + content + "**"
+			}
+			if text.Link != "" {
+				content = "[" + content + "](" + markdownLinkTarget(text.Link) + ")"
+			}
+			sb.WriteString(lead + content + trail)
+		}
+	}
+	return sb.String()
+}
+
+// markdownLinkTarget 链接地址中的空格和括号需要编码，否则Markdown解析会截断
+func markdownLinkTarget(link string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(link)
+}
+
+// markdownParagraph 普通段落，每行行首的Markdown语法字符都转义
+func markdownParagraph(texts []TextNode) string {
+	lines := strings.Split(textsToMarkdown(texts), "  \n")
+	for i, line := range lines {
+		lines[i] = escapeLineStart(line)
+	}
+	return strings.Join(lines, "  \n")
+}
+
+// isBoldOnly 段落是否全部为加粗文字，导出时第一段加粗文字作为标题
+func isBoldOnly(texts []TextNode) bool {
+	if !hasVisibleText(texts) {
+		return false
+	}
+	for _, text := range texts {
+		if strings.TrimSpace(text.Text) != "" && !text.Bold {
+			return false
+		}
+	}
+	return true
+}
+
+// plainTexts 去掉格式后的文字
+func plainTexts(texts []TextNode) string {
+	var sb strings.Builder
+	for _, text := range texts {
+		sb.WriteString(text.Text)
+	}
+	return sb.String()
+}
+
+// markdownFileSrc 文件块在Markdown中的地址：远程文件为原始地址，本地文件为原始路径，只有file_id时为空
+func markdownFileSrc(block ContentBlock) string {
+	if block.SourcePath == "" || block.SourceType == "dir" {
+		return ""
+	}
+	return markdownLinkTarget(block.SourcePath)
+}
+
+// fileBlockToMarkdown 图片为图片语法，音频和PDF为链接；没有来源地址的文件（如从墨问拉取的内容）写为带file_id的说明文字
+func fileBlockToMarkdown(block ContentBlock) string {
+	if block.SourceType == "dir" {
+		return fmt.Sprintf("_[目录 %s 中的文件]_", markdownSpecialChars.Replace(block.SourcePath))
+	}
+	typeName := fileTypeNames[block.FileType]
+	if typeName == "" {
+		typeName = "文件"
+	}
+	name := uploadFileName(&block)
+	if name == "" {
+		name = typeName
+	}
+	src := markdownFileSrc(block)
+	if src == "" {
+		return fmt.Sprintf("_[%s: %s，file_id: %s]_", typeName, markdownSpecialChars.Replace(name), block.FileID)
+	}
+	if block.FileType == "image" {
+		alt, _ := block.Metadata["alt"].(string)
+		if alt == "" {
+			alt = name
+		}
+		return fmt.Sprintf("![%s](%s)", markdownSpecialChars.Replace(alt), src)
+	}
+	return fmt.Sprintf("[%s: %s](%s)", typeName, markdownSpecialChars.Replace(name), src)
+}
+
+// quoteToMarkdown 引用块，每个段落一行，段落之间以空的引用行分隔，嵌套引用增加一级>
+func quoteToMarkdown(block ContentBlock, depth int) []string {
+	prefix := strings.Repeat("> ", depth)
+	var lines []string
+	add := func(texts []TextNode) {
+		if len(lines) > 0 {
+			lines = append(lines, strings.TrimRight(prefix, " "))
+		}
+		for _, line := range strings.Split(textsToMarkdown(texts), "  \n") {
+			lines = append(lines, prefix+line)
+		}
+	}
+	if hasVisibleText(block.Texts) {
+		add(block.Texts)
+	}
+	for _, texts := range block.Paragraphs {
+		add(texts)
+	}
+	for _, child := range block.Children {
+		if len(lines) > 0 {
+			lines = append(lines, strings.TrimRight(prefix, " "))
+		}
+		lines = append(lines, quoteToMarkdown(child, depth+1)...)
+	}
+	return lines
+}
+
+// blocksToMarkdown 把内容块转换为Markdown，块之间空一行
+// titleHeading为true时，第一段全部加粗的文字作为一级标题
+func blocksToMarkdown(tenantID string, blocks []ContentBlock, titleHeading bool) string {
+	var parts []string
+	for i, block := range blocks {
+		switch {
+		case block.Type == "quote":
+			parts = append(parts, strings.Join(quoteToMarkdown(block, 1), "\n"))
+		case block.Type == "todo":
+			mark := " "
+			if block.Checked {
+				mark = "x"
+			}
+			parts = append(parts, fmt.Sprintf("- [%s] %s", mark, textsToMarkdown(block.Texts)))
+		case block.Type == "divider" || (block.Type == "" || block.Type == "paragraph") && isMarkdownRule(block.Texts):
+			parts = append(parts, "---")
+		case block.Type == "note":
+			name := "内链笔记 " + block.NoteID
+			if record, err := GetNoteCached(tenantID, block.NoteID); err == nil {
+				if title := noteTitle(record.Content); title != "" {
+					name = title
+				}
+			}
+			parts = append(parts, fmt.Sprintf("[%s](https://note.mowen.cn/detail/%s)", markdownSpecialChars.Replace(name), block.NoteID))
+		case block.Type == "file":
+			parts = append(parts, fileBlockToMarkdown(block))
+		case block.Type == "math":
+			parts = append(parts, "$$\n"+strings.TrimSpace(plainTexts(block.Texts))+"\n$$")
+		case i == 0 && titleHeading && isBoldOnly(block.Texts):
+			parts = append(parts, "# "+markdownSpecialChars.Replace(strings.TrimSpace(plainTexts(block.Texts))))
+		default:
+			if hasVisibleText(block.Texts) {
+				parts = append(parts, markdownParagraph(block.Texts))
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+// markdownFrontMatter YAML头信息：标题、笔记ID、创建时间和标签
+func markdownFrontMatter(title, noteID, createdAt string, tags []string) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	sb.WriteString(fmt.Sprintf("title: %s\n", strconv.Quote(title)))
+	sb.WriteString(fmt.Sprintf("mowen_note_id: %s\n", strconv.Quote(noteID)))
+	if createdAt != "" {
+		sb.WriteString(fmt.Sprintf("created: %s\n", strconv.Quote(createdAt)))
+	}
+	if len(tags) > 0 {
+		sb.WriteString("tags:\n")
+		for _, tag := range tags {
+			sb.WriteString(fmt.Sprintf("  - %s\n", strconv.Quote(tag)))
+		}
+	}
+	sb.WriteString("---\n\n")
+	return sb.String()
+}
+
+// ExportNote 把笔记导出为Markdown，指定output_path时保存到文件，否则直接返回Markdown文本
+// 内容的读取方式与get_note相同：本地记录近期核对过时使用本地内容，否则从墨问拉取
+func ExportNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	refresh, _ := args["refresh"].(bool)
+	titleHeading := true
+	if v, ok := args["title_heading"].(bool); ok {
+		titleHeading = v
+	}
+	frontMatter, _ := args["front_matter"].(bool)
+
+	read, err := readNote(ctx, noteID, refresh)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(read.Record.Content), &blocks); err != nil {
+		return errorResult("解析笔记内容失败", err), nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	title := noteTitle(read.Record.Content)
+	markdown := blocksToMarkdown(tenantID, blocks, titleHeading)
+	if frontMatter {
+		createdAt := ""
+		if t, err := parseDBTime(read.Record.CreatedAt); err == nil {
+			createdAt = t.Local().Format("2006-01-02 15:04")
+		}
+		tags, _ := GetNoteTags(tenantID, noteID)
+		sort.Strings(tags)
+		markdown = markdownFrontMatter(title, noteID, createdAt, tags) + markdown
+	}
+
+	var warnings []string
+	if read.FetchErr != nil {
+		warnings = append(warnings, fmt.Sprintf("⚠️ 从墨问拉取失败，导出的是本地内容: %v", read.FetchErr))
+	}
+	if len(read.Unknown) > 0 {
+		warnings = append(warnings, fmt.Sprintf("⚠️ 有 %d 个无法识别的节点未导出（%s）", len(read.Unknown), strings.Join(read.Unknown, ", ")))
+	}
+
+	output, _ := args["output_path"].(string)
+	if output == "" {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("📝 笔记 %s 的Markdown（来源: %s）\n", noteID, read.Source))
+		for _, warning := range warnings {
+			sb.WriteString(warning + "\n")
+		}
+		sb.WriteString("\n" + markdown)
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+
+	if title == "" {
+		title = noteID
+	}
+	path, err := exportOutputPath(ctx, output, title, ".md")
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if err := os.WriteFile(path, []byte(markdown), 0o644); err != nil {
+		return errorResult("写入文件失败", err), nil
+	}
+	text := fmt.Sprintf("✅ 笔记已导出为Markdown！\n\n笔记ID: %s\n标题: %s\n来源: %s\n保存路径: %s\n大小: %d 字节",
+		noteID, title, read.Source, path, len(markdown))
+	if len(warnings) > 0 {
+		text += "\n\n" + strings.Join(warnings, "\n")
+	}
+	return mcp.NewToolResultText(text), nil
+}
+
+// 导出Markdown工具
+var ExportNoteTool = mcp.NewTool("export_note",
+	mcp.WithDescription("把笔记导出为Markdown，便于迁移到Obsidian、Notion等其他工具。加粗、高亮、链接、引用、待办、分隔线都转换为对应的Markdown语法，图片、音频和PDF写为图片或链接（只有file_id的文件写为说明文字）。指定output_path时保存为文件，否则直接返回Markdown文本"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记（见set_current_note）"),
+	),
+	mcp.WithString("output_path",
+		mcp.Description("保存路径，例如 ~/Documents/note.md；不传时直接在结果中返回Markdown"),
+	),
+	mcp.WithBoolean("refresh",
+		mcp.Description("为true时忽略本地记录，总是从墨问拉取最新内容"),
+	),
+	mcp.WithBoolean("title_heading",
+		mcp.Description("第一段全部加粗时是否作为一级标题（# 标题），默认true"),
+	),
+	mcp.WithBoolean("front_matter",
+		mcp.Description("为true时在开头添加YAML头信息：标题、笔记ID、创建时间和标签"),
+	),
+)
+
+func exportNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ExportNote(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 故障注入环境变量，仅用于测试，默认全部关闭
+const (
+	// 每个请求额外增加的延迟，例如 500ms；可写成区间 200ms-2s，在区间内随机取值
+	FaultLatencyEnvVar = "MOWEN_FAULT_LATENCY"
+	// 返回5xx错误的比例，0到1之间，例如 0.2
+	FaultErrorRateEnvVar = "MOWEN_FAULT_ERROR_RATE"
+	// 返回的5xx状态码，默认503
+	FaultErrorStatusEnvVar = "MOWEN_FAULT_ERROR_STATUS"
+	// 响应体被截断的比例，0到1之间
+	FaultTruncateRateEnvVar = "MOWEN_FAULT_TRUNCATE_RATE"
+)
+
+// faultConfig 故障注入配置
+type faultConfig struct {
+	MinLatency   time.Duration
+	MaxLatency   time.Duration
+	ErrorRate    float64
+	ErrorStatus  int
+	TruncateRate float64
+}
+
+// enabled 是否配置了任意一种故障
+func (c faultConfig) enabled() bool {
+	return c.MaxLatency > 0 || c.ErrorRate > 0 || c.TruncateRate > 0
+}
+
+// loadFaultConfig 从环境变量读取故障注入配置
+func loadFaultConfig() faultConfig {
+	config := faultConfig{
+		ErrorRate:    envRate(FaultErrorRateEnvVar),
+		ErrorStatus:  envInt(FaultErrorStatusEnvVar, http.StatusServiceUnavailable),
+		TruncateRate: envRate(FaultTruncateRateEnvVar),
+	}
+	if config.ErrorStatus < 500 || config.ErrorStatus > 599 {
+		config.ErrorStatus = http.StatusServiceUnavailable
+	}
+
+	if value := envString(FaultLatencyEnvVar, ""); value != "" {
+		low, high, _ := strings.Cut(value, "-")
+		minLatency, err := time.ParseDuration(strings.TrimSpace(low))
+		if err != nil {
+			logger.Warnf("%s 格式错误，忽略: %s", FaultLatencyEnvVar, value)
+		} else {
+			config.MinLatency, config.MaxLatency = minLatency, minLatency
+			if high != "" {
+				if maxLatency, err := time.ParseDuration(strings.TrimSpace(high)); err == nil && maxLatency >= minLatency {
+					config.MaxLatency = maxLatency
+				}
+			}
+		}
+	}
+	return config
+}
+
+// envRate 读取0到1之间的比例，格式错误或超出范围时返回0
+func envRate(key string) float64 {
+	rate, err := strconv.ParseFloat(envString(key, "0"), 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0
+	}
+	return rate
+}
+
+var faultLogOnce sync.Once
+
+// faultTransport 按配置在请求上注入延迟、5xx错误和截断的响应体
+type faultTransport struct {
+	config faultConfig
+	base   http.RoundTripper
+}
+
+// newFaultTransport 配置了故障注入时包装base，否则原样返回base
+// base为nil时使用http.DefaultTransport
+func newFaultTransport(base http.RoundTripper) http.RoundTripper {
+	config := loadFaultConfig()
+	if !config.enabled() {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	faultLogOnce.Do(func() {
+		logger.Warnf("墨问API故障注入已开启: 延迟 %v-%v，错误比例 %.2f（状态码 %d），截断比例 %.2f",
+			config.MinLatency, config.MaxLatency, config.ErrorRate, config.ErrorStatus, config.TruncateRate)
+	})
+	return &faultTransport{config: config, base: base}
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if delay := t.latency(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	// 注入错误时不发送请求，模拟网关直接返回
+	if rand.Float64() < t.config.ErrorRate {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		body := fmt.Sprintf(`{"message":"fault injected: %d"}`, t.config.ErrorStatus)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", t.config.ErrorStatus, http.StatusText(t.config.ErrorStatus)),
+			StatusCode:    t.config.ErrorStatus,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || rand.Float64() >= t.config.TruncateRate {
+		return resp, err
+	}
+
+	// 只保留前一半响应体，模拟连接中断
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	data = data[:len(data)/2]
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// latency 本次请求注入的延迟
+func (t *faultTransport) latency() time.Duration {
+	if t.config.MaxLatency <= t.config.MinLatency {
+		return t.config.MinLatency
+	}
+	return t.config.MinLatency + time.Duration(rand.Int63n(int64(t.config.MaxLatency-t.config.MinLatency)))
+}
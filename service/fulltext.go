@@ -0,0 +1,141 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// searchTokens 把内容块JSON转换为全文索引的分词文本
+func searchTokens(content string) string {
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(content), &blocks); err != nil {
+		return ""
+	}
+	return strings.Join(segmentText(blocksText(blocks)), " ")
+}
+
+// indexNoteForSearch 更新笔记的全文索引，只保留最新内容
+func indexNoteForSearch(tenantID, noteID, content string) error {
+	tx, err := sqliteDB.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err = indexNoteTokens(tx, tenantID, noteID, content); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// indexNoteTokens 在已有事务中更新笔记的全文索引
+func indexNoteTokens(tx *sql.Tx, tenantID, noteID, content string) error {
+	tokens := searchTokens(content)
+	deleteStmt, err := txStmt(tx, "DELETE FROM "+sqliteFTSTable+" WHERE tenant_id = ? AND note_id = ?")
+	if err != nil {
+		return err
+	}
+	if _, err = deleteStmt.Exec(tenantID, noteID); err != nil {
+		return fmt.Errorf("删除旧索引失败: %v", err)
+	}
+	if tokens != "" {
+		insertStmt, err := txStmt(tx, "INSERT INTO "+sqliteFTSTable+" (tenant_id, note_id, tokens) VALUES (?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		if _, err = insertStmt.Exec(tenantID, noteID, tokens); err != nil {
+			return fmt.Errorf("写入索引失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// rebuildSearchIndexIfEmpty 全文索引为空而本地已有笔记时，为每篇笔记的最新内容建立索引
+func rebuildSearchIndexIfEmpty() {
+	var indexed int
+	if err := sqliteDB.QueryRow("SELECT COUNT(*) FROM " + sqliteFTSTable).Scan(&indexed); err != nil || indexed > 0 {
+		return
+	}
+
+	query := fmt.Sprintf(`SELECT tenant_id, note_id, content FROM %s
+		WHERE id IN (SELECT MAX(id) FROM %s GROUP BY tenant_id, note_id)`, dbTable, dbTable)
+	rows, err := sqliteDB.Query(query)
+	if err != nil {
+		logger.Warnf("读取笔记失败，无法建立全文索引: %v", err)
+		return
+	}
+	var records []NoteRecord
+	for rows.Next() {
+		var record NoteRecord
+		if err = rows.Scan(&record.TenantID, &record.NoteID, &record.Content); err != nil {
+			logger.Warnf("扫描结果失败: %v", err)
+			break
+		}
+		records = append(records, record)
+	}
+	rows.Close()
+	if len(records) == 0 {
+		return
+	}
+
+	for _, record := range records {
+		if err = indexNoteForSearch(record.TenantID, record.NoteID, record.Content); err != nil {
+			logger.Warnf("建立全文索引失败，noteID: %s, error: %v", record.NoteID, err)
+		}
+	}
+	logger.Infof("全文索引已建立，共 %d 篇笔记", len(records))
+}
+
+// buildMatchQuery 把查询语句分词后转换为FTS查询，各个词需全部命中
+func buildMatchQuery(query string) string {
+	tokens := segmentText(query)
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		terms = append(terms, `"`+strings.ReplaceAll(token, `"`, "")+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchNotesFullText 全文查询笔记，返回每篇笔记的最新记录
+func SearchNotesFullText(tenantID, query string, limit int) ([]NoteRecord, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	match := buildMatchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	querySQL := fmt.Sprintf(`SELECT id, tenant_id, note_id, content, summary, keywords, created_at FROM %s
+		WHERE id IN (
+			SELECT MAX(m.id) FROM %s m
+			JOIN %s f ON f.tenant_id = m.tenant_id AND f.note_id = m.note_id
+			WHERE %s MATCH ? AND f.tenant_id = ?
+			GROUP BY m.note_id
+		)
+		ORDER BY id DESC LIMIT ?`, dbTable, dbTable, sqliteFTSTable, sqliteFTSTable)
+	rows, err := sqliteDB.Query(querySQL, match, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("全文查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var results []NoteRecord
+	for rows.Next() {
+		var record NoteRecord
+		err = rows.Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary, &record.Keywords, &record.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		results = append(results, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return results, nil
+}
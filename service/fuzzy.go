@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 默认的最低相似度
+const defaultFuzzyThreshold = 0.5
+
+// normalizeFuzzy 统一大小写并去掉空白和标点
+func normalizeFuzzy(s string) []rune {
+	var runes []rune
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
+
+// editDistance 两个字符序列的编辑距离
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// partialEditDistance 查询词与目标文本中最相近片段的编辑距离
+// 目标文本的开头和结尾可以任意跳过，适合匹配只记得一部分的标题
+// 查询词不少于4个字时，片段中间多出的字符按半个编辑计算，例如"周会纪要"匹配"周会会议纪要"；
+// 更短的查询词容易在长文本中凑出匹配，仍按完整编辑计算
+func partialEditDistance(query, text []rune) float64 {
+	gapCost := 1.0
+	if len(query) >= 4 {
+		gapCost = 0.5
+	}
+
+	prev := make([]float64, len(text)+1)
+	curr := make([]float64, len(text)+1)
+	for i := 1; i <= len(query); i++ {
+		curr[0] = float64(i)
+		for j := 1; j <= len(text); j++ {
+			cost := 1.0
+			if query[i-1] == text[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+gapCost, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	best := prev[0]
+	for _, d := range prev {
+		best = min(best, d)
+	}
+	return best
+}
+
+// trigramSimilarity 三元组的Jaccard相似度
+func trigramSimilarity(a, b []rune) float64 {
+	grams := func(r []rune) map[string]bool {
+		set := make(map[string]bool)
+		for i := 0; i+3 <= len(r); i++ {
+			set[string(r[i:i+3])] = true
+		}
+		return set
+	}
+	ga, gb := grams(a), grams(b)
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	shared := 0
+	for g := range ga {
+		if gb[g] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ga)+len(gb)-shared)
+}
+
+// fuzzyScore 查询词与候选文本的相似度，取值0~1
+// 综合整体编辑距离、片段编辑距离和三元组相似度，取其中最高者
+func fuzzyScore(query, candidate string) float64 {
+	q, c := normalizeFuzzy(query), normalizeFuzzy(candidate)
+	if len(q) == 0 || len(c) == 0 {
+		return 0
+	}
+
+	score := 1 - float64(editDistance(q, c))/float64(max(len(q), len(c)))
+	if len(c) > len(q) {
+		// 片段匹配略低于整体匹配，避免短查询在长文本中得满分
+		partial := 1 - partialEditDistance(q, c)/float64(len(q))
+		score = max(score, partial*0.9)
+	}
+	return max(score, trigramSimilarity(q, c))
+}
+
+// fuzzyMatch 模糊匹配结果
+type fuzzyMatch struct {
+	Kind   string // tag, title
+	Value  string
+	NoteID string
+	Count  int
+	Score  float64
+}
+
+// FuzzySearch 模糊查找标签和笔记标题
+func FuzzySearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return mcp.NewToolResultText("❌ 查询内容不能为空"), nil
+	}
+	target, _ := args["target"].(string)
+	if target == "" {
+		target = "all"
+	}
+	threshold := defaultFuzzyThreshold
+	if v, ok := args["threshold"].(float64); ok && v > 0 && v <= 1 {
+		threshold = v
+	}
+	limit := 10
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = int(v)
+	}
+
+	tenantID := tenantFromContext(ctx)
+	var matches []fuzzyMatch
+
+	if target == "all" || target == "tag" {
+		tags, err := ListAllTags(tenantID)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		for tag, noteIDs := range tags {
+			if score := fuzzyScore(query, tag); score >= threshold {
+				matches = append(matches, fuzzyMatch{Kind: "tag", Value: tag, Count: len(noteIDs), Score: score})
+			}
+		}
+	}
+
+	if target == "all" || target == "title" {
+		records, err := ListLatestNotes(tenantID, maxSearchAllNotes)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		for _, record := range records {
+			title := noteTitle(record.Content)
+			if score := fuzzyScore(query, title); score >= threshold {
+				matches = append(matches, fuzzyMatch{Kind: "title", Value: title, NoteID: record.NoteID, Score: score})
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("📝 未找到与\"%s\"相近的标签或标题", query)), nil
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Value < matches[j].Value
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 与\"%s\"相近的结果 %d 条:\n\n", query, len(matches)))
+	for i, m := range matches {
+		if m.Kind == "tag" {
+			sb.WriteString(fmt.Sprintf("%d. 标签 %s（%d 篇笔记）相关度: %.2f\n", i+1, m.Value, m.Count, m.Score))
+		} else {
+			sb.WriteString(fmt.Sprintf("%d. 笔记 %s: %s 相关度: %.2f\n", i+1, m.NoteID, m.Value, m.Score))
+		}
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 模糊查找工具
+var FuzzySearchTool = mcp.NewTool("fuzzy_search",
+	mcp.WithDescription("模糊查找标签和笔记标题，容忍拼写错误和错别字（例如\"wrk\"找到\"work\"，\"工做\"找到\"工作\"），也可以用记得的部分标题查找笔记。结果按相关度排序。"),
+	mcp.WithString("query",
+		mcp.Required(),
+		mcp.Description("要查找的标签或标题片段"),
+	),
+	mcp.WithString("target",
+		mcp.Description("查找范围：tag(标签)、title(笔记标题)、all(全部，默认)"),
+		mcp.Enum("all", "tag", "title"),
+	),
+	mcp.WithNumber("threshold",
+		mcp.Description("最低相关度，0~1之间，默认0.5"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("最多返回的结果数，默认10"),
+	),
+)
+
+func fuzzySearchHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return FuzzySearch(ctx, request)
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 打卡表格中的标记
+const (
+	habitDoneMark    = "●"
+	habitMissedMark  = "○"
+	habitPendingMark = "·"
+)
+
+// SetHabitCheckin 记录或撤销某天的打卡，返回记录是否发生了变化
+func SetHabitCheckin(tenantID, habit, day string, done bool) (bool, error) {
+	if err := InitSQLite(); err != nil {
+		return false, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	var changed bool
+	err := execWrite(func(tx *sql.Tx) error {
+		var result sql.Result
+		var err error
+		if done {
+			result, err = tx.Exec("INSERT OR IGNORE INTO habit_checkins (tenant_id, habit, day) VALUES (?, ?, ?)", tenantID, habit, day)
+		} else {
+			result, err = tx.Exec("DELETE FROM habit_checkins WHERE tenant_id = ? AND habit = ? AND day = ?", tenantID, habit, day)
+		}
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		changed = n > 0
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("保存打卡失败: %v", err)
+	}
+	return changed, nil
+}
+
+// ListHabitCheckins 查询截至to（含）的全部打卡，按习惯分组返回打卡日期集合
+func ListHabitCheckins(tenantID, to string) (map[string]map[string]bool, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT habit, day FROM habit_checkins WHERE tenant_id = ? AND day <= ?", tenantID, to)
+	if err != nil {
+		return nil, fmt.Errorf("查询打卡失败: %v", err)
+	}
+	defer rows.Close()
+
+	habits := make(map[string]map[string]bool)
+	for rows.Next() {
+		var habit, day string
+		if err = rows.Scan(&habit, &day); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		if habits[habit] == nil {
+			habits[habit] = make(map[string]bool)
+		}
+		habits[habit][day] = true
+	}
+	return habits, rows.Err()
+}
+
+// sortedHabits 习惯名称按字母顺序排列
+func sortedHabits(habits map[string]map[string]bool) []string {
+	names := make([]string, 0, len(habits))
+	for name := range habits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildHabitGridBlocks 生成月度打卡表格：每个习惯一行，每周7天一组
+// 当月有打卡记录的习惯才会出现在表格中
+func buildHabitGridBlocks(tenantID string, month time.Time) ([]ContentBlock, error) {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local)
+	last := first.AddDate(0, 1, -1)
+	checkins, err := ListHabitCheckins(tenantID, last.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	today := dayOf(time.Now())
+
+	blocks := []ContentBlock{
+		{Texts: []TextNode{{Text: "✅ 习惯打卡 " + first.Format("2006-01"), Bold: true}}},
+		{Texts: []TextNode{{Text: fmt.Sprintf("%s 已打卡  %s 未打卡  %s 未到  每7天一组，从1日开始", habitDoneMark, habitMissedMark, habitPendingMark)}}},
+	}
+	for _, habit := range sortedHabits(checkins) {
+		var grid strings.Builder
+		done, elapsed := 0, 0
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			if day.Day() > 1 && (day.Day()-1)%7 == 0 {
+				grid.WriteString(" ")
+			}
+			switch {
+			case checkins[habit][day.Format("2006-01-02")]:
+				grid.WriteString(habitDoneMark)
+				done++
+				elapsed++
+			case day.After(today):
+				grid.WriteString(habitPendingMark)
+			default:
+				grid.WriteString(habitMissedMark)
+				elapsed++
+			}
+		}
+		if done == 0 {
+			continue
+		}
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{
+			{Text: habit, Bold: true},
+			{Text: fmt.Sprintf("（%d/%d天）", done, elapsed)},
+		}})
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: grid.String()}}})
+	}
+	return blocks, nil
+}
+
+// regenerateHabitNote 重建某月的打卡笔记，不存在时自动创建
+func regenerateHabitNote(ctx context.Context, client *MowenClient, month time.Time) (string, error) {
+	tenantID := tenantFromContext(ctx)
+	name := "habits:" + month.Format("2006-01")
+
+	unlock := lockNote(tenantID, "named:"+name)
+	defer unlock()
+
+	blocks, err := buildHabitGridBlocks(tenantID, month)
+	if err != nil {
+		return "", err
+	}
+
+	noteID, err := GetNamedNote(tenantID, name)
+	if err != nil {
+		return "", err
+	}
+	if noteID != "" {
+		return noteID, replaceNoteBlocks(ctx, client, noteID, blocks)
+	}
+
+	noteID, err = createNoteFromBlocks(ctx, client, blocks, nil)
+	if err != nil {
+		return "", err
+	}
+	if noteID == "" {
+		return "", fmt.Errorf("创建打卡笔记失败：接口未返回笔记ID")
+	}
+	return noteID, SetNamedNote(tenantID, name, noteID)
+}
+
+// habitStats 习惯在统计区间内的完成情况
+type habitStats struct {
+	Habit   string
+	Done    int
+	Days    int // 统计的天数，从区间开始或首次打卡算起
+	Current int // 截至今天的连续打卡天数，今天未打卡时从昨天算起
+	Longest int
+}
+
+// computeHabitStats 统计习惯在 [from, to] 内的完成率和连续打卡天数
+func computeHabitStats(habit string, days map[string]bool, from, to time.Time) habitStats {
+	stats := habitStats{Habit: habit}
+
+	var sorted []time.Time
+	for day := range days {
+		if t, err := time.ParseInLocation("2006-01-02", day, time.Local); err == nil {
+			sorted = append(sorted, t)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	if len(sorted) == 0 {
+		return stats
+	}
+
+	// 新习惯从首次打卡开始计算，避免完成率被之前的日子拉低
+	start := from
+	if sorted[0].After(start) {
+		start = sorted[0]
+	}
+	if !start.After(to) {
+		stats.Days = daysBetween(start, to) + 1
+	}
+
+	run := 0
+	for i, day := range sorted {
+		if !day.Before(start) && !day.After(to) {
+			stats.Done++
+		}
+		if i > 0 && daysBetween(sorted[i-1], day) == 1 {
+			run++
+		} else {
+			run = 1
+		}
+		stats.Longest = max(stats.Longest, run)
+	}
+	if last := sorted[len(sorted)-1]; daysBetween(last, to) <= 1 {
+		stats.Current = run
+	}
+	return stats
+}
+
+// TrackHabit 记录习惯打卡并更新当月的打卡笔记
+func TrackHabit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	habit, _ := args["habit"].(string)
+	habit = strings.TrimSpace(habit)
+	if habit == "" {
+		return mcp.NewToolResultText("❌ 习惯名称不能为空"), nil
+	}
+	today := dayOf(time.Now())
+	day := today
+	if dateStr, _ := args["date"].(string); dateStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 日期格式错误: %s，应为 YYYY-MM-DD", dateStr)), nil
+		}
+		if t.After(today) {
+			return mcp.NewToolResultText("❌ 不能为未来的日期打卡"), nil
+		}
+		day = t
+	}
+	undo, _ := args["undo"].(bool)
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	changed, err := SetHabitCheckin(tenantID, habit, day.Format("2006-01-02"), !undo)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	action := "已打卡"
+	switch {
+	case undo && changed:
+		action = "已撤销打卡"
+	case undo:
+		action = "当天没有打卡记录，无需撤销"
+	case !changed:
+		action = "当天已经打过卡"
+	}
+
+	noteID, err := regenerateHabitNote(ctx, client, day)
+	if err != nil {
+		return errorResult("打卡已保存，但更新打卡笔记失败", err), nil
+	}
+
+	resultText := fmt.Sprintf("✅ %s！\n\n习惯: %s\n日期: %s\n打卡笔记ID: %s", action, habit, day.Format("2006-01-02"), noteID)
+	if checkins, err := ListHabitCheckins(tenantID, today.Format("2006-01-02")); err == nil {
+		if stats := computeHabitStats(habit, checkins[habit], today, today); stats.Current > 0 {
+			resultText += fmt.Sprintf("\n🔥 已连续打卡 %d 天", stats.Current)
+		}
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// HabitReport 汇总习惯的完成率和连续打卡天数
+func HabitReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	habitFilter, _ := args["habit"].(string)
+	habitFilter = strings.TrimSpace(habitFilter)
+	days := 30
+	if v, ok := args["days"].(float64); ok && v > 0 {
+		days = int(v)
+	}
+
+	to := dayOf(time.Now())
+	from := to.AddDate(0, 0, 1-days)
+	checkins, err := ListHabitCheckins(tenantFromContext(ctx), to.Format("2006-01-02"))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if habitFilter != "" {
+		if checkins[habitFilter] == nil {
+			return mcp.NewToolResultText(fmt.Sprintf("📊 习惯 %s 没有打卡记录", habitFilter)), nil
+		}
+		checkins = map[string]map[string]bool{habitFilter: checkins[habitFilter]}
+	}
+	if len(checkins) == 0 {
+		return mcp.NewToolResultText("📊 还没有习惯打卡记录，使用track_habit开始打卡"), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 习惯报告 %s 至 %s\n\n", from.Format("2006-01-02"), to.Format("2006-01-02")))
+	for _, habit := range sortedHabits(checkins) {
+		stats := computeHabitStats(habit, checkins[habit], from, to)
+		rate := 0.0
+		if stats.Days > 0 {
+			rate = float64(stats.Done) * 100 / float64(stats.Days)
+		}
+		sb.WriteString(fmt.Sprintf("**%s**: 完成率 %.0f%%（%d/%d天），当前连续 %d 天，最长连续 %d 天\n",
+			habit, rate, stats.Done, stats.Days, stats.Current, stats.Longest))
+	}
+	return mcp.NewToolResultText(strings.TrimRight(sb.String(), "\n")), nil
+}
+
+// 习惯打卡工具
+var TrackHabitTool = mcp.NewTool("track_habit",
+	mcp.WithDescription("记录习惯打卡（如阅读、运动），并更新当月的\"习惯打卡\"笔记：每个习惯一行打卡表格。"),
+	mcp.WithString("habit",
+		mcp.Required(),
+		mcp.Description("习惯名称，如 阅读、跑步"),
+	),
+	mcp.WithString("date",
+		mcp.Description("打卡日期 YYYY-MM-DD，默认今天，可以补打过去的日期"),
+	),
+	mcp.WithBoolean("undo",
+		mcp.Description("为true时撤销该日期的打卡"),
+	),
+)
+
+// 习惯报告工具
+var HabitReportTool = mcp.NewTool("habit_report",
+	mcp.WithDescription("汇总习惯打卡的完成率、当前连续天数和最长连续天数。完成率从统计区间开始或首次打卡算起。"),
+	mcp.WithString("habit",
+		mcp.Description("只统计该习惯，不传时统计全部"),
+	),
+	mcp.WithNumber("days",
+		mcp.Description("统计最近多少天，默认30"),
+	),
+)
+
+func trackHabitHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := TrackHabit(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
+
+func habitReportHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return HabitReport(ctx, request)
+}
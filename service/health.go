@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// HealthCheck 汇报服务状态：数据库是否可用（不可用时为降级模式）、API密钥、模拟和故障注入配置等
+func HealthCheck(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var sb strings.Builder
+	healthy := true
+
+	sb.WriteString(fmt.Sprintf("服务版本: %s，运行时长: %s\n", Version, time.Since(processStart).Round(time.Second)))
+
+	// 数据库：不可用时尝试一次重新初始化，返回最新的状态
+	InitSQLite()
+	if ok, err, lastAttempt := sqliteStatus(); ok {
+		sb.WriteString("✅ 数据库: 正常\n")
+	} else {
+		healthy = false
+		sb.WriteString(fmt.Sprintf("❌ 数据库: 不可用（降级模式，本地记录、搜索和标签等功能暂不可用，后台会自动重试）\n   最近一次尝试: %s，错误: %v\n",
+			lastAttempt.Format("2006-01-02 15:04:05"), err))
+	}
+
+	switch {
+	case sessionFromContext(ctx).APIKey() != "":
+		sb.WriteString("✅ API密钥: 已通过会话配置\n")
+	case os.Getenv(APIKeyEnvVar) != "":
+		sb.WriteString("✅ API密钥: 已通过环境变量配置\n")
+	case mockReplaying():
+		sb.WriteString("✅ API密钥: 模拟回放模式，不需要\n")
+	default:
+		healthy = false
+		sb.WriteString(fmt.Sprintf("❌ API密钥: 未配置（%s）\n", APIKeyEnvVar))
+	}
+
+	if mode := mockMode(); mode != "" {
+		sb.WriteString(fmt.Sprintf("📝 模拟模式: %s\n", mode))
+	}
+	if loadFaultConfig().enabled() {
+		sb.WriteString("📝 故障注入: 已开启\n")
+	}
+	if drifts := listSchemaDrifts(); len(drifts) > 0 {
+		healthy = false
+		sb.WriteString(fmt.Sprintf("❌ API响应格式: 发现 %d 个接口的格式变化，详见check_api_compat\n", len(drifts)))
+	}
+
+	if healthy {
+		return mcp.NewToolResultText("✅ 服务运行正常\n\n" + strings.TrimRight(sb.String(), "\n")), nil
+	}
+	return mcp.NewToolResultText("❌ 服务处于降级状态\n\n" + strings.TrimRight(sb.String(), "\n")), nil
+}
+
+// 健康检查工具
+var HealthCheckTool = mcp.NewTool("health_check",
+	mcp.WithDescription("检查服务状态：数据库是否可用（不可用时服务以降级模式运行，只能调用墨问API）、API密钥是否配置，以及API响应格式是否有变化。"),
+)
+
+func healthCheckHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return HealthCheck(ctx, request)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 生命周期钩子名称
+const (
+	HookBeforeCreate = "before_create" // 创建笔记前，可修改内容块和标签，返回错误则取消创建
+	HookAfterCreate  = "after_create"  // 创建笔记后，错误只记录日志
+	HookBeforeEdit   = "before_edit"   // 编辑笔记前，可修改内容块，返回错误则取消编辑
+	HookBeforeUpload = "before_upload" // 上传文件前，可修改文件路径，返回错误则取消上传
+)
+
+// 外部命令钩子的超时时间
+const hookCommandTimeout = 10 * time.Second
+
+// HookEvent 钩子事件，钩子可以直接修改其中的字段
+type HookEvent struct {
+	Hook       string         `json:"hook"`
+	TenantID   string         `json:"tenant_id,omitempty"`
+	NoteID     string         `json:"note_id,omitempty"`
+	Blocks     []ContentBlock `json:"blocks,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	FileType   string         `json:"file_type,omitempty"`
+	SourceType string         `json:"source_type,omitempty"`
+	SourcePath string         `json:"source_path,omitempty"`
+}
+
+// HookFunc 钩子函数
+type HookFunc func(ctx context.Context, event *HookEvent) error
+
+var (
+	hooksMu sync.RWMutex
+	hooks   = make(map[string][]HookFunc)
+)
+
+// RegisterHook 注册进程内钩子，按注册顺序执行
+func RegisterHook(name string, fn HookFunc) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks[name] = append(hooks[name], fn)
+}
+
+// hookEnvVar 外部命令钩子的环境变量名，例如 MOWEN_HOOK_BEFORE_CREATE
+func hookEnvVar(name string) string {
+	return "MOWEN_HOOK_" + strings.ToUpper(name)
+}
+
+// runHooks 依次执行进程内钩子和配置的外部命令钩子
+func runHooks(ctx context.Context, event *HookEvent) error {
+	event.TenantID = tenantFromContext(ctx)
+
+	hooksMu.RLock()
+	fns := append([]HookFunc(nil), hooks[event.Hook]...)
+	hooksMu.RUnlock()
+
+	for _, fn := range fns {
+		if err := fn(ctx, event); err != nil {
+			return withErrorCode(ErrPermissionDenied, fmt.Errorf("钩子 %s 拒绝了操作: %w", event.Hook, err))
+		}
+	}
+
+	if command := envString(hookEnvVar(event.Hook), ""); command != "" {
+		if err := runHookCommand(ctx, command, event); err != nil {
+			return withErrorCode(ErrPermissionDenied, fmt.Errorf("钩子 %s 拒绝了操作: %w", event.Hook, err))
+		}
+	}
+	return nil
+}
+
+// runHookCommand 执行外部命令钩子
+// 事件以JSON写入命令的标准输入；命令输出非空时按JSON解析并替换事件内容；
+// 命令以非零状态退出时视为拒绝，标准错误输出作为原因
+func runHookCommand(ctx context.Context, command string, event *HookEvent) error {
+	fields := strings.Fields(command)
+	input, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化钩子事件失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hookCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return fmt.Errorf("执行钩子命令失败: %w", err)
+	}
+
+	if output := bytes.TrimSpace(stdout.Bytes()); len(output) > 0 {
+		hook := event.Hook
+		if err := json.Unmarshal(output, event); err != nil {
+			return fmt.Errorf("解析钩子命令输出失败: %w", err)
+		}
+		event.Hook = hook
+	}
+	return nil
+}
+
+// runAfterHooks 执行"之后"类钩子，错误只记录日志，不影响已完成的操作
+func runAfterHooks(ctx context.Context, event *HookEvent) {
+	if err := runHooks(ctx, event); err != nil {
+		logger.Warnf("执行钩子 %s 失败: %v", event.Hook, err)
+	}
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 一次最多导入的HTML笔记数
+const maxHTMLImportNotes = 500
+
+// 创建时间可能所在的meta标签，按优先级排列
+var htmlCreatedMetaKeys = []string{"created", "creation-date", "dcterms.created", "date", "article:published_time", "modified", "dcterms.modified"}
+
+// 导出工具写入的日期格式，不带时区的按本地时间处理
+var htmlDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"Monday, January 2, 2006 at 3:04:05 PM",
+	"Monday, January 2, 2006 at 3:04 PM",
+	"January 2, 2006 at 3:04 PM",
+	"January 2, 2006",
+	"2006年1月2日 15:04",
+	"2006年1月2日",
+}
+
+// 内嵌图片的MIME类型对应的扩展名
+var dataURIExtensions = map[string]string{
+	"image/png": ".png", "image/jpeg": ".jpg", "image/jpg": ".jpg", "image/gif": ".gif",
+	"image/webp": ".webp", "image/bmp": ".bmp", "image/heic": ".heic", "image/heif": ".heif",
+}
+
+// htmlNote 待导入的一篇HTML笔记
+type htmlNote struct {
+	Path      string
+	RelPath   string
+	Title     string
+	Folder    string
+	CreatedAt time.Time
+	Blocks    []ContentBlock
+	Hash      string
+	NoteID    string
+	Existing  bool
+}
+
+// parseHTMLDate 按常见格式解析日期
+func parseHTMLDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range htmlDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// htmlNoteCreatedAt 笔记的创建时间：优先使用meta标签和<time>标签，没有时使用文件修改时间
+func htmlNoteCreatedAt(page string, info fs.FileInfo) time.Time {
+	meta := parseMetaTags(page)
+	for _, key := range htmlCreatedMetaKeys {
+		for _, value := range meta[key] {
+			if t, ok := parseHTMLDate(value); ok {
+				return t
+			}
+		}
+	}
+	for _, m := range htmlTokenPattern.FindAllStringSubmatch(page, -1) {
+		if strings.EqualFold(m[2], "time") && m[1] == "" {
+			if t, ok := parseHTMLDate(htmlAttrs(m[3])["datetime"]); ok {
+				return t
+			}
+		}
+	}
+	return info.ModTime()
+}
+
+// decodeDataURI 把内嵌的base64图片写入临时目录，返回文件路径
+func decodeDataURI(dir, uri string, n int) (string, error) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return "", fmt.Errorf("只支持base64编码的内嵌图片")
+	}
+	ext, ok := dataURIExtensions[strings.ToLower(strings.TrimSuffix(header, ";base64"))]
+	if !ok {
+		return "", fmt.Errorf("不支持的内嵌图片类型 %s", strings.TrimSuffix(header, ";base64"))
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+	if err != nil {
+		return "", fmt.Errorf("解码内嵌图片失败: %w", err)
+	}
+	if len(raw) > maxAttachmentSize {
+		return "", fmt.Errorf("内嵌图片超过大小上限 %d MB", maxAttachmentSize>>20)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("image-%d%s", n, ext))
+	return path, os.WriteFile(path, raw, 0o644)
+}
+
+// htmlNoteImporter 导入过程中的状态
+type htmlNoteImporter struct {
+	ctx      context.Context
+	client   *MowenClient
+	root     string
+	tmpDir   string
+	images   int
+	warnings []string
+}
+
+// resolveImages 上传笔记中的图片：内嵌图片解码后上传，相对路径和file://地址在导入目录中查找，网络图片保持原样
+func (im *htmlNoteImporter) resolveImages(note *htmlNote) {
+	result := make([]ContentBlock, 0, len(note.Blocks))
+	for _, block := range note.Blocks {
+		if block.Type != "file" || block.SourceType != "local" {
+			result = append(result, block)
+			continue
+		}
+		src := block.SourcePath
+		var path string
+		var err error
+		switch {
+		case strings.HasPrefix(src, "data:"):
+			im.images++
+			path, err = decodeDataURI(im.tmpDir, src, im.images)
+		case strings.Contains(src, "://") && !strings.HasPrefix(src, "file://"):
+			err = fmt.Errorf("不支持的图片地址 %s", src)
+		default:
+			if u, parseErr := url.Parse(src); parseErr == nil && u.Scheme == "file" {
+				path = u.Path
+			} else if unescaped, unescapeErr := url.PathUnescape(src); unescapeErr == nil {
+				path = filepath.Join(filepath.Dir(note.Path), filepath.FromSlash(unescaped))
+			} else {
+				path = filepath.Join(filepath.Dir(note.Path), filepath.FromSlash(src))
+			}
+			// 图片必须位于导入目录中，避免通过HTML或符号链接读取沙箱外的文件
+			if resolved, resolveErr := canonicalPath(path); resolveErr == nil {
+				path = resolved
+			}
+			if !isWithinDir(path, im.root) {
+				err = fmt.Errorf("图片 %s 不在导入目录中", src)
+			}
+		}
+		if err == nil {
+			block, err = uploadImportAsset(im.ctx, im.client, path, block)
+		}
+		if err != nil {
+			name := src
+			if strings.HasPrefix(src, "data:") {
+				name = "内嵌图片"
+			}
+			im.warnings = append(im.warnings, fmt.Sprintf("%s: %s 上传失败: %v", note.RelPath, name, err))
+			continue
+		}
+		result = append(result, block)
+	}
+	note.Blocks = result
+}
+
+// loadHTMLNote 读取并转换一篇HTML笔记
+func loadHTMLNote(root, path string, info fs.FileInfo) (*htmlNote, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	page := string(data)
+	sum := sha256.Sum256(data)
+	note := &htmlNote{
+		Path:      path,
+		CreatedAt: htmlNoteCreatedAt(page, info),
+		Blocks:    htmlToBlocks(page),
+		Hash:      hex.EncodeToString(sum[:8]),
+	}
+	note.RelPath, _ = filepath.Rel(root, path)
+	if dir := filepath.Dir(note.RelPath); dir != "." {
+		note.Folder = filepath.Base(dir)
+	}
+
+	// 标题依次取<title>、第一段文字和文件名
+	note.Title = htmlTitle(page)
+	if note.Title == "" && len(note.Blocks) > 0 {
+		note.Title = strings.TrimSpace(blockPlainText(note.Blocks[0]))
+	}
+	if note.Title == "" {
+		note.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return note, nil
+}
+
+// collectHTMLNotes 列出目录中的HTML文件，跳过隐藏文件和目录
+func collectHTMLNotes(root string, recursive bool) ([]*htmlNote, error) {
+	var notes []*htmlNote
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if path != root && (!recursive || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(name))
+		if strings.HasPrefix(name, ".") || !entry.Type().IsRegular() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+		if len(notes) >= maxHTMLImportNotes {
+			return fmt.Errorf("HTML文件超过单次导入上限 %d 个，请分批导入", maxHTMLImportNotes)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		note, err := loadHTMLNote(root, path, info)
+		if err != nil {
+			return fmt.Errorf("读取 %s 失败: %w", path, err)
+		}
+		notes = append(notes, note)
+		return nil
+	})
+	return notes, err
+}
+
+// ImportHTMLNotes 批量导入目录中的HTML笔记（例如Apple Notes导出工具生成的文件），每个文件一篇笔记
+func ImportHTMLNotes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	dirPath, _ := args["dir_path"].(string)
+	if dirPath == "" {
+		return mcp.NewToolResultText("❌ dir_path不能为空"), nil
+	}
+	tags := []string{"备忘录导入"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	recursive := true
+	if v, ok := args["recursive"].(bool); ok {
+		recursive = v
+	}
+	folderTags := true
+	if v, ok := args["folder_tags"].(bool); ok {
+		folderTags = v
+	}
+	dateLine := true
+	if v, ok := args["date_line"].(bool); ok {
+		dateLine = v
+	}
+	dryRun, _ := args["dry_run"].(bool)
+
+	root, err := checkLocalPath(ctx, dirPath)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ %s 不是目录", dirPath)), nil
+	}
+	notes, err := collectHTMLNotes(root, recursive)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if len(notes) == 0 {
+		return mcp.NewToolResultText("❌ 目录中没有找到HTML文件（.html或.htm）"), nil
+	}
+	// 按创建时间从早到晚导入，墨问中的顺序与原来一致
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].CreatedAt.Before(notes[j].CreatedAt) })
+
+	var sb strings.Builder
+	if dryRun {
+		sb.WriteString(fmt.Sprintf("📝 预览：共 %d 篇笔记，未创建笔记\n\n", len(notes)))
+		for _, note := range notes {
+			sb.WriteString(fmt.Sprintf("- %s %s（%s）\n", note.CreatedAt.Local().Format("2006-01-02 15:04"), note.Title, note.RelPath))
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	tmpDir, err := os.MkdirTemp("", "mowen-html-import-")
+	if err != nil {
+		return errorResult("创建临时目录失败", err), nil
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tenantID := tenantFromContext(ctx)
+	im := &htmlNoteImporter{ctx: ctx, client: client, root: root, tmpDir: tmpDir}
+	var created, existing int
+	var failures []string
+	for _, note := range notes {
+		// 同一文件内容只导入一次
+		name := "html:" + note.Hash
+		if id, err := GetNamedNote(tenantID, name); err == nil && id != "" {
+			note.NoteID, note.Existing = id, true
+			existing++
+			continue
+		}
+
+		im.resolveImages(note)
+		blocks := note.Blocks
+		if len(blocks) == 0 || strings.TrimSpace(blockPlainText(blocks[0])) != note.Title {
+			blocks = append([]ContentBlock{{Texts: []TextNode{{Text: note.Title, Bold: true}}}}, blocks...)
+		}
+		// 墨问接口不能指定创建时间，原始日期写在标题下方
+		if dateLine {
+			dateBlock := ContentBlock{Texts: []TextNode{{Text: "创建于 " + note.CreatedAt.Local().Format("2006-01-02 15:04")}}}
+			blocks = append(blocks[:1], append([]ContentBlock{dateBlock}, blocks[1:]...)...)
+		}
+		noteTags := tags
+		if folderTags && note.Folder != "" {
+			noteTags = append(append([]string(nil), tags...), note.Folder)
+		}
+
+		noteID, err := createNoteFromBlocks(ctx, client, blocks, &Settings{Tags: noteTags})
+		if err == nil && noteID == "" {
+			err = fmt.Errorf("接口未返回笔记ID")
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", note.RelPath, err))
+			continue
+		}
+		note.NoteID = noteID
+		created++
+		if err := SetNamedNote(tenantID, name, noteID); err != nil {
+			im.warnings = append(im.warnings, fmt.Sprintf("%s: 记录导入状态失败: %v", note.RelPath, err))
+		}
+	}
+
+	if created == 0 && existing == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 导入失败:\n%s", strings.Join(failures, "\n"))), nil
+	}
+	sb.WriteString(fmt.Sprintf("✅ HTML笔记导入完成！\n\n新建笔记: %d 篇\n已导入过: %d 篇\n失败: %d 篇\n标签: %s\n\n",
+		created, existing, len(failures), strings.Join(tags, ", ")))
+	for _, note := range notes {
+		switch {
+		case note.Existing:
+			sb.WriteString(fmt.Sprintf("- %s（已导入过: %s）\n", note.Title, note.NoteID))
+		case note.NoteID != "":
+			sb.WriteString(fmt.Sprintf("- %s（%s）\n", note.Title, note.NoteID))
+		}
+	}
+	if len(failures) > 0 {
+		sb.WriteString(fmt.Sprintf("\n❌ 失败的文件:\n%s\n", strings.Join(failures, "\n")))
+	}
+	if len(im.warnings) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ 警告:\n%s\n", strings.Join(im.warnings, "\n")))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 导入HTML笔记工具
+var ImportHTMLNotesTool = mcp.NewTool("import_html_notes",
+	mcp.WithDescription("批量导入目录中的HTML笔记（例如Apple Notes导出工具生成的HTML文件）：每个文件一篇笔记，内嵌图片和相对路径图片自动上传，按原始创建时间从早到晚导入。创建时间取自meta标签或<time>标签，没有时使用文件修改时间；墨问不支持指定创建时间，原始日期写在标题下方。同一文件只会导入一次。"),
+	mcp.WithString("dir_path",
+		mcp.Required(),
+		mcp.Description("HTML文件所在目录，例如 ~/Documents/AppleNotesExport"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组，默认 [\"备忘录导入\"]"),
+	),
+	mcp.WithBoolean("recursive",
+		mcp.Description("是否包含子目录，默认true"),
+	),
+	mcp.WithBoolean("folder_tags",
+		mcp.Description("是否把文件所在的子目录名（通常是原来的文件夹）作为额外标签，默认true"),
+	),
+	mcp.WithBoolean("date_line",
+		mcp.Description("是否在标题下方写入原始创建日期，默认true"),
+	),
+	mcp.WithBoolean("dry_run",
+		mcp.Description("为true时只列出将要导入的笔记，不创建笔记"),
+	),
+)
+
+func importHTMLNotesHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ImportHTMLNotes(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
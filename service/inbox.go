@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// InboxItem 收件箱条目
+type InboxItem struct {
+	ID        int    `json:"id"`
+	Kind      string `json:"kind"` // text, url, file
+	Content   string `json:"content"`
+	NoteID    string `json:"note_id"`
+	Processed bool   `json:"processed"`
+	CreatedAt string `json:"created_at"`
+}
+
+// SaveInboxItem 保存收件箱条目
+func SaveInboxItem(tenantID, kind, content, noteID string) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	err := execWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO inbox_items (tenant_id, kind, content, note_id) VALUES (?, ?, ?, ?)",
+			tenantID, kind, content, noteID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存收件箱条目失败: %v", err)
+	}
+	return nil
+}
+
+// ListInboxItems 查询未整理的收件箱条目
+func ListInboxItems(tenantID string, limit int) ([]InboxItem, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT id, kind, content, note_id, processed, created_at FROM inbox_items WHERE tenant_id = ? AND processed = 0 ORDER BY id LIMIT ?",
+		tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var items []InboxItem
+	for rows.Next() {
+		var item InboxItem
+		if err = rows.Scan(&item.ID, &item.Kind, &item.Content, &item.NoteID, &item.Processed, &item.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return items, nil
+}
+
+// MarkInboxItemsProcessed 将收件箱条目标记为已整理，返回实际更新的条数
+func MarkInboxItemsProcessed(tenantID string, ids []int) (int64, error) {
+	if err := InitSQLite(); err != nil {
+		return 0, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	var total int64
+	for _, id := range ids {
+		result, err := sqliteDB.Exec("UPDATE inbox_items SET processed = 1 WHERE tenant_id = ? AND id = ?", tenantID, id)
+		if err != nil {
+			return total, fmt.Errorf("更新收件箱条目失败: %v", err)
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+	return total, nil
+}
+
+// fileTypeFromExt 根据扩展名推断内容块的文件类型
+func fileTypeFromExt(path string) (string, error) {
+	fileType, err := getFileTypeFromPath(path)
+	if err != nil {
+		return "", err
+	}
+	switch fileType {
+	case 1:
+		return "image", nil
+	case 2:
+		return "audio", nil
+	default:
+		return "pdf", nil
+	}
+}
+
+// Capture 将文本、链接或文件收集到收件箱笔记
+func Capture(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	args := request.Params.Arguments
+	text, _ := args["text"].(string)
+	link, _ := args["url"].(string)
+	filePath, _ := args["file_path"].(string)
+
+	now := time.Now()
+	stamp := TextNode{Text: now.Format("2006-01-02 15:04") + " ", Bold: true}
+
+	var kind, content string
+	var blocks []ContentBlock
+	switch {
+	case filePath != "":
+		fileType, err := fileTypeFromExt(filePath)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		kind, content = "file", filePath
+		caption := text
+		if caption == "" {
+			caption = filepath.Base(filePath)
+		}
+		blocks = []ContentBlock{
+			{Texts: []TextNode{stamp, {Text: caption}}},
+			{Type: "file", FileType: fileType, SourceType: "local", SourcePath: filePath},
+		}
+	case link != "":
+		kind, content = "url", link
+		title := text
+		if title == "" {
+			title = link
+		}
+		blocks = []ContentBlock{{Texts: []TextNode{stamp, {Text: title, Link: link}}}}
+	case text != "":
+		kind, content = "text", text
+		blocks = []ContentBlock{{Texts: []TextNode{stamp, {Text: text}}}}
+	default:
+		return mcp.NewToolResultText("❌ text、url、file_path至少需要提供一个"), nil
+	}
+
+	// rolling模式使用同一篇收件箱笔记，daily模式每天一篇
+	name, title := "inbox", "📥 收件箱"
+	if mode, _ := args["mode"].(string); mode == "daily" {
+		day := now.Format("2006-01-02")
+		name, title = "inbox:"+day, "📥 收件箱 "+day
+	}
+
+	noteID, created, err := appendToNamedNote(ctx, client, name, title, blocks)
+	if err != nil {
+		return errorResult("收集失败", err), nil
+	}
+	if err = SaveInboxItem(tenantFromContext(ctx), kind, content, noteID); err != nil {
+		return errorResult("内容已写入笔记，但记录收件箱条目失败", err), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 已收集到收件箱！\n\n类型: %s\n笔记ID: %s", kind, noteID)
+	if created {
+		resultText += "\n（收件箱笔记不存在，已自动创建）"
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// ProcessInbox 列出待整理的收件箱条目，并可将条目标记为已整理
+func ProcessInbox(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	tenantID := tenantFromContext(ctx)
+
+	var resultText strings.Builder
+	if idsStr, _ := args["mark_processed"].(string); idsStr != "" {
+		var ids []int
+		if err := json.Unmarshal([]byte(idsStr), &ids); err != nil {
+			return errorResult("mark_processed JSON解析错误", err), nil
+		}
+		n, err := MarkInboxItemsProcessed(tenantID, ids)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		resultText.WriteString(fmt.Sprintf("✅ 已将 %d 条收件箱条目标记为已整理\n\n", n))
+	}
+
+	limit := 50
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+	items, err := ListInboxItems(tenantID, limit)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if len(items) == 0 {
+		resultText.WriteString("📥 收件箱已清空，没有待整理的条目")
+		return mcp.NewToolResultText(resultText.String()), nil
+	}
+
+	resultText.WriteString(fmt.Sprintf("📥 待整理条目 %d 条:\n\n", len(items)))
+	for _, item := range items {
+		resultText.WriteString(fmt.Sprintf("**#%d** [%s] %s\n", item.ID, item.Kind, item.Content))
+		resultText.WriteString(fmt.Sprintf("收集时间: %s  笔记ID: %s\n\n", item.CreatedAt, item.NoteID))
+	}
+	resultText.WriteString("整理完成后，使用mark_processed参数传入条目ID将其标记为已整理。")
+	return mcp.NewToolResultText(resultText.String()), nil
+}
+
+// 收集工具
+var CaptureTool = mcp.NewTool("capture",
+	mcp.WithDescription("快速收集文本、链接或文件到收件箱笔记（GTD式收集），之后可以用process_inbox统一整理。"),
+	mcp.WithString("text",
+		mcp.Description("要收集的文本；与url或file_path同时提供时作为说明文字"),
+	),
+	mcp.WithString("url",
+		mcp.Description("要收集的链接"),
+	),
+	mcp.WithString("file_path",
+		mcp.Description("要收集的本地文件路径（图片、音频或PDF）"),
+	),
+	mcp.WithString("mode",
+		mcp.Description("收件箱模式：rolling(默认，使用同一篇收件箱笔记)、daily(每天一篇收件箱笔记)"),
+	),
+)
+
+// 整理收件箱工具
+var ProcessInboxTool = mcp.NewTool("process_inbox",
+	mcp.WithDescription("列出收件箱中尚未整理的条目，用于分类整理；可以同时把已处理的条目标记为已整理。"),
+	mcp.WithString("mark_processed",
+		mcp.Description("要标记为已整理的条目ID列表JSON字符串，例如：[1, 2, 3]"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("最多返回的条目数，默认50"),
+	),
+)
+
+func captureHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := Capture(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
+
+func processInboxHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ProcessInbox(ctx, request)
+}
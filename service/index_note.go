@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 创建笔记后是否自动重建目录笔记
+const AutoIndexEnvVar = "MOWEN_AUTO_INDEX"
+
+// 目录笔记的具名笔记名称
+const indexNoteName = "index"
+
+// 目录中未打标签的笔记分组名称
+const untaggedGroup = "未分类"
+
+// buildIndexBlocks 生成目录笔记的内容块：置顶分组 + 按标签分组的最近笔记
+func buildIndexBlocks(tenantID string, recentLimit int) ([]ContentBlock, int, error) {
+	pinned, err := ListPinnedNotes(tenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+	recent, err := ListLatestNotes(tenantID, recentLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 具名笔记（目录、日志、收件箱等）不出现在目录中
+	named, err := ListNamedNoteIDs(tenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	blocks := []ContentBlock{
+		{Texts: []TextNode{{Text: "📚 目录", Bold: true}}},
+		{Texts: []TextNode{{Text: "更新时间: " + time.Now().Format("2006-01-02 15:04")}}},
+	}
+	count := 0
+
+	pinnedSet := make(map[string]bool)
+	if len(pinned) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "📌 置顶", Bold: true}}})
+		for _, noteID := range pinned {
+			pinnedSet[noteID] = true
+			blocks = append(blocks, ContentBlock{Type: "note", NoteID: noteID})
+			count++
+		}
+	}
+
+	groups := make(map[string][]string)
+	for _, record := range recent {
+		if named[record.NoteID] || pinnedSet[record.NoteID] {
+			continue
+		}
+		tags, err := GetNoteTags(tenantID, record.NoteID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(tags) == 0 {
+			tags = []string{untaggedGroup}
+		}
+		for _, tag := range tags {
+			groups[tag] = append(groups[tag], record.NoteID)
+		}
+		count++
+	}
+
+	tagNames := make([]string, 0, len(groups))
+	for tag := range groups {
+		tagNames = append(tagNames, tag)
+	}
+	sort.Slice(tagNames, func(i, j int) bool {
+		// 未分类放在最后
+		if tagNames[i] == untaggedGroup || tagNames[j] == untaggedGroup {
+			return tagNames[j] == untaggedGroup && tagNames[i] != untaggedGroup
+		}
+		return tagNames[i] < tagNames[j]
+	})
+
+	for _, tag := range tagNames {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "# " + tag, Bold: true}}})
+		for _, noteID := range groups[tag] {
+			blocks = append(blocks, ContentBlock{Type: "note", NoteID: noteID})
+		}
+	}
+	return blocks, count, nil
+}
+
+// regenerateIndexNote 重建目录笔记，不存在时自动创建，返回笔记ID和收录的笔记数
+func regenerateIndexNote(ctx context.Context, client *MowenClient, recentLimit int) (string, int, error) {
+	tenantID := tenantFromContext(ctx)
+
+	unlock := lockNote(tenantID, "named:"+indexNoteName)
+	defer unlock()
+
+	blocks, count, err := buildIndexBlocks(tenantID, recentLimit)
+	if err != nil {
+		return "", 0, err
+	}
+
+	noteID, err := GetNamedNote(tenantID, indexNoteName)
+	if err != nil {
+		return "", 0, err
+	}
+	if noteID != "" {
+		return noteID, count, replaceNoteBlocks(ctx, client, noteID, blocks)
+	}
+
+	noteID, err = createNoteFromBlocks(ctx, client, blocks, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	if noteID == "" {
+		return "", 0, fmt.Errorf("创建目录笔记失败：接口未返回笔记ID")
+	}
+	return noteID, count, SetNamedNote(tenantID, indexNoteName, noteID)
+}
+
+// autoRegenerateIndex 创建笔记后按配置在后台重建目录笔记
+func autoRegenerateIndex(ctx context.Context, client *MowenClient) {
+	if !envBool(AutoIndexEnvVar, false) {
+		return
+	}
+	go func() {
+		if _, _, err := regenerateIndexNote(ctx, client, 50); err != nil {
+			logger.Warnf("自动重建目录笔记失败: %v", err)
+		}
+	}()
+}
+
+// UpdateIndexNote 重建目录笔记
+func UpdateIndexNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	recentLimit := 50
+	if v, ok := request.Params.Arguments["recent_limit"].(float64); ok && v > 0 {
+		recentLimit = int(v)
+	}
+
+	noteID, count, err := regenerateIndexNote(ctx, client, recentLimit)
+	if err != nil {
+		return errorResult("更新目录笔记失败", err), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 目录笔记已更新！\n\n笔记ID: %s\n收录笔记数: %d", noteID, count)), nil
+}
+
+// PinNote 置顶或取消置顶笔记
+func PinNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	unpin, _ := args["unpin"].(bool)
+
+	if err := SetNotePinned(tenantFromContext(ctx), noteID, !unpin); err != nil {
+		return errorResult("", err), nil
+	}
+
+	action := "置顶"
+	if unpin {
+		action = "取消置顶"
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 已%s笔记: %s\n\n调用update_index_note后目录笔记生效", action, noteID)), nil
+}
+
+// 更新目录笔记工具
+var UpdateIndexNoteTool = mcp.NewTool("update_index_note",
+	mcp.WithDescription("重新生成\"目录\"笔记：包含置顶笔记和按标签分组的最近笔记的内链，作为墨问中的导航首页。设置环境变量MOWEN_AUTO_INDEX=true后每次创建笔记都会自动更新。"),
+	mcp.WithNumber("recent_limit",
+		mcp.Description("收录的最近笔记数量，默认50"),
+	),
+)
+
+// 置顶笔记工具
+var PinNoteTool = mcp.NewTool("pin_note",
+	mcp.WithDescription("将笔记置顶到目录笔记的顶部，或取消置顶"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记（见set_current_note）"),
+	),
+	mcp.WithBoolean("unpin",
+		mcp.Description("为true时取消置顶"),
+	),
+)
+
+func updateIndexNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return UpdateIndexNote(ctx, request)
+}
+
+func pinNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return PinNote(ctx, request)
+}
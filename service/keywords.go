@@ -0,0 +1,163 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 每篇笔记保存的关键词数量上限
+const maxNoteKeywords = 10
+
+// 常见的无意义中文词和英文停用词
+var keywordStopwords = map[string]bool{
+	"我们": true, "你们": true, "他们": true, "一个": true, "这个": true, "那个": true,
+	"没有": true, "可以": true, "什么": true, "因为": true, "所以": true, "但是": true,
+	"然后": true, "已经": true, "如果": true, "就是": true, "还是": true, "自己": true,
+	"这样": true, "那样": true, "这些": true, "那些": true, "需要": true, "进行": true,
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"are": true, "was": true, "you": true, "not": true, "but": true, "have": true,
+	"from": true, "has": true, "will": true, "can": true, "its": true, "into": true,
+}
+
+// 单独成词时通常不构成关键词的汉字
+const keywordEdgeParticles = "的了是在和与及或也就都而着过吗呢吧啊把被给让对从向"
+
+// keywordCandidates 统计候选词频：按分词结果计数，去掉停用词、单个汉字和纯数字
+// 分词使用搜索引擎模式，长词和其中的短词都会出现，由extractKeywords取舍
+func keywordCandidates(text string) map[string]int {
+	counts := make(map[string]int)
+	for _, token := range segmentText(text) {
+		runes := []rune(token)
+		if keywordStopwords[token] || len(runes) < 2 || strings.IndexFunc(token, unicode.IsLetter) < 0 {
+			continue
+		}
+		counts[token]++
+	}
+	return counts
+}
+
+// extractKeywords 提取关键词，按重要程度排序
+// 汉字词越长得分越高，被选中的长词会覆盖其中包含的短词
+func extractKeywords(text string, limit int) []string {
+	type candidate struct {
+		term  string
+		score float64
+	}
+
+	var candidates []candidate
+	for term, count := range keywordCandidates(text) {
+		length := len([]rune(term))
+		score := float64(count)
+		if unicode.Is(unicode.Han, []rune(term)[0]) {
+			score *= 1 + 0.5*float64(length-2)
+		} else {
+			score *= 1.5
+		}
+		candidates = append(candidates, candidate{term: term, score: score})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		if li, lj := len([]rune(candidates[i].term)), len([]rune(candidates[j].term)); li != lj {
+			return li > lj
+		}
+		return candidates[i].term < candidates[j].term
+	})
+
+	var keywords []string
+	for _, c := range candidates {
+		if len(keywords) >= limit {
+			break
+		}
+		covered := false
+		for _, k := range keywords {
+			if strings.Contains(k, c.term) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			keywords = append(keywords, c.term)
+		}
+	}
+	return keywords
+}
+
+// keywordsFromContent 从本地保存的内容块JSON中提取关键词，以逗号连接
+func keywordsFromContent(content string) string {
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(content), &blocks); err != nil {
+		return ""
+	}
+	return strings.Join(extractKeywords(blocksText(blocks), maxNoteKeywords), ",")
+}
+
+// parseKeywordTerms 解析查询关键词，支持空格和逗号分隔
+func parseKeywordTerms(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == '，' || r == '、'
+	})
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		terms = append(terms, strings.ToLower(f))
+	}
+	return terms
+}
+
+// matchKeywordTerms 判断记录是否包含全部查询关键词
+// 依次尝试：保存的关键词包含查询词、正文直接包含查询词、汉字查询词按两字切分后都出现在正文中
+func matchKeywordTerms(record NoteRecord, terms []string) bool {
+	keywords := record.Keywords
+	if keywords == "" {
+		keywords = keywordsFromContent(record.Content)
+	}
+	var saved []string
+	if keywords != "" {
+		saved = strings.Split(keywords, ",")
+	}
+
+	var text string
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(record.Content), &blocks); err == nil {
+		text = strings.ToLower(blocksText(blocks))
+	}
+
+	for _, term := range terms {
+		if !matchKeywordTerm(term, saved, text) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchKeywordTerm 判断单个查询词是否命中
+func matchKeywordTerm(term string, saved []string, text string) bool {
+	for _, k := range saved {
+		if strings.Contains(k, term) {
+			return true
+		}
+	}
+	if strings.Contains(text, term) {
+		return true
+	}
+
+	runes := []rune(term)
+	if len(runes) < 3 || !unicode.Is(unicode.Han, runes[0]) {
+		return false
+	}
+	// 按两字切分，词序不同时也能命中，例如"计划招聘"命中"招聘计划"
+	for i := 0; i < len(runes); i += 2 {
+		start := i
+		if start+2 > len(runes) {
+			start = len(runes) - 2
+		}
+		if !strings.Contains(text, string(runes[start:start+2])) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 为true时把文本中的网址和邮箱转换为链接，只包含墨问笔记链接的段落转换为内链笔记，默认false
+const LinkifyEnvVar = "MOWEN_LINKIFY"
+
+var (
+	linkifyPattern = regexp.MustCompile(`https?://[^\s<>"'，。；：！？、（）【】《》「」]+|[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// 墨问笔记的分享链接
+	mowenNoteURLPattern = regexp.MustCompile(`^https?://note\.mowen\.cn/(?:detail|note)/([A-Za-z0-9_\-]+)/?(?:[?#]\S*)?$`)
+)
+
+// trimURLPunctuation 去掉网址末尾的句读和不成对的右括号，它们通常属于正文
+func trimURLPunctuation(link string) string {
+	for link != "" {
+		last := link[len(link)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"", last) >= 0:
+			link = link[:len(link)-1]
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+			link = link[:len(link)-1]
+		default:
+			return link
+		}
+	}
+	return link
+}
+
+// linkifyTexts 把文本节点中的网址和邮箱拆分为带链接的节点，已有链接的节点保持不变
+func linkifyTexts(texts []TextNode) []TextNode {
+	result := make([]TextNode, 0, len(texts))
+	for _, text := range texts {
+		if text.Link != "" {
+			result = append(result, text)
+			continue
+		}
+		rest := text.Text
+		start := len(result)
+		for {
+			loc := linkifyPattern.FindStringIndex(rest)
+			if loc == nil {
+				break
+			}
+			match := rest[loc[0]:loc[1]]
+			var link string
+			if strings.Contains(match, "://") {
+				link = trimURLPunctuation(match)
+			} else {
+				link = "mailto:" + match
+			}
+			end := loc[0] + len(strings.TrimPrefix(link, "mailto:"))
+			if loc[0] > 0 {
+				node := text
+				node.Text = rest[:loc[0]]
+				result = append(result, node)
+			}
+			node := text
+			node.Text = rest[loc[0]:end]
+			node.Link = link
+			result = append(result, node)
+			rest = rest[end:]
+		}
+		if rest != "" || len(result) == start {
+			node := text
+			node.Text = rest
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// mowenNoteRef 段落只包含一个墨问笔记链接时返回笔记ID
+func mowenNoteRef(texts []TextNode) (string, bool) {
+	var sb strings.Builder
+	for _, t := range texts {
+		sb.WriteString(t.Text)
+	}
+	m := mowenNoteURLPattern.FindStringSubmatch(strings.TrimSpace(sb.String()))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 创建时间参数支持的格式，不带时区的按UTC处理（与数据库一致）
+var createTimeLayouts = []string{
+	time.RFC3339,
+	sqliteTimeLayout,
+	"2006-01-02T15:04:05",
+}
+
+// parseCreateTime 解析创建时间参数，转换为数据库中的格式
+func parseCreateTime(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range createTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format(sqliteTimeLayout), nil
+		}
+	}
+	return "", fmt.Errorf("时间格式错误，应为 YYYY-MM-DD HH:MM:SS（UTC）或 RFC3339 格式")
+}
+
+// formatNoteDetail 格式化单篇笔记的本地记录
+func formatNoteDetail(tenantID string, record *NoteRecord, includeBlocks bool) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 笔记 %s\n\n", record.NoteID))
+	sb.WriteString(fmt.Sprintf("标题: %s\n", noteTitle(record.Content)))
+	sb.WriteString(fmt.Sprintf("保存时间: %s\n", record.CreatedAt))
+	if tags, err := GetNoteTags(tenantID, record.NoteID); err == nil && len(tags) > 0 {
+		sb.WriteString(fmt.Sprintf("标签: %s\n", strings.Join(tags, ", ")))
+	}
+	if record.Keywords != "" {
+		sb.WriteString(fmt.Sprintf("关键词: %s\n", record.Keywords))
+	}
+	if record.Summary != "" {
+		sb.WriteString(fmt.Sprintf("总结: %s\n", record.Summary))
+	}
+	if attachments, err := noteAttachments(record.Content); err == nil && len(attachments) > 0 {
+		sb.WriteString(fmt.Sprintf("附件: %d 个（可用download_attachment下载）\n", len(attachments)))
+	}
+	if isNotePruned(tenantID, record.NoteID) {
+		sb.WriteString("注意: 本地内容已按保留策略清理，只保留标题、附件和元数据\n")
+	}
+	sb.WriteString(fmt.Sprintf("\n内容:\n%s\n", notePlainText(record.Content)))
+
+	if includeBlocks {
+		var blocks []ContentBlock
+		if err := json.Unmarshal([]byte(record.Content), &blocks); err == nil {
+			data, _ := json.MarshalIndent(blocks, "", "  ")
+			sb.WriteString(fmt.Sprintf("\n内容块JSON（可直接用于edit_note的paragraphs）:\n%s\n", data))
+		}
+	}
+	return sb.String()
+}
+
+// GetLocalNote 根据笔记ID读取本地保存的笔记
+func GetLocalNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	includeBlocks, _ := args["include_blocks"].(bool)
+
+	tenantID := tenantFromContext(ctx)
+	record, err := GetNoteCached(tenantID, noteID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	return mcp.NewToolResultText(formatNoteDetail(tenantID, record, includeBlocks)), nil
+}
+
+// GetNoteByCreateTime 根据保存时间读取本地记录
+func GetNoteByCreateTime(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	value, _ := args["create_time"].(string)
+	if value == "" {
+		return mcp.NewToolResultText("❌ 创建时间不能为空"), nil
+	}
+	cdt, err := parseCreateTime(value)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	includeBlocks, _ := args["include_blocks"].(bool)
+
+	tenantID := tenantFromContext(ctx)
+	record, err := SearchByCreateDt(tenantID, cdt)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	return mcp.NewToolResultText(formatNoteDetail(tenantID, record, includeBlocks)), nil
+}
+
+// 读取本地笔记工具
+var GetLocalNoteTool = mcp.NewTool("get_local_note",
+	mcp.WithDescription("根据笔记ID读取本地保存的笔记内容和元数据（标题、标签、关键词、总结、附件），用于回看本会话或之前通过本服务创建、编辑过的笔记"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记（见set_current_note）"),
+	),
+	mcp.WithBoolean("include_blocks",
+		mcp.Description("为true时同时返回内容块JSON，便于修改后传给edit_note"),
+	),
+)
+
+// 按保存时间读取笔记工具
+var GetNoteByCreateTimeTool = mcp.NewTool("get_note_by_create_time",
+	mcp.WithDescription("根据精确的保存时间读取本地笔记记录，时间取自search_note结果中的创建时间"),
+	mcp.WithString("create_time",
+		mcp.Required(),
+		mcp.Description("保存时间，格式：YYYY-MM-DD HH:MM:SS（UTC）或RFC3339，例如2024-05-01T08:30:00Z"),
+	),
+	mcp.WithBoolean("include_blocks",
+		mcp.Description("为true时同时返回内容块JSON"),
+	),
+)
+
+func getLocalNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return GetLocalNote(ctx, request)
+}
+
+func getNoteByCreateTimeHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return GetNoteByCreateTime(ctx, request)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 默认日志笔记名称
+const defaultLogName = "日志"
+
+// LogEntry 向日志笔记追加一条带时间戳的记录
+func LogEntry(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	args := request.Params.Arguments
+	text, ok := args["text"].(string)
+	if !ok || text == "" {
+		return mcp.NewToolResultText("❌ 日志内容不能为空"), nil
+	}
+	logName, _ := args["log_name"].(string)
+	if logName == "" {
+		logName = defaultLogName
+	}
+
+	now := time.Now()
+	entry := ContentBlock{
+		Texts: []TextNode{
+			{Text: now.Format("2006-01-02 15:04") + " ", Bold: true},
+			{Text: text},
+		},
+	}
+
+	// 指定了笔记ID时直接追加
+	if noteID, _ := args["note_id"].(string); noteID != "" {
+		if _, err = appendNoteBlocks(ctx, client, noteID, []ContentBlock{entry}); err != nil {
+			return errorResult("追加日志失败", err), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 日志已记录！\n\n笔记ID: %s\n时间: %s", noteID, now.Format("2006-01-02 15:04"))), nil
+	}
+
+	noteID, created, err := appendToNamedNote(ctx, client, "log:"+logName, "📒 "+logName, []ContentBlock{entry})
+	if err != nil {
+		return errorResult("追加日志失败", err), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 日志已记录！\n\n日志: %s\n笔记ID: %s\n时间: %s", logName, noteID, now.Format("2006-01-02 15:04"))
+	if created {
+		resultText += "\n（日志笔记不存在，已自动创建）"
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 记录日志工具
+var LogEntryTool = mcp.NewTool("log_entry",
+	mcp.WithDescription("向日志笔记追加一条以当前时间开头的记录，日志笔记不存在时自动创建。适合快速记录想法或Agent的操作轨迹。"),
+	mcp.WithString("text",
+		mcp.Required(),
+		mcp.Description("日志内容"),
+	),
+	mcp.WithString("log_name",
+		mcp.Description("日志名称，不同名称对应不同的日志笔记，默认为\"日志\""),
+	),
+	mcp.WithString("note_id",
+		mcp.Description("直接指定要追加的笔记ID（可选），指定后忽略log_name"),
+	),
+)
+
+func logEntryHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := LogEntry(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
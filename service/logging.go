@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 日志环境变量
+const (
+	// 日志级别：debug, info(默认), warn, error
+	LogLevelEnvVar = "MOWEN_LOG_LEVEL"
+	// 日志格式：text(默认，key=value)或json
+	LogFormatEnvVar = "MOWEN_LOG_FORMAT"
+	// 日志文件路径，不设置时输出到标准错误
+	LogFileEnvVar = "MOWEN_LOG_FILE"
+	// 单个日志文件的最大大小（MB），超过后轮转，默认50
+	LogMaxSizeEnvVar = "MOWEN_LOG_MAX_SIZE"
+	// 最多保留的轮转文件数，默认5
+	LogMaxBackupsEnvVar = "MOWEN_LOG_MAX_BACKUPS"
+	// 轮转文件最多保留的天数，默认30
+	LogMaxAgeEnvVar = "MOWEN_LOG_MAX_AGE"
+)
+
+// 日志级别名称
+var logLevelNames = map[logger.Level]string{
+	logger.LevelTrace:  "trace",
+	logger.LevelDebug:  "debug",
+	logger.LevelInfo:   "info",
+	logger.LevelNotice: "notice",
+	logger.LevelWarn:   "warn",
+	logger.LevelError:  "error",
+	logger.LevelFatal:  "fatal",
+}
+
+// InitLogging 按环境变量配置日志输出，应在启动时最先调用
+// 日志只写到标准错误或文件，不能写到标准输出，否则会破坏stdio协议
+func InitLogging() error {
+	var out io.Writer = os.Stderr
+	if path := envString(LogFileEnvVar, ""); path != "" {
+		file, err := newRotatingFile(expandPath(context.Background(), path),
+			int64(envInt(LogMaxSizeEnvVar, 50))*1024*1024,
+			envInt(LogMaxBackupsEnvVar, 5),
+			time.Duration(envInt(LogMaxAgeEnvVar, 30))*24*time.Hour)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	level := logger.LevelInfo
+	for lv, name := range logLevelNames {
+		if name == strings.ToLower(envString(LogLevelEnvVar, "info")) {
+			level = lv
+		}
+	}
+	logger.SetLevel(level)
+	logger.SetDefaultLogger(&structuredLogger{
+		out:  out,
+		json: strings.EqualFold(envString(LogFormatEnvVar, "text"), "json"),
+	})
+	return nil
+}
+
+// structuredLogger 输出带时间、级别、调用位置和请求ID字段的日志
+type structuredLogger struct {
+	mu   sync.Mutex
+	out  io.Writer
+	json bool
+}
+
+// logEntry 一条日志的字段
+type logEntry struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Caller    string `json:"caller,omitempty"`
+	Message   string `json:"msg"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (l *structuredLogger) log(ctx context.Context, lv logger.Level, format *string, v ...interface{}) {
+	// 库中的非格式化函数会把参数整体作为一个切片传入，这里展开
+	if len(v) == 1 {
+		if inner, ok := v[0].([]interface{}); ok {
+			v = inner
+		}
+	}
+	entry := logEntry{
+		Time:      time.Now().Format("2006-01-02T15:04:05.000Z07:00"),
+		Level:     logLevelNames[lv],
+		RequestID: requestIDFromContext(ctx),
+	}
+	if format != nil {
+		entry.Message = fmt.Sprintf(*format, v...)
+	} else {
+		entry.Message = fmt.Sprint(v...)
+	}
+	// 调用层级：log <- 本类型的方法 <- logger包函数 <- 业务代码
+	if _, file, line, ok := runtime.Caller(3); ok {
+		entry.Caller = fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(file)), filepath.Base(file), line)
+	}
+
+	var line string
+	if l.json {
+		data, _ := json.Marshal(entry)
+		line = string(data)
+	} else {
+		line = fmt.Sprintf("time=%s level=%s caller=%s msg=%q", entry.Time, entry.Level, entry.Caller, entry.Message)
+		if entry.RequestID != "" {
+			line += " request_id=" + entry.RequestID
+		}
+	}
+
+	l.mu.Lock()
+	fmt.Fprintln(l.out, line)
+	l.mu.Unlock()
+	if lv == logger.LevelFatal {
+		os.Exit(1)
+	}
+}
+
+func (l *structuredLogger) Trace(v ...interface{}) {
+	l.log(context.Background(), logger.LevelTrace, nil, v...)
+}
+func (l *structuredLogger) Debug(v ...interface{}) {
+	l.log(context.Background(), logger.LevelDebug, nil, v...)
+}
+func (l *structuredLogger) Info(v ...interface{}) {
+	l.log(context.Background(), logger.LevelInfo, nil, v...)
+}
+func (l *structuredLogger) Notice(v ...interface{}) {
+	l.log(context.Background(), logger.LevelNotice, nil, v...)
+}
+func (l *structuredLogger) Warn(v ...interface{}) {
+	l.log(context.Background(), logger.LevelWarn, nil, v...)
+}
+func (l *structuredLogger) Error(v ...interface{}) {
+	l.log(context.Background(), logger.LevelError, nil, v...)
+}
+func (l *structuredLogger) Fatal(v ...interface{}) {
+	l.log(context.Background(), logger.LevelFatal, nil, v...)
+}
+
+func (l *structuredLogger) Tracef(format string, v ...interface{}) {
+	l.log(context.Background(), logger.LevelTrace, &format, v...)
+}
+func (l *structuredLogger) Debugf(format string, v ...interface{}) {
+	l.log(context.Background(), logger.LevelDebug, &format, v...)
+}
+func (l *structuredLogger) Infof(format string, v ...interface{}) {
+	l.log(context.Background(), logger.LevelInfo, &format, v...)
+}
+func (l *structuredLogger) Noticef(format string, v ...interface{}) {
+	l.log(context.Background(), logger.LevelNotice, &format, v...)
+}
+func (l *structuredLogger) Warnf(format string, v ...interface{}) {
+	l.log(context.Background(), logger.LevelWarn, &format, v...)
+}
+func (l *structuredLogger) Errorf(format string, v ...interface{}) {
+	l.log(context.Background(), logger.LevelError, &format, v...)
+}
+func (l *structuredLogger) Fatalf(format string, v ...interface{}) {
+	l.log(context.Background(), logger.LevelFatal, &format, v...)
+}
+
+func (l *structuredLogger) CtxTracef(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, logger.LevelTrace, &format, v...)
+}
+func (l *structuredLogger) CtxDebugf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, logger.LevelDebug, &format, v...)
+}
+func (l *structuredLogger) CtxInfof(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, logger.LevelInfo, &format, v...)
+}
+func (l *structuredLogger) CtxNoticef(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, logger.LevelNotice, &format, v...)
+}
+func (l *structuredLogger) CtxWarnf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, logger.LevelWarn, &format, v...)
+}
+func (l *structuredLogger) CtxErrorf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, logger.LevelError, &format, v...)
+}
+func (l *structuredLogger) CtxFatalf(ctx context.Context, format string, v ...interface{}) {
+	l.log(ctx, logger.LevelFatal, &format, v...)
+}
+
+// rotatingFile 按大小轮转的日志文件
+// 轮转后的文件名为 原文件名.时间戳，超过数量或天数的旧文件会被删除
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	file       *os.File
+	size       int64
+}
+
+// newRotatingFile 打开日志文件，不存在时创建
+func newRotatingFile(path string, maxSize int64, maxBackups int, maxAge time.Duration) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %v", err)
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups, maxAge: maxAge}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件信息失败: %v", err)
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// Write 写入日志，超过大小限制时先轮转
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "日志轮转失败: %v\n", err)
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate 重命名当前文件并打开新文件，然后清理过期的轮转文件
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	backup := r.path + "." + time.Now().Format("20060102-150405.000")
+	if err := os.Rename(r.path, backup); err != nil {
+		return r.open()
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.cleanup()
+	return nil
+}
+
+// cleanup 删除超过数量或天数限制的轮转文件
+func (r *rotatingFile) cleanup() {
+	backups, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return
+	}
+	// 时间戳后缀可以按字符串排序，最新的在前
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i, backup := range backups {
+		expired := false
+		if r.maxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && time.Since(info.ModTime()) > r.maxAge {
+				expired = true
+			}
+		}
+		if expired || (r.maxBackups > 0 && i >= r.maxBackups) {
+			os.Remove(backup)
+		}
+	}
+}
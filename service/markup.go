@@ -0,0 +1,493 @@
+package service
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Markdown和HTML转换为内容块，供导入工具使用
+// 图片转换为文件块，source_path保留原始地址，http(s)地址的source_type为url，其余为local，由调用方解析相对路径；
+// 链接保留原始href，由调用方改写指向其他导入页面的链接
+
+var (
+	markdownHeading   = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	markdownTodo      = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	markdownBullet    = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	markdownOrdered   = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	markdownImageLine = regexp.MustCompile(`^!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)$`)
+	// 行内语法：图片、链接、自动链接、加粗、高亮、行内代码、斜体
+	markdownInline = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)` +
+		`|\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)` +
+		`|<(https?://[^>\s]+)>` +
+		`|\*\*(.+?)\*\*|__(.+?)__` +
+		`|==(.+?)==` +
+		"|`([^`]+)`" +
+		`|\*([^*\s][^*]*?)\*`)
+	markdownEscape = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!|~=<>])`)
+)
+
+// markupImageBlock 图片文件块，alt非空时写入元数据
+func markupImageBlock(src, alt string) ContentBlock {
+	block := ContentBlock{Type: "file", FileType: "image", SourceType: "local", SourcePath: src}
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		block.SourceType = "url"
+	}
+	if alt = strings.TrimSpace(alt); alt != "" {
+		block.Metadata = map[string]interface{}{"alt": alt}
+	}
+	return block
+}
+
+// appendTextNode 追加文本节点，与前一个节点格式相同时合并
+func appendTextNode(texts []TextNode, node TextNode) []TextNode {
+	if node.Text == "" {
+		return texts
+	}
+	if n := len(texts); n > 0 {
+		last := &texts[n-1]
+		if last.Bold == node.Bold && last.Highlight == node.Highlight && last.Link == node.Link {
+			last.Text += node.Text
+			return texts
+		}
+	}
+	return append(texts, node)
+}
+
+// hasVisibleText 文本节点中是否有非空白文字
+func hasVisibleText(texts []TextNode) bool {
+	for _, text := range texts {
+		if strings.TrimSpace(text.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// markdownInlineNodes 解析一行Markdown的行内格式，行中的图片单独返回
+func markdownInlineNodes(line string) ([]TextNode, []ContentBlock) {
+	var texts []TextNode
+	var images []ContentBlock
+	unescape := func(s string) string { return markdownEscape.ReplaceAllString(s, "$1") }
+	for {
+		m := markdownInline.FindStringSubmatchIndex(line)
+		if m == nil {
+			texts = appendTextNode(texts, TextNode{Text: unescape(line)})
+			return texts, images
+		}
+		texts = appendTextNode(texts, TextNode{Text: unescape(line[:m[0]])})
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return line[m[2*i]:m[2*i+1]]
+		}
+		switch {
+		case m[4] >= 0:
+			images = append(images, markupImageBlock(group(2), group(1)))
+		case m[6] >= 0:
+			// 链接文字中的格式标记直接去掉
+			label, _ := markdownInlineNodes(group(3))
+			var sb strings.Builder
+			for _, t := range label {
+				sb.WriteString(t.Text)
+			}
+			texts = appendTextNode(texts, TextNode{Text: sb.String(), Link: group(4)})
+		case m[10] >= 0:
+			texts = appendTextNode(texts, TextNode{Text: group(5), Link: group(5)})
+		case m[12] >= 0 || m[14] >= 0:
+			inner, _ := markdownInlineNodes(group(6) + group(7))
+			for _, t := range inner {
+				t.Bold = true
+				texts = appendTextNode(texts, t)
+			}
+		case m[16] >= 0:
+			inner, _ := markdownInlineNodes(group(8))
+			for _, t := range inner {
+				t.Highlight = true
+				texts = appendTextNode(texts, t)
+			}
+		case m[18] >= 0:
+			texts = appendTextNode(texts, TextNode{Text: group(9)})
+		default:
+			inner, _ := markdownInlineNodes(group(10))
+			for _, t := range inner {
+				texts = appendTextNode(texts, t)
+			}
+		}
+		line = line[m[1]:]
+	}
+}
+
+// markdownToBlocks 把Markdown转换为内容块，每个非空行为一个段落
+// 标题转为加粗段落，列表保留项目符号，代码块和连续的引用行合并为一个引用块
+func markdownToBlocks(text string) []ContentBlock {
+	var blocks []ContentBlock
+	var quote *ContentBlock
+	addQuoteLine := func(texts []TextNode) {
+		if quote == nil {
+			quote = &ContentBlock{Type: "quote", Texts: texts}
+			return
+		}
+		quote.Paragraphs = append(quote.Paragraphs, texts)
+	}
+	endQuote := func() {
+		if quote != nil {
+			blocks = append(blocks, *quote)
+			quote = nil
+		}
+	}
+	addLine := func(texts []TextNode, images []ContentBlock) {
+		if hasVisibleText(texts) {
+			blocks = append(blocks, ContentBlock{Texts: texts})
+		}
+		blocks = append(blocks, images...)
+	}
+
+	inCode := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			endQuote()
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			if line == "" {
+				line = " "
+			}
+			addQuoteLine([]TextNode{{Text: line}})
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, ">") {
+			content := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+			if content == "" {
+				continue
+			}
+			texts, images := markdownInlineNodes(content)
+			if hasVisibleText(texts) {
+				addQuoteLine(texts)
+			}
+			if len(images) > 0 {
+				endQuote()
+				blocks = append(blocks, images...)
+			}
+			continue
+		}
+		endQuote()
+
+		switch {
+		case trimmed == "":
+		case isMarkdownRule([]TextNode{{Text: trimmed}}):
+			blocks = append(blocks, ContentBlock{Type: "divider"})
+		case markdownImageLine.MatchString(trimmed):
+			m := markdownImageLine.FindStringSubmatch(trimmed)
+			blocks = append(blocks, markupImageBlock(m[2], m[1]))
+		case markdownHeading.MatchString(trimmed):
+			texts, images := markdownInlineNodes(markdownHeading.FindStringSubmatch(trimmed)[1])
+			for i := range texts {
+				texts[i].Bold = true
+			}
+			addLine(texts, images)
+		case markdownTodo.MatchString(line):
+			m := markdownTodo.FindStringSubmatch(line)
+			texts, images := markdownInlineNodes(m[2])
+			blocks = append(blocks, ContentBlock{Type: "todo", Texts: texts, Checked: m[1] != " "})
+			blocks = append(blocks, images...)
+		case markdownBullet.MatchString(line):
+			m := markdownBullet.FindStringSubmatch(line)
+			texts, images := markdownInlineNodes(m[2])
+			addLine(append([]TextNode{{Text: listIndent(m[1]) + "• "}}, texts...), images)
+		case markdownOrdered.MatchString(line):
+			m := markdownOrdered.FindStringSubmatch(line)
+			texts, images := markdownInlineNodes(m[3])
+			addLine(append([]TextNode{{Text: listIndent(m[1]) + m[2] + ". "}}, texts...), images)
+		default:
+			addLine(markdownInlineNodes(trimmed))
+		}
+	}
+	endQuote()
+	return blocks
+}
+
+// listIndent 嵌套列表的缩进，每级两个全角空格
+func listIndent(prefix string) string {
+	level := len(strings.ReplaceAll(prefix, "\t", "    ")) / 2
+	return strings.Repeat("　", level)
+}
+
+var (
+	htmlTokenPattern = regexp.MustCompile(`(?s)<!--.*?-->|<!\w[^>]*>|<(/?)([a-zA-Z][a-zA-Z0-9]*)\b([^>]*?)(/?)>`)
+	htmlAttrPattern  = regexp.MustCompile(`(?s)([a-zA-Z_:\-]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+	htmlTitlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// htmlBlockTags 开始或结束时结束当前段落的标签
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "li": true, "tr": true, "dt": true, "dd": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "table": true, "figure": true, "figcaption": true,
+	"section": true, "article": true, "header": true, "footer": true, "details": true, "summary": true,
+}
+
+// htmlSkipTags 内容不导入的标签
+var htmlSkipTags = map[string]bool{"head": true, "script": true, "style": true, "title": true, "svg": true, "noscript": true, "template": true}
+
+// htmlAttrs 解析标签属性，属性名转为小写，值已解码实体
+func htmlAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range htmlAttrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3] + m[4])
+	}
+	return attrs
+}
+
+// htmlTitle 页面<title>中的标题
+func htmlTitle(page string) string {
+	m := htmlTitlePattern.FindStringSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+}
+
+// htmlConverter 逐个标签转换HTML时的状态
+type htmlConverter struct {
+	blocks    []ContentBlock
+	texts     []TextNode
+	todo      *bool
+	bold      int
+	highlight int
+	links     []string
+	quote     int
+	quoteOpen bool // 当前引用中已经输出了引用块，后续段落追加到该块
+	pre       int
+	skip      int
+	lists     []int // 列表序号，无序列表为-1
+}
+
+// flush 结束当前段落，还没有文字（或只有列表符号）时保留到下一次
+func (c *htmlConverter) flush() {
+	texts, todo := c.texts, c.todo
+	if !hasVisibleText(texts) || trimListMarker(texts) == nil {
+		return
+	}
+	c.texts, c.todo = nil, nil
+	// 去掉段落首尾的空白
+	texts[0].Text = strings.TrimLeft(texts[0].Text, " ")
+	texts[len(texts)-1].Text = strings.TrimRight(texts[len(texts)-1].Text, " ")
+
+	switch {
+	case c.quote > 0 || c.pre > 0:
+		if c.quoteOpen {
+			last := &c.blocks[len(c.blocks)-1]
+			last.Paragraphs = append(last.Paragraphs, texts)
+			return
+		}
+		c.blocks = append(c.blocks, ContentBlock{Type: "quote", Texts: texts})
+		c.quoteOpen = true
+	case todo != nil:
+		c.blocks = append(c.blocks, ContentBlock{Type: "todo", Texts: texts, Checked: *todo})
+	default:
+		c.blocks = append(c.blocks, ContentBlock{Texts: texts})
+	}
+}
+
+// addText 追加文字，pre以外的空白合并为一个空格
+func (c *htmlConverter) addText(text string) {
+	text = html.UnescapeString(text)
+	if c.pre > 0 {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if i > 0 {
+				c.flush()
+			}
+			c.texts = appendTextNode(c.texts, TextNode{Text: line})
+		}
+		return
+	}
+	isSpace := func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' }
+	collapsed := strings.Join(strings.FieldsFunc(text, isSpace), " ")
+	n := len(c.texts)
+	endsWithSpace := n == 0 || strings.HasSuffix(c.texts[n-1].Text, " ")
+	if collapsed == "" {
+		if !endsWithSpace {
+			c.texts[n-1].Text += " "
+		}
+		return
+	}
+	if first, _ := utf8.DecodeRuneInString(text); isSpace(first) && !endsWithSpace {
+		collapsed = " " + collapsed
+	}
+	if last, _ := utf8.DecodeLastRuneInString(text); isSpace(last) {
+		collapsed += " "
+	}
+	node := TextNode{Text: collapsed, Bold: c.bold > 0, Highlight: c.highlight > 0}
+	if len(c.links) > 0 {
+		node.Link = c.links[len(c.links)-1]
+	}
+	c.texts = appendTextNode(c.texts, node)
+}
+
+// setTodo 把当前段落标记为待办
+func (c *htmlConverter) setTodo(checked bool) {
+	c.todo = &checked
+}
+
+// tag 处理一个开始或结束标签，selfClosing表示 <tag /> 形式的标签
+func (c *htmlConverter) tag(name string, closing, selfClosing bool, rawAttrs string) {
+	if htmlSkipTags[name] {
+		if closing {
+			if c.skip > 0 {
+				c.skip--
+			}
+		} else if !selfClosing {
+			c.skip++
+		}
+		return
+	}
+	if c.skip > 0 {
+		return
+	}
+
+	if htmlBlockTags[name] {
+		c.flush()
+	}
+	switch name {
+	case "br":
+		c.flush()
+	case "hr":
+		c.flush()
+		c.blocks = append(c.blocks, ContentBlock{Type: "divider"})
+		c.quoteOpen = false
+	case "img":
+		c.flush()
+		attrs := htmlAttrs(rawAttrs)
+		if src := attrs["src"]; src != "" {
+			c.blocks = append(c.blocks, markupImageBlock(src, attrs["alt"]))
+			c.quoteOpen = false
+		}
+	case "b", "strong", "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		if closing {
+			if c.bold > 0 {
+				c.bold--
+			}
+		} else {
+			c.bold++
+		}
+	case "mark":
+		if closing {
+			if c.highlight > 0 {
+				c.highlight--
+			}
+		} else {
+			c.highlight++
+		}
+	case "a":
+		if closing {
+			if len(c.links) > 0 {
+				c.links = c.links[:len(c.links)-1]
+			}
+		} else {
+			c.links = append(c.links, htmlAttrs(rawAttrs)["href"])
+		}
+	case "blockquote":
+		c.flush()
+		if closing {
+			if c.quote > 0 {
+				c.quote--
+			}
+		} else {
+			c.quote++
+		}
+		c.quoteOpen = false
+	case "pre":
+		c.flush()
+		if closing {
+			if c.pre > 0 {
+				c.pre--
+			}
+		} else {
+			c.pre++
+		}
+		c.quoteOpen = false
+	case "ul", "ol":
+		if closing {
+			if len(c.lists) > 0 {
+				c.lists = c.lists[:len(c.lists)-1]
+			}
+		} else if name == "ol" {
+			start, err := strconv.Atoi(htmlAttrs(rawAttrs)["start"])
+			if err != nil {
+				start = 1
+			}
+			c.lists = append(c.lists, start)
+		} else {
+			c.lists = append(c.lists, -1)
+		}
+	case "li":
+		if closing || len(c.lists) == 0 {
+			break
+		}
+		indent := strings.Repeat("　", len(c.lists)-1)
+		if n := &c.lists[len(c.lists)-1]; *n >= 0 {
+			c.texts = appendTextNode(c.texts, TextNode{Text: indent + strconv.Itoa(*n) + ". "})
+			*n++
+		} else {
+			c.texts = appendTextNode(c.texts, TextNode{Text: indent + "• "})
+		}
+	case "input":
+		attrs := htmlAttrs(rawAttrs)
+		if strings.EqualFold(attrs["type"], "checkbox") {
+			_, checked := attrs["checked"]
+			c.todo, c.texts = nil, trimListMarker(c.texts)
+			c.setTodo(checked)
+		}
+	}
+
+	// Notion导出的待办使用带checkbox-on/checkbox-off样式的元素表示复选框
+	if !closing && name != "input" {
+		class := " " + htmlAttrs(rawAttrs)["class"] + " "
+		switch {
+		case strings.Contains(class, " checkbox-on "):
+			c.texts = trimListMarker(c.texts)
+			c.setTodo(true)
+		case strings.Contains(class, " checkbox-off "):
+			c.texts = trimListMarker(c.texts)
+			c.setTodo(false)
+		}
+	}
+}
+
+// trimListMarker 待办不需要列表符号，去掉li添加的项目符号
+func trimListMarker(texts []TextNode) []TextNode {
+	if len(texts) == 1 && strings.TrimSpace(strings.TrimLeft(texts[0].Text, "　•0123456789. ")) == "" {
+		return nil
+	}
+	return texts
+}
+
+// htmlToBlocks 把HTML转换为内容块，只处理常见的排版标签，忽略样式和脚本
+func htmlToBlocks(page string) []ContentBlock {
+	c := &htmlConverter{}
+	pos := 0
+	for _, m := range htmlTokenPattern.FindAllStringSubmatchIndex(page, -1) {
+		if c.skip == 0 && m[0] > pos {
+			c.addText(page[pos:m[0]])
+		}
+		pos = m[1]
+		if m[4] < 0 {
+			// 注释和DOCTYPE
+			continue
+		}
+		c.tag(strings.ToLower(page[m[4]:m[5]]), m[3] > m[2], m[9] > m[8], page[m[6]:m[7]])
+	}
+	if c.skip == 0 && pos < len(page) {
+		c.addText(page[pos:])
+	}
+	c.flush()
+	return c.blocks
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// 公式渲染环境变量
+const (
+	// 公式渲染服务的地址模板，{latex}替换为URL编码后的公式，渲染结果作为图片通过URL上传
+	// 例如 https://latex.codecogs.com/png.image?%5Cdpi%7B200%7D{latex}
+	// 未配置时公式以文字形式保存
+	MathRendererEnvVar = "MOWEN_MATH_RENDERER"
+)
+
+// 渲染后的公式图片的文件名
+const mathImageFileName = "formula.png"
+
+func init() {
+	RegisterBlockConverter("math", convertMathBlock)
+}
+
+// mathFormula 识别整段的Markdown公式：$...$、$$...$$ 或 ```math 代码块，返回去掉定界符的LaTeX
+// 夹在正文中的行内公式不做处理，墨问的图片只能独占一段
+func mathFormula(texts []TextNode) (string, bool) {
+	var sb strings.Builder
+	for _, t := range texts {
+		sb.WriteString(t.Text)
+	}
+	text := strings.TrimSpace(sb.String())
+
+	if strings.HasPrefix(text, "```math") && strings.HasSuffix(text, "```") && len(text) > len("```math```") {
+		formula := strings.TrimSpace(text[len("```math") : len(text)-len("```")])
+		return formula, formula != ""
+	}
+	for _, delim := range []string{"$$", "$"} {
+		if len(text) > 2*len(delim) && strings.HasPrefix(text, delim) && strings.HasSuffix(text, delim) {
+			formula := strings.TrimSpace(text[len(delim) : len(text)-len(delim)])
+			// $a$ 和 $b$ 这样的两个行内公式不是一整段公式
+			if formula == "" || strings.Contains(formula, "$") {
+				return "", false
+			}
+			return formula, true
+		}
+	}
+	return "", false
+}
+
+// mathRenderURL 按配置的渲染服务生成公式图片地址，未配置时返回空字符串
+func mathRenderURL(formula string) string {
+	template := strings.TrimSpace(envString(MathRendererEnvVar, ""))
+	if template == "" || !strings.Contains(template, "{latex}") {
+		return ""
+	}
+	return strings.ReplaceAll(template, "{latex}", url.PathEscape(formula))
+}
+
+// convertMathBlock 公式段落：配置了渲染服务时上传渲染后的图片，替代文本为公式原文；否则保存为 $$公式$$ 文字
+func convertMathBlock(ctx context.Context, client *MowenClient, block *ContentBlock) ([]MowenContentNode, error) {
+	formula, ok := mathFormula(block.Texts)
+	if !ok {
+		var sb strings.Builder
+		for _, t := range block.Texts {
+			sb.WriteString(t.Text)
+		}
+		formula = strings.TrimSpace(sb.String())
+	}
+
+	renderURL := mathRenderURL(formula)
+	if renderURL == "" && block.FileID == "" {
+		return []MowenContentNode{{
+			Type:    "paragraph",
+			Content: []MowenTextNode{{Type: "text", Text: "$$" + formula + "$$"}},
+		}}, nil
+	}
+
+	image := ContentBlock{
+		Type:       "file",
+		FileType:   "image",
+		SourceType: "url",
+		SourcePath: renderURL,
+		FileID:     block.FileID,
+		Metadata:   map[string]interface{}{"alt": formula, fileNameMetadataKey: mathImageFileName},
+	}
+	nodes, err := convertFileBlock(ctx, client, &image)
+	if err != nil {
+		return nil, err
+	}
+	// 回写上传后的文件ID，重新转换时不再重复渲染上传
+	block.FileID = image.FileID
+	return nodes, nil
+}
@@ -0,0 +1,567 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// memos服务的访问令牌，未传入memos_token时使用
+const MemosTokenEnvVar = "MOWEN_MEMOS_TOKEN"
+
+const (
+	// 一次最多导入的memo数
+	maxImportMemos = 2000
+	// memos接口单页数量
+	memosPageSize = 200
+	// 导入文件和接口响应的大小上限
+	maxMemosExportSize = 50 << 20
+)
+
+var (
+	memoTagPattern     = regexp.MustCompile(`(?:^|\s)#([^\s#]+)`)
+	flomoMemoPattern   = regexp.MustCompile(`(?is)<div class="memo">(.*?)(?:<div class="memo">|</body>|$)`)
+	flomoTimePattern   = regexp.MustCompile(`(?is)<div class="time">(.*?)</div>`)
+	flomoFilesPattern  = regexp.MustCompile(`(?is)<div class="files">(.*)`)
+	flomoImagePattern  = regexp.MustCompile(`(?is)<img\b[^>]*\bsrc\s*=\s*["']([^"']+)["']`)
+	flomoContentPrefix = regexp.MustCompile(`(?is)^.*?<div class="content">`)
+)
+
+// memoItem 一条短记录
+type memoItem struct {
+	Content   string
+	CreatedAt time.Time
+	Tags      []string
+	Images    []string // 图片地址，本地图片为相对导出文件的路径
+	HTML      bool     // flomo导出的内容为HTML，memos为Markdown
+}
+
+// key 用于去重的标识：创建时间和内容的摘要
+func (m memoItem) key() string {
+	sum := sha256.Sum256([]byte(m.CreatedAt.UTC().Format(time.RFC3339) + "\n" + m.Content))
+	return "memo:" + hex.EncodeToString(sum[:8])
+}
+
+// memoString 按顺序取第一个非空的字符串字段
+func memoString(item map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := item[key].(string); ok && strings.TrimSpace(s) != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// memoTime 解析创建时间，支持时间字符串和Unix时间戳（秒或毫秒）
+func memoTime(item map[string]interface{}) time.Time {
+	for _, key := range []string{"createTime", "created_at", "createdAt", "created", "displayTime", "time", "createdTs", "created_ts"} {
+		switch v := item[key].(type) {
+		case string:
+			if t, ok := parseHTMLDate(v); ok {
+				return t
+			}
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return unixTime(n)
+			}
+		case float64:
+			return unixTime(int64(v))
+		}
+	}
+	return time.Time{}
+}
+
+// unixTime 秒或毫秒时间戳
+func unixTime(n int64) time.Time {
+	if n > 1e12 {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+// memoFromJSON 把memos接口或导出文件中的一条记录转换为memoItem，base为memos服务地址，用于拼接附件地址
+func memoFromJSON(item map[string]interface{}, base string) (memoItem, bool) {
+	memo := memoItem{
+		Content:   memoString(item, "content", "text", "memo"),
+		CreatedAt: memoTime(item),
+	}
+	if strings.TrimSpace(memo.Content) == "" {
+		return memo, false
+	}
+	if rowStatus := memoString(item, "rowStatus", "state"); strings.EqualFold(rowStatus, "ARCHIVED") {
+		return memo, false
+	}
+	if tags, ok := item["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok && s != "" {
+				memo.Tags = append(memo.Tags, s)
+			}
+		}
+	}
+	for _, key := range []string{"resources", "resourceList", "attachments", "files", "images"} {
+		list, _ := item[key].([]interface{})
+		for _, entry := range list {
+			var src string
+			switch v := entry.(type) {
+			case string:
+				src = v
+			case map[string]interface{}:
+				if typ := memoString(v, "type", "mimeType"); typ != "" && !strings.HasPrefix(typ, "image/") {
+					continue
+				}
+				src = memoString(v, "externalLink", "url", "link", "path")
+				if src == "" && base != "" {
+					// 新版接口的附件名形如 resources/1 或 attachments/1，旧版使用数字ID
+					if name, filename := memoString(v, "name"), memoString(v, "filename"); strings.Contains(name, "/") && filename != "" {
+						src = base + "/file/" + name + "/" + url.PathEscape(filename)
+					} else if id, ok := v["id"].(float64); ok {
+						src = fmt.Sprintf("%s/o/r/%d", base, int64(id))
+					}
+				}
+			}
+			if src != "" {
+				memo.Images = append(memo.Images, src)
+			}
+		}
+	}
+	return memo, true
+}
+
+// parseMemosJSON 解析memos的JSON导出：数组，或带memos/data字段的对象
+func parseMemosJSON(data []byte) ([]memoItem, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
+	}
+	list, ok := raw.([]interface{})
+	if obj, isObj := raw.(map[string]interface{}); isObj {
+		for _, key := range []string{"memos", "data", "items"} {
+			if list, ok = obj[key].([]interface{}); ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("JSON中没有找到memo列表")
+	}
+	var memos []memoItem
+	for _, entry := range list {
+		if item, ok := entry.(map[string]interface{}); ok {
+			if memo, ok := memoFromJSON(item, ""); ok {
+				memos = append(memos, memo)
+			}
+		}
+	}
+	return memos, nil
+}
+
+// parseFlomoHTML 解析flomo导出的HTML，每个 <div class="memo"> 是一条记录
+func parseFlomoHTML(page string) []memoItem {
+	var memos []memoItem
+	rest := page
+	for {
+		m := flomoMemoPattern.FindStringSubmatchIndex(rest)
+		if m == nil {
+			return memos
+		}
+		chunk := rest[m[2]:m[3]]
+		rest = rest[m[3]:]
+
+		memo := memoItem{HTML: true}
+		if t := flomoTimePattern.FindStringSubmatch(chunk); t != nil {
+			memo.CreatedAt, _ = parseHTMLDate(cleanHTMLText(t[1]))
+		}
+		content := flomoContentPrefix.ReplaceAllString(chunk, "")
+		if files := flomoFilesPattern.FindStringSubmatchIndex(content); files != nil {
+			for _, img := range flomoImagePattern.FindAllStringSubmatch(content[files[2]:files[3]], -1) {
+				memo.Images = append(memo.Images, img[1])
+			}
+			content = content[:files[0]]
+		}
+		memo.Content = strings.TrimSpace(content)
+		if memo.Content != "" || len(memo.Images) > 0 {
+			memos = append(memos, memo)
+		}
+	}
+}
+
+// fetchMemosPage 请求memos接口
+func fetchMemosPage(ctx context.Context, apiURL, token string) ([]byte, int, error) {
+	if err := checkRemoteURL(apiURL); err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("无效的URL %s: %w", apiURL, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := newSafeHTTPClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("无法访问memos服务: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMemosExportSize))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("读取memos响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("memos服务返回状态码 %d: %s", resp.StatusCode, truncateRunes(string(data), 200))
+	}
+	return data, resp.StatusCode, nil
+}
+
+// fetchMemos 通过memos接口分页拉取全部memo，新版接口（/api/v1/memos）不存在时回退到旧版（/api/v1/memo）
+func fetchMemos(ctx context.Context, base, token string) ([]memoItem, error) {
+	var memos []memoItem
+	pageToken := ""
+	for len(memos) < maxImportMemos {
+		apiURL := fmt.Sprintf("%s/api/v1/memos?pageSize=%d", base, memosPageSize)
+		if pageToken != "" {
+			apiURL += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		data, status, err := fetchMemosPage(ctx, apiURL, token)
+		if status == http.StatusNotFound && pageToken == "" {
+			return fetchLegacyMemos(ctx, base, token)
+		}
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Memos         []map[string]interface{} `json:"memos"`
+			NextPageToken string                   `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("解析memos响应失败: %w", err)
+		}
+		for _, item := range page.Memos {
+			if memo, ok := memoFromJSON(item, base); ok {
+				memos = append(memos, memo)
+			}
+		}
+		if page.NextPageToken == "" || len(page.Memos) == 0 {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	return memos, nil
+}
+
+// fetchLegacyMemos 旧版memos接口，按offset分页，返回数组
+func fetchLegacyMemos(ctx context.Context, base, token string) ([]memoItem, error) {
+	var memos []memoItem
+	for offset := 0; len(memos) < maxImportMemos; offset += memosPageSize {
+		data, _, err := fetchMemosPage(ctx, fmt.Sprintf("%s/api/v1/memo?limit=%d&offset=%d", base, memosPageSize, offset), token)
+		if err != nil {
+			return nil, err
+		}
+		var items []map[string]interface{}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("解析memos响应失败: %w", err)
+		}
+		for _, item := range items {
+			if memo, ok := memoFromJSON(item, base); ok {
+				memos = append(memos, memo)
+			}
+		}
+		if len(items) < memosPageSize {
+			break
+		}
+	}
+	return memos, nil
+}
+
+// memoImporter 导入过程中的状态
+type memoImporter struct {
+	ctx      context.Context
+	client   *MowenClient
+	root     string // 导出文件所在目录，本地图片必须位于其中
+	warnings []string
+}
+
+// memoBlocks 把一条memo转换为内容块，本地图片上传
+func (im *memoImporter) memoBlocks(memo memoItem) []ContentBlock {
+	var blocks []ContentBlock
+	if memo.HTML {
+		blocks = htmlToBlocks(memo.Content)
+	} else {
+		blocks = markdownToBlocks(memo.Content)
+	}
+	for _, src := range memo.Images {
+		blocks = append(blocks, markupImageBlock(src, ""))
+	}
+
+	result := make([]ContentBlock, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type != "file" || block.SourceType != "local" {
+			result = append(result, block)
+			continue
+		}
+		path := block.SourcePath
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			path = unescaped
+		}
+		path = filepath.Join(im.root, filepath.FromSlash(path))
+		if resolved, err := canonicalPath(path); err == nil {
+			path = resolved
+		}
+		if im.root == "" || !isWithinDir(path, im.root) {
+			im.warnings = append(im.warnings, fmt.Sprintf("图片 %s 不在导出目录中，已跳过", block.SourcePath))
+			continue
+		}
+		uploaded, err := uploadImportAsset(im.ctx, im.client, path, block)
+		if err != nil {
+			im.warnings = append(im.warnings, fmt.Sprintf("上传图片 %s 失败: %v", block.SourcePath, err))
+			continue
+		}
+		result = append(result, uploaded)
+	}
+	return result
+}
+
+// memoTags 合并memo自带的标签和正文中的 #标签
+func memoTags(memo memoItem) []string {
+	tags := append([]string(nil), memo.Tags...)
+	text := memo.Content
+	if memo.HTML {
+		text = cleanHTMLText(text)
+	}
+	for _, m := range memoTagPattern.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, strings.TrimRight(m[1], ",.;:!?，。；：！？"))
+	}
+	return tags
+}
+
+// mergeTags 合并标签，去掉重复和空标签
+func mergeTags(groups ...[]string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, group := range groups {
+		for _, tag := range group {
+			if tag = strings.TrimSpace(tag); tag != "" && !seen[tag] {
+				seen[tag] = true
+				result = append(result, tag)
+			}
+		}
+	}
+	return result
+}
+
+// memoGroup 合并为一篇笔记的memo
+type memoGroup struct {
+	Title  string
+	Memos  []memoItem
+	NoteID string
+}
+
+// ImportMemos 导入flomo或memos的短记录，按天合并或每条一篇笔记
+func ImportMemos(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	filePath, _ := args["file_path"].(string)
+	memosURL, _ := args["memos_url"].(string)
+	if (filePath == "") == (memosURL == "") {
+		return mcp.NewToolResultText("❌ 请传入file_path（flomo导出的HTML或memos导出的JSON）或memos_url（memos服务地址）中的一个"), nil
+	}
+	groupBy, _ := args["group_by"].(string)
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	if groupBy != "day" && groupBy != "memo" {
+		return mcp.NewToolResultText("❌ group_by必须是 'day' 或 'memo'"), nil
+	}
+	tags := []string{"碎片记录"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	dryRun, _ := args["dry_run"].(bool)
+
+	var memos []memoItem
+	root := ""
+	if filePath != "" {
+		path, err := checkLocalPath(ctx, filePath)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return errorResult("读取文件失败", err), nil
+		}
+		if info.Size() > maxMemosExportSize {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 文件超过大小上限 %d MB", maxMemosExportSize>>20)), nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errorResult("读取文件失败", err), nil
+		}
+		root = filepath.Dir(path)
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".html" || ext == ".htm" {
+			memos = parseFlomoHTML(string(data))
+		} else if memos, err = parseMemosJSON(data); err != nil {
+			return errorResult("", err), nil
+		}
+	} else {
+		token, _ := args["memos_token"].(string)
+		if token == "" {
+			token = envString(MemosTokenEnvVar, "")
+		}
+		var err error
+		if memos, err = fetchMemos(ctx, strings.TrimRight(memosURL, "/"), token); err != nil {
+			return errorResult("", err), nil
+		}
+	}
+	if len(memos) == 0 {
+		return mcp.NewToolResultText("❌ 没有找到可导入的记录"), nil
+	}
+	if len(memos) > maxImportMemos {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 记录数 %d 超过单次导入上限 %d，请分批导入", len(memos), maxImportMemos)), nil
+	}
+
+	// 跳过已导入的记录，剩余的按时间从早到晚分组
+	tenantID := tenantFromContext(ctx)
+	var pending []memoItem
+	skipped := 0
+	for _, memo := range memos {
+		if id, err := GetNamedNote(tenantID, memo.key()); err == nil && id != "" {
+			skipped++
+			continue
+		}
+		pending = append(pending, memo)
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	var groups []*memoGroup
+	for _, memo := range pending {
+		title := memo.CreatedAt.Local().Format("2006-01-02") + " 碎片记录"
+		if memo.CreatedAt.IsZero() {
+			title = "未知日期的碎片记录"
+		}
+		if groupBy == "day" && len(groups) > 0 && groups[len(groups)-1].Title == title {
+			last := groups[len(groups)-1]
+			last.Memos = append(last.Memos, memo)
+			continue
+		}
+		groups = append(groups, &memoGroup{Title: title, Memos: []memoItem{memo}})
+	}
+
+	var sb strings.Builder
+	if dryRun {
+		sb.WriteString(fmt.Sprintf("📝 预览：共 %d 条记录，%d 条已导入过，将创建 %d 篇笔记\n\n", len(memos), skipped, len(groups)))
+		for _, group := range groups {
+			sb.WriteString(fmt.Sprintf("- %s（%d 条）\n", group.Title, len(group.Memos)))
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+	if len(groups) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 全部 %d 条记录都已导入过", skipped)), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	im := &memoImporter{ctx: ctx, client: client, root: root}
+	var failures []string
+	imported := 0
+	for _, group := range groups {
+		var blocks []ContentBlock
+		noteTags := tags
+		if groupBy == "day" {
+			blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: group.Title, Bold: true}}})
+		}
+		for i, memo := range group.Memos {
+			if groupBy == "day" {
+				if i > 0 {
+					blocks = append(blocks, ContentBlock{Type: "divider"})
+				}
+				if !memo.CreatedAt.IsZero() {
+					blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: memo.CreatedAt.Local().Format("15:04"), Bold: true}}})
+				}
+			}
+			blocks = append(blocks, im.memoBlocks(memo)...)
+			if groupBy == "memo" && !memo.CreatedAt.IsZero() {
+				blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "记录于 " + memo.CreatedAt.Local().Format("2006-01-02 15:04")}}})
+			}
+			noteTags = mergeTags(noteTags, memoTags(memo))
+		}
+
+		noteID, err := createNoteFromBlocks(ctx, client, blocks, &Settings{Tags: noteTags})
+		if err == nil && noteID == "" {
+			err = fmt.Errorf("接口未返回笔记ID")
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", group.Title, err))
+			continue
+		}
+		group.NoteID = noteID
+		for _, memo := range group.Memos {
+			imported++
+			if err := SetNamedNote(tenantID, memo.key(), noteID); err != nil {
+				im.warnings = append(im.warnings, fmt.Sprintf("%s: 记录导入状态失败: %v", group.Title, err))
+			}
+		}
+	}
+
+	if imported == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 导入失败:\n%s", strings.Join(failures, "\n"))), nil
+	}
+	sb.WriteString(fmt.Sprintf("✅ 短记录导入完成！\n\n导入记录: %d 条\n已导入过: %d 条\n新建笔记: %d 篇\n失败: %d 篇\n\n",
+		imported, skipped, len(groups)-len(failures), len(failures)))
+	for _, group := range groups {
+		if group.NoteID != "" {
+			sb.WriteString(fmt.Sprintf("- %s（%d 条）: %s\n", group.Title, len(group.Memos), group.NoteID))
+		}
+	}
+	if len(failures) > 0 {
+		sb.WriteString(fmt.Sprintf("\n❌ 失败的笔记:\n%s\n", strings.Join(failures, "\n")))
+	}
+	if len(im.warnings) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ 警告:\n%s\n", strings.Join(im.warnings, "\n")))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 导入短记录工具
+var ImportMemosTool = mcp.NewTool("import_memos",
+	mcp.WithDescription("导入flomo或memos的短记录：支持flomo导出的HTML、memos导出的JSON，或直接从memos服务的接口拉取。默认把同一天的记录合并为一篇按日期命名的笔记，也可以每条记录一篇笔记；记录自带的标签和正文中的 #标签 都会保留为笔记标签。同一条记录只会导入一次。"),
+	mcp.WithString("file_path",
+		mcp.Description("flomo导出的HTML文件（图片按导出目录中的相对路径上传）或memos导出的JSON文件路径，与memos_url二选一"),
+	),
+	mcp.WithString("memos_url",
+		mcp.Description("memos服务地址，例如 https://memos.example.com，与file_path二选一"),
+	),
+	mcp.WithString("memos_token",
+		mcp.Description("memos的访问令牌，不传时使用环境变量MOWEN_MEMOS_TOKEN"),
+	),
+	mcp.WithString("group_by",
+		mcp.Description("分组方式：day(默认，每天一篇笔记), memo(每条记录一篇笔记)"),
+		mcp.Enum("day", "memo"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("额外的标签，JSON字符串数组，默认 [\"碎片记录\"]"),
+	),
+	mcp.WithBoolean("dry_run",
+		mcp.Description("为true时只统计将要创建的笔记，不创建笔记"),
+	),
+)
+
+func importMemosHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ImportMemos(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
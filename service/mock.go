@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 模拟模式环境变量
+const (
+	// 模拟模式：replay(回放样例响应，不需要API密钥和网络), record(真实请求并录制响应)，默认关闭
+	MockModeEnvVar = "MOWEN_MOCK"
+	// 样例响应目录，默认为当前目录下的 mock_fixtures
+	MockDirEnvVar = "MOWEN_MOCK_DIR"
+)
+
+// 模拟模式下使用的占位API密钥
+const mockAPIKey = "mock"
+
+// 模拟的OSS上传地址，回放时上传授权接口返回该地址
+const mockUploadEndpoint = "https://mock.mowen.invalid/upload"
+
+// 样例中的占位符，回放时替换为递增序号，使每次创建的笔记和文件ID不同
+const mockSeqPlaceholder = "{{seq}}"
+
+// mockFixture 录制的一次响应
+type mockFixture struct {
+	StatusCode  int             `json:"status_code"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// 没有录制样例时使用的内置响应
+var defaultMockFixtures = map[string]mockFixture{
+	APICreateNote:      {StatusCode: http.StatusOK, Body: json.RawMessage(`{"noteId":"mock-note-{{seq}}"}`)},
+	APIEditNote:        {StatusCode: http.StatusOK, Body: json.RawMessage(`{}`)},
+	APISetNote:         {StatusCode: http.StatusOK, Body: json.RawMessage(`{}`)},
+	APIGetNote:         {StatusCode: http.StatusOK, Body: json.RawMessage(`{"note":{"body":{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"模拟笔记","marks":[{"type":"bold"}]}]},{"type":"paragraph"},{"type":"paragraph","content":[{"type":"text","text":"模拟笔记的正文"}]}]}}}`)},
+	APIUploadPrepare:   {StatusCode: http.StatusOK, Body: json.RawMessage(`{"form":{"endpoint":"` + mockUploadEndpoint + `","key":"mock/{{seq}}","policy":"mock","signature":"mock"}}`)},
+	APIUploadFileByURL: {StatusCode: http.StatusOK, Body: json.RawMessage(`{"file":{"fileId":"mock-file-{{seq}}"}}`)},
+	apiUploadFile:      {StatusCode: http.StatusOK, Body: json.RawMessage(`{"file":{"fileId":"mock-file-{{seq}}"}}`)},
+}
+
+var (
+	mockSeq     atomic.Int64
+	mockLogOnce sync.Once
+)
+
+// mockMode 当前的模拟模式，未开启时返回空字符串
+func mockMode() string {
+	switch mode := strings.ToLower(envString(MockModeEnvVar, "")); mode {
+	case "replay", "record":
+		return mode
+	}
+	return ""
+}
+
+// mockReplaying 是否处于回放模式，回放时不访问网络
+func mockReplaying() bool {
+	return mockMode() == "replay"
+}
+
+// mockTransport 按模拟模式回放或录制墨问API的响应
+type mockTransport struct {
+	mode string
+	dir  string
+	base http.RoundTripper
+}
+
+// newMockTransport 模拟模式开启时返回对应的Transport，否则返回nil
+func newMockTransport() http.RoundTripper {
+	mode := mockMode()
+	if mode == "" {
+		return nil
+	}
+	dir := expandPath(context.Background(), envString(MockDirEnvVar, "mock_fixtures"))
+	mockLogOnce.Do(func() {
+		logger.Warnf("墨问API模拟模式已开启: %s，样例目录: %s", mode, dir)
+	})
+	return &mockTransport{
+		mode: mode,
+		dir:  dir,
+		base: http.DefaultTransport,
+	}
+}
+
+// fixtureKey 请求对应的样例名称：墨问API按路径区分，上传到OSS的请求统一为apiUploadFile
+func fixtureKey(req *http.Request) string {
+	if strings.HasPrefix(req.URL.Path, "/api/open/") {
+		return req.URL.Path
+	}
+	return apiUploadFile
+}
+
+// fixturePath 样例文件路径，例如 /api/open/api/v1/note/create 对应 note_create.json
+func (t *mockTransport) fixturePath(key string) string {
+	name := strings.TrimPrefix(key, "/api/open/api/v1/")
+	name = strings.NewReplacer("/", "_", ":", "_").Replace(name)
+	return filepath.Join(t.dir, name+".json")
+}
+
+func (t *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := fixtureKey(req)
+	if t.mode == "record" {
+		return t.record(req, key)
+	}
+	return t.replay(req, key)
+}
+
+// replay 返回录制的样例，没有样例时使用内置响应
+func (t *mockTransport) replay(req *http.Request, key string) (*http.Response, error) {
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+
+	fixture, ok := defaultMockFixtures[key]
+	data, err := os.ReadFile(t.fixturePath(key))
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("解析样例 %s 失败: %w", t.fixturePath(key), err)
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("读取样例失败: %w", err)
+	case !ok:
+		return nil, fmt.Errorf("模拟模式下没有 %s 的样例，请先用 %s=record 录制", key, MockModeEnvVar)
+	}
+
+	// 非JSON的响应录制为JSON字符串
+	body := string(fixture.Body)
+	var text string
+	if json.Unmarshal(fixture.Body, &text) == nil {
+		body = text
+	}
+	body = strings.ReplaceAll(body, mockSeqPlaceholder, strconv.FormatInt(mockSeq.Add(1), 10))
+	contentType := fixture.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// record 发送真实请求，并把响应保存为样例
+// 只保存状态码和响应体，不保存请求头，API密钥不会写入样例
+func (t *mockTransport) record(req *http.Request, key string) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	fixture := mockFixture{StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type")}
+	if json.Valid(data) {
+		fixture.Body = data
+	} else {
+		fixture.Body, _ = json.Marshal(string(data))
+	}
+	if err = t.saveFixture(key, fixture); err != nil {
+		logger.Warnf("保存样例失败: %v", err)
+	}
+	return resp, nil
+}
+
+// saveFixture 写入样例文件
+func (t *mockTransport) saveFixture(key string, fixture mockFixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+	path := t.fixturePath(key)
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	logger.Infof("已录制样例: %s", path)
+	return nil
+}
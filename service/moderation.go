@@ -0,0 +1,145 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 内容审核环境变量
+const (
+	// 审核服务地址，设置后发布笔记前会先提交审核
+	ModerationURLEnvVar = "MOWEN_MODERATION_URL"
+	// 审核服务的Bearer令牌（可选）
+	ModerationTokenEnvVar = "MOWEN_MODERATION_TOKEN"
+	// 审核请求超时时间，默认10秒
+	ModerationTimeoutEnvVar = "MOWEN_MODERATION_TIMEOUT"
+	// 审核服务不可用时是否放行，默认不放行
+	ModerationFailOpenEnvVar = "MOWEN_MODERATION_FAIL_OPEN"
+)
+
+// moderationRequest 提交给审核服务的内容
+type moderationRequest struct {
+	Action   string         `json:"action"` // create, publish
+	TenantID string         `json:"tenant_id,omitempty"`
+	NoteID   string         `json:"note_id,omitempty"`
+	Blocks   []ContentBlock `json:"blocks"`
+	Tags     []string       `json:"tags,omitempty"`
+}
+
+// moderationResponse 审核服务的返回
+// allow为false时拒绝发布；blocks非空时以其替换原内容（脱敏后发布）
+type moderationResponse struct {
+	Allow  bool           `json:"allow"`
+	Reason string         `json:"reason,omitempty"`
+	Blocks []ContentBlock `json:"blocks,omitempty"`
+}
+
+// moderationEnabled 是否配置了审核服务
+func moderationEnabled() bool {
+	return envString(ModerationURLEnvVar, "") != ""
+}
+
+// moderateContent 发布前提交审核，返回审核后的内容块，redacted表示内容被审核服务修改
+// 未配置审核服务时原样返回
+func moderateContent(ctx context.Context, action, noteID string, blocks []ContentBlock, tags []string) ([]ContentBlock, bool, error) {
+	endpoint := envString(ModerationURLEnvVar, "")
+	if endpoint == "" {
+		return blocks, false, nil
+	}
+
+	resp, err := callModeration(ctx, endpoint, moderationRequest{
+		Action:   action,
+		TenantID: tenantFromContext(ctx),
+		NoteID:   noteID,
+		Blocks:   blocks,
+		Tags:     tags,
+	})
+	if err != nil {
+		if envBool(ModerationFailOpenEnvVar, false) {
+			logger.Warnf("内容审核服务不可用，已放行: %v", err)
+			return blocks, false, nil
+		}
+		return nil, false, withErrorCode(ErrUpstream, fmt.Errorf("内容审核服务不可用: %w", err))
+	}
+
+	if !resp.Allow {
+		if resp.Reason == "" {
+			resp.Reason = "未说明原因"
+		}
+		return nil, false, withErrorCode(ErrPermissionDenied, fmt.Errorf("内容未通过审核: %s", resp.Reason))
+	}
+	if len(resp.Blocks) > 0 {
+		logger.Infof("内容审核服务修改了笔记内容，noteID: %s", noteID)
+		return resp.Blocks, true, nil
+	}
+	return blocks, false, nil
+}
+
+// needsPublishModeration 配置了审核服务且笔记已公开时，写入的内容需要先通过审核
+// 无法确认发布状态时按已公开处理，避免未经审核的内容被公开
+func needsPublishModeration(ctx context.Context, noteID string) bool {
+	if !moderationEnabled() {
+		return false
+	}
+	published, err := IsNotePublished(tenantFromContext(ctx), noteID)
+	if err != nil {
+		logger.Warnf("%v，按已公开笔记提交审核，noteID: %s", err, noteID)
+		return true
+	}
+	return published
+}
+
+// moderatePublishedNote 已公开的笔记写入新内容前提交审核，不需要审核时原样返回
+func moderatePublishedNote(ctx context.Context, noteID string, blocks []ContentBlock, tags []string) ([]ContentBlock, bool, error) {
+	if !needsPublishModeration(ctx, noteID) {
+		return blocks, false, nil
+	}
+	return moderateContent(ctx, "publish", noteID, blocks, tags)
+}
+
+// callModeration 调用审核服务
+func callModeration(ctx context.Context, endpoint string, payload moderationRequest) (*moderationResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化审核请求失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, envDuration(ModerationTimeoutEnvVar, 10*time.Second))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建审核请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := envString(ModerationTokenEnvVar, ""); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求审核服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取审核结果失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("审核服务返回状态码 %d: %s", resp.StatusCode, data)
+	}
+
+	var result moderationResponse
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析审核结果失败: %w", err)
+	}
+	return &result, nil
+}
@@ -65,9 +65,9 @@ type UploadURLFile struct {
 // 创建一篇新的墨问笔记
 func CreateNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// 创建墨问客户端
-	client, err := NewMowenClient()
+	client, err := NewMowenClientFromContext(ctx)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ 创建客户端失败: %v", err)), nil
+		return errorResult("创建客户端失败", err), nil
 	}
 
 	// 解析paragraphs参数
@@ -79,7 +79,7 @@ func CreateNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 
 	var blocks []ContentBlock
 	if err = json.Unmarshal([]byte(paragraphsStr), &blocks); err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ paragraphs JSON解析错误: %v", err)), nil
+		return errorResult("paragraphs JSON解析错误", err), nil
 	}
 
 	// 解析其他参数
@@ -97,57 +97,35 @@ func CreateNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 		return mcp.NewToolResultText("❌ 段落列表不能为空"), nil
 	}
 
-	// 使用ConvertToMowenFormat函数进行数据转换
-	mowenDoc, err := ConvertToMowenFormat(client, blocks)
-	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ 转换文档格式失败: %v", err)), nil
-	}
-
 	// 构建设置
 	settings := &Settings{
 		AutoPublish: &autoPublish,
 		Tags:        tags,
 	}
 
-	payload := CreateNoteParams{
-		Body:     &mowenDoc,
-		Settings: settings,
+	// 本次创建的翻译设置，覆盖环境变量中的默认值
+	translateTo, _ := args["translate_to"].(string)
+	translateOutput, _ := args["translate_output"].(string)
+	if translateTo != "" || translateOutput != "" {
+		ctx = withTranslateOptions(ctx, translateOptions{Target: strings.TrimSpace(translateTo), Output: translateOutput})
 	}
 
-	// 调用API创建笔记
-	resp, err := client.PostRequest(APICreateNote, payload)
+	// 转换格式、调用API创建笔记并保存到本地
+	noteID, err := createNoteFromBlocks(ctx, client, blocks, settings)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ API请求失败: %v", err)), nil
+		return errorResult("", err), nil
 	}
-
-	// 处理响应
-	if resp.StatusCode != 200 {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ API请求失败，状态码: %d，响应: %s", resp.StatusCode, resp.RawBody)), nil
-	}
-
-	// 解析响应获取笔记ID
-	var noteID string
-	if resp.Body != nil {
-		if id, ok := resp.Body["noteId"].(string); ok {
-			noteID = id
-		}
-	}
-
 	if noteID == "" {
 		noteID = "未知ID"
+	} else {
+		// 新建的笔记自动成为当前笔记
+		sessionFromContext(ctx).SetCurrentNoteID(noteID)
+		autoRegenerateIndex(ctx, client)
 	}
-	go func() {
-		// 存入数据库
-		summary := ""
-		if success, err := SaveNoteToSQLite(noteID, paragraphsStr, summary); !success {
-			logger.Info("保存笔记到数据库失败", "error", err, "noteID", noteID)
-		} else {
-			logger.Info("笔记已成功保存到数据库", "noteID", noteID)
-		}
-	}()
 
+	// 标签以钩子处理后的为准
 	resultText := fmt.Sprintf("✅ 笔记创建成功！\n\n笔记ID: %s\n段落数: %d\n自动发布: %t\n标签: %s",
-		noteID, len(blocks), autoPublish, strings.Join(tags, ", "))
+		noteID, len(blocks), autoPublish, strings.Join(settings.Tags, ", "))
 
 	return mcp.NewToolResultText(resultText), nil
 }
@@ -155,16 +133,16 @@ func CreateNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 // 编辑已存在的笔记内容
 func EditNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// 创建墨问客户端
-	client, err := NewMowenClient()
+	client, err := NewMowenClientFromContext(ctx)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ 创建客户端失败: %v", err)), nil
+		return errorResult("创建客户端失败", err), nil
 	}
 
 	// 解析参数
 	args := request.Params.Arguments
-	noteID, ok := args["note_id"].(string)
-	if !ok || noteID == "" {
-		return mcp.NewToolResultText("❌ 笔记ID不能为空"), nil
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
 	}
 
 	paragraphsStr, ok := args["paragraphs"].(string)
@@ -174,7 +152,7 @@ func EditNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolRe
 
 	var blocks []ContentBlock
 	if err = json.Unmarshal([]byte(paragraphsStr), &blocks); err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ paragraphs JSON解析错误: %v", err)), nil
+		return errorResult("paragraphs JSON解析错误", err), nil
 	}
 
 	// 参数验证
@@ -182,48 +160,66 @@ func EditNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolRe
 		return mcp.NewToolResultText("❌ 段落列表不能为空"), nil
 	}
 
-	// 使用ConvertToMowenFormat函数进行数据转换
-	mowenDoc, err := ConvertToMowenFormat(client, blocks)
-	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ 转换文档格式失败: %v", err)), nil
+	// 上传文件、写入远端并同步本地记录
+	if err = replaceNoteBlocks(ctx, client, noteID, blocks); err != nil {
+		return errorResult("", err), nil
 	}
 
-	// 构建请求参数
-	payload := EditNoteParams{
-		NoteID:     noteID,
-		Paragraphs: []MowenDocument{mowenDoc},
-	}
+	resultText := fmt.Sprintf("✅ 笔记编辑成功！\n\n笔记ID: %s\n段落数: %d",
+		noteID, len(blocks))
+
+	return mcp.NewToolResultText(resultText), nil
+}
 
-	// 调用API编辑笔记
-	resp, err := client.PostRequest(APIEditNote, payload)
+// AppendToNote 在笔记末尾追加内容，保留原有内容
+func AppendToNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client, err := NewMowenClientFromContext(ctx)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ API请求失败: %v", err)), nil
+		return errorResult("创建客户端失败", err), nil
 	}
 
-	// 处理响应
-	if resp.StatusCode != 200 {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ API请求失败，状态码: %d，响应: %s", resp.StatusCode, resp.RawBody)), nil
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
 	}
 
-	resultText := fmt.Sprintf("✅ 笔记编辑成功！\n\n笔记ID: %s\n段落数: %d",
-		noteID, len(blocks))
+	paragraphsStr, ok := args["paragraphs"].(string)
+	if !ok {
+		return mcp.NewToolResultText("❌ paragraphs参数必须是JSON字符串"), nil
+	}
+	var blocks []ContentBlock
+	if err = json.Unmarshal([]byte(paragraphsStr), &blocks); err != nil {
+		return errorResult("paragraphs JSON解析错误", err), nil
+	}
+	if len(blocks) == 0 {
+		return mcp.NewToolResultText("❌ 段落列表不能为空"), nil
+	}
+	if divider, _ := args["divider"].(bool); divider {
+		blocks = append([]ContentBlock{{Type: "divider"}}, blocks...)
+	}
 
-	return mcp.NewToolResultText(resultText), nil
+	total, err := appendNoteBlocks(ctx, client, noteID, blocks)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 内容已追加到笔记末尾！\n\n笔记ID: %s\n追加段落数: %d\n段落总数: %d",
+		noteID, len(blocks), total)), nil
 }
 
 // 设置笔记的隐私权限
 func SetNotePrivacy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// 创建墨问客户端
-	client, err := NewMowenClient()
+	client, err := NewMowenClientFromContext(ctx)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ 创建客户端失败: %v", err)), nil
+		return errorResult("创建客户端失败", err), nil
 	}
 
 	// 解析参数
 	args := request.Params.Arguments
-	noteID, ok := args["note_id"].(string)
-	if !ok || noteID == "" {
-		return mcp.NewToolResultText("❌ 笔记ID不能为空"), nil
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
 	}
 
 	privacyType, ok := args["privacy_type"].(string)
@@ -272,16 +268,46 @@ func SetNotePrivacy(ctx context.Context, request mcp.CallToolRequest) (*mcp.Call
 		Settings: settings,
 	}
 
+	tenantID := tenantFromContext(ctx)
+
+	// 公开笔记前先提交内容审核，审核服务脱敏后的内容会先写回笔记
+	redactedNote := false
+	if privacyType != "private" && moderationEnabled() {
+		blocks, err := loadNoteBlocks(tenantID, noteID)
+		if err != nil {
+			return errorResult("无法读取笔记内容进行审核", err), nil
+		}
+		tags, _ := GetNoteTags(tenantID, noteID)
+		moderated, redacted, err := moderateContent(ctx, "publish", noteID, blocks, tags)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		if redacted {
+			if err = replaceNoteBlocks(ctx, client, noteID, moderated); err != nil {
+				return errorResult("写入审核后的内容失败", err), nil
+			}
+			redactedNote = true
+		}
+	}
+
+	unlock := lockNote(tenantID, noteID)
+	defer unlock()
+
 	// 调用API设置笔记隐私
 	resp, err := client.PostRequest(APISetNote, payload)
 	if err != nil {
-		return mcp.NewToolResultText(fmt.Sprintf("❌ API请求失败: %v", err)), nil
+		return errorResult("API请求失败", err), nil
 	}
 
 	// 处理响应
 	if resp.StatusCode != 200 {
 		requestStr, _ := json.Marshal(payload)
-		return mcp.NewToolResultText(fmt.Sprintf("❌ API请求失败，状态码: %d，响应: %s，请求参数：%s", resp.StatusCode, resp.RawBody, requestStr)), nil
+		return errorResult("", apiStatusError(resp.StatusCode, "API请求失败，状态码: %d，响应: %s，请求参数：%s", resp.StatusCode, resp.RawBody, requestStr)), nil
+	}
+
+	InvalidateNote(tenantID, noteID)
+	if err := SetNotePrivacyType(tenantID, noteID, privacyType); err != nil {
+		logger.Warnf("保存隐私设置失败，noteID: %s, error: %v", noteID, err)
 	}
 
 	responseText := fmt.Sprintf("✅ 笔记隐私设置成功！\n\n笔记ID: %s\n隐私类型: %s",
@@ -295,10 +321,19 @@ func SetNotePrivacy(ctx context.Context, request mcp.CallToolRequest) (*mcp.Call
 			responseText += fmt.Sprintf("\n过期时间戳: %.0f", expireAt)
 		}
 	}
+	if redactedNote {
+		responseText += "\n\n📝 内容审核服务修改了部分内容，已按审核结果更新笔记"
+	}
 
 	return mcp.NewToolResultText(responseText), nil
 }
 
+// 按全部笔记查询时最多读取的笔记数量
+const maxSearchAllNotes = 1000
+
+// 数据库中created_at的时间格式（UTC）
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
 // 分析笔记内容
 // SearchNote 查询笔记功能
 func SearchNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -331,25 +366,39 @@ func SearchNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 		}
 	}
 
+	keywordsArg, _ := request.Params.Arguments["keywords"].(string)
+	keywordTerms := parseKeywordTerms(keywordsArg)
+	textQuery, _ := request.Params.Arguments["query"].(string)
+	textQuery = strings.TrimSpace(textQuery)
+	// 只按关键词或全文查询时在全部笔记中筛选
+	if queryType == "" && (len(keywordTerms) > 0 || textQuery != "") {
+		queryType = "all"
+	}
+
+	tenantID := tenantFromContext(ctx)
 	nowDate := time.Now()
 	var results []NoteRecord
 	var err error
 
 	// 根据查询类型执行不同的查询
 	switch queryType {
+	case "all":
+		// 查询全部笔记的最新版本
+		results, err = ListLatestNotes(tenantID, maxSearchAllNotes)
+
 	case "specific_date":
 		// 查询特定日期的笔记
 		if specificDate == "" {
 			specificDate = nowDate.Format("2006-01-02")
 		}
-		results, err = SearchByDate(specificDate)
+		results, err = SearchByDate(tenantID, specificDate)
 
 	case "date_range":
 		// 查询日期范围内的笔记
 		if startDate == "" || endDate == "" {
 			return mcp.NewToolResultError("日期范围查询需要提供开始日期和结束日期"), nil
 		}
-		results, err = SearchByDateRange(startDate, endDate)
+		results, err = SearchByDateRange(tenantID, startDate, endDate)
 
 	case "this_week":
 		// 查询本周的笔记
@@ -360,6 +409,7 @@ func SearchNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 		startOfWeek := nowDate.AddDate(0, 0, -(weekday - 1))
 		endOfWeek := startOfWeek.AddDate(0, 0, 6)
 		results, err = SearchByDateRange(
+			tenantID,
 			startOfWeek.Format("2006-01-02"),
 			endOfWeek.Format("2006-01-02"),
 		)
@@ -369,6 +419,7 @@ func SearchNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 		startOfMonth := time.Date(nowDate.Year(), nowDate.Month(), 1, 0, 0, 0, 0, nowDate.Location())
 		endOfMonth := startOfMonth.AddDate(0, 1, -1)
 		results, err = SearchByDateRange(
+			tenantID,
 			startOfMonth.Format("2006-01-02"),
 			endOfMonth.Format("2006-01-02"),
 		)
@@ -382,6 +433,7 @@ func SearchNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 		startOfLastWeek := nowDate.AddDate(0, 0, -(weekday - 1 + 7))
 		endOfLastWeek := startOfLastWeek.AddDate(0, 0, 6)
 		results, err = SearchByDateRange(
+			tenantID,
 			startOfLastWeek.Format("2006-01-02"),
 			endOfLastWeek.Format("2006-01-02"),
 		)
@@ -391,55 +443,111 @@ func SearchNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallTool
 		startOfLastMonth := time.Date(nowDate.Year(), nowDate.Month()-1, 1, 0, 0, 0, 0, nowDate.Location())
 		endOfLastMonth := startOfLastMonth.AddDate(0, 1, -1)
 		results, err = SearchByDateRange(
+			tenantID,
 			startOfLastMonth.Format("2006-01-02"),
 			endOfLastMonth.Format("2006-01-02"),
 		)
 
+	case "last_n_days", "last_n_hours":
+		// 查询最近N天（含今天）或最近N小时的笔记，数据库中的时间为UTC
+		n := 1
+		if v, ok := request.Params.Arguments["n"].(float64); ok && v >= 1 {
+			n = int(v)
+		}
+		since := nowDate.Add(-time.Duration(n) * time.Hour)
+		if queryType == "last_n_days" {
+			since = time.Date(nowDate.Year(), nowDate.Month(), nowDate.Day()-(n-1), 0, 0, 0, 0, nowDate.Location())
+		}
+		results, err = SearchByDateRange(
+			tenantID,
+			since.UTC().Format(sqliteTimeLayout),
+			nowDate.UTC().Format(sqliteTimeLayout),
+		)
+
 	case "today":
 		// 查询今天的笔记
-		results, err = SearchByDate(nowDate.Format("2006-01-02"))
+		results, err = SearchByDate(tenantID, nowDate.Format("2006-01-02"))
 
 	case "yesterday":
 		// 查询昨天的笔记
 		yesterday := nowDate.AddDate(0, 0, -1)
-		results, err = SearchByDate(yesterday.Format("2006-01-02"))
+		results, err = SearchByDate(tenantID, yesterday.Format("2006-01-02"))
 
 	default:
 		// 默认查询今天的笔记
-		results, err = SearchByDate(nowDate.Format("2006-01-02"))
+		results, err = SearchByDate(tenantID, nowDate.Format("2006-01-02"))
 	}
 
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("查询笔记失败: %v", err)), nil
 	}
 
+	if textQuery != "" {
+		matched, err := SearchNotesFullText(tenantID, textQuery, maxSearchAllNotes)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("全文查询失败: %v", err)), nil
+		}
+		if queryType == "all" {
+			results = matched
+		} else {
+			matchedIDs := make(map[string]bool, len(matched))
+			for _, record := range matched {
+				matchedIDs[record.NoteID] = true
+			}
+			filtered := results[:0]
+			for _, record := range results {
+				if matchedIDs[record.NoteID] {
+					filtered = append(filtered, record)
+				}
+			}
+			results = filtered
+		}
+	}
+
+	if len(keywordTerms) > 0 {
+		filtered := results[:0]
+		for _, record := range results {
+			if matchKeywordTerms(record, keywordTerms) {
+				filtered = append(filtered, record)
+			}
+		}
+		results = filtered
+	}
+
 	// 格式化查询结果
 	if len(results) == 0 {
 		return mcp.NewToolResultText("📝 未找到符合条件的笔记"), nil
 	}
 
-	var resultText strings.Builder
-	resultText.WriteString(fmt.Sprintf("📝 找到 %d 条笔记:\n\n", len(results)))
-
-	for i, note := range results {
-		resultText.WriteString(fmt.Sprintf("**%d. 笔记 %s**\n", i+1, note.NoteID))
-		resultText.WriteString(fmt.Sprintf("创建时间: %s\n", note.CreatedAt))
-
-		// 显示内容摘要（前100个字符）
-		content := note.Content
-		if len(content) > 100 {
-			content = content[:100] + "..."
+	offset, pageSize, err := searchPage(request.Params.Arguments)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	// 按需从墨问刷新本页的笔记，刷新后的内容不再重新筛选
+	var refreshFailures []string
+	if refresh, _ := request.Params.Arguments["refresh"].(bool); refresh && offset < len(results) {
+		client, err := NewMowenClientFromContext(ctx)
+		if err != nil {
+			return errorResult("创建客户端失败", err), nil
 		}
-		resultText.WriteString(fmt.Sprintf("内容摘要: %s\n", content))
-
-		if note.Summary != "" {
-			resultText.WriteString(fmt.Sprintf("总结: %s\n", note.Summary))
+		for i := offset; i < min(offset+pageSize, len(results)); i++ {
+			fresh, _, err := refreshNoteRecord(ctx, client, results[i].NoteID)
+			if err != nil {
+				refreshFailures = append(refreshFailures, fmt.Sprintf("%s: %v", results[i].NoteID, err))
+				continue
+			}
+			results[i] = *fresh
 		}
-
-		resultText.WriteString("\n")
 	}
 
-	return mcp.NewToolResultText(resultText.String()), nil
+	includeContent, _ := request.Params.Arguments["include_content"].(string)
+	fieldsArg, _ := request.Params.Arguments["fields"].(string)
+	text := formatSearchResults(tenantID, results, parseResultFields(fieldsArg), includeContent,
+		offset, pageSize, searchQueryHash(request.Params.Arguments))
+	if len(refreshFailures) > 0 {
+		text += fmt.Sprintf("\n⚠️ 以下笔记从墨问刷新失败，显示的是本地内容:\n%s\n", strings.Join(refreshFailures, "\n"))
+	}
+	return mcp.NewToolResultText(text), nil
 }
 
 // 所有墨问相关的MCP工具
@@ -454,8 +562,21 @@ var CreateNoteTool = mcp.NewTool("create_note",
         段落类型：
         1. 普通段落（默认）：{"texts": [...]}
         2. 引用段落：{"type": "quote", "texts": [...]}
+           多段引用用paragraphs传入后续段落：{"type": "quote", "texts": [...], "paragraphs": [[...], [...]]}
+           嵌套引用用children传入内层引用块：{"type": "quote", "texts": [...], "children": [{"type": "quote", "texts": [...]}]}
         3. 内链笔记：{"type": "note", "note_id": "笔记ID"}
         4. 文件段落：{"type": "file", "file_type": "image|audio|pdf", "source_type": "local|url", "source_path": "路径", "metadata": {...}}
+           metadata中的file_name可以指定上传后显示的文件名，默认使用本地文件名或URL路径的最后一段
+           已用upload_file上传过的文件直接传file_id，不会重复上传：{"type": "file", "file_type": "image", "file_id": "文件ID"}
+           本地路径支持 ~/ 开头的主目录路径和 $VAR 环境变量，例如 "~/Downloads/report.pdf"
+           本地的HEIC照片和动态WebP会自动转换为JPEG和GIF后上传，metadata中设置"convert": false可关闭转换
+           附加目录中的多个文件：{"type": "file", "source_type": "dir", "source_path": "目录", "pattern": "*.png"}
+           按文件名排序依次上传匹配的文件（不含子目录），file_type可省略，指定时只附加该类型的文件
+        5. 待办：{"type": "todo", "texts": [...], "checked": false}，显示为带勾选框的段落，可用list_open_tasks汇总
+        6. 分隔线：{"type": "divider"}，用于分隔长笔记的章节；只包含 --- 的普通段落同样处理
+           墨问没有分隔线节点，在墨问中显示为章节之间的空行，导出HTML和Markdown时输出为分隔线
+        7. 公式：{"type": "math", "texts": [{"text": "E=mc^2"}]}，配置MOWEN_MATH_RENDERER后渲染为图片上传，否则保存为 $$公式$$ 文字
+           整段为 $...$、$$...$$ 或 math 代码块的普通段落也按公式处理
         
         格式示例：
         [
@@ -494,7 +615,8 @@ var CreateNoteTool = mcp.NewTool("create_note",
                 "source_type": "url",
                 "source_path": "https://example.com/audio.mp3",
                 "metadata": {
-                    "show_note": "00:00 开场\\n01:30 主要内容"
+                    "show_note": "00:00 开场\\n01:30 主要内容",
+                    "file_name": "第一期节目.mp3"
                 }
             },
             {
@@ -511,14 +633,19 @@ var CreateNoteTool = mcp.NewTool("create_note",
 	mcp.WithString("tags",
 		mcp.Description("笔记标签列表JSON字符串，例如：['工作', '学习', '重要']"),
 	),
+	mcp.WithString("translate_to",
+		mcp.Description("保存前把段落和引用翻译为指定语言，例如 en、中文；不传时使用MOWEN_TRANSLATE_TO配置，未配置则不翻译"),
+	),
+	mcp.WithString("translate_output",
+		mcp.Description("翻译结果的保存方式：bilingual(默认，每段原文后附译文) 或 replace(只保存译文)"),
+	),
 )
 
 // 编辑笔记工具
 var EditNoteTool = mcp.NewTool("edit_note",
 	mcp.WithDescription("编辑已存在的笔记内容。此操作会完全替换笔记的原有内容。支持多种内容块。"),
 	mcp.WithString("note_id",
-		mcp.Required(),
-		mcp.Description("要编辑的笔记ID"),
+		mcp.Description("要编辑的笔记ID，不传时使用当前笔记（见set_current_note）"),
 	),
 	mcp.WithString("paragraphs",
 		mcp.Required(),
@@ -526,12 +653,26 @@ var EditNoteTool = mcp.NewTool("edit_note",
 	),
 )
 
+// 追加笔记内容工具
+var AppendToNoteTool = mcp.NewTool("append_to_note",
+	mcp.WithDescription("在已存在的笔记末尾追加内容块，原有内容保持不变，适合日志、流水账等逐步记录的笔记。本地记录近期与墨问核对过时基于本地内容合并，否则先从墨问拉取当前内容，避免覆盖在网页或App中的修改。"),
+	mcp.WithString("note_id",
+		mcp.Description("要追加内容的笔记ID，不传时使用当前笔记（见set_current_note）"),
+	),
+	mcp.WithString("paragraphs",
+		mcp.Required(),
+		mcp.Description("要追加的内容块列表JSON字符串，格式与create_note相同"),
+	),
+	mcp.WithBoolean("divider",
+		mcp.Description("为true时在追加的内容前插入一条分隔线，墨问中显示为空行"),
+	),
+)
+
 // 设置笔记隐私工具
 var SetNotePrivacyTool = mcp.NewTool("set_note_privacy",
 	mcp.WithDescription("设置笔记的隐私权限。支持三种模式：完全公开(public)、私有(private)、规则公开(rule)。"),
 	mcp.WithString("note_id",
-		mcp.Required(),
-		mcp.Description("笔记ID"),
+		mcp.Description("笔记ID，不传时使用当前笔记（见set_current_note）"),
 	),
 	mcp.WithString("privacy_type",
 		mcp.Required(),
@@ -549,7 +690,7 @@ var SetNotePrivacyTool = mcp.NewTool("set_note_privacy",
 var SearchNoteTool = mcp.NewTool("search_note",
 	mcp.WithDescription("查询笔记功能，支持多种时间查询模式：特定日期、日期范围、今天、昨天、本周、本月、上周、上月等"),
 	mcp.WithString("query_type",
-		mcp.Description("查询类型：specific_date(特定日期)、date_range(日期范围)、 today(今天)、yesterday(昨天)、this_week(本周)、this_month(本月)、last_week(上周)、last_month(上月)"),
+		mcp.Description("查询类型：specific_date(特定日期)、date_range(日期范围)、 today(今天)、yesterday(昨天)、this_week(本周)、this_month(本月)、last_week(上周)、last_month(上月)、last_n_days(最近N天，含今天)、last_n_hours(最近N小时)、all(全部笔记)"),
 	),
 	mcp.WithString("specific_date",
 		mcp.Description("特定日期，格式：YYYY-MM-DD，用于specific_date查询类型"),
@@ -560,36 +701,127 @@ var SearchNoteTool = mcp.NewTool("search_note",
 	mcp.WithString("end_date",
 		mcp.Description("结束日期，格式：YYYY-MM-DD，用于date_range查询类型"),
 	),
+	mcp.WithNumber("n",
+		mcp.Description("天数或小时数，用于last_n_days和last_n_hours查询类型，默认1"),
+	),
+	mcp.WithString("include_content",
+		mcp.Description("返回的正文详细程度：none(不返回正文)、summary(摘要，默认)、full(完整正文)。结果较多时建议用none，再对选中的笔记取完整内容"),
+		mcp.Enum(includeContentNone, includeContentSummary, includeContentFull),
+	),
+	mcp.WithString("fields",
+		mcp.Description("返回的元数据字段，逗号分隔：title(标题)、created_at(创建时间)、keywords(关键词)、tags(标签)。默认title,created_at,keywords；note_id总是返回"),
+	),
+	mcp.WithString("query",
+		mcp.Description("全文查询语句，按中文分词匹配笔记正文，各个词需全部命中。只传该参数时在全部笔记中查询"),
+	),
+	mcp.WithString("keywords",
+		mcp.Description("关键词过滤，多个关键词用空格或逗号分隔，需全部命中。只传关键词时在全部笔记中查询"),
+	),
+	mcp.WithNumber("page_size",
+		mcp.Description(fmt.Sprintf("每页返回的笔记数，默认%d，最多%d", defaultSearchPageSize, maxSearchPageSize)),
+	),
+	mcp.WithString("cursor",
+		mcp.Description("翻页游标，取自上一页结果末尾；翻页时其他查询参数需保持不变"),
+	),
+	mcp.WithBoolean("refresh",
+		mcp.Description("为true时从墨问重新拉取本页笔记的内容并更新本地记录，用于核对标记为可能过期的结果"),
+	),
 )
 
 // 适配器函数，将我们的函数签名转换为 ToolHandlerFunc 期望的签名
 func createNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
-	request := mcp.CallToolRequest{}
-	request.Params.Arguments = arguments
-	return CreateNote(context.Background(), request)
+	ctx, request := newToolRequest(arguments)
+	result, err := CreateNote(ctx, request)
+	return withAPIDebug(ctx, result), err
 }
 
 func editNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
-	request := mcp.CallToolRequest{}
-	request.Params.Arguments = arguments
-	return EditNote(context.Background(), request)
+	ctx, request := newToolRequest(arguments)
+	result, err := EditNote(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
+
+func appendToNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := AppendToNote(ctx, request)
+	return withAPIDebug(ctx, result), err
 }
 
 func setNotePrivacyHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
-	request := mcp.CallToolRequest{}
-	request.Params.Arguments = arguments
-	return SetNotePrivacy(context.Background(), request)
+	ctx, request := newToolRequest(arguments)
+	result, err := SetNotePrivacy(ctx, request)
+	return withAPIDebug(ctx, result), err
 }
 
 func searchNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
-	request := mcp.CallToolRequest{}
-	request.Params.Arguments = arguments
-	return SearchNote(context.Background(), request)
+	ctx, request := newToolRequest(arguments)
+	return SearchNote(ctx, request)
 }
 
 func RegisterAllTools(s *server.MCPServer) {
-	s.AddTool(CreateNoteTool, createNoteHandler)
-	s.AddTool(EditNoteTool, editNoteHandler)
-	s.AddTool(SetNotePrivacyTool, setNotePrivacyHandler)
-	s.AddTool(SearchNoteTool, searchNoteHandler)
+	addTool(s, CreateNoteTool, createNoteHandler, withDebugParam, withUploadRateParam)
+	addTool(s, EditNoteTool, editNoteHandler, withDebugParam, withUploadRateParam)
+	addTool(s, SetNotePrivacyTool, setNotePrivacyHandler, withDebugParam)
+	addTool(s, SearchNoteTool, searchNoteHandler)
+	addTool(s, SetCurrentNoteTool, setCurrentNoteHandler)
+	addTool(s, GetCurrentNoteTool, getCurrentNoteHandler)
+	addTool(s, DownloadAttachmentTool, downloadAttachmentHandler)
+	addTool(s, EditNotesBatchTool, editNotesBatchHandler, withDebugParam)
+	addTool(s, LogEntryTool, logEntryHandler, withDebugParam)
+	addTool(s, CaptureTool, captureHandler, withDebugParam, withUploadRateParam)
+	addTool(s, ProcessInboxTool, processInboxHandler)
+	addTool(s, UpdateIndexNoteTool, updateIndexNoteHandler)
+	addTool(s, PinNoteTool, pinNoteHandler)
+	addTool(s, AddTagRuleTool, addTagRuleHandler)
+	addTool(s, ListTagRulesTool, listTagRulesHandler)
+	addTool(s, RegenerateSummariesTool, regenerateSummariesHandler)
+	addTool(s, FuzzySearchTool, fuzzySearchHandler)
+	addTool(s, GetLocalNoteTool, getLocalNoteHandler)
+	addTool(s, GetNoteByCreateTimeTool, getNoteByCreateTimeHandler)
+	addTool(s, ListArchiveTool, listArchiveHandler)
+	addTool(s, WritingStreakTool, writingStreakHandler)
+	addTool(s, ActivityHeatmapTool, activityHeatmapHandler)
+	addTool(s, NoteStatsTool, noteStatsHandler)
+	addTool(s, GenerateDebugBundleTool, generateDebugBundleHandler)
+	addTool(s, NoteAttachmentsReportTool, noteAttachmentsReportHandler)
+	addTool(s, CheckAPICompatTool, checkAPICompatHandler)
+	addTool(s, HealthCheckTool, healthCheckHandler)
+	addTool(s, CaptureClipboardTool, captureClipboardHandler, withDebugParam, withUploadRateParam)
+	addTool(s, SetReminderTool, setReminderHandler)
+	addTool(s, DueRemindersTool, dueRemindersHandler)
+	addTool(s, ListOpenTasksTool, listOpenTasksHandler)
+	addTool(s, CompleteTaskTool, completeTaskHandler)
+	addTool(s, GenerateBoardNoteTool, generateBoardNoteHandler)
+	addTool(s, LogWorkSessionTool, logWorkSessionHandler, withDebugParam)
+	addTool(s, TimeReportTool, timeReportHandler)
+	addTool(s, TrackHabitTool, trackHabitHandler, withDebugParam)
+	addTool(s, HabitReportTool, habitReportHandler)
+	addTool(s, UpsertPersonNoteTool, upsertPersonNoteHandler, withDebugParam)
+	addTool(s, LogReadingTool, logReadingHandler, withDebugParam)
+	addTool(s, LogExpenseTool, logExpenseHandler, withDebugParam)
+	addTool(s, ExpenseSummaryTool, expenseSummaryHandler)
+	addTool(s, LogCheckinTool, logCheckinHandler, withDebugParam)
+	addTool(s, ImportRecipeTool, importRecipeHandler, withDebugParam)
+	addTool(s, ImportPaperTool, importPaperHandler, withDebugParam)
+	addTool(s, ImportPodcastEpisodeTool, importPodcastEpisodeHandler, withDebugParam)
+	addTool(s, ArchivePostTool, archivePostHandler, withDebugParam)
+	addTool(s, SaveConversationTool, saveConversationHandler, withDebugParam)
+	addTool(s, SaveDiffNoteTool, saveDiffNoteHandler, withDebugParam)
+	addTool(s, ExportNoteHTMLTool, exportNoteHTMLHandler)
+	addTool(s, ExportNotePDFTool, exportNotePDFHandler)
+	addTool(s, ExportSiteTool, exportSiteHandler)
+	addTool(s, ImportNotionTool, importNotionHandler, withDebugParam)
+	addTool(s, ImportHTMLNotesTool, importHTMLNotesHandler, withDebugParam)
+	addTool(s, ImportMemosTool, importMemosHandler, withDebugParam)
+	addTool(s, GetNoteTool, getNoteHandler, withDebugParam)
+	addTool(s, ImportCSVTool, importCSVHandler, withDebugParam)
+	addTool(s, ExportStateTool, exportStateHandler)
+	addTool(s, ImportStateTool, importStateHandler)
+	addTool(s, AppendToNoteTool, appendToNoteHandler, withDebugParam, withUploadRateParam)
+	addTool(s, BatchCreateNotesTool, batchCreateNotesHandler, withDebugParam)
+	addTool(s, UpdateNoteTagsTool, updateNoteTagsHandler, withDebugParam)
+	addTool(s, UploadFileTool, uploadFileHandler, withDebugParam, withUploadRateParam)
+	addTool(s, GetUsageTool, getUsageHandler)
+	addTool(s, ResolveNoteTool, resolveNoteHandler)
+	addTool(s, ExportNoteTool, exportNoteHandler, withDebugParam)
 }
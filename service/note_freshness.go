@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 本地记录超过多久未与墨问核对视为可能过期，格式同 time.ParseDuration，默认24小时
+const CacheStaleAfterEnvVar = "MOWEN_CACHE_STALE_AFTER"
+
+// cacheStaleAfter 本地记录的过期时长
+func cacheStaleAfter() time.Duration {
+	d := envDuration(CacheStaleAfterEnvVar, 24*time.Hour)
+	if d <= 0 {
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// MarkNoteVerified 记录本地内容刚与墨问核对一致，以及当时墨问返回的校验信息
+func MarkNoteVerified(tenantID, noteID string, validators noteValidators) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+	return execWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO note_verified (tenant_id, note_id, verified_at, etag, last_modified) VALUES (?, ?, CURRENT_TIMESTAMP, ?, ?)
+			ON CONFLICT (tenant_id, note_id) DO UPDATE SET verified_at = CURRENT_TIMESTAMP, etag = excluded.etag, last_modified = excluded.last_modified`,
+			tenantID, noteID, validators.ETag, validators.LastModified)
+		if err != nil {
+			return fmt.Errorf("保存核对时间失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// noteValidatorsFor 本地记录对应的校验信息，没有时返回空值
+func noteValidatorsFor(tenantID, noteID string) noteValidators {
+	var validators noteValidators
+	if err := InitSQLite(); err != nil {
+		return validators
+	}
+	_ = sqliteDB.QueryRow("SELECT etag, last_modified FROM note_verified WHERE tenant_id = ? AND note_id = ?", tenantID, noteID).
+		Scan(&validators.ETag, &validators.LastModified)
+	return validators
+}
+
+// noteVerifiedAt 最近一次与墨问核对的时间，没有核对过时返回零值
+func noteVerifiedAt(tenantID, noteID string) time.Time {
+	if err := InitSQLite(); err != nil {
+		return time.Time{}
+	}
+	var value string
+	if err := sqliteDB.QueryRow("SELECT verified_at FROM note_verified WHERE tenant_id = ? AND note_id = ?", tenantID, noteID).Scan(&value); err != nil {
+		return time.Time{}
+	}
+	t, _ := parseDBTime(value)
+	return t
+}
+
+// noteCachedAt 本地记录最近一次与墨问一致的时间：记录写入时间和核对时间中较晚的一个
+func noteCachedAt(tenantID string, record *NoteRecord) time.Time {
+	cachedAt, _ := parseDBTime(record.CreatedAt)
+	if verified := noteVerifiedAt(tenantID, record.NoteID); verified.After(cachedAt) {
+		cachedAt = verified
+	}
+	return cachedAt
+}
+
+// noteCacheStale 本地记录是否已超过过期时长未与墨问核对
+func noteCacheStale(cachedAt time.Time) bool {
+	return time.Since(cachedAt) > cacheStaleAfter()
+}
+
+// markNoteVerified 记录核对结果，失败只写日志
+func markNoteVerified(tenantID, noteID string, validators noteValidators) {
+	if err := MarkNoteVerified(tenantID, noteID, validators); err != nil {
+		logger.Warnf("%v，noteID: %s", err, noteID)
+	}
+}
+
+// formatCacheAge 以 3天、5小时、20分钟 的形式显示缓存时长
+func formatCacheAge(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d天", int(d.Hours()/24))
+	}
+	return formatWorkDuration(d)
+}
+
+// cacheStatusLines 本地记录的缓存时间，超过过期时长时附带提示
+func cacheStatusLines(cachedAt time.Time) string {
+	if cachedAt.IsZero() {
+		return ""
+	}
+	line := fmt.Sprintf("缓存时间: %s（UTC）\n", cachedAt.UTC().Format(sqliteTimeLayout))
+	if noteCacheStale(cachedAt) {
+		line += fmt.Sprintf("⚠️ 本地内容已有%s未与墨问核对，可能已在网页或App中修改，可传入refresh=true重新获取\n", formatCacheAge(time.Since(cachedAt)))
+	}
+	return line
+}
+
+// refreshNoteRecord 从墨问拉取笔记并与本地记录核对，正文不同时保存为新的本地记录
+// 有本地记录时带上次的ETag和Last-Modified做条件请求，墨问返回304时直接使用本地记录
+// 比较的是纯文本：从墨问转换回来的内容块没有本地文件路径等信息，格式差异不视为修改
+// 返回最新的本地记录和无法识别的节点类型
+func refreshNoteRecord(ctx context.Context, client *MowenClient, noteID string) (*NoteRecord, []string, error) {
+	tenantID := tenantFromContext(ctx)
+	local, err := GetNoteCached(tenantID, noteID)
+	if err != nil || isNotePruned(tenantID, noteID) {
+		local = nil
+	}
+	var validators noteValidators
+	if local != nil {
+		validators = noteValidatorsFor(tenantID, noteID)
+	}
+
+	doc, latest, modified, err := client.GetNoteIfChanged(noteID, validators)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !modified && local != nil {
+		markNoteVerified(tenantID, noteID, latest)
+		return local, nil, nil
+	}
+	if !modified {
+		return nil, nil, fmt.Errorf("墨问返回内容未修改，但本地没有笔记 %s 的记录", noteID)
+	}
+	blocks, unknown := mowenDocumentToBlocks(doc)
+	data, _ := json.Marshal(blocks)
+	content := string(data)
+
+	if local != nil && strings.TrimSpace(notePlainText(local.Content)) == strings.TrimSpace(blocksText(blocks)) {
+		markNoteVerified(tenantID, noteID, latest)
+		return local, unknown, nil
+	}
+
+	summary := summarizeForSave(ctx, tenantID, noteID, content, blocks)
+	if ok, _ := SaveFetchedNote(tenantID, noteID, content, summary); ok {
+		markNoteVerified(tenantID, noteID, latest)
+		InvalidateNote(tenantID, noteID)
+		if record, err := GetNoteCached(tenantID, noteID); err == nil {
+			return record, unknown, nil
+		}
+	}
+	// 数据库不可用时仍返回远端内容
+	return &NoteRecord{
+		TenantID:  tenantID,
+		NoteID:    noteID,
+		Content:   content,
+		Summary:   summary,
+		CreatedAt: time.Now().UTC().Format(sqliteTimeLayout),
+	}, unknown, nil
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// createNoteFromBlocks 创建笔记并保存到本地，返回笔记ID
+// 接口未返回笔记ID时返回空字符串
+func createNoteFromBlocks(ctx context.Context, client *MowenClient, blocks []ContentBlock, settings *Settings) (string, error) {
+	if settings == nil {
+		settings = &Settings{}
+	}
+	blocks, err := expandDirectoryBlocks(ctx, blocks)
+	if err != nil {
+		return "", err
+	}
+
+	// 执行创建前钩子，钩子可以修改内容和标签
+	event := &HookEvent{Hook: HookBeforeCreate, Blocks: blocks, Tags: settings.Tags}
+	if err := runHooks(ctx, event); err != nil {
+		return "", err
+	}
+	blocks, settings.Tags = event.Blocks, event.Tags
+
+	// 直接发布的笔记需要先通过内容审核
+	if settings.AutoPublish != nil && *settings.AutoPublish {
+		if blocks, _, err = moderateContent(ctx, "create", "", blocks, settings.Tags); err != nil {
+			return "", err
+		}
+	}
+
+	// 使用ConvertToMowenFormat函数进行数据转换
+	mowenDoc, err := ConvertToMowenFormat(ctx, client, blocks)
+	if err != nil {
+		return "", fmt.Errorf("转换文档格式失败: %w", err)
+	}
+
+	payload := CreateNoteParams{
+		Body:     &mowenDoc,
+		Settings: settings,
+	}
+
+	// 调用API创建笔记
+	resp, err := client.PostRequest(APICreateNote, payload)
+	if err != nil {
+		return "", fmt.Errorf("API请求失败: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return "", apiStatusError(resp.StatusCode, "API请求失败，状态码: %d，响应: %s", resp.StatusCode, resp.RawBody)
+	}
+
+	// 解析响应获取笔记ID
+	var noteID string
+	if resp.Body != nil {
+		if id, ok := resp.Body["noteId"].(string); ok {
+			noteID = id
+		}
+	}
+	if noteID == "" {
+		return "", nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	// 返回前同步写入本地记录、全文索引、标签和发布状态，同一轮对话中紧接着的查询就能找到新笔记
+	// 保存转换后的内容块，其中包含已上传文件的file_id
+	content, _ := json.Marshal(blocks)
+	summary := summarizeForSave(ctx, tenantID, noteID, string(content), blocks)
+	if success, err := SaveNoteToSQLite(tenantID, noteID, string(content), summary); !success {
+		logger.Info("保存笔记到数据库失败", "error", err, "noteID", noteID)
+	} else {
+		logger.Info("笔记已成功保存到数据库", "noteID", noteID)
+		go notifyNoteUpdated(tenantID, noteID)
+	}
+	if len(settings.Tags) > 0 {
+		if err := SetNoteTags(tenantID, noteID, settings.Tags); err != nil {
+			logger.Warnf("保存笔记标签失败，noteID: %s, error: %v", noteID, err)
+		}
+	}
+	if settings.AutoPublish != nil && *settings.AutoPublish {
+		if err := SetNotePublished(tenantID, noteID); err != nil {
+			logger.Warnf("保存发布状态失败，noteID: %s, error: %v", noteID, err)
+		}
+	}
+
+	go runAfterHooks(ctx, &HookEvent{Hook: HookAfterCreate, NoteID: noteID, Blocks: blocks, Tags: settings.Tags})
+
+	return noteID, nil
+}
+
+// noteTitle 从内容块中提取标题：第一个包含文字的段落
+func noteTitle(content string) string {
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(content), &blocks); err != nil {
+		return ""
+	}
+	for _, block := range blocks {
+		var sb strings.Builder
+		for _, text := range block.Texts {
+			sb.WriteString(text.Text)
+		}
+		if title := strings.TrimSpace(sb.String()); title != "" {
+			if runes := []rune(title); len(runes) > 50 {
+				title = string(runes[:50]) + "..."
+			}
+			return title
+		}
+	}
+	return ""
+}
+
+// loadNoteBlocks 从本地记录中读取笔记的内容块
+func loadNoteBlocks(tenantID, noteID string) ([]ContentBlock, error) {
+	record, err := GetNoteCached(tenantID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if isNotePruned(tenantID, noteID) {
+		return nil, fmt.Errorf("笔记 %s 的本地内容已按保留策略清理，只保留了元数据，请使用edit_note提供完整内容", noteID)
+	}
+
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(record.Content), &blocks); err != nil {
+		return nil, fmt.Errorf("解析笔记 %s 的本地内容失败: %w", noteID, err)
+	}
+	return blocks, nil
+}
+
+// uploadBlockFiles 展开目录文件块并预先上传内容块中的文件，避免在持有笔记锁期间执行耗时上传
+// 返回展开后的内容块
+func uploadBlockFiles(ctx context.Context, client *MowenClient, blocks []ContentBlock) ([]ContentBlock, error) {
+	blocks, err := expandDirectoryBlocks(ctx, blocks)
+	if err != nil {
+		return nil, err
+	}
+	for i := range blocks {
+		if blocks[i].Type != "file" {
+			continue
+		}
+		if _, err := resolveFileID(ctx, client, &blocks[i]); err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+// writeNoteBlocks 将内容块写入远端笔记并同步本地记录，调用方需持有笔记锁
+func writeNoteBlocks(ctx context.Context, client *MowenClient, noteID string, blocks []ContentBlock) error {
+	// 执行编辑前钩子，钩子可以修改内容
+	event := &HookEvent{Hook: HookBeforeEdit, NoteID: noteID, Blocks: blocks}
+	if err := runHooks(ctx, event); err != nil {
+		return err
+	}
+	blocks = event.Blocks
+
+	// 已公开的笔记修改后直接对外可见，需要先通过内容审核
+	tenantID := tenantFromContext(ctx)
+	tags, _ := GetNoteTags(tenantID, noteID)
+	blocks, _, err := moderatePublishedNote(ctx, noteID, blocks, tags)
+	if err != nil {
+		return err
+	}
+
+	mowenDoc, err := ConvertToMowenFormat(ctx, client, blocks)
+	if err != nil {
+		return fmt.Errorf("转换文档格式失败: %w", err)
+	}
+
+	payload := EditNoteParams{
+		NoteID:     noteID,
+		Paragraphs: []MowenDocument{mowenDoc},
+	}
+	resp, err := client.PostRequest(APIEditNote, payload)
+	if err != nil {
+		return fmt.Errorf("API请求失败: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return apiStatusError(resp.StatusCode, "API请求失败，状态码: %d，响应: %s", resp.StatusCode, resp.RawBody)
+	}
+
+	// 更新本地记录并通知订阅者
+	content, _ := json.Marshal(blocks)
+	summary := summarizeForSave(ctx, tenantID, noteID, string(content), blocks)
+	if success, err := SaveNoteToSQLite(tenantID, noteID, string(content), summary); !success {
+		logger.Info("保存笔记到数据库失败", "error", err, "noteID", noteID)
+	}
+	InvalidateNote(tenantID, noteID)
+	go notifyNoteUpdated(tenantID, noteID)
+	return nil
+}
+
+// replaceNoteBlocks 用新的内容块完全替换笔记内容
+func replaceNoteBlocks(ctx context.Context, client *MowenClient, noteID string, blocks []ContentBlock) error {
+	blocks, err := uploadBlockFiles(ctx, client, blocks)
+	if err != nil {
+		return err
+	}
+
+	// 同一笔记的编辑按顺序执行，保证远端与本地记录一致
+	unlock := lockNote(tenantFromContext(ctx), noteID)
+	defer unlock()
+	return writeNoteBlocks(ctx, client, noteID, blocks)
+}
+
+// currentNoteBlocks 读取笔记当前的内容块：本地记录近期与墨问核对过时直接使用，否则从墨问拉取
+// 墨问中的内容有无法识别的节点时返回错误，避免整篇写回时丢失这些内容
+func currentNoteBlocks(ctx context.Context, client *MowenClient, noteID string) ([]ContentBlock, error) {
+	tenantID := tenantFromContext(ctx)
+	if record, err := GetNoteCached(tenantID, noteID); err == nil && !isNotePruned(tenantID, noteID) && !noteCacheStale(noteCachedAt(tenantID, record)) {
+		return loadNoteBlocks(tenantID, noteID)
+	}
+
+	record, unknown, err := refreshNoteRecord(ctx, client, noteID)
+	if err != nil {
+		// 拉取失败时退回本地记录
+		blocks, localErr := loadNoteBlocks(tenantID, noteID)
+		if localErr != nil {
+			return nil, fmt.Errorf("读取笔记 %s 的当前内容失败: %w", noteID, err)
+		}
+		logger.Warnf("从墨问拉取笔记失败，使用本地记录，noteID: %s, error: %v", noteID, err)
+		return blocks, nil
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("笔记 %s 包含无法识别的内容（%s），整篇写回会丢失这些内容，请在墨问中手动编辑", noteID, strings.Join(unknown, ", "))
+	}
+
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(record.Content), &blocks); err != nil {
+		return nil, fmt.Errorf("解析笔记 %s 的内容失败: %w", noteID, err)
+	}
+	return blocks, nil
+}
+
+// appendNoteBlocks 在笔记末尾追加内容块，返回追加后的内容块总数
+// 墨问API只支持整体替换，这里读取原内容（见currentNoteBlocks），合并后写回
+func appendNoteBlocks(ctx context.Context, client *MowenClient, noteID string, extra []ContentBlock) (int, error) {
+	extra, err := uploadBlockFiles(ctx, client, extra)
+	if err != nil {
+		return 0, err
+	}
+
+	tenantID := tenantFromContext(ctx)
+	unlock := lockNote(tenantID, noteID)
+	defer unlock()
+
+	// 必须在锁内读取，避免并发追加互相覆盖
+	InvalidateNote(tenantID, noteID)
+	blocks, err := currentNoteBlocks(ctx, client, noteID)
+	if err != nil {
+		return 0, err
+	}
+	blocks = append(blocks, extra...)
+
+	if err := writeNoteBlocks(ctx, client, noteID, blocks); err != nil {
+		return 0, err
+	}
+	return len(blocks), nil
+}
+
+// appendToNamedNote 向具名笔记追加内容，笔记不存在时以title为标题新建
+// 返回笔记ID以及是否为新建
+func appendToNamedNote(ctx context.Context, client *MowenClient, name, title string, blocks []ContentBlock) (string, bool, error) {
+	tenantID := tenantFromContext(ctx)
+
+	// 防止并发调用时重复创建同名笔记
+	unlock := lockNote(tenantID, "named:"+name)
+	defer unlock()
+
+	noteID, err := GetNamedNote(tenantID, name)
+	if err != nil {
+		return "", false, err
+	}
+	if noteID != "" {
+		if _, err := appendNoteBlocks(ctx, client, noteID, blocks); err != nil {
+			return "", false, err
+		}
+		return noteID, false, nil
+	}
+
+	titleBlock := ContentBlock{Texts: []TextNode{{Text: title, Bold: true}}}
+	noteID, err = createNoteFromBlocks(ctx, client, append([]ContentBlock{titleBlock}, blocks...), nil)
+	if err != nil {
+		return "", false, err
+	}
+	if noteID == "" {
+		return "", false, fmt.Errorf("创建笔记失败：接口未返回笔记ID")
+	}
+	if err := SetNamedNote(tenantID, name, noteID); err != nil {
+		return "", false, err
+	}
+	return noteID, true, nil
+}
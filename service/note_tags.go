@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// noteSettingsSectionTags 笔记设置接口中标签设置的section，隐私设置为1
+const noteSettingsSectionTags = 2
+
+// SetNoteTagsParams 用于设置标签的笔记设置请求
+type SetNoteTagsParams struct {
+	NoteID   string `json:"noteId"`
+	Section  int    `json:"section"`
+	Settings struct {
+		Tags []string `json:"tags"`
+	} `json:"settings"`
+}
+
+// updateTagList 按模式计算新的标签列表：add追加、remove移除、replace整体替换
+func updateTagList(current, tags []string, mode string) []string {
+	switch mode {
+	case "replace":
+		return mergeTags(tags)
+	case "remove":
+		removed := make(map[string]bool, len(tags))
+		for _, tag := range tags {
+			removed[strings.TrimSpace(tag)] = true
+		}
+		var result []string
+		for _, tag := range current {
+			if !removed[tag] {
+				result = append(result, tag)
+			}
+		}
+		return result
+	default:
+		return mergeTags(current, tags)
+	}
+}
+
+// sameTags 判断两组标签是否相同，不考虑顺序
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateNoteTags 修改已有笔记的标签，通过墨问的笔记设置接口写入后同步本地记录
+// 追加和移除基于本地记录的标签计算，本地没有记录的标签（如在App中添加的）用replace整体设置
+func UpdateNoteTags(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	mode, _ := args["mode"].(string)
+	if mode == "" {
+		mode = "add"
+	}
+	if mode != "add" && mode != "remove" && mode != "replace" {
+		return mcp.NewToolResultText("❌ mode必须是 'add', 'remove' 或 'replace'"), nil
+	}
+	tagsStr, _ := args["tags"].(string)
+	if tagsStr == "" {
+		return mcp.NewToolResultText("❌ tags不能为空"), nil
+	}
+	var tags []string
+	if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+		return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+	}
+	if len(mergeTags(tags)) == 0 && mode != "replace" {
+		return mcp.NewToolResultText("❌ tags不能为空"), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	unlock := lockNote(tenantID, noteID)
+	defer unlock()
+
+	current, err := GetNoteTags(tenantID, noteID)
+	if err != nil {
+		return errorResult("读取笔记标签失败", err), nil
+	}
+	updated := updateTagList(current, tags, mode)
+	if sameTags(current, updated) {
+		return mcp.NewToolResultText(fmt.Sprintf("📝 笔记 %s 的标签没有变化\n\n当前标签: %s", noteID, formatTagList(current))), nil
+	}
+
+	// 已公开笔记的标签也对外可见，修改前连同正文提交审核，审核服务脱敏后的正文先写回笔记
+	if needsPublishModeration(ctx, noteID) {
+		blocks, err := loadNoteBlocks(tenantID, noteID)
+		if err != nil {
+			return errorResult("无法读取笔记内容进行审核", err), nil
+		}
+		moderated, redacted, err := moderateContent(ctx, "publish", noteID, blocks, updated)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		if redacted {
+			if err = writeNoteBlocks(ctx, client, noteID, moderated); err != nil {
+				return errorResult("写入审核后的内容失败", err), nil
+			}
+		}
+	}
+
+	payload := SetNoteTagsParams{
+		NoteID:  noteID,
+		Section: noteSettingsSectionTags,
+	}
+	payload.Settings.Tags = updated
+	if payload.Settings.Tags == nil {
+		payload.Settings.Tags = []string{}
+	}
+
+	resp, err := client.PostRequest(APISetNote, payload)
+	if err != nil {
+		return errorResult("API请求失败", err), nil
+	}
+	if resp.StatusCode != 200 {
+		requestStr, _ := json.Marshal(payload)
+		return errorResult("", apiStatusError(resp.StatusCode, "API请求失败，状态码: %d，响应: %s，请求参数：%s", resp.StatusCode, resp.RawBody, requestStr)), nil
+	}
+
+	if err := SetNoteTags(tenantID, noteID, updated); err != nil {
+		logger.Warnf("保存笔记标签失败，noteID: %s, error: %v", noteID, err)
+	}
+	go notifyNoteUpdated(tenantID, noteID)
+
+	var sb strings.Builder
+	sb.WriteString("✅ 笔记标签已更新！\n\n")
+	sb.WriteString(fmt.Sprintf("笔记ID: %s\n", noteID))
+	sb.WriteString(fmt.Sprintf("原标签: %s\n", formatTagList(current)))
+	sb.WriteString(fmt.Sprintf("新标签: %s\n", formatTagList(updated)))
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// formatTagList 以逗号分隔显示标签，没有标签时显示"无"
+func formatTagList(tags []string) string {
+	if len(tags) == 0 {
+		return "无"
+	}
+	return strings.Join(tags, ", ")
+}
+
+// 修改笔记标签工具
+var UpdateNoteTagsTool = mcp.NewTool("update_note_tags",
+	mcp.WithDescription("修改已有笔记的标签：追加、移除或整体替换，写入墨问后同步本地记录。追加和移除基于本地记录的标签计算，如果笔记在App中改过标签，用replace整体设置"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记（见set_current_note）"),
+	),
+	mcp.WithString("tags",
+		mcp.Required(),
+		mcp.Description(`标签列表JSON字符串，如 ["读书", "2024"]；replace模式下传 [] 表示清空标签`),
+	),
+	mcp.WithString("mode",
+		mcp.Description("修改方式：add追加（默认）、remove移除、replace整体替换"),
+		mcp.Enum("add", "remove", "replace"),
+	),
+)
+
+func updateNoteTagsHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := UpdateNoteTags(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
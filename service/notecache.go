@@ -0,0 +1,136 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// 笔记缓存环境变量
+const (
+	// 缓存有效期，格式同 time.ParseDuration，默认10分钟
+	CacheTTLEnvVar = "MOWEN_CACHE_TTL"
+	// 内存中最多缓存的笔记数量，默认256
+	CacheSizeEnvVar = "MOWEN_CACHE_SIZE"
+)
+
+// noteCacheEntry 缓存条目
+type noteCacheEntry struct {
+	key      string
+	record   NoteRecord
+	expireAt time.Time
+}
+
+// noteCache 笔记内容的内存LRU缓存
+// SQLite中的本地记录是持久层，内存缓存用于减少同一会话中的重复读取
+type noteCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+var (
+	noteCacheInstance *noteCache
+	noteCacheOnce     sync.Once
+)
+
+// getNoteCache 获取全局笔记缓存
+func getNoteCache() *noteCache {
+	noteCacheOnce.Do(func() {
+		noteCacheInstance = &noteCache{
+			ttl:      envDuration(CacheTTLEnvVar, 10*time.Minute),
+			capacity: envInt(CacheSizeEnvVar, 256),
+			items:    make(map[string]*list.Element),
+			order:    list.New(),
+		}
+	})
+	return noteCacheInstance
+}
+
+// noteCacheKey 缓存键，按租户隔离
+func noteCacheKey(tenantID, noteID string) string {
+	return tenantID + "/" + noteID
+}
+
+// get 读取缓存，过期的条目会被移除
+func (c *noteCache) get(key string) (NoteRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return NoteRecord{}, false
+	}
+	entry := elem.Value.(*noteCacheEntry)
+	if time.Now().After(entry.expireAt) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return NoteRecord{}, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.record, true
+}
+
+// put 写入缓存，超过容量时淘汰最久未使用的条目
+func (c *noteCache) put(key string, record NoteRecord) {
+	if c.capacity <= 0 || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expireAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*noteCacheEntry)
+		entry.record = record
+		entry.expireAt = expireAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&noteCacheEntry{
+		key:      key,
+		record:   record,
+		expireAt: expireAt,
+	})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*noteCacheEntry).key)
+	}
+}
+
+// remove 移除缓存条目
+func (c *noteCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// GetNoteCached 读取笔记，优先使用内存缓存，未命中时读取本地数据库
+func GetNoteCached(tenantID, noteID string) (*NoteRecord, error) {
+	cache := getNoteCache()
+	key := noteCacheKey(tenantID, noteID)
+	if record, ok := cache.get(key); ok {
+		return &record, nil
+	}
+
+	record, err := SearchByNoteID(tenantID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(key, *record)
+	return record, nil
+}
+
+// InvalidateNote 使笔记缓存失效，在编辑、修改隐私设置后调用
+func InvalidateNote(tenantID, noteID string) {
+	getNoteCache().remove(noteCacheKey(tenantID, noteID))
+}
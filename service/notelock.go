@@ -0,0 +1,41 @@
+package service
+
+import "sync"
+
+// noteLock 单个笔记的互斥锁，带引用计数以便及时回收
+type noteLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	noteLocksMu sync.Mutex
+	noteLocks   = make(map[string]*noteLock)
+)
+
+// lockNote 锁定笔记，用于"读取-修改-写回"类操作，返回解锁函数
+// 不同笔记之间互不影响，同一笔记的并发编辑按顺序执行
+func lockNote(tenantID, noteID string) func() {
+	key := noteCacheKey(tenantID, noteID)
+
+	noteLocksMu.Lock()
+	lock, ok := noteLocks[key]
+	if !ok {
+		lock = &noteLock{}
+		noteLocks[key] = lock
+	}
+	lock.refs++
+	noteLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		noteLocksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(noteLocks, key)
+		}
+		noteLocksMu.Unlock()
+	}
+}
@@ -0,0 +1,486 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// 解压后的总大小上限
+	maxImportExtractSize = 2 << 30
+	// 一次最多导入的页面数
+	maxNotionPages = 500
+)
+
+// Notion导出的文件和目录名以空格加32位页面ID结尾
+var notionIDPattern = regexp.MustCompile(`\s+([0-9a-f]{32})$`)
+
+// notionPage 导出包中的一个页面，子页面位于与页面同名的目录中
+type notionPage struct {
+	Path     string
+	Title    string
+	NotionID string
+	Children []*notionPage
+	NoteID   string
+	Existing bool // 之前已导入过
+}
+
+// extractZip 把zip解压到目录，拒绝指向目录外的条目；顶层的zip（Notion大导出分卷）继续解压一层
+func extractZip(zipPath, dir string, nested bool) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("打开zip失败: %w", err)
+	}
+	defer reader.Close()
+
+	var total int64
+	var innerZips []string
+	for _, file := range reader.File {
+		name := filepath.FromSlash(file.Name)
+		target := filepath.Join(dir, name)
+		if !isWithinDir(target, dir) {
+			return fmt.Errorf("zip条目 %s 指向解压目录之外", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		total += int64(file.UncompressedSize64)
+		if total > maxImportExtractSize {
+			return fmt.Errorf("解压后超过大小上限 %d MB", maxImportExtractSize>>20)
+		}
+		if err := extractZipFile(file, target); err != nil {
+			return fmt.Errorf("解压 %s 失败: %w", file.Name, err)
+		}
+		if nested && !strings.Contains(strings.Trim(file.Name, "/"), "/") && strings.EqualFold(filepath.Ext(name), ".zip") {
+			innerZips = append(innerZips, target)
+		}
+	}
+
+	for _, inner := range innerZips {
+		if err := extractZip(inner, dir, false); err != nil {
+			return err
+		}
+		os.Remove(inner)
+	}
+	return nil
+}
+
+// extractZipFile 解压单个文件
+func extractZipFile(file *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	in, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	// 条目头中的大小可能被篡改，按上限截断
+	if _, err = io.Copy(out, io.LimitReader(in, maxImportExtractSize)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// isNotionPageFile 是否为页面文件
+func isNotionPageFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".md" || ext == ".html"
+}
+
+// splitNotionName 从文件或目录名中拆出标题和页面ID，没有ID时ID为空
+func splitNotionName(name string) (string, string) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	if m := notionIDPattern.FindStringSubmatchIndex(base); m != nil {
+		return strings.TrimSpace(base[:m[0]]), base[m[2]:m[3]]
+	}
+	return base, ""
+}
+
+// scanNotionDir 列出目录中的页面，与页面同名的目录作为子页面；
+// 没有对应页面的目录（例如数据库导出的行）中的页面作为本层页面
+func scanNotionDir(dir string) ([]*notionPage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	pageNames := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isNotionPageFile(entry.Name()) {
+			pageNames[strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))] = true
+		}
+	}
+
+	var pages []*notionPage
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "__MACOSX") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		switch {
+		case entry.IsDir() && !pageNames[name]:
+			orphans, err := scanNotionDir(path)
+			if err != nil {
+				return nil, err
+			}
+			pages = append(pages, orphans...)
+		case entry.Type().IsRegular() && isNotionPageFile(name):
+			page := &notionPage{Path: path}
+			page.Title, page.NotionID = splitNotionName(name)
+			childDir := strings.TrimSuffix(path, filepath.Ext(path))
+			if info, err := os.Stat(childDir); err == nil && info.IsDir() {
+				if page.Children, err = scanNotionDir(childDir); err != nil {
+					return nil, err
+				}
+			}
+			pages = append(pages, page)
+		}
+	}
+	return pages, nil
+}
+
+// countNotionPages 页面总数，含子页面
+func countNotionPages(pages []*notionPage) int {
+	n := len(pages)
+	for _, page := range pages {
+		n += countNotionPages(page.Children)
+	}
+	return n
+}
+
+// notionImporter 导入过程中的状态
+type notionImporter struct {
+	ctx      context.Context
+	client   *MowenClient
+	root     string
+	tags     []string
+	byPath   map[string]*notionPage
+	created  int
+	existing int
+	failures []string
+	warnings []string
+}
+
+// localTarget 把页面中的相对地址解析为解压目录中的路径，网络地址或目录外的路径返回空字符串
+func (im *notionImporter) localTarget(pagePath, href string) string {
+	if href == "" || strings.Contains(href, "://") || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "mailto:") {
+		return ""
+	}
+	if i := strings.IndexAny(href, "?#"); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	target := filepath.Join(filepath.Dir(pagePath), filepath.FromSlash(href))
+	if !isWithinDir(target, im.root) {
+		return ""
+	}
+	return target
+}
+
+// uploadAsset 上传导出包中的文件，返回带file_id的文件块
+// 文件由服务解压到临时目录，导出包本身已通过沙箱校验，这里不再校验
+func (im *notionImporter) uploadAsset(path string, block ContentBlock) (ContentBlock, error) {
+	return uploadImportAsset(im.ctx, im.client, path, block)
+}
+
+// uploadImportAsset 上传导入内容中已校验过的本地文件，返回带file_id的文件块
+func uploadImportAsset(ctx context.Context, client *MowenClient, path string, block ContentBlock) (ContentBlock, error) {
+	typeKey, err := getFileTypeFromPath(path)
+	if err != nil {
+		return block, err
+	}
+	name := sanitizeFileName(filepath.Base(path))
+	fileID, err := uploadLocalFile(ctx, client, path, name, true)
+	if err != nil {
+		return block, err
+	}
+	block.Type, block.FileType, block.SourceType, block.SourcePath, block.FileID = "file", fileTypeKeys[typeKey], "", "", fileID
+	if block.Metadata == nil {
+		block.Metadata = make(map[string]interface{})
+	}
+	block.Metadata[fileNameMetadataKey] = name
+	return block, nil
+}
+
+// resolveBlocks 改写页面内容：本地图片和附件上传，单独一段的子页面链接转为内链笔记，
+// 指向已导入页面的链接改为墨问笔记地址，其他本地链接去掉；返回被引用的页面
+func (im *notionImporter) resolveBlocks(page *notionPage, blocks []ContentBlock) ([]ContentBlock, map[*notionPage]bool) {
+	linked := make(map[*notionPage]bool)
+	relink := func(texts []TextNode) []TextNode {
+		for i, text := range texts {
+			target := im.localTarget(page.Path, text.Link)
+			if target == "" {
+				continue
+			}
+			if p := im.byPath[target]; p != nil && p.NoteID != "" {
+				texts[i].Link = "https://note.mowen.cn/detail/" + p.NoteID
+			} else {
+				texts[i].Link = ""
+			}
+		}
+		return texts
+	}
+
+	result := make([]ContentBlock, 0, len(blocks))
+	for _, block := range blocks {
+		switch {
+		case block.Type == "file" && block.SourceType == "local":
+			target := im.localTarget(page.Path, block.SourcePath)
+			if target == "" {
+				im.warnings = append(im.warnings, fmt.Sprintf("%s: 图片 %s 不在导出包中，已跳过", page.Title, block.SourcePath))
+				continue
+			}
+			uploaded, err := im.uploadAsset(target, block)
+			if err != nil {
+				im.warnings = append(im.warnings, fmt.Sprintf("%s: 上传 %s 失败: %v", page.Title, filepath.Base(target), err))
+				continue
+			}
+			result = append(result, uploaded)
+			continue
+		case (block.Type == "" || block.Type == "paragraph") && len(block.Texts) == 1 && block.Texts[0].Link != "":
+			// 单独一段的链接：子页面转为内链笔记，支持的附件直接上传
+			if target := im.localTarget(page.Path, block.Texts[0].Link); target != "" {
+				if p := im.byPath[target]; p != nil {
+					if p.NoteID != "" {
+						linked[p] = true
+						result = append(result, ContentBlock{Type: "note", NoteID: p.NoteID})
+						continue
+					}
+				} else if _, err := getFileTypeFromPath(target); err == nil {
+					uploaded, err := im.uploadAsset(target, ContentBlock{})
+					if err == nil {
+						result = append(result, uploaded)
+						continue
+					}
+					im.warnings = append(im.warnings, fmt.Sprintf("%s: 上传 %s 失败: %v", page.Title, filepath.Base(target), err))
+				}
+			}
+		}
+		block.Texts = relink(block.Texts)
+		for i := range block.Paragraphs {
+			block.Paragraphs[i] = relink(block.Paragraphs[i])
+		}
+		result = append(result, block)
+	}
+	return result, linked
+}
+
+// importPage 先导入子页面再导入页面本身，正文没有链接到的子页面以内链笔记附加在末尾
+func (im *notionImporter) importPage(page *notionPage) {
+	for _, child := range page.Children {
+		im.importPage(child)
+	}
+
+	tenantID := tenantFromContext(im.ctx)
+	name := "notion:" + page.NotionID
+	if page.NotionID != "" {
+		if existing, err := GetNamedNote(tenantID, name); err == nil && existing != "" {
+			page.NoteID, page.Existing = existing, true
+			im.existing++
+			return
+		}
+	}
+
+	data, err := os.ReadFile(page.Path)
+	if err != nil {
+		im.failures = append(im.failures, fmt.Sprintf("%s: 读取失败: %v", page.Title, err))
+		return
+	}
+	var blocks []ContentBlock
+	if strings.EqualFold(filepath.Ext(page.Path), ".html") {
+		blocks = htmlToBlocks(string(data))
+	} else {
+		blocks = markdownToBlocks(string(data))
+	}
+	blocks, linked := im.resolveBlocks(page, blocks)
+
+	// 正文第一段通常就是标题，不是时补上
+	if len(blocks) == 0 || strings.TrimSpace(blockPlainText(blocks[0])) != page.Title {
+		blocks = append([]ContentBlock{{Texts: []TextNode{{Text: page.Title, Bold: true}}}}, blocks...)
+	}
+	var subpages []ContentBlock
+	for _, child := range page.Children {
+		if child.NoteID != "" && !linked[child] {
+			subpages = append(subpages, ContentBlock{Type: "note", NoteID: child.NoteID})
+		}
+	}
+	if len(subpages) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "子页面", Bold: true}}})
+		blocks = append(blocks, subpages...)
+	}
+
+	noteID, err := createNoteFromBlocks(im.ctx, im.client, blocks, &Settings{Tags: im.tags})
+	if err != nil {
+		im.failures = append(im.failures, fmt.Sprintf("%s: 创建笔记失败: %v", page.Title, err))
+		return
+	}
+	if noteID == "" {
+		im.failures = append(im.failures, fmt.Sprintf("%s: 接口未返回笔记ID", page.Title))
+		return
+	}
+	page.NoteID = noteID
+	im.created++
+	if page.NotionID != "" {
+		if err := SetNamedNote(tenantID, name, noteID); err != nil {
+			im.warnings = append(im.warnings, fmt.Sprintf("%s: 记录导入状态失败: %v", page.Title, err))
+		}
+	}
+}
+
+// blockPlainText 内容块的纯文字
+func blockPlainText(block ContentBlock) string {
+	var sb strings.Builder
+	for _, text := range block.Texts {
+		sb.WriteString(text.Text)
+	}
+	return sb.String()
+}
+
+// writeNotionTree 按层级输出页面和对应的笔记ID
+func writeNotionTree(sb *strings.Builder, pages []*notionPage, depth int) {
+	for _, page := range pages {
+		sb.WriteString(strings.Repeat("  ", depth) + "- " + page.Title)
+		switch {
+		case page.Existing:
+			sb.WriteString(fmt.Sprintf("（已导入过: %s）", page.NoteID))
+		case page.NoteID != "":
+			sb.WriteString(fmt.Sprintf("（%s）", page.NoteID))
+		}
+		sb.WriteString("\n")
+		writeNotionTree(sb, page.Children, depth+1)
+	}
+}
+
+// indexNotionPages 按路径索引全部页面
+func indexNotionPages(pages []*notionPage, byPath map[string]*notionPage) {
+	for _, page := range pages {
+		byPath[page.Path] = page
+		indexNotionPages(page.Children, byPath)
+	}
+}
+
+// ImportNotion 导入Notion导出的zip包，每个页面一篇笔记，子页面以内链笔记关联
+func ImportNotion(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	zipPath, _ := args["zip_path"].(string)
+	if zipPath == "" {
+		return mcp.NewToolResultText("❌ zip_path不能为空"), nil
+	}
+	tags := []string{"Notion导入"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	dryRun, _ := args["dry_run"].(bool)
+
+	path, err := checkLocalPath(ctx, zipPath)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	dir, err := os.MkdirTemp("", "mowen-notion-")
+	if err != nil {
+		return errorResult("创建临时目录失败", err), nil
+	}
+	defer os.RemoveAll(dir)
+	if err := extractZip(path, dir, true); err != nil {
+		return errorResult("", err), nil
+	}
+	// 解压后的目录可能经过符号链接，统一使用规范路径
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	pages, err := scanNotionDir(dir)
+	if err != nil {
+		return errorResult("读取导出内容失败", err), nil
+	}
+	sort.SliceStable(pages, func(i, j int) bool { return pages[i].Title < pages[j].Title })
+	total := countNotionPages(pages)
+	if total == 0 {
+		return mcp.NewToolResultText("❌ zip中没有找到Notion页面（.md或.html文件），请确认是Notion的Markdown或HTML导出"), nil
+	}
+	if total > maxNotionPages {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 页面数 %d 超过单次导入上限 %d，请在Notion中分批导出", total, maxNotionPages)), nil
+	}
+
+	var sb strings.Builder
+	if dryRun {
+		sb.WriteString(fmt.Sprintf("📝 预览：共 %d 个页面，未创建笔记\n\n", total))
+		writeNotionTree(&sb, pages, 0)
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	im := &notionImporter{ctx: ctx, client: client, root: dir, tags: tags, byPath: make(map[string]*notionPage)}
+	indexNotionPages(pages, im.byPath)
+	for _, page := range pages {
+		im.importPage(page)
+	}
+
+	if im.created == 0 && im.existing == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 导入失败:\n%s", strings.Join(im.failures, "\n"))), nil
+	}
+	if len(pages) == 1 && pages[0].NoteID != "" {
+		sessionFromContext(ctx).SetCurrentNoteID(pages[0].NoteID)
+	}
+	sb.WriteString(fmt.Sprintf("✅ Notion导入完成！\n\n新建笔记: %d 篇\n已导入过: %d 篇\n失败: %d 篇\n标签: %s\n\n",
+		im.created, im.existing, len(im.failures), strings.Join(tags, ", ")))
+	writeNotionTree(&sb, pages, 0)
+	if len(im.failures) > 0 {
+		sb.WriteString(fmt.Sprintf("\n❌ 失败的页面:\n%s\n", strings.Join(im.failures, "\n")))
+	}
+	if len(im.warnings) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ 警告:\n%s\n", strings.Join(im.warnings, "\n")))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 导入Notion工具
+var ImportNotionTool = mcp.NewTool("import_notion",
+	mcp.WithDescription("导入Notion导出的zip包（Markdown或HTML格式）：每个页面创建一篇笔记，子页面先导入并以内链笔记关联到父页面，页面间的链接改为墨问笔记链接，图片和附件自动上传。同一页面只会导入一次，可重复执行以续传失败的页面。"),
+	mcp.WithString("zip_path",
+		mcp.Required(),
+		mcp.Description("Notion导出的zip文件路径，例如 ~/Downloads/Export-xxxx.zip"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组，默认 [\"Notion导入\"]"),
+	),
+	mcp.WithBoolean("dry_run",
+		mcp.Description("为true时只列出将要导入的页面层级，不创建笔记"),
+	),
+)
+
+func importNotionHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ImportNotion(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 论文元数据接口地址
+var (
+	arxivAPIURL    = "https://export.arxiv.org/api/query"
+	crossrefAPIURL = "https://api.crossref.org/works/"
+)
+
+// 元数据响应的大小上限
+const maxPaperMetadataSize = 2 << 20
+
+var (
+	// 新格式 2301.00001v2 与旧格式 hep-th/9901001
+	arxivIDPattern = regexp.MustCompile(`(?i)^(?:arxiv:)?(\d{4}\.\d{4,5}(?:v\d+)?|[a-z-]+(?:\.[a-z]{2})?/\d{7}(?:v\d+)?)$`)
+	arxivURLPrefix = regexp.MustCompile(`(?i)^https?://(?:www\.|export\.)?arxiv\.org/(?:abs|pdf)/`)
+	doiPattern     = regexp.MustCompile(`^10\.\d{4,9}/\S+$`)
+	arxivDOIPrefix = regexp.MustCompile(`(?i)^10\.48550/arxiv\.`)
+	arxivVersion   = regexp.MustCompile(`v\d+$`)
+)
+
+// paper 论文元数据
+type paper struct {
+	Source   string // arxiv 或 doi
+	ID       string
+	Title    string
+	Authors  []string
+	Venue    string
+	Year     string
+	Abstract string
+	URL      string
+	PDFURL   string
+	DOI      string
+	Category string // arXiv主分类
+}
+
+// parsePaperID 识别arXiv编号或DOI，支持带前缀和链接的写法；arXiv的DOI按arXiv处理
+func parsePaperID(value string) (source, id string, err error) {
+	value = strings.TrimSpace(value)
+	if loc := arxivURLPrefix.FindStringIndex(value); loc != nil {
+		value = strings.TrimSuffix(value[loc[1]:], ".pdf")
+	}
+	if m := arxivIDPattern.FindStringSubmatch(value); m != nil {
+		return "arxiv", m[1], nil
+	}
+
+	doi := value
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:", "DOI:"} {
+		doi = strings.TrimPrefix(doi, prefix)
+	}
+	if loc := arxivDOIPrefix.FindStringIndex(doi); loc != nil {
+		if m := arxivIDPattern.FindStringSubmatch(doi[loc[1]:]); m != nil {
+			return "arxiv", m[1], nil
+		}
+	}
+	if doiPattern.MatchString(doi) {
+		return "doi", doi, nil
+	}
+	return "", "", fmt.Errorf("无法识别的论文标识: %s，应为arXiv编号（如 2301.00001）或DOI（如 10.1000/xyz123）", value)
+}
+
+// arxivFeed arXiv接口返回的Atom
+type arxivFeed struct {
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Summary   string `xml:"summary"`
+		Published string `xml:"published"`
+		Authors   []struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Links []struct {
+			Href  string `xml:"href,attr"`
+			Title string `xml:"title,attr"`
+		} `xml:"link"`
+		DOI             string `xml:"http://arxiv.org/schemas/atom doi"`
+		JournalRef      string `xml:"http://arxiv.org/schemas/atom journal_ref"`
+		PrimaryCategory struct {
+			Term string `xml:"term,attr"`
+		} `xml:"http://arxiv.org/schemas/atom primary_category"`
+	} `xml:"entry"`
+}
+
+// fetchArxivPaper 通过arXiv接口查询论文
+func fetchArxivPaper(ctx context.Context, id string) (*paper, error) {
+	data, err := fetchRemoteBody(ctx, arxivAPIURL+"?id_list="+url.QueryEscape(id), maxPaperMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	var feed arxivFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("解析arXiv响应失败: %w", err)
+	}
+	// 编号不存在时arXiv返回一条只有id的错误条目
+	if len(feed.Entries) == 0 || strings.TrimSpace(feed.Entries[0].Title) == "" || strings.Contains(feed.Entries[0].ID, "api/errors") {
+		return nil, fmt.Errorf("arXiv上没有找到论文 %s", id)
+	}
+
+	entry := feed.Entries[0]
+	p := &paper{
+		Source:   "arxiv",
+		ID:       id,
+		Title:    strings.Join(strings.Fields(entry.Title), " "),
+		Abstract: strings.Join(strings.Fields(entry.Summary), " "),
+		URL:      "https://arxiv.org/abs/" + id,
+		PDFURL:   "https://arxiv.org/pdf/" + id,
+		DOI:      strings.TrimSpace(entry.DOI),
+		Venue:    strings.TrimSpace(entry.JournalRef),
+		Category: entry.PrimaryCategory.Term,
+	}
+	if len(entry.Published) >= 4 {
+		p.Year = entry.Published[:4]
+	}
+	for _, author := range entry.Authors {
+		p.Authors = append(p.Authors, strings.TrimSpace(author.Name))
+	}
+	for _, link := range entry.Links {
+		if link.Title == "pdf" && link.Href != "" {
+			p.PDFURL = link.Href
+		}
+	}
+	return p, nil
+}
+
+// crossrefWork Crossref接口返回的论文信息
+type crossrefWork struct {
+	Message struct {
+		Title          []string `json:"title"`
+		ContainerTitle []string `json:"container-title"`
+		Abstract       string   `json:"abstract"`
+		URL            string   `json:"URL"`
+		Author         []struct {
+			Given  string `json:"given"`
+			Family string `json:"family"`
+			Name   string `json:"name"`
+		} `json:"author"`
+		Issued struct {
+			DateParts [][]int `json:"date-parts"`
+		} `json:"issued"`
+		Link []struct {
+			URL         string `json:"URL"`
+			ContentType string `json:"content-type"`
+		} `json:"link"`
+	} `json:"message"`
+}
+
+// fetchDOIPaper 通过Crossref查询DOI对应的论文，只有出版方提供了PDF链接时才附加PDF
+func fetchDOIPaper(ctx context.Context, doi string) (*paper, error) {
+	data, err := fetchRemoteBody(ctx, crossrefAPIURL+url.PathEscape(doi), maxPaperMetadataSize)
+	if err != nil {
+		return nil, err
+	}
+	var work crossrefWork
+	if err := json.Unmarshal(data, &work); err != nil {
+		return nil, fmt.Errorf("解析Crossref响应失败: %w", err)
+	}
+
+	msg := work.Message
+	p := &paper{
+		Source:   "doi",
+		ID:       doi,
+		DOI:      doi,
+		Abstract: strings.Join(strings.Fields(cleanHTMLText(msg.Abstract)), " "),
+		URL:      firstNonEmpty(msg.URL, "https://doi.org/"+doi),
+	}
+	if len(msg.Title) > 0 {
+		p.Title = strings.Join(strings.Fields(msg.Title[0]), " ")
+	}
+	if len(msg.ContainerTitle) > 0 {
+		p.Venue = msg.ContainerTitle[0]
+	}
+	if len(msg.Issued.DateParts) > 0 && len(msg.Issued.DateParts[0]) > 0 {
+		p.Year = strconv.Itoa(msg.Issued.DateParts[0][0])
+	}
+	for _, author := range msg.Author {
+		if name := strings.TrimSpace(author.Given + " " + author.Family); name != "" {
+			p.Authors = append(p.Authors, name)
+		} else if author.Name != "" {
+			p.Authors = append(p.Authors, author.Name)
+		}
+	}
+	for _, link := range msg.Link {
+		if link.ContentType == "application/pdf" {
+			p.PDFURL = link.URL
+			break
+		}
+	}
+	if p.Title == "" {
+		return nil, fmt.Errorf("Crossref上没有找到DOI %s 的标题", doi)
+	}
+	return p, nil
+}
+
+// citation 生成 作者 (年份). 标题. 出处. 标识 形式的引用
+func (p *paper) citation() string {
+	authors := strings.Join(p.Authors, ", ")
+	if len(p.Authors) > 3 {
+		authors = p.Authors[0] + " et al."
+	}
+	parts := []string{firstNonEmpty(authors, "佚名")}
+	if p.Year != "" {
+		parts[0] += " (" + p.Year + ")"
+	}
+	parts = append(parts, p.Title)
+	if p.Venue != "" {
+		parts = append(parts, p.Venue)
+	}
+	if p.Source == "arxiv" {
+		parts = append(parts, "arXiv:"+p.ID)
+	}
+	if p.DOI != "" {
+		parts = append(parts, "doi:"+p.DOI)
+	}
+	return strings.Join(parts, ". ")
+}
+
+// buildPaperBlocks 生成文献笔记：标题、作者、出处、引用、摘要和PDF
+func buildPaperBlocks(p *paper, withPDF bool) []ContentBlock {
+	blocks := []ContentBlock{
+		{Texts: []TextNode{{Text: "📄 " + p.Title, Bold: true}}},
+	}
+	if len(p.Authors) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "作者: " + strings.Join(p.Authors, ", ")}}})
+	}
+	var meta []string
+	if p.Venue != "" {
+		meta = append(meta, p.Venue)
+	}
+	if p.Year != "" {
+		meta = append(meta, p.Year)
+	}
+	if p.Category != "" {
+		meta = append(meta, p.Category)
+	}
+	if len(meta) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "出处: " + strings.Join(meta, " · ")}}})
+	}
+	blocks = append(blocks,
+		ContentBlock{Texts: []TextNode{{Text: "链接: "}, {Text: p.URL, Link: p.URL}}},
+		ContentBlock{Texts: []TextNode{{Text: "引用: ", Bold: true}, {Text: p.citation()}}},
+	)
+	if p.Abstract != "" {
+		blocks = append(blocks,
+			ContentBlock{Texts: []TextNode{{Text: "摘要", Bold: true}}},
+			ContentBlock{Type: "quote", Texts: []TextNode{{Text: p.Abstract}}},
+		)
+	}
+	if withPDF && p.PDFURL != "" {
+		blocks = append(blocks, ContentBlock{Type: "file", FileType: "pdf", SourceType: "url", SourcePath: p.PDFURL,
+			Metadata: map[string]interface{}{fileNameMetadataKey: p.ID + ".pdf"}})
+	}
+	return blocks
+}
+
+// ImportPaper 按arXiv编号或DOI导入论文
+func ImportPaper(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	identifier, _ := args["id"].(string)
+	source, id, err := parsePaperID(identifier)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	tags := []string{"论文"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	withPDF := true
+	if v, ok := args["with_pdf"].(bool); ok {
+		withPDF = v
+	}
+
+	// 同一篇论文只导入一次，arXiv编号忽略版本号
+	tenantID := tenantFromContext(ctx)
+	key := strings.ToLower(id)
+	if source == "arxiv" {
+		key = arxivVersion.ReplaceAllString(key, "")
+	}
+	name := "paper:" + source + ":" + key
+	unlock := lockNote(tenantID, "named:"+name)
+	defer unlock()
+	if existing, err := GetNamedNote(tenantID, name); err != nil {
+		return errorResult("", err), nil
+	} else if existing != "" {
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 论文已导入过\n\n笔记ID: %s", existing)), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	var p *paper
+	if source == "arxiv" {
+		p, err = fetchArxivPaper(ctx, id)
+	} else {
+		p, err = fetchDOIPaper(ctx, id)
+	}
+	if err != nil {
+		return errorResult("获取论文信息失败", err), nil
+	}
+	if p.Category != "" {
+		tags = append(tags, p.Category)
+	}
+
+	noteID, err := createNoteFromBlocks(ctx, client, buildPaperBlocks(p, withPDF), &Settings{Tags: tags})
+	if err != nil {
+		return errorResult("创建文献笔记失败", err), nil
+	}
+	if noteID == "" {
+		return mcp.NewToolResultText("❌ 创建文献笔记失败：接口未返回笔记ID"), nil
+	}
+	sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	if err := SetNamedNote(tenantID, name, noteID); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 文献笔记已创建（%s），但记录导入状态失败: %v", noteID, err)), nil
+	}
+
+	pdf := "未附加"
+	if withPDF && p.PDFURL != "" {
+		pdf = "已附加"
+	} else if withPDF {
+		pdf = "出版方未提供公开的PDF链接"
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 论文已导入！\n\n标题: %s\n笔记ID: %s\n引用: %s\nPDF: %s\n标签: %s",
+		p.Title, noteID, p.citation(), pdf, strings.Join(tags, ", "))), nil
+}
+
+// 导入论文工具
+var ImportPaperTool = mcp.NewTool("import_paper",
+	mcp.WithDescription("按arXiv编号或DOI导入论文：获取标题、作者、出处和摘要，生成带引用信息的文献笔记，并附加PDF（arXiv总是可用，DOI需出版方提供公开PDF链接）。同一篇论文只会导入一次。"),
+	mcp.WithString("id",
+		mcp.Required(),
+		mcp.Description("arXiv编号（如 2301.00001、arXiv:2301.00001v2）、DOI（如 10.1000/xyz123），或它们的链接"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组，默认 [\"论文\"]；arXiv论文会额外加上主分类"),
+	),
+	mcp.WithBoolean("with_pdf",
+		mcp.Description("是否附加PDF，默认true"),
+	),
+)
+
+func importPaperHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ImportPaper(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
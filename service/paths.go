@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// 本地路径规范化：MCP客户端传入的路径格式不统一，
+// Windows客户端常见盘符、UNC共享路径、正反斜杠混用、带引号的路径和 file:// URI
+
+const isWindows = runtime.GOOS == "windows"
+
+// normalizeLocalPath 把客户端传入的本地路径转换为当前系统可用的路径
+func normalizeLocalPath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	// 资源管理器"复制为路径"会在两端加引号
+	if len(path) >= 2 && (path[0] == '"' || path[0] == '\'') && path[len(path)-1] == path[0] {
+		path = strings.TrimSpace(path[1 : len(path)-1])
+	}
+	if path == "" {
+		return "", fmt.Errorf("文件路径不能为空")
+	}
+	if len(path) > 5 && strings.EqualFold(path[:5], "file:") {
+		path = fileURIPath(path[5:])
+	}
+
+	if !isWindows {
+		if isWindowsPath(path) {
+			return "", fmt.Errorf("%s 是Windows路径，服务运行在 %s 上无法访问", path, runtime.GOOS)
+		}
+		return path, nil
+	}
+
+	// /C:/Users/... 是URI中的写法，去掉开头的斜杠
+	if len(path) > 1 && (path[0] == '/' || path[0] == '\\') && isDrivePath(path[1:]) {
+		path = path[1:]
+	}
+	path = filepath.FromSlash(path)
+	// C:foo 相对于该盘的当前目录，MCP服务的当前目录不确定，不接受
+	if vol := filepath.VolumeName(path); len(vol) == 2 && vol[1] == ':' && (len(path) == 2 || path[2] != '\\') {
+		return "", fmt.Errorf("路径 %s 缺少盘符后的反斜杠，请使用完整路径，例如 %s\\...", path, vol)
+	}
+	return path, nil
+}
+
+// resolveLocalPath 把客户端传入或配置中的路径解析为当前系统可用的路径：规范化格式后展开 ~ 和环境变量
+func resolveLocalPath(ctx context.Context, path string) (string, error) {
+	path, err := normalizeLocalPath(path)
+	if err != nil {
+		return "", err
+	}
+	return expandPath(ctx, path), nil
+}
+
+// envVarPattern 匹配 $VAR 和 ${VAR}，Windows上还匹配 %VAR%
+var envVarPattern = func() *regexp.Regexp {
+	pattern := `\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`
+	if isWindows {
+		pattern += `|%([A-Za-z_][A-Za-z0-9_()]*)%`
+	}
+	return regexp.MustCompile(pattern)
+}()
+
+// expandPath 展开开头的 ~ 为用户主目录，并展开其中已设置的环境变量，未设置的保持原样
+// HTTP多租户模式下只展开 ~，避免服务端的环境变量（例如API密钥）通过路径和错误信息泄露给租户
+func expandPath(ctx context.Context, path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || (isWindows && strings.HasPrefix(path, `~\`)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+	if sessionFromContext(ctx) != defaultSession {
+		return path
+	}
+	return envVarPattern.ReplaceAllStringFunc(path, func(match string) string {
+		name := strings.Trim(match, "${}%")
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return match
+	})
+}
+
+// fileURIPath 把 file:// URI（已去掉 file: 前缀）转换为路径
+// file:///C:/a 得到 C:/a，file://server/share/a 得到UNC路径 //server/share/a
+func fileURIPath(rest string) string {
+	if strings.HasPrefix(rest, "//") {
+		rest = rest[2:]
+		host, p := rest, ""
+		if i := strings.IndexAny(rest, `/\`); i >= 0 {
+			host, p = rest[:i], rest[i:]
+		}
+		switch {
+		case host == "" || strings.EqualFold(host, "localhost"):
+			rest = p
+		case isDrivePath(host):
+			// file://C:/a 不是合法的URI，但客户端常这样写
+			rest = host + p
+		default:
+			rest = "//" + host + p
+		}
+	}
+	if unescaped, err := url.PathUnescape(rest); err == nil {
+		rest = unescaped
+	}
+	if len(rest) > 1 && rest[0] == '/' && isDrivePath(rest[1:]) {
+		rest = rest[1:]
+	}
+	return rest
+}
+
+// isDrivePath 是否以盘符开头，例如 C: 或 C:\ 或 C:/
+func isDrivePath(path string) bool {
+	if len(path) < 2 || path[1] != ':' {
+		return false
+	}
+	c := path[0]
+	if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+		return false
+	}
+	return len(path) == 2 || path[2] == '/' || path[2] == '\\'
+}
+
+// isWindowsPath 是否为带盘符的绝对路径或UNC路径，用于在非Windows系统上给出明确的错误
+func isWindowsPath(path string) bool {
+	return (isDrivePath(path) && len(path) > 2) || strings.HasPrefix(path, `\\`)
+}
+
+// localFileBase 本地路径的文件名，路径无法规范化时取最后一个斜杠或反斜杠之后的部分
+func localFileBase(path string) string {
+	normalized, err := normalizeLocalPath(path)
+	if err != nil {
+		return path[strings.LastIndexAny(path, `/\`)+1:]
+	}
+	return filepath.Base(normalized)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// personNoteName 人物笔记的具名笔记名称，忽略大小写和多余空白，
+// "Zhang  San" 和 "zhang san" 对应同一篇笔记
+func personNoteName(name string) string {
+	return "person:" + strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// UpsertPersonNote 向人物笔记追加互动记录，笔记不存在时新建
+func UpsertPersonNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	name, _ := args["name"].(string)
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return mcp.NewToolResultText("❌ 姓名不能为空"), nil
+	}
+	interaction, _ := args["interaction"].(string)
+	interaction = strings.TrimSpace(interaction)
+	details, _ := args["details"].(string)
+	details = strings.TrimSpace(details)
+
+	day := time.Now()
+	if dateStr, _ := args["date"].(string); dateStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 日期格式错误: %s，应为 YYYY-MM-DD", dateStr)), nil
+		}
+		day = t
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	var blocks []ContentBlock
+	if details != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{
+			{Text: "📇 资料 ", Bold: true},
+			{Text: details},
+		}})
+	}
+	if interaction != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{
+			{Text: day.Format("2006-01-02") + " ", Bold: true},
+			{Text: interaction},
+		}})
+	}
+
+	tenantID := tenantFromContext(ctx)
+	noteName := personNoteName(name)
+	if len(blocks) == 0 {
+		// 没有要追加的内容时只查找或新建人物笔记
+		noteID, err := GetNamedNote(tenantID, noteName)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		if noteID != "" {
+			return mcp.NewToolResultText(fmt.Sprintf("✅ 人物笔记已存在\n\n姓名: %s\n笔记ID: %s", name, noteID)), nil
+		}
+	}
+
+	noteID, created, err := appendToNamedNote(ctx, client, noteName, "👤 "+name, blocks)
+	if err != nil {
+		return errorResult("更新人物笔记失败", err), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 人物笔记已更新！\n\n姓名: %s\n笔记ID: %s", name, noteID)
+	if interaction != "" {
+		resultText += fmt.Sprintf("\n互动记录: %s %s", day.Format("2006-01-02"), interaction)
+	}
+	if created {
+		resultText += "\n（人物笔记不存在，已自动创建）"
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 更新人物笔记工具
+var UpsertPersonNoteTool = mcp.NewTool("upsert_person_note",
+	mcp.WithDescription("维护人物笔记（轻量CRM）：每个人对应一篇笔记，按姓名在本地索引（忽略大小写和多余空白），不存在时自动创建。每次调用向笔记追加一条带日期的互动记录，不会重复创建同一个人的笔记。"),
+	mcp.WithString("name",
+		mcp.Required(),
+		mcp.Description("姓名"),
+	),
+	mcp.WithString("interaction",
+		mcp.Description("本次互动记录，如 \"喝咖啡聊了新项目，下周发方案\""),
+	),
+	mcp.WithString("details",
+		mcp.Description("人物资料，如公司、职位、联系方式，追加为一条资料记录"),
+	),
+	mcp.WithString("date",
+		mcp.Description("互动日期 YYYY-MM-DD，默认今天"),
+	),
+)
+
+func upsertPersonNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := UpsertPersonNote(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
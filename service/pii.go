@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 敏感信息检测环境变量
+const (
+	// 检测到敏感信息时的处理方式：off(默认), warn, mask, block
+	PIIModeEnvVar = "MOWEN_PII_MODE"
+	// 启用的检测类型，逗号分隔，默认全部：api_key, credit_card, id_number, email
+	PIITypesEnvVar = "MOWEN_PII_TYPES"
+)
+
+// 敏感信息处理方式
+const (
+	piiModeOff   = "off"
+	piiModeWarn  = "warn"
+	piiModeMask  = "mask"
+	piiModeBlock = "block"
+)
+
+// piiDetector 敏感信息检测器
+type piiDetector struct {
+	Name    string
+	Label   string
+	Pattern *regexp.Regexp
+	Valid   func(match string) bool // 对正则命中结果做二次校验，为空表示不校验
+}
+
+var piiDetectors = []piiDetector{
+	{
+		Name:  "api_key",
+		Label: "密钥",
+		Pattern: regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_-]{16,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9-]{10,}|AIza[0-9A-Za-z_-]{35})\b` +
+			`|(?i:\b(?:api[_-]?key|secret|token|password|passwd)\b\s*[:=]\s*["']?[^\s"']{8,})`),
+	},
+	{
+		Name:    "credit_card",
+		Label:   "银行卡号",
+		Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Valid:   luhnValid,
+	},
+	{
+		Name:    "id_number",
+		Label:   "身份证号",
+		Pattern: regexp.MustCompile(`\b\d{17}[\dXx]\b`),
+		Valid:   idNumberValid,
+	},
+	{
+		Name:    "email",
+		Label:   "邮箱",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+}
+
+// piiFinding 一处敏感信息
+type piiFinding struct {
+	Label string
+	Match string
+}
+
+// piiMode 读取处理方式，无法识别的值按off处理
+func piiMode() string {
+	switch mode := strings.ToLower(envString(PIIModeEnvVar, piiModeOff)); mode {
+	case piiModeWarn, piiModeMask, piiModeBlock:
+		return mode
+	default:
+		return piiModeOff
+	}
+}
+
+// enabledPIIDetectors 返回配置启用的检测器
+func enabledPIIDetectors() []piiDetector {
+	types := envList(PIITypesEnvVar)
+	if len(types) == 0 {
+		return piiDetectors
+	}
+
+	enabled := make(map[string]bool, len(types))
+	for _, t := range types {
+		enabled[strings.ToLower(t)] = true
+	}
+	var detectors []piiDetector
+	for _, d := range piiDetectors {
+		if enabled[d.Name] {
+			detectors = append(detectors, d)
+		}
+	}
+	return detectors
+}
+
+// scanPII 检测文本中的敏感信息，mask为true时返回脱敏后的文本
+func scanPII(detectors []piiDetector, text string, mask bool) (string, []piiFinding) {
+	var findings []piiFinding
+	for _, d := range detectors {
+		text = d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.Valid != nil && !d.Valid(match) {
+				return match
+			}
+			findings = append(findings, piiFinding{Label: d.Label, Match: match})
+			if mask {
+				return maskSecret(match)
+			}
+			return match
+		})
+	}
+	return text, findings
+}
+
+// maskSecret 保留开头少量字符，其余替换为星号
+func maskSecret(s string) string {
+	runes := []rune(s)
+	keep := len(runes) / 4
+	if keep > 4 {
+		keep = 4
+	}
+	return string(runes[:keep]) + strings.Repeat("*", len(runes)-keep)
+}
+
+// luhnValid 银行卡号的Luhn校验
+func luhnValid(s string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// idNumberValid 18位居民身份证号的校验码校验
+func idNumberValid(s string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	checkCodes := "10X98765432"
+	sum := 0
+	for i, w := range weights {
+		sum += int(s[i]-'0') * w
+	}
+	return strings.ToUpper(s[17:]) == string(checkCodes[sum%11])
+}
+
+// piiHook 创建、编辑笔记前检测内容中的敏感信息
+func piiHook(ctx context.Context, event *HookEvent) error {
+	mode := piiMode()
+	if mode == piiModeOff {
+		return nil
+	}
+	detectors := enabledPIIDetectors()
+	mask := mode == piiModeMask
+
+	// 脱敏时复制内容块，避免修改调用方的数据
+	blocks := event.Blocks
+	if mask {
+		blocks = make([]ContentBlock, len(event.Blocks))
+		copy(blocks, event.Blocks)
+	}
+
+	var findings []piiFinding
+	for i := range blocks {
+		if mask {
+			blocks[i] = cloneBlockTexts(blocks[i])
+		}
+		for _, node := range blockTextNodes(&blocks[i]) {
+			text, found := scanPII(detectors, node.Text, mask)
+			node.Text = text
+			findings = append(findings, found...)
+		}
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+
+	labels := make([]string, 0, len(findings))
+	for _, f := range findings {
+		labels = append(labels, f.Label+" "+maskSecret(f.Match))
+	}
+	summary := strings.Join(labels, "、")
+
+	switch mode {
+	case piiModeBlock:
+		return withErrorCode(ErrPermissionDenied, fmt.Errorf("内容中包含敏感信息: %s，请删除后重试", summary))
+	case piiModeMask:
+		event.Blocks = blocks
+		logger.Warnf("笔记内容中的敏感信息已脱敏: %s", summary)
+		noteResultWarning(ctx, fmt.Sprintf("笔记内容中的敏感信息已脱敏后保存: %s", summary))
+	default:
+		logger.Warnf("笔记内容中包含敏感信息: %s", summary)
+		noteResultWarning(ctx, fmt.Sprintf("笔记内容中包含敏感信息: %s，请确认是否需要删除", summary))
+	}
+	return nil
+}
+
+func init() {
+	RegisterHook(HookBeforeCreate, piiHook)
+	RegisterHook(HookBeforeEdit, piiHook)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TestPIIHookReportsFindings warn和mask模式下检测结果附加到工具结果中，不只写日志
+func TestPIIHookReportsFindings(t *testing.T) {
+	for _, mode := range []string{piiModeWarn, piiModeMask} {
+		t.Run(mode, func(t *testing.T) {
+			t.Setenv(PIIModeEnvVar, mode)
+			requestID := newRequestID()
+			ctx := context.WithValue(context.Background(), requestIDContextKey{}, requestID)
+			event := &HookEvent{
+				Hook:   HookBeforeCreate,
+				Blocks: []ContentBlock{{Texts: []TextNode{{Text: "联系我 someone@example.com"}}}},
+			}
+			if err := piiHook(ctx, event); err != nil {
+				t.Fatalf("%s模式不应拒绝保存: %v", mode, err)
+			}
+
+			result := mcp.NewToolResultText("✅ 笔记创建成功")
+			appendResultWarnings(result, takeResultWarnings(requestID))
+			text := result.Content[0].(mcp.TextContent).Text
+			if !strings.Contains(text, "\n⚠️ ") || !strings.Contains(text, maskSecret("someone@example.com")) {
+				t.Errorf("结果中缺少脱敏后的检测结果: %q", text)
+			}
+			if strings.Contains(text, "someone@example.com") {
+				t.Errorf("结果中包含未脱敏的敏感信息: %q", text)
+			}
+			if rest := takeResultWarnings(requestID); len(rest) != 0 {
+				t.Errorf("取出后仍有警告: %v", rest)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 设置为true时所有工具结果去掉表情符号和Markdown装饰，可被单次调用的plain_output参数覆盖
+const PlainOutputEnvVar = "MOWEN_PLAIN_OUTPUT"
+
+// plainOutputArgKey 单次调用指定是否输出纯文本的参数
+const plainOutputArgKey = "plain_output"
+
+// 表示结果状态的符号在纯文本模式下替换为文字，其他装饰性符号直接去掉
+var plainOutputMarks = map[rune]string{
+	'❌': "错误: ",
+	'⚠': "警告: ",
+}
+
+// withToolParam 为工具补充一个参数，复制参数表以免修改共享的工具定义
+func withToolParam(tool mcp.Tool, name, paramType, description string) mcp.Tool {
+	properties := make(map[string]interface{}, len(tool.InputSchema.Properties)+1)
+	for key, value := range tool.InputSchema.Properties {
+		properties[key] = value
+	}
+	properties[name] = map[string]interface{}{
+		"type":        paramType,
+		"description": description,
+	}
+	tool.InputSchema.Properties = properties
+	return tool
+}
+
+// withPlainOutputParam 为工具补充plain_output参数
+func withPlainOutputParam(tool mcp.Tool) mcp.Tool {
+	return withToolParam(tool, plainOutputArgKey, "boolean",
+		"为true时结果去掉表情符号和Markdown加粗等装饰，适合语音播报或程序解析；不传时使用MOWEN_PLAIN_OUTPUT配置")
+}
+
+// takePlainOutputArg 取出plain_output参数并返回本次是否输出纯文本，参数不参与后续处理
+func takePlainOutputArg(arguments map[string]interface{}) bool {
+	value, ok := arguments[plainOutputArgKey].(bool)
+	delete(arguments, plainOutputArgKey)
+	if ok {
+		return value
+	}
+	return envBool(PlainOutputEnvVar, false)
+}
+
+// isDecorativeEmoji 判断是否为结果中用作装饰的表情符号，待办的勾选框属于笔记内容，不在此列
+func isDecorativeEmoji(r rune) bool {
+	switch {
+	case r >= 0x2610 && r <= 0x2612:
+		return false
+	case r >= 0x1F000 && r <= 0x1FAFF, r >= 0x2600 && r <= 0x27BF, r >= 0x2B00 && r <= 0x2BFF:
+		return true
+	}
+	return false
+}
+
+// plainText 去掉每行开头的表情符号和全文的Markdown加粗标记
+// 只处理行首是因为工具结果的装饰都在行首，正文中用户自己写的表情保持不变
+func plainText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "**", ""), "\n")
+	for i, line := range lines {
+		rest := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(rest)]
+		prefix := ""
+		for rest != "" {
+			r, size := utf8.DecodeRuneInString(rest)
+			if r == 0xFE0F || r == 0x200D {
+				rest = strings.TrimLeft(rest[size:], " ")
+				continue
+			}
+			if !isDecorativeEmoji(r) {
+				break
+			}
+			if mark, ok := plainOutputMarks[r]; ok && prefix == "" {
+				prefix = mark
+			}
+			rest = strings.TrimLeft(rest[size:], " ")
+		}
+		lines[i] = indent + prefix + rest
+	}
+	return strings.Join(lines, "\n")
+}
+
+// plainResult 把结果中的文本内容转换为纯文本
+func plainResult(result *mcp.CallToolResult) {
+	for i, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			text.Text = plainText(text.Text)
+			result.Content[i] = text
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 订阅源大小上限，长期更新的播客订阅源可能有数MB
+const maxPodcastFeedSize = 20 << 20
+
+// 音频节点show note的最大字符数，完整的节目介绍另以段落保存
+const maxShowNoteRunes = 500
+
+// podcastFeed RSS订阅源中用到的字段
+type podcastFeed struct {
+	Channel struct {
+		Title string        `xml:"title"`
+		Items []podcastItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// podcastItem 订阅源中的一期节目
+type podcastItem struct {
+	Title       string           `xml:"title"`
+	Link        string           `xml:"link"`
+	GUID        string           `xml:"guid"`
+	PubDate     string           `xml:"pubDate"`
+	Description string           `xml:"description"`
+	Encoded     string           `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Summary     string           `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	Duration    string           `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Enclosure   podcastEnclosure `xml:"enclosure"`
+}
+
+// podcastEnclosure 节目的音频文件
+type podcastEnclosure struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// showNotes 节目介绍，优先使用内容最完整的字段
+func (item podcastItem) showNotes() string {
+	return cleanHTMLText(firstNonEmpty(item.Encoded, item.Description, item.Summary))
+}
+
+// key 节目的唯一标识，用于避免重复导入
+func (item podcastItem) key() string {
+	return firstNonEmpty(strings.TrimSpace(item.GUID), item.Enclosure.URL)
+}
+
+// findPodcastItem 按关键词查找节目：匹配GUID、链接、音频地址或标题，为空时返回最新一期
+func findPodcastItem(items []podcastItem, episode string) (*podcastItem, error) {
+	var withAudio []podcastItem
+	for _, item := range items {
+		if item.Enclosure.URL != "" {
+			withAudio = append(withAudio, item)
+		}
+	}
+	if len(withAudio) == 0 {
+		return nil, fmt.Errorf("订阅源中没有带音频的节目")
+	}
+	episode = strings.TrimSpace(episode)
+	if episode == "" {
+		return &withAudio[0], nil
+	}
+	for i, item := range withAudio {
+		if episode == strings.TrimSpace(item.GUID) || episode == strings.TrimSpace(item.Link) || episode == item.Enclosure.URL {
+			return &withAudio[i], nil
+		}
+	}
+	lower := strings.ToLower(episode)
+	for i, item := range withAudio {
+		if strings.Contains(strings.ToLower(item.Title), lower) {
+			return &withAudio[i], nil
+		}
+	}
+	return nil, fmt.Errorf("订阅源中没有找到节目: %s", episode)
+}
+
+// formatPodcastDuration 把itunes:duration（秒数或 HH:MM:SS）显示为 1小时5分钟
+func formatPodcastDuration(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	seconds := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return value
+		}
+		seconds = seconds*60 + n
+	}
+	return formatWorkDuration(time.Duration(seconds) * time.Second)
+}
+
+// buildPodcastBlocks 生成节目笔记：标题、节目信息、带show note的音频和完整的节目介绍
+func buildPodcastBlocks(show string, item *podcastItem) []ContentBlock {
+	blocks := []ContentBlock{{Texts: []TextNode{{Text: "🎧 " + strings.TrimSpace(item.Title), Bold: true}}}}
+
+	var meta []string
+	if show != "" {
+		meta = append(meta, show)
+	}
+	if t, err := time.Parse(time.RFC1123Z, strings.TrimSpace(item.PubDate)); err == nil {
+		meta = append(meta, t.Local().Format("2006-01-02"))
+	} else if t, err := time.Parse(time.RFC1123, strings.TrimSpace(item.PubDate)); err == nil {
+		meta = append(meta, t.Local().Format("2006-01-02"))
+	}
+	if d := formatPodcastDuration(item.Duration); d != "" {
+		meta = append(meta, d)
+	}
+	if len(meta) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: strings.Join(meta, " · ")}}})
+	}
+
+	notes := item.showNotes()
+	audio := ContentBlock{Type: "file", FileType: "audio", SourceType: "url", SourcePath: item.Enclosure.URL}
+	if notes != "" {
+		showNote := notes
+		if runes := []rune(showNote); len(runes) > maxShowNoteRunes {
+			showNote = string(runes[:maxShowNoteRunes]) + "..."
+		}
+		audio.Metadata = map[string]interface{}{"show_note": showNote}
+	}
+	blocks = append(blocks, audio)
+
+	if notes != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "节目介绍", Bold: true}}})
+		for _, line := range splitLines(notes) {
+			blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: line}}})
+		}
+	}
+	if link := strings.TrimSpace(item.Link); link != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "链接: "}, {Text: link, Link: link}}})
+	}
+	return blocks
+}
+
+// ImportPodcastEpisode 从播客订阅源导入一期节目
+func ImportPodcastEpisode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	feedURL, _ := args["feed_url"].(string)
+	feedURL = strings.TrimSpace(feedURL)
+	audioURL, _ := args["audio_url"].(string)
+	audioURL = strings.TrimSpace(audioURL)
+	if feedURL == "" && audioURL == "" {
+		return mcp.NewToolResultText("❌ feed_url和audio_url至少需要传入一个"), nil
+	}
+	episode, _ := args["episode"].(string)
+	title, _ := args["title"].(string)
+	tags := []string{"播客"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+
+	// 没有订阅源时直接导入音频地址，节目信息来自参数
+	show := ""
+	item := &podcastItem{Title: title, Enclosure: podcastEnclosure{URL: audioURL}}
+	if feedURL != "" {
+		data, err := fetchRemoteBody(ctx, feedURL, maxPodcastFeedSize)
+		if err != nil {
+			return errorResult("抓取订阅源失败", err), nil
+		}
+		var feed podcastFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return errorResult("解析订阅源失败", err), nil
+		}
+		if audioURL != "" && episode == "" {
+			episode = audioURL
+		}
+		if item, err = findPodcastItem(feed.Channel.Items, episode); err != nil {
+			return errorResult("", err), nil
+		}
+		show = feed.Channel.Title
+		if title != "" {
+			item.Title = title
+		}
+	}
+	if strings.TrimSpace(item.Title) == "" {
+		item.Title = "未命名节目"
+	}
+	show = strings.TrimSpace(show)
+	if show != "" && !slices.Contains(tags, show) {
+		tags = append(tags, show)
+	}
+
+	tenantID := tenantFromContext(ctx)
+	name := "podcast:" + item.key()
+	unlock := lockNote(tenantID, "named:"+name)
+	defer unlock()
+	if existing, err := GetNamedNote(tenantID, name); err != nil {
+		return errorResult("", err), nil
+	} else if existing != "" {
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 这期节目已导入过\n\n节目: %s\n笔记ID: %s", item.Title, existing)), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	noteID, err := createNoteFromBlocks(ctx, client, buildPodcastBlocks(show, item), &Settings{Tags: tags})
+	if err != nil {
+		return errorResult("创建节目笔记失败", err), nil
+	}
+	if noteID == "" {
+		return mcp.NewToolResultText("❌ 创建节目笔记失败：接口未返回笔记ID"), nil
+	}
+	sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	if err := SetNamedNote(tenantID, name, noteID); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 节目笔记已创建（%s），但记录导入状态失败: %v", noteID, err)), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 播客节目已导入！\n\n节目: %s\n", strings.TrimSpace(item.Title))
+	if show != "" {
+		resultText += fmt.Sprintf("播客: %s\n", show)
+	}
+	resultText += fmt.Sprintf("笔记ID: %s\n标签: %s", noteID, strings.Join(tags, ", "))
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 导入播客节目工具
+var ImportPodcastEpisodeTool = mcp.NewTool("import_podcast_episode",
+	mcp.WithDescription("导入一期播客节目作为收听记录：从RSS订阅源读取节目信息，通过URL上传音频并附上show note，节目介绍保存为正文，自动打上播客和节目名标签。同一期节目只会导入一次。"),
+	mcp.WithString("feed_url",
+		mcp.Description("播客RSS订阅源地址"),
+	),
+	mcp.WithString("episode",
+		mcp.Description("要导入的节目：标题关键词、GUID或节目链接，不传时导入最新一期"),
+	),
+	mcp.WithString("audio_url",
+		mcp.Description("节目音频地址；与feed_url同时传入时用于在订阅源中定位节目，单独传入时直接导入该音频"),
+	),
+	mcp.WithString("title",
+		mcp.Description("节目标题，覆盖订阅源中的标题；只传audio_url时建议填写"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组，默认 [\"播客\"]，并自动加上节目名"),
+	),
+)
+
+func importPodcastEpisodeHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ImportPodcastEpisode(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 上传限速环境变量，每秒字节数，支持KB、MB单位，例如 512KB；默认不限速
+const UploadRateLimitEnvVar = "MOWEN_UPLOAD_RATE_LIMIT"
+
+// uploadRateArgKey 单次调用覆盖上传限速的参数，0表示本次不限速
+const uploadRateArgKey = "upload_rate_limit"
+
+// uploadRateContextKey 上下文中保存单次调用上传限速的键
+type uploadRateContextKey struct{}
+
+// withUploadRateParam 为会上传文件的工具补充upload_rate_limit参数，注册时通过addTool的选项添加
+func withUploadRateParam(tool mcp.Tool) mcp.Tool {
+	return withToolParam(tool, uploadRateArgKey, "string",
+		"本次上传文件的限速，例如512KB、2MB（每秒），0表示不限速；不传时使用MOWEN_UPLOAD_RATE_LIMIT配置")
+}
+
+// parseByteRate 解析每秒字节数，例如 "1048576"、"512KB"、"2MB"、"1.5M/s"
+func parseByteRate(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(s, "/S")
+	s = strings.TrimSuffix(s, "B")
+
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit, s = 1<<10, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		unit, s = 1<<20, strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "G"):
+		unit, s = 1<<30, strings.TrimSuffix(s, "G")
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的速率: %s", value)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseUploadRateArg 解析单次调用的限速参数，可以是数字（字节/秒）或带单位的字符串
+func parseUploadRateArg(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(max(v, 0)), true
+	case string:
+		rate, err := parseByteRate(v)
+		return rate, err == nil
+	}
+	return 0, false
+}
+
+// uploadRateLimit 本次调用的上传限速（字节/秒），0表示不限速
+// 调用参数优先，其次是环境变量
+func uploadRateLimit(ctx context.Context) int64 {
+	if rate, ok := ctx.Value(uploadRateContextKey{}).(int64); ok {
+		return rate
+	}
+	if value := envString(UploadRateLimitEnvVar, ""); value != "" {
+		if rate, err := parseByteRate(value); err == nil {
+			return rate
+		}
+	}
+	return 0
+}
+
+// throttledReader 按固定速率读取，用于限制上传带宽
+type throttledReader struct {
+	r     io.Reader
+	rate  int64 // 字节/秒
+	start time.Time
+	read  int64
+}
+
+// newThrottledReader 创建限速读取器，rate不大于0时不限速
+func newThrottledReader(r io.Reader, rate int64) io.Reader {
+	if rate <= 0 {
+		return r
+	}
+	return &throttledReader{r: r, rate: rate}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+	// 每次最多读取约0.1秒的数据量，使发送更平滑
+	if chunk := max(t.rate/10, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := t.r.Read(p)
+	t.read += int64(n)
+
+	expected := time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// uploadTimeout 限速上传所需的超时时间，在按速率估算的耗时上留出余量
+func uploadTimeout(base time.Duration, size, rate int64) time.Duration {
+	if rate <= 0 {
+		return base
+	}
+	return base + time.Duration(float64(size)/float64(rate)*1.5*float64(time.Second))
+}
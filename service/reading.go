@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 阅读记录笔记的具名笔记名称
+const readingLogName = "reading-log"
+
+// 阅读状态
+var readingStatuses = []string{"想读", "在读", "读完", "弃读"}
+
+// bookNoteName 书籍笔记的具名笔记名称，同名不同作者的书对应不同的笔记
+func bookNoteName(title, author string) string {
+	key := strings.ToLower(strings.Join(strings.Fields(title), " "))
+	if author != "" {
+		key += "|" + strings.ToLower(strings.Join(strings.Fields(author), " "))
+	}
+	return "book:" + key
+}
+
+// ratingStars 以五角星显示1-5分的评分
+func ratingStars(rating int) string {
+	return strings.Repeat("★", rating) + strings.Repeat("☆", 5-rating)
+}
+
+// LogReading 记录阅读进展：追加到书籍笔记和阅读记录笔记
+func LogReading(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	title, _ := args["title"].(string)
+	title = strings.TrimSpace(strings.Trim(strings.TrimSpace(title), "《》"))
+	if title == "" {
+		return mcp.NewToolResultText("❌ 书名不能为空"), nil
+	}
+	author, _ := args["author"].(string)
+	author = strings.TrimSpace(author)
+	status, _ := args["status"].(string)
+	if status != "" && !slices.Contains(readingStatuses, status) {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 阅读状态只能是 %s", strings.Join(readingStatuses, "、"))), nil
+	}
+	rating := 0
+	if v, ok := args["rating"].(float64); ok {
+		if v < 1 || v > 5 {
+			return mcp.NewToolResultText("❌ 评分应为1到5"), nil
+		}
+		rating = int(v)
+	}
+	var quotes []string
+	if quotesStr, _ := args["quotes"].(string); quotesStr != "" {
+		if err := json.Unmarshal([]byte(quotesStr), &quotes); err != nil {
+			return errorResult("quotes格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	comment, _ := args["comment"].(string)
+	comment = strings.TrimSpace(comment)
+	coverURL, _ := args["cover_url"].(string)
+	coverURL = strings.TrimSpace(coverURL)
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	// 状态和评分组成一行摘要，书籍笔记和阅读记录共用
+	var summary []string
+	if status != "" {
+		summary = append(summary, status)
+	}
+	if rating > 0 {
+		summary = append(summary, ratingStars(rating))
+	}
+	today := time.Now().Format("2006-01-02")
+
+	// 作者只在新建书籍笔记时写入一次
+	bookName := bookNoteName(title, author)
+	existing, err := GetNamedNote(tenantFromContext(ctx), bookName)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	var bookBlocks []ContentBlock
+	if coverURL != "" {
+		bookBlocks = append(bookBlocks, ContentBlock{Type: "file", FileType: "image", SourceType: "url", SourcePath: coverURL})
+	}
+	if author != "" && existing == "" {
+		bookBlocks = append(bookBlocks, ContentBlock{Texts: []TextNode{{Text: "作者: " + author}}})
+	}
+	entry := []TextNode{{Text: today + " ", Bold: true}}
+	if len(summary) > 0 {
+		entry = append(entry, TextNode{Text: strings.Join(summary, " · ") + " "})
+	}
+	if comment != "" {
+		entry = append(entry, TextNode{Text: comment})
+	}
+	bookBlocks = append(bookBlocks, ContentBlock{Texts: entry})
+	for _, quote := range quotes {
+		if quote = strings.TrimSpace(quote); quote != "" {
+			bookBlocks = append(bookBlocks, ContentBlock{Type: "quote", Texts: []TextNode{{Text: quote}}})
+		}
+	}
+
+	bookNoteID, created, err := appendToNamedNote(ctx, client, bookName, "📖 《"+title+"》", bookBlocks)
+	if err != nil {
+		return errorResult("更新书籍笔记失败", err), nil
+	}
+
+	line := []TextNode{
+		{Text: today + " ", Bold: true},
+		{Text: "《" + title + "》"},
+	}
+	if author != "" {
+		line = append(line, TextNode{Text: " " + author})
+	}
+	if len(summary) > 0 {
+		line = append(line, TextNode{Text: " · " + strings.Join(summary, " · ")})
+	}
+	logBlocks := []ContentBlock{{Texts: line}, {Type: "note", NoteID: bookNoteID}}
+	logNoteID, _, err := appendToNamedNote(ctx, client, readingLogName, "📚 阅读记录", logBlocks)
+	if err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 书籍笔记已更新（%s），但追加阅读记录失败: %v", bookNoteID, err)), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 阅读记录已保存！\n\n书名: 《%s》\n书籍笔记ID: %s\n阅读记录笔记ID: %s", title, bookNoteID, logNoteID)
+	if len(summary) > 0 {
+		resultText += "\n状态: " + strings.Join(summary, " · ")
+	}
+	if len(quotes) > 0 {
+		resultText += fmt.Sprintf("\n摘录: %d 条", len(quotes))
+	}
+	if created {
+		resultText += "\n（书籍笔记不存在，已自动创建）"
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// 记录阅读工具
+var LogReadingTool = mcp.NewTool("log_reading",
+	mcp.WithDescription("记录读书/观影进展：每本书维护一篇书籍笔记（按书名和作者在本地索引），追加状态、评分、感想，摘录以引用段落保存；同时在\"阅读记录\"笔记中追加一行并链接到书籍笔记。"),
+	mcp.WithString("title",
+		mcp.Required(),
+		mcp.Description("书名或作品名"),
+	),
+	mcp.WithString("author",
+		mcp.Description("作者"),
+	),
+	mcp.WithString("status",
+		mcp.Description("阅读状态"),
+		mcp.Enum(readingStatuses...),
+	),
+	mcp.WithNumber("rating",
+		mcp.Description("评分，1到5"),
+	),
+	mcp.WithString("quotes",
+		mcp.Description("摘录，JSON字符串数组，例如 [\"第一句\", \"第二句\"]，每条保存为一个引用段落"),
+	),
+	mcp.WithString("comment",
+		mcp.Description("感想或笔记"),
+	),
+	mcp.WithString("cover_url",
+		mcp.Description("封面图片URL，上传后插入书籍笔记"),
+	),
+)
+
+func logReadingHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := LogReading(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 抓取菜谱网页的大小上限
+const maxRecipePageSize = 5 << 20
+
+// recipe 从网页中解析出的schema.org Recipe
+type recipe struct {
+	Name        string
+	Description string
+	Author      string
+	Image       string
+	Yield       string
+	PrepTime    string
+	CookTime    string
+	TotalTime   string
+	Ingredients []string
+	Steps       []string
+}
+
+var (
+	jsonLDPattern    = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']application/ld\+json["'][^>]*>(.*?)</script>`)
+	itempropPattern  = regexp.MustCompile(`(?is)<(\w+)\b([^>]*\bitemprop\s*=\s*["']([^"']+)["'][^>]*)>`)
+	attrValuePattern = regexp.MustCompile(`(?is)\b(content|src|href|datetime)\s*=\s*["']([^"']*)["']`)
+	breakTagPattern  = regexp.MustCompile(`(?i)<(br|/p|/li|/div|/h\d)\b[^>]*>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	isoDurationRegex = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:[\d.]+S)?)?$`)
+)
+
+// parseRecipe 解析网页中的菜谱，优先使用JSON-LD，没有时回退到microdata
+func parseRecipe(page string) (*recipe, error) {
+	for _, match := range jsonLDPattern.FindAllStringSubmatch(page, -1) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(match[1])), &data); err != nil {
+			continue
+		}
+		if node := findRecipeNode(data); node != nil {
+			return recipeFromJSONLD(node), nil
+		}
+	}
+	if r := recipeFromMicrodata(page); r != nil {
+		return r, nil
+	}
+	return nil, fmt.Errorf("网页中没有找到schema.org Recipe数据")
+}
+
+// findRecipeNode 在JSON-LD中查找@type为Recipe的节点，支持数组和@graph
+func findRecipeNode(data interface{}) map[string]interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if node := findRecipeNode(item); node != nil {
+				return node
+			}
+		}
+	case map[string]interface{}:
+		if isRecipeType(v["@type"]) {
+			return v
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findRecipeNode(graph)
+		}
+	}
+	return nil
+}
+
+// isRecipeType 判断@type是否包含Recipe
+func isRecipeType(t interface{}) bool {
+	switch v := t.(type) {
+	case string:
+		return v == "Recipe"
+	case []interface{}:
+		for _, item := range v {
+			if s, _ := item.(string); s == "Recipe" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonLDText 取JSON-LD字段的文本：字符串、数字，或对象的name/text/url，数组取第一个
+func jsonLDText(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return cleanHTMLText(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case []interface{}:
+		for _, item := range val {
+			if text := jsonLDText(item); text != "" {
+				return text
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"name", "text", "url"} {
+			if text := jsonLDText(val[key]); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLDSteps 展开recipeInstructions：字符串、HowToStep列表或带分组的HowToSection
+func jsonLDSteps(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return splitLines(cleanHTMLText(val))
+	case []interface{}:
+		var steps []string
+		for _, item := range val {
+			steps = append(steps, jsonLDSteps(item)...)
+		}
+		return steps
+	case map[string]interface{}:
+		if items, ok := val["itemListElement"]; ok {
+			return jsonLDSteps(items)
+		}
+		if text := jsonLDText(val["text"]); text != "" {
+			return []string{text}
+		}
+		if text := jsonLDText(val["name"]); text != "" {
+			return []string{text}
+		}
+	}
+	return nil
+}
+
+// recipeFromJSONLD 从JSON-LD的Recipe节点构建菜谱
+func recipeFromJSONLD(node map[string]interface{}) *recipe {
+	r := &recipe{
+		Name:        jsonLDText(node["name"]),
+		Description: jsonLDText(node["description"]),
+		Author:      jsonLDText(node["author"]),
+		Image:       jsonLDText(node["image"]),
+		Yield:       jsonLDText(node["recipeYield"]),
+		PrepTime:    jsonLDText(node["prepTime"]),
+		CookTime:    jsonLDText(node["cookTime"]),
+		TotalTime:   jsonLDText(node["totalTime"]),
+		Steps:       jsonLDSteps(node["recipeInstructions"]),
+	}
+	ingredients := node["recipeIngredient"]
+	if ingredients == nil {
+		ingredients = node["ingredients"]
+	}
+	if list, ok := ingredients.([]interface{}); ok {
+		for _, item := range list {
+			if text := jsonLDText(item); text != "" {
+				r.Ingredients = append(r.Ingredients, text)
+			}
+		}
+	}
+	return r
+}
+
+// recipeFromMicrodata 从itemprop属性中提取菜谱，页面没有声明Recipe类型时返回nil
+func recipeFromMicrodata(page string) *recipe {
+	if !regexp.MustCompile(`(?i)itemtype\s*=\s*["']https?://schema\.org/Recipe["']`).MatchString(page) {
+		return nil
+	}
+	r := &recipe{}
+	for _, loc := range itempropPattern.FindAllStringSubmatchIndex(page, -1) {
+		tag, attrs := page[loc[2]:loc[3]], page[loc[4]:loc[5]]
+		value := ""
+		if m := attrValuePattern.FindStringSubmatch(attrs); m != nil {
+			value = html.UnescapeString(strings.TrimSpace(m[2]))
+		} else if end := strings.Index(strings.ToLower(page[loc[1]:]), "</"+strings.ToLower(tag)); end >= 0 {
+			value = cleanHTMLText(page[loc[1] : loc[1]+end])
+		}
+		if value == "" {
+			continue
+		}
+		for _, prop := range strings.Fields(page[loc[6]:loc[7]]) {
+			switch prop {
+			case "name":
+				if r.Name == "" {
+					r.Name = value
+				}
+			case "description":
+				r.Description = firstNonEmpty(r.Description, value)
+			case "author":
+				r.Author = firstNonEmpty(r.Author, value)
+			case "image":
+				r.Image = firstNonEmpty(r.Image, value)
+			case "recipeYield":
+				r.Yield = firstNonEmpty(r.Yield, value)
+			case "prepTime":
+				r.PrepTime = firstNonEmpty(r.PrepTime, value)
+			case "cookTime":
+				r.CookTime = firstNonEmpty(r.CookTime, value)
+			case "totalTime":
+				r.TotalTime = firstNonEmpty(r.TotalTime, value)
+			case "recipeIngredient", "ingredients":
+				r.Ingredients = append(r.Ingredients, value)
+			case "recipeInstructions":
+				r.Steps = append(r.Steps, splitLines(value)...)
+			}
+		}
+	}
+	if r.Name == "" && len(r.Ingredients) == 0 {
+		return nil
+	}
+	return r
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// cleanHTMLText 去掉HTML标签和实体，块级标签转为换行
+func cleanHTMLText(s string) string {
+	s = breakTagPattern.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+	lines := splitLines(s)
+	return strings.Join(lines, "\n")
+}
+
+// splitLines 按行拆分并去掉空行和行首尾空白
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// formatISODuration 把 PT1H30M 形式的ISO 8601时长显示为 1小时30分钟，无法解析时原样返回
+func formatISODuration(value string) string {
+	m := isoDurationRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if m == nil {
+		return value
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute} {
+		if n, err := strconv.Atoi(m[i+1]); err == nil {
+			d += time.Duration(n) * unit
+		}
+	}
+	if d == 0 {
+		return ""
+	}
+	return formatWorkDuration(d)
+}
+
+// buildRecipeBlocks 生成菜谱笔记：标题、简介、成品图、用时、食材列表和编号步骤
+func buildRecipeBlocks(r *recipe, sourceURL string) []ContentBlock {
+	blocks := []ContentBlock{{Texts: []TextNode{{Text: "🍳 " + r.Name, Bold: true}}}}
+	if r.Description != "" {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: r.Description}}})
+	}
+	if r.Image != "" {
+		blocks = append(blocks, ContentBlock{Type: "file", FileType: "image", SourceType: "url", SourcePath: r.Image})
+	}
+
+	var meta []string
+	if r.Yield != "" {
+		meta = append(meta, "份量: "+r.Yield)
+	}
+	for _, t := range []struct{ label, value string }{{"准备", r.PrepTime}, {"烹饪", r.CookTime}, {"总计", r.TotalTime}} {
+		if d := formatISODuration(t.value); d != "" {
+			meta = append(meta, t.label+": "+d)
+		}
+	}
+	if r.Author != "" {
+		meta = append(meta, "作者: "+r.Author)
+	}
+	if len(meta) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: strings.Join(meta, " · ")}}})
+	}
+
+	if len(r.Ingredients) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "食材", Bold: true}}})
+		for _, ingredient := range r.Ingredients {
+			blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "• " + ingredient}}})
+		}
+	}
+	if len(r.Steps) > 0 {
+		blocks = append(blocks, ContentBlock{Texts: []TextNode{{Text: "步骤", Bold: true}}})
+		for i, step := range r.Steps {
+			blocks = append(blocks, ContentBlock{Texts: []TextNode{
+				{Text: fmt.Sprintf("%d. ", i+1), Bold: true},
+				{Text: step},
+			}})
+		}
+	}
+	blocks = append(blocks, ContentBlock{Texts: []TextNode{
+		{Text: "来源: "},
+		{Text: sourceURL, Link: sourceURL},
+	}})
+	return blocks
+}
+
+// ImportRecipe 从网页导入菜谱
+func ImportRecipe(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	pageURL, _ := args["url"].(string)
+	pageURL = strings.TrimSpace(pageURL)
+	if pageURL == "" {
+		return mcp.NewToolResultText("❌ 网页URL不能为空"), nil
+	}
+	tags := []string{"菜谱"}
+	if tagsStr, _ := args["tags"].(string); tagsStr != "" {
+		if err := json.Unmarshal([]byte(tagsStr), &tags); err != nil {
+			return errorResult("tags格式错误，应为JSON字符串数组", err), nil
+		}
+	}
+	withImage := true
+	if v, ok := args["with_image"].(bool); ok {
+		withImage = v
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	page, err := fetchRemoteBody(ctx, pageURL, maxRecipePageSize)
+	if err != nil {
+		return errorResult("抓取网页失败", err), nil
+	}
+	r, err := parseRecipe(string(page))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if r.Name == "" {
+		r.Name = "未命名菜谱"
+	}
+	// 图片可能是相对路径
+	if r.Image != "" {
+		if base, err := url.Parse(pageURL); err == nil {
+			if ref, err := url.Parse(r.Image); err == nil {
+				r.Image = base.ResolveReference(ref).String()
+			}
+		}
+	}
+	if !withImage {
+		r.Image = ""
+	}
+
+	noteID, err := createNoteFromBlocks(ctx, client, buildRecipeBlocks(r, pageURL), &Settings{Tags: tags})
+	if err != nil {
+		return errorResult("创建菜谱笔记失败", err), nil
+	}
+	if noteID == "" {
+		noteID = "未知ID"
+	} else {
+		sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 菜谱已导入！\n\n菜名: %s\n笔记ID: %s\n食材: %d 项\n步骤: %d 步\n标签: %s",
+		r.Name, noteID, len(r.Ingredients), len(r.Steps), strings.Join(tags, ", "))), nil
+}
+
+// 导入菜谱工具
+var ImportRecipeTool = mcp.NewTool("import_recipe",
+	mcp.WithDescription("从菜谱网页导入菜谱：解析页面中的schema.org Recipe数据（JSON-LD或microdata），生成包含成品图、用时、食材列表和编号步骤的笔记，并附上来源链接。"),
+	mcp.WithString("url",
+		mcp.Required(),
+		mcp.Description("菜谱网页URL"),
+	),
+	mcp.WithString("tags",
+		mcp.Description("标签，JSON字符串数组，默认 [\"菜谱\"]"),
+	),
+	mcp.WithBoolean("with_image",
+		mcp.Description("是否上传成品图，默认true"),
+	),
+)
+
+func importRecipeHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := ImportRecipe(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
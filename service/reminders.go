@@ -0,0 +1,414 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 提醒送达渠道环境变量，都未配置时提醒只能通过due_reminders工具查询
+const (
+	// 提醒到期时POST JSON的地址
+	ReminderWebhookEnvVar = "MOWEN_REMINDER_WEBHOOK"
+	// 提醒到期时执行的命令，参数中的{title}、{message}、{note_id}、{remind_at}替换为提醒内容，
+	// 例如 notify-send 墨问提醒 {message}
+	ReminderCommandEnvVar = "MOWEN_REMINDER_COMMAND"
+	// 检查到期提醒的间隔，默认1分钟
+	ReminderCheckIntervalEnvVar = "MOWEN_REMINDER_CHECK_INTERVAL"
+)
+
+// 送达渠道的超时时间
+const reminderDeliveryTimeout = 10 * time.Second
+
+// Reminder 笔记提醒
+type Reminder struct {
+	ID       int       `json:"id"`
+	TenantID string    `json:"tenant_id"`
+	NoteID   string    `json:"note_id"`
+	RemindAt time.Time `json:"remind_at"`
+	Message  string    `json:"message"`
+	Title    string    `json:"title"` // 笔记标题，送达时从本地记录读取
+}
+
+var reminderDeliveryOnce sync.Once
+
+// SaveReminder 保存提醒，返回提醒ID
+func SaveReminder(tenantID, noteID string, remindAt time.Time, message string) (int64, error) {
+	if err := InitSQLite(); err != nil {
+		return 0, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	var id int64
+	err := execWrite(func(tx *sql.Tx) error {
+		result, err := tx.Exec("INSERT INTO reminders (tenant_id, note_id, remind_at, message) VALUES (?, ?, ?, ?)",
+			tenantID, noteID, remindAt.UTC().Format(sqliteTimeLayout), message)
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("保存提醒失败: %v", err)
+	}
+	return id, nil
+}
+
+// ListReminders 查询尚未送达的提醒，dueOnly为true时只返回已到期的
+// tenantID为nil时查询所有租户，用于后台送达
+func ListReminders(tenantID *string, dueOnly bool, limit int) ([]Reminder, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	query := "SELECT id, tenant_id, note_id, remind_at, message FROM reminders WHERE delivered_at IS NULL"
+	var args []interface{}
+	if tenantID != nil {
+		query += " AND tenant_id = ?"
+		args = append(args, *tenantID)
+	}
+	if dueOnly {
+		query += " AND remind_at <= ?"
+		args = append(args, time.Now().UTC().Format(sqliteTimeLayout))
+	}
+	query += " ORDER BY remind_at, id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := sqliteDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+	defer rows.Close()
+
+	var reminders []Reminder
+	for rows.Next() {
+		var reminder Reminder
+		var remindAt string
+		if err = rows.Scan(&reminder.ID, &reminder.TenantID, &reminder.NoteID, &remindAt, &reminder.Message); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		if reminder.RemindAt, err = parseDBTime(remindAt); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return reminders, nil
+}
+
+// MarkRemindersDelivered 标记提醒已送达
+func MarkRemindersDelivered(ids []int) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	now := time.Now().UTC().Format(sqliteTimeLayout)
+	return execWrite(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if _, err := tx.Exec("UPDATE reminders SET delivered_at = ? WHERE id = ?", now, id); err != nil {
+				return fmt.Errorf("更新提醒失败: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// 提醒时间参数支持的绝对时间格式，不带时区的按本地时间处理
+var remindTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// parseRemindTime 解析提醒时间：绝对时间、只有日期（当天9点）或相对时长，例如 2h30m、3d
+func parseRemindTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range remindTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.Add(9 * time.Hour), nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("时间格式错误，应为 YYYY-MM-DD HH:MM（本地时间）、RFC3339，或相对时长如 30m、2h、3d")
+}
+
+// reminderTitle 从本地记录读取笔记标题，没有记录时返回空字符串
+func reminderTitle(tenantID, noteID string) string {
+	record, err := GetNoteCached(tenantID, noteID)
+	if err != nil {
+		return ""
+	}
+	return noteTitle(record.Content)
+}
+
+// startReminderDelivery 配置了送达渠道时启动后台任务，定期送达到期的提醒
+func startReminderDelivery() {
+	reminderDeliveryOnce.Do(func() {
+		if envString(ReminderWebhookEnvVar, "") == "" && envString(ReminderCommandEnvVar, "") == "" {
+			return
+		}
+		interval := envDuration(ReminderCheckIntervalEnvVar, time.Minute)
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				deliverDueReminders()
+			}
+		}()
+	})
+}
+
+// deliverDueReminders 送达所有到期的提醒，失败的提醒下次检查时重试
+func deliverDueReminders() {
+	reminders, err := ListReminders(nil, true, 100)
+	if err != nil {
+		logger.Warnf("查询到期提醒失败: %v", err)
+		return
+	}
+
+	var delivered []int
+	for _, reminder := range reminders {
+		reminder.Title = reminderTitle(reminder.TenantID, reminder.NoteID)
+		if err := deliverReminder(reminder); err != nil {
+			logger.Warnf("送达提醒失败，id: %d, noteID: %s, error: %v", reminder.ID, reminder.NoteID, err)
+			continue
+		}
+		delivered = append(delivered, reminder.ID)
+	}
+	if len(delivered) > 0 {
+		if err := MarkRemindersDelivered(delivered); err != nil {
+			logger.Warnf("标记提醒已送达失败: %v", err)
+		}
+	}
+}
+
+// deliverReminder 通过配置的渠道送达一条提醒
+// 任一渠道送达即视为已送达，避免已成功的渠道在下次检查时重复推送；只有所有渠道都失败时才返回错误
+func deliverReminder(reminder Reminder) error {
+	var errs []string
+	delivered := false
+	if url := envString(ReminderWebhookEnvVar, ""); url != "" {
+		if err := deliverReminderWebhook(url, reminder); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			delivered = true
+		}
+	}
+	if command := envString(ReminderCommandEnvVar, ""); command != "" {
+		if err := deliverReminderCommand(command, reminder); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			delivered = true
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	if delivered {
+		logger.Warnf("提醒部分渠道送达失败，id: %d, noteID: %s, error: %s", reminder.ID, reminder.NoteID, strings.Join(errs, "; "))
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// deliverReminderWebhook 把提醒POST到webhook
+func deliverReminderWebhook(url string, reminder Reminder) error {
+	body, _ := json.Marshal(reminder)
+	client := &http.Client{Timeout: reminderDeliveryTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deliverReminderCommand 执行本地命令送达提醒，参数中的占位符替换为提醒内容
+func deliverReminderCommand(command string, reminder Reminder) error {
+	message := reminder.Message
+	if message == "" {
+		message = reminder.Title
+	}
+	replacer := strings.NewReplacer(
+		"{title}", reminder.Title,
+		"{message}", message,
+		"{note_id}", reminder.NoteID,
+		"{remind_at}", reminder.RemindAt.Local().Format("2006-01-02 15:04"),
+	)
+	fields := strings.Fields(command)
+	args := make([]string, len(fields)-1)
+	for i, field := range fields[1:] {
+		args[i] = replacer.Replace(field)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reminderDeliveryTimeout)
+	defer cancel()
+	if output, err := exec.CommandContext(ctx, fields[0], args...).CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("%s: %s", fields[0], msg)
+		}
+		return fmt.Errorf("%s: %w", fields[0], err)
+	}
+	return nil
+}
+
+// SetReminder 为笔记设置提醒
+func SetReminder(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	remindAtStr, _ := args["remind_at"].(string)
+	if remindAtStr == "" {
+		return mcp.NewToolResultText("❌ remind_at不能为空"), nil
+	}
+	now := time.Now()
+	remindAt, err := parseRemindTime(remindAtStr, now)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if !remindAt.After(now) {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 提醒时间 %s 已经过去", remindAt.Format("2006-01-02 15:04"))), nil
+	}
+	message, _ := args["message"].(string)
+
+	id, err := SaveReminder(tenantFromContext(ctx), noteID, remindAt, strings.TrimSpace(message))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	channels := []string{"due_reminders工具"}
+	if envString(ReminderWebhookEnvVar, "") != "" {
+		channels = append(channels, "webhook")
+	}
+	if envString(ReminderCommandEnvVar, "") != "" {
+		channels = append(channels, "通知命令")
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 提醒已设置！\n\n提醒ID: %d\n笔记ID: %s\n提醒时间: %s\n送达方式: %s",
+		id, noteID, remindAt.Format("2006-01-02 15:04 (MST)"), strings.Join(channels, "、"))), nil
+}
+
+// DueReminders 列出已到期尚未送达的提醒，并标记为已送达
+func DueReminders(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	tenantID := tenantFromContext(ctx)
+	upcoming, _ := args["include_upcoming"].(bool)
+
+	reminders, err := ListReminders(&tenantID, !upcoming, 100)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	now := time.Now()
+	var due, pending []Reminder
+	for _, reminder := range reminders {
+		reminder.Title = reminderTitle(reminder.TenantID, reminder.NoteID)
+		if reminder.RemindAt.After(now) {
+			pending = append(pending, reminder)
+		} else {
+			due = append(due, reminder)
+		}
+	}
+	if len(due) == 0 && len(pending) == 0 {
+		return mcp.NewToolResultText("📅 没有到期的提醒"), nil
+	}
+
+	var sb strings.Builder
+	writeReminder := func(reminder Reminder) {
+		sb.WriteString(fmt.Sprintf("**#%d** %s  笔记ID: %s\n", reminder.ID, reminder.RemindAt.Local().Format("2006-01-02 15:04"), reminder.NoteID))
+		if reminder.Title != "" {
+			sb.WriteString(fmt.Sprintf("标题: %s\n", reminder.Title))
+		}
+		if reminder.Message != "" {
+			sb.WriteString(fmt.Sprintf("内容: %s\n", reminder.Message))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(due) > 0 {
+		sb.WriteString(fmt.Sprintf("🔥 到期的提醒 %d 条:\n\n", len(due)))
+		ids := make([]int, 0, len(due))
+		for _, reminder := range due {
+			writeReminder(reminder)
+			ids = append(ids, reminder.ID)
+		}
+		if err := MarkRemindersDelivered(ids); err != nil {
+			sb.WriteString(fmt.Sprintf("❌ 标记提醒已送达失败，下次查询会再次返回: %v\n\n", err))
+		}
+	} else {
+		sb.WriteString("📅 没有到期的提醒\n\n")
+	}
+	if len(pending) > 0 {
+		sb.WriteString(fmt.Sprintf("📅 尚未到期的提醒 %d 条:\n\n", len(pending)))
+		for _, reminder := range pending {
+			writeReminder(reminder)
+		}
+	}
+	return mcp.NewToolResultText(strings.TrimRight(sb.String(), "\n")), nil
+}
+
+// 设置提醒工具
+var SetReminderTool = mcp.NewTool("set_reminder",
+	mcp.WithDescription("为笔记设置提醒，例如\"周五提醒我看这篇笔记\"。到期后通过配置的webhook或通知命令送达，也可以用due_reminders查询。"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记"),
+	),
+	mcp.WithString("remind_at",
+		mcp.Required(),
+		mcp.Description("提醒时间：YYYY-MM-DD HH:MM（本地时间）、只有日期时为当天9点、RFC3339，或相对时长如 30m、2h、3d"),
+	),
+	mcp.WithString("message",
+		mcp.Description("提醒内容，例如要做的事情"),
+	),
+)
+
+// 到期提醒工具
+var DueRemindersTool = mcp.NewTool("due_reminders",
+	mcp.WithDescription("列出已到期的笔记提醒，返回后标记为已送达，不会重复返回。适合在对话开始时调用，检查是否有需要处理的提醒。"),
+	mcp.WithBoolean("include_upcoming",
+		mcp.Description("为true时同时列出尚未到期的提醒（不标记），默认false"),
+	),
+)
+
+func setReminderHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return SetReminder(ctx, request)
+}
+
+func dueRemindersHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return DueReminders(ctx, request)
+}
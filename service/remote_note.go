@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mowenDocumentToBlocks 将墨问API标准格式的文档转换回简化格式的内容块
+// 内容块之间的空段落会被去掉，分隔线和待办按创建时的显示方式还原，无法识别的节点类型返回在unknown中
+func mowenDocumentToBlocks(doc *MowenDocument) (blocks []ContentBlock, unknown []string) {
+	for _, node := range doc.Content {
+		block, ok := mowenNodeToBlock(node)
+		if !ok {
+			unknown = append(unknown, node.Type)
+			continue
+		}
+		if block != nil {
+			blocks = append(blocks, *block)
+		}
+	}
+	return blocks, unknown
+}
+
+// mowenNodeToBlock 转换单个节点，空段落返回nil，无法识别的节点返回false
+func mowenNodeToBlock(node MowenContentNode) (*ContentBlock, bool) {
+	switch node.Type {
+	case "paragraph":
+		texts := mowenTextsToTexts(node.Content)
+		if len(texts) == 0 {
+			return nil, true
+		}
+		// 以勾选框开头的段落是待办
+		if first := texts[0]; !first.Bold && !first.Highlight && first.Link == "" {
+			for _, mark := range []string{todoUncheckedMark, todoCheckedMark} {
+				if strings.HasPrefix(first.Text, mark) {
+					texts[0].Text = strings.TrimPrefix(first.Text, mark)
+					if texts[0].Text == "" {
+						texts = texts[1:]
+					}
+					return &ContentBlock{Type: "todo", Texts: texts, Checked: mark == todoCheckedMark}, true
+				}
+			}
+		}
+		return &ContentBlock{Texts: texts}, true
+
+	case "quote":
+		block := mowenQuoteToBlock(node)
+		return &block, true
+
+	case "note":
+		noteID, _ := node.Attrs["uuid"].(string)
+		return &ContentBlock{Type: "note", NoteID: noteID}, true
+
+	case "image", "audio", "pdf":
+		block := ContentBlock{Type: "file", FileType: node.Type}
+		for key, value := range node.Attrs {
+			switch key {
+			case "uuid", "audio-uuid":
+				block.FileID, _ = value.(string)
+			case "show-note":
+				block.setMetadata("show_note", value)
+			default:
+				block.setMetadata(key, value)
+			}
+		}
+		return &block, true
+	}
+	return nil, false
+}
+
+// setMetadata 设置内容块的元数据
+func (b *ContentBlock) setMetadata(key string, value interface{}) {
+	if b.Metadata == nil {
+		b.Metadata = make(map[string]interface{})
+	}
+	b.Metadata[key] = value
+}
+
+// mowenQuoteToBlock 转换引用节点：第一个段落是texts，后续段落是paragraphs，嵌套引用是children
+func mowenQuoteToBlock(node MowenContentNode) ContentBlock {
+	block := ContentBlock{Type: "quote", Texts: mowenTextsToTexts(node.Content)}
+	for _, child := range node.Blocks {
+		switch child.Type {
+		case "quote":
+			block.Children = append(block.Children, mowenQuoteToBlock(child))
+		default:
+			texts := mowenTextsToTexts(child.Content)
+			if len(texts) == 0 {
+				continue
+			}
+			if len(block.Texts) == 0 {
+				block.Texts = texts
+			} else {
+				block.Paragraphs = append(block.Paragraphs, texts)
+			}
+		}
+	}
+	return block
+}
+
+// mowenTextsToTexts 将墨问文本节点的标记还原为加粗、高亮和链接
+func mowenTextsToTexts(nodes []MowenTextNode) []TextNode {
+	texts := make([]TextNode, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Text == "" {
+			continue
+		}
+		text := TextNode{Text: node.Text}
+		for _, mark := range node.Marks {
+			switch mark.Type {
+			case "bold":
+				text.Bold = true
+			case "highlight":
+				text.Highlight = true
+			case "link":
+				text.Link, _ = mark.Attrs["href"].(string)
+			}
+		}
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+// noteReadResult 读取笔记的结果
+type noteReadResult struct {
+	Record   *NoteRecord
+	CachedAt time.Time
+	Source   string   // 本地缓存或墨问
+	Unknown  []string // 从墨问拉取时无法识别的节点类型
+	FetchErr error    // 从墨问拉取失败、退回本地内容时的错误
+}
+
+// readNote 读取笔记内容
+// 本地记录在过期时长内与墨问核对过时直接返回本地内容，否则从墨问拉取；拉取失败时退回本地内容，错误记录在FetchErr中
+func readNote(ctx context.Context, noteID string, refresh bool) (*noteReadResult, error) {
+	tenantID := tenantFromContext(ctx)
+	result := &noteReadResult{Source: "本地缓存"}
+	if local, err := GetNoteCached(tenantID, noteID); err == nil && !isNotePruned(tenantID, noteID) {
+		result.Record, result.CachedAt = local, noteCachedAt(tenantID, local)
+	}
+	if !refresh && result.Record != nil && !noteCacheStale(result.CachedAt) {
+		return result, nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err == nil {
+		var fresh *NoteRecord
+		if fresh, result.Unknown, err = refreshNoteRecord(ctx, client, noteID); err == nil {
+			result.Record, result.CachedAt, result.Source = fresh, time.Now(), "墨问"
+			return result, nil
+		}
+	}
+	if result.Record == nil {
+		return nil, err
+	}
+	result.FetchErr = err
+	return result, nil
+}
+
+// GetNote 获取笔记的完整内容，转换为简化格式的内容块
+// 本地记录在过期时长内与墨问核对过时直接返回本地内容，否则从墨问拉取；拉取失败时退回本地内容并提示可能过期
+func GetNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	noteID, ok := resolveNoteID(ctx, args)
+	if !ok {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空，请传入note_id或先调用set_current_note"), nil
+	}
+	refresh, _ := args["refresh"].(bool)
+
+	read, err := readNote(ctx, noteID, refresh)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	record, unknown := read.Record, read.Unknown
+
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(record.Content), &blocks); err != nil {
+		return errorResult("解析笔记内容失败", err), nil
+	}
+	data, _ := json.MarshalIndent(blocks, "", "  ")
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 笔记 %s\n\n", noteID))
+	sb.WriteString(fmt.Sprintf("标题: %s\n", noteTitle(record.Content)))
+	sb.WriteString(fmt.Sprintf("来源: %s\n", read.Source))
+	sb.WriteString(cacheStatusLines(read.CachedAt))
+	if read.FetchErr != nil {
+		sb.WriteString(fmt.Sprintf("⚠️ 从墨问拉取失败，返回的是本地内容: %v\n", read.FetchErr))
+	}
+	sb.WriteString(fmt.Sprintf("段落数: %d\n", len(blocks)))
+	if len(unknown) > 0 {
+		sb.WriteString(fmt.Sprintf("⚠️ 有 %d 个无法识别的节点已跳过（%s），用edit_note整篇写回时这些内容会丢失\n", len(unknown), strings.Join(unknown, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf("\n内容:\n%s\n", notePlainText(record.Content)))
+	sb.WriteString(fmt.Sprintf("\n内容块JSON（可直接用于edit_note的paragraphs）:\n%s\n", data))
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 获取笔记工具
+var GetNoteTool = mcp.NewTool("get_note",
+	mcp.WithDescription("获取笔记的完整内容，转换为与create_note、edit_note相同的内容块格式返回。编辑已有笔记前先用它读取当前内容，也适用于在网页或App中创建、修改过的笔记。本地记录近期与墨问核对过时直接返回本地内容，否则从墨问拉取并更新本地记录"),
+	mcp.WithString("note_id",
+		mcp.Description("笔记ID，不传时使用当前笔记（见set_current_note）"),
+	),
+	mcp.WithBoolean("refresh",
+		mcp.Description("为true时忽略本地记录，总是从墨问拉取最新内容"),
+	),
+)
+
+func getNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := GetNote(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
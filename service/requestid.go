@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// requestIDArgKey 注入到工具参数中的请求ID键名，由适配器取出放入上下文
+const requestIDArgKey = "__mowen_request_id"
+
+// requestIDContextKey 上下文中保存请求ID的键
+type requestIDContextKey struct{}
+
+// newRequestID 生成一次工具调用的请求ID
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestIDFromContext 从上下文中获取请求ID，没有时返回空字符串
+func requestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDContextKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// addTool 注册工具，每次调用生成请求ID，贯穿日志、API请求头和错误结果，失败结果附带错误码和限流时的重试建议
+// 结果按plain_output参数或配置转换为不带表情符号的纯文本
+// params为各工具共用的参数，如withDebugParam、withUploadRateParam，统一在注册时补充到工具定义中
+func addTool(s *server.MCPServer, tool mcp.Tool, handler server.ToolHandlerFunc, params ...func(mcp.Tool) mcp.Tool) {
+	tool = withPlainOutputParam(tool)
+	for _, param := range params {
+		tool = param(tool)
+	}
+	s.AddTool(tool, func(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+		if arguments == nil {
+			arguments = make(map[string]interface{})
+		}
+		plain := takePlainOutputArg(arguments)
+		requestID := newRequestID()
+		arguments[requestIDArgKey] = requestID
+		logCtx := context.WithValue(context.Background(), requestIDContextKey{}, requestID)
+		start := time.Now()
+
+		result, err := handler(arguments)
+		elapsed := time.Since(start)
+		// 遇到限流时提示调用方等待多久再重试，部分成功的批量结果也在元数据中带上
+		warnings := takeResultWarnings(requestID)
+		hint := ""
+		retryAfter, throttled := takeRetryAfter(requestID)
+		if throttled {
+			hint = retryAfterText(retryAfter)
+		}
+		if err != nil {
+			logger.CtxErrorf(logCtx, "工具 %s 调用失败，耗时 %v: %v", tool.Name, elapsed, err)
+			return result, fmt.Errorf("%w（错误码: %s，请求ID: %s%s）", err, errorCodeOf(err), requestID, hint)
+		}
+		if result != nil {
+			// 钩子等深层逻辑记录的警告，例如内容中检测到的敏感信息
+			appendResultWarnings(result, warnings)
+			if throttled {
+				setResultMeta(result, retryAfterMetaKey, retryAfterSeconds(retryAfter))
+			}
+			if message, failed := errorResultText(result); failed {
+				code := annotateErrorResult(result, message, requestID, hint)
+				logger.CtxWarnf(logCtx, "工具 %s 返回错误 %s，耗时 %v: %s", tool.Name, code, elapsed, message)
+			}
+			if plain {
+				plainResult(result)
+			}
+		}
+		logger.CtxDebugf(logCtx, "工具 %s 调用完成，耗时 %v", tool.Name, elapsed)
+		return result, nil
+	})
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 候选笔记预览的长度（字符数）
+const resolvePreviewRunes = 80
+
+// 第一名的得分领先第二名超过该差值时视为可以直接使用
+const resolveConfidentGap = 0.15
+
+// 描述中指代笔记本身的词，不参与内容匹配
+var resolveFillerWords = map[string]bool{
+	"笔记": true, "那篇": true, "这篇": true, "一篇": true, "关于": true, "有关": true, "记录": true,
+	"note": true, "notes": true, "about": true, "my": true, "the": true, "on": true, "of": true,
+}
+
+// resolveDateRange 描述中的时间范围，To不包含在内
+type resolveDateRange struct {
+	Label    string
+	From, To time.Time
+}
+
+// resolveDatePatterns 描述中可识别的时间说法，较长的说法放在前面，避免"day before yesterday"被识别为昨天
+var resolveDatePatterns = []struct {
+	Pattern *regexp.Regexp
+	Label   string
+	Range   func(today time.Time, n int) (time.Time, time.Time)
+}{
+	{regexp.MustCompile(`(?i)(?:最近|过去|近)\s*(\d+)\s*天|last\s+(\d+)\s+days`), "最近%d天", func(today time.Time, n int) (time.Time, time.Time) {
+		return today.AddDate(0, 0, -(n - 1)), today.AddDate(0, 0, 1)
+	}},
+	{regexp.MustCompile(`(?i)前天|day\s+before\s+yesterday`), "前天", func(today time.Time, _ int) (time.Time, time.Time) {
+		return today.AddDate(0, 0, -2), today.AddDate(0, 0, -1)
+	}},
+	{regexp.MustCompile(`(?i)昨天|昨日|yesterday`), "昨天", func(today time.Time, _ int) (time.Time, time.Time) {
+		return today.AddDate(0, 0, -1), today
+	}},
+	{regexp.MustCompile(`(?i)今天|今日|today`), "今天", func(today time.Time, _ int) (time.Time, time.Time) {
+		return today, today.AddDate(0, 0, 1)
+	}},
+	{regexp.MustCompile(`(?i)上周|上个?星期|上个?礼拜|last\s+week`), "上周", func(today time.Time, _ int) (time.Time, time.Time) {
+		start := startOfWeek(today).AddDate(0, 0, -7)
+		return start, start.AddDate(0, 0, 7)
+	}},
+	{regexp.MustCompile(`(?i)本周|这周|这个?星期|这个?礼拜|this\s+week`), "本周", func(today time.Time, _ int) (time.Time, time.Time) {
+		return startOfWeek(today), startOfWeek(today).AddDate(0, 0, 7)
+	}},
+	{regexp.MustCompile(`(?i)上个?月|last\s+month`), "上月", func(today time.Time, _ int) (time.Time, time.Time) {
+		start := time.Date(today.Year(), today.Month()-1, 1, 0, 0, 0, 0, today.Location())
+		return start, start.AddDate(0, 1, 0)
+	}},
+	{regexp.MustCompile(`(?i)本月|这个月|this\s+month`), "本月", func(today time.Time, _ int) (time.Time, time.Time) {
+		start := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return start, start.AddDate(0, 1, 0)
+	}},
+}
+
+// startOfWeek 本周一零点，与search_note的本周、上周一致
+func startOfWeek(today time.Time) time.Time {
+	weekday := int(today.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return today.AddDate(0, 0, -(weekday - 1))
+}
+
+// parseResolveDate 识别描述中的第一个时间说法，返回时间范围和去掉时间说法后的描述
+func parseResolveDate(description string, now time.Time) (*resolveDateRange, string) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, p := range resolveDatePatterns {
+		match := p.Pattern.FindStringSubmatchIndex(description)
+		if match == nil {
+			continue
+		}
+		n, label := 1, p.Label
+		for i := 2; i+1 < len(match); i += 2 {
+			if match[i] >= 0 {
+				n, _ = strconv.Atoi(description[match[i]:match[i+1]])
+				n = max(n, 1)
+				label = fmt.Sprintf(p.Label, n)
+				break
+			}
+		}
+		from, to := p.Range(today, n)
+		rest := description[:match[0]] + " " + description[match[1]:]
+		return &resolveDateRange{Label: label, From: from, To: to}, rest
+	}
+	return nil, description
+}
+
+// contains 时间是否在范围内
+func (r *resolveDateRange) contains(t time.Time) bool {
+	return !t.IsZero() && !t.Before(r.From) && t.Before(r.To)
+}
+
+// resolveTerms 把描述分词为参与匹配的词，去掉停用词、指代笔记的词、单个字母和单个虚字
+func resolveTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range segmentText(text) {
+		runes := []rune(token)
+		if seen[token] || keywordStopwords[token] || resolveFillerWords[token] ||
+			(len(runes) == 1 && (!unicode.Is(unicode.Han, runes[0]) || strings.ContainsRune(keywordEdgeParticles, runes[0]))) {
+			continue
+		}
+		seen[token] = true
+		terms = append(terms, token)
+	}
+	return terms
+}
+
+// resolveCandidate 候选笔记及其得分
+type resolveCandidate struct {
+	Record  NoteRecord
+	Title   string
+	Text    string
+	Tags    []string
+	Created time.Time
+	Updated time.Time
+	Matched []string
+	Score   float64
+}
+
+// scoreResolveCandidate 计算候选笔记与描述的相关度，取值0~1
+// 词命中标题或标签计满分，只命中正文计0.7，再与标题的模糊相似度加权合并
+func scoreResolveCandidate(c *resolveCandidate, description string, terms []string) {
+	if len(terms) == 0 {
+		return
+	}
+	title := strings.ToLower(c.Title)
+	text := strings.ToLower(c.Text)
+	tags := strings.ToLower(strings.Join(c.Tags, " "))
+
+	hits := 0.0
+	for _, term := range terms {
+		switch {
+		case strings.Contains(title, term) || strings.Contains(tags, term):
+			hits++
+		case strings.Contains(text, term):
+			hits += 0.7
+		default:
+			continue
+		}
+		c.Matched = append(c.Matched, term)
+	}
+	coverage := hits / float64(len(terms))
+	c.Score = 0.75*coverage + 0.25*fuzzyScore(description, c.Title)
+}
+
+// resolvePreview 正文预览：从第一个命中的词附近开始截取
+func resolvePreview(text string, matched []string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	start := 0
+	lower := strings.ToLower(text)
+	for _, term := range matched {
+		if i := strings.Index(lower, term); i >= 0 {
+			start = max(len([]rune(lower[:i]))-20, 0)
+			break
+		}
+	}
+	preview := truncateRunes(string(runes[start:]), resolvePreviewRunes)
+	if start > 0 {
+		preview = "…" + preview
+	}
+	return preview
+}
+
+// ResolveNote 根据模糊的描述在本地记录中查找最可能的笔记，返回按相关度排序的候选笔记ID和预览
+// 编辑、追加前先用它确定笔记ID，候选得分接近时应向用户确认
+func ResolveNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	description, _ := args["description"].(string)
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return mcp.NewToolResultText("❌ description不能为空"), nil
+	}
+	limit := 5
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = min(int(v), 20)
+	}
+	setCurrent, _ := args["set_current"].(bool)
+
+	tenantID := tenantFromContext(ctx)
+	dateRange, rest := parseResolveDate(description, time.Now())
+	terms := resolveTerms(rest)
+	if dateRange == nil && len(terms) == 0 {
+		return mcp.NewToolResultText("❌ 描述中没有可用于查找的内容，请补充标题、关键词或时间"), nil
+	}
+
+	records, err := ListLatestNotes(tenantID, maxSearchAllNotes)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	creations, err := ListNoteCreations(tenantID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	created := make(map[string]time.Time, len(creations))
+	for _, c := range creations {
+		created[c.NoteID] = c.CreatedAt
+	}
+	noteTags := make(map[string][]string)
+	if tags, err := ListAllTags(tenantID); err == nil {
+		for tag, noteIDs := range tags {
+			for _, noteID := range noteIDs {
+				noteTags[noteID] = append(noteTags[noteID], tag)
+			}
+		}
+	}
+
+	var candidates []resolveCandidate
+	for _, record := range records {
+		if isNotePruned(tenantID, record.NoteID) {
+			continue
+		}
+		updated, _ := parseDBTime(record.CreatedAt)
+		c := resolveCandidate{
+			Record:  record,
+			Title:   noteTitle(record.Content),
+			Text:    notePlainText(record.Content),
+			Tags:    noteTags[record.NoteID],
+			Created: created[record.NoteID],
+			Updated: updated.Local(),
+		}
+		// 指定了时间时，创建或最后修改时间在范围内的笔记才是候选
+		if dateRange != nil && !dateRange.contains(c.Created) && !dateRange.contains(c.Updated) {
+			continue
+		}
+		scoreResolveCandidate(&c, description, terms)
+		if len(terms) > 0 && len(c.Matched) == 0 {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	var conditions []string
+	if dateRange != nil {
+		conditions = append(conditions, "时间: "+dateRange.Label)
+	}
+	if len(terms) > 0 {
+		conditions = append(conditions, "关键词: "+strings.Join(terms, " "))
+	}
+	if len(candidates) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("📝 没有找到符合描述的笔记（%s）\n\n可以换个说法，或用search_note按日期浏览", strings.Join(conditions, "，"))), nil
+	}
+
+	// 得分相同时较新的笔记在前；只有时间条件时按时间排序
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Updated.After(candidates[j].Updated)
+	})
+	total := len(candidates)
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	confident := len(candidates) == 1 ||
+		(len(terms) > 0 && candidates[0].Score-candidates[1].Score >= resolveConfidentGap)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 找到 %d 篇候选笔记（%s），显示前 %d 篇:\n\n", total, strings.Join(conditions, "，"), len(candidates)))
+	for i, c := range candidates {
+		title := c.Title
+		if title == "" {
+			title = "（无标题）"
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, title))
+		sb.WriteString(fmt.Sprintf("   笔记ID: %s\n", c.Record.NoteID))
+		if len(terms) > 0 {
+			sb.WriteString(fmt.Sprintf("   相关度: %.2f，命中: %s\n", c.Score, strings.Join(c.Matched, " ")))
+		}
+		if !c.Created.IsZero() {
+			sb.WriteString(fmt.Sprintf("   创建: %s，最后修改: %s\n", c.Created.Format("2006-01-02 15:04"), c.Updated.Format("2006-01-02 15:04")))
+		}
+		if len(c.Tags) > 0 {
+			sort.Strings(c.Tags)
+			sb.WriteString(fmt.Sprintf("   标签: %s\n", strings.Join(c.Tags, ", ")))
+		}
+		if preview := resolvePreview(c.Text, c.Matched); preview != "" {
+			sb.WriteString(fmt.Sprintf("   预览: %s\n", preview))
+		}
+		sb.WriteString("\n")
+	}
+
+	if confident {
+		sb.WriteString(fmt.Sprintf("✅ 第1篇明显最符合描述，可直接使用笔记ID %s", candidates[0].Record.NoteID))
+		if setCurrent {
+			sessionFromContext(ctx).SetCurrentNoteID(candidates[0].Record.NoteID)
+			sb.WriteString("，已设为当前笔记")
+		}
+	} else {
+		sb.WriteString("⚠️ 多篇笔记得分接近，编辑或追加前请向用户确认是哪一篇")
+		if setCurrent {
+			sb.WriteString("，未自动设置当前笔记")
+		}
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 查找笔记工具
+var ResolveNoteTool = mcp.NewTool("resolve_note",
+	mcp.WithDescription("根据模糊的描述（例如\"昨天关于定价的会议笔记\"、\"上周的读书笔记\"）在本地记录中查找最可能的笔记，返回按相关度排序的候选笔记ID、标题、时间和预览。调用edit_note、append_to_note等工具前，用户没有给出笔记ID时先用它确定是哪篇笔记"),
+	mcp.WithString("description",
+		mcp.Required(),
+		mcp.Description("对笔记的描述，可以包含时间（今天、昨天、前天、本周、上周、本月、上月、最近N天，也支持英文说法）、标题片段、关键词和标签"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("最多返回的候选数，默认5，最大20"),
+	),
+	mcp.WithBoolean("set_current",
+		mcp.Description("为true时，如果第一篇明显最符合描述，则设为当前笔记（见set_current_note）"),
+	),
+)
+
+func resolveNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ResolveNote(ctx, request)
+}
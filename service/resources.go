@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// 笔记资源URI前缀
+const noteResourcePrefix = "mowen://notes/"
+
+// noteResourceURI 返回笔记对应的资源URI
+func noteResourceURI(noteID string) string {
+	return noteResourcePrefix + noteID
+}
+
+// 笔记内容资源模板
+var NoteResourceTemplate = mcp.NewResourceTemplate(
+	noteResourcePrefix+"{note_id}",
+	"墨问笔记",
+	mcp.WithTemplateDescription("本地保存的墨问笔记内容（简化的内容块JSON格式）。支持订阅，笔记被编辑后会推送资源更新通知。"),
+	mcp.WithTemplateMIMEType("application/json"),
+)
+
+// ReadNoteResource 读取笔记资源
+func ReadNoteResource(ctx context.Context, request mcp.ReadResourceRequest) ([]interface{}, error) {
+	uri := request.Params.URI
+	noteID := strings.TrimPrefix(uri, noteResourcePrefix)
+	if noteID == "" || noteID == uri {
+		return nil, fmt.Errorf("无效的笔记资源URI: %s", uri)
+	}
+
+	record, err := GetNoteCached(tenantFromContext(ctx), noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		mcp.TextResourceContents{
+			ResourceContents: mcp.ResourceContents{
+				URI:      uri,
+				MIMEType: "application/json",
+			},
+			Text: record.Content,
+		},
+	}, nil
+}
+
+// notifyNoteUpdated 通知订阅了该笔记的会话资源已更新
+func notifyNoteUpdated(tenantID, noteID string) {
+	uri := noteResourceURI(noteID)
+	sessions.Range(func(_, value interface{}) bool {
+		session := value.(*Session)
+		if session.TenantID() != tenantID || !session.IsSubscribed(uri) {
+			return true
+		}
+		params := map[string]interface{}{"uri": uri}
+		if err := session.Notify("notifications/resources/updated", params); err != nil {
+			logger.Warnf("推送资源更新通知失败，会话: %s, uri: %s, error: %v", session.ID, uri, err)
+		}
+		return true
+	})
+}
+
+// 适配器函数，将资源读取请求转换为带会话上下文的调用
+func readNoteResourceHandler(request mcp.ReadResourceRequest) ([]interface{}, error) {
+	ctx := contextFromArguments(request.Params.Arguments)
+	return ReadNoteResource(ctx, request)
+}
+
+// RegisterAllResources 注册所有资源，需要服务端开启资源能力
+func RegisterAllResources(s *server.MCPServer) {
+	s.AddResourceTemplate(NoteResourceTemplate, readNoteResourceHandler)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// resultWarnings 按请求ID记录本次工具调用需要告知调用方的警告，由addTool在返回结果前附加到结果文本
+// 钩子等深层逻辑不直接返回结果，日志在stdio模式下调用方看不到，需要通过这里提示
+var (
+	resultWarningsMu sync.Mutex
+	resultWarnings   = make(map[string][]string)
+)
+
+// noteResultWarning 记录一条需要附加到工具结果中的警告，同一次调用中相同的警告只记录一次
+func noteResultWarning(ctx context.Context, warning string) {
+	requestID := requestIDFromContext(ctx)
+	if requestID == "" {
+		return
+	}
+	resultWarningsMu.Lock()
+	defer resultWarningsMu.Unlock()
+	if !slices.Contains(resultWarnings[requestID], warning) {
+		resultWarnings[requestID] = append(resultWarnings[requestID], warning)
+	}
+}
+
+// takeResultWarnings 取出并清除工具调用记录的警告
+func takeResultWarnings(requestID string) []string {
+	resultWarningsMu.Lock()
+	defer resultWarningsMu.Unlock()
+	warnings := resultWarnings[requestID]
+	delete(resultWarnings, requestID)
+	return warnings
+}
+
+// appendResultWarnings 在结果的第一段文本末尾逐行附加警告
+func appendResultWarnings(result *mcp.CallToolResult, warnings []string) {
+	for i, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			for _, warning := range warnings {
+				text.Text += "\n⚠️ " + warning
+			}
+			result.Content[i] = text
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 保留策略环境变量
+const (
+	// 完整内容保留的天数，超过后只保留标题、附件、总结、关键词等元数据，默认0（永久保留）
+	RetentionContentDaysEnvVar = "MOWEN_RETENTION_CONTENT_DAYS"
+	// 历史版本保留的天数，超过后删除被后续编辑覆盖的旧记录，每篇笔记的第一条和最新记录始终保留，默认0（永久保留）
+	RetentionHistoryDaysEnvVar = "MOWEN_RETENTION_HISTORY_DAYS"
+	// 维护任务的执行间隔，格式同 time.ParseDuration，默认24小时
+	MaintenanceIntervalEnvVar = "MOWEN_MAINTENANCE_INTERVAL"
+)
+
+var maintenanceOnce sync.Once
+
+// retentionCutoff 保留天数对应的截止时间（UTC，与数据库格式一致），天数不大于0时不清理
+func retentionCutoff(days int) (string, bool) {
+	if days <= 0 {
+		return "", false
+	}
+	return time.Now().UTC().AddDate(0, 0, -days).Format(sqliteTimeLayout), true
+}
+
+// startMaintenance 启动后台维护任务，未配置任何保留策略时不启动
+func startMaintenance() {
+	maintenanceOnce.Do(func() {
+		if envInt(RetentionContentDaysEnvVar, 0) <= 0 && envInt(RetentionHistoryDaysEnvVar, 0) <= 0 {
+			return
+		}
+		interval := envDuration(MaintenanceIntervalEnvVar, 24*time.Hour)
+		if interval <= 0 {
+			interval = 24 * time.Hour
+		}
+		go func() {
+			runMaintenance()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				runMaintenance()
+			}
+		}()
+	})
+}
+
+// runMaintenance 执行一次保留策略
+func runMaintenance() {
+	if cutoff, ok := retentionCutoff(envInt(RetentionHistoryDaysEnvVar, 0)); ok {
+		deleted, err := pruneNoteHistory(cutoff)
+		if err != nil {
+			logger.Warnf("清理历史版本失败: %v", err)
+		} else if deleted > 0 {
+			logger.Infof("已清理 %d 条 %s 之前的历史版本", deleted, cutoff)
+		}
+	}
+	if cutoff, ok := retentionCutoff(envInt(RetentionContentDaysEnvVar, 0)); ok {
+		pruned, err := pruneNoteContent(cutoff)
+		if err != nil {
+			logger.Warnf("清理笔记内容失败: %v", err)
+		} else if pruned > 0 {
+			logger.Infof("已清理 %d 条 %s 之前的笔记内容，保留元数据", pruned, cutoff)
+		}
+	}
+}
+
+// pruneNoteHistory 删除截止时间之前、已被后续编辑覆盖的旧记录
+// 每篇笔记的第一条非拉取记录保存着创建时间，与最新记录一样始终保留
+func pruneNoteHistory(cutoff string) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %[1]s WHERE created_at < ?
+		AND id NOT IN (SELECT MAX(id) FROM %[1]s GROUP BY tenant_id, note_id
+			UNION SELECT MIN(id) FROM %[1]s WHERE fetched = 0 GROUP BY tenant_id, note_id)`, dbTable)
+	result, err := sqliteDB.Exec(query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// pruneNoteContent 把截止时间之前的记录替换为只含元数据的内容
+// 总结、关键词和全文索引不变，清理后仍可以搜索到这些笔记
+func pruneNoteContent(cutoff string) (int, error) {
+	query := fmt.Sprintf(`SELECT id, tenant_id, note_id, content, summary FROM %s
+		WHERE pruned = 0 AND created_at < ?`, dbTable)
+	rows, err := sqliteDB.Query(query, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("查询失败: %v", err)
+	}
+	var records []NoteRecord
+	for rows.Next() {
+		var record NoteRecord
+		if err = rows.Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		records = append(records, record)
+	}
+	rows.Close()
+
+	update := fmt.Sprintf("UPDATE %s SET content = ?, summary = ?, pruned = 1 WHERE id = ?", dbTable)
+	for _, record := range records {
+		var blocks []ContentBlock
+		if err = json.Unmarshal([]byte(record.Content), &blocks); err != nil {
+			continue
+		}
+		summary := record.Summary
+		if summary == "" {
+			summary = firstNSummary(blocksText(blocks), summaryLength())
+		}
+		content, err := json.Marshal(metadataBlocks(blocks))
+		if err != nil {
+			continue
+		}
+		if _, err = sqliteDB.Exec(update, string(content), summary, record.ID); err != nil {
+			return 0, fmt.Errorf("更新记录失败: %v", err)
+		}
+		InvalidateNote(record.TenantID, record.NoteID)
+	}
+	return len(records), nil
+}
+
+// metadataBlocks 只保留标题段落和附件，附件只包含文件ID等引用信息
+func metadataBlocks(blocks []ContentBlock) []ContentBlock {
+	var kept []ContentBlock
+	titleKept := false
+	for _, block := range blocks {
+		switch {
+		case block.Type == "file":
+			kept = append(kept, block)
+		case !titleKept:
+			var sb strings.Builder
+			for _, text := range block.Texts {
+				sb.WriteString(text.Text)
+			}
+			if title := strings.TrimSpace(sb.String()); title != "" {
+				kept = append(kept, ContentBlock{Texts: []TextNode{{Text: title}}})
+				titleKept = true
+			}
+		}
+	}
+	return kept
+}
+
+// isNotePruned 笔记的最新记录是否已按保留策略清理了内容
+func isNotePruned(tenantID, noteID string) bool {
+	query := fmt.Sprintf(`SELECT pruned FROM %s WHERE tenant_id = ? AND note_id = ?
+		ORDER BY id DESC LIMIT 1`, dbTable)
+	var pruned bool
+	if err := sqliteDB.QueryRow(query, tenantID, noteID).Scan(&pruned); err != nil {
+		return false
+	}
+	return pruned
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestPruneNoteHistoryKeepsCreation 清理历史版本后笔记的创建时间不变
+func TestPruneNoteHistoryKeepsCreation(t *testing.T) {
+	if err := InitSQLite(); err != nil {
+		t.Skipf("SQLite不可用: %v", err)
+	}
+	tenantID := t.Name()
+	for _, content := range []string{"第一版", "第二版", "第三版"} {
+		if _, err := SaveNoteToSQLite(tenantID, "n1", content, ""); err != nil {
+			t.Fatalf("保存笔记失败: %v", err)
+		}
+	}
+
+	// 三条记录都早于截止时间，创建时间是40天前，之后两次编辑在30天前
+	now := time.Now().UTC()
+	created := now.AddDate(0, 0, -40).Format(sqliteTimeLayout)
+	edited := now.AddDate(0, 0, -30).Format(sqliteTimeLayout)
+	if _, err := sqliteDB.Exec(fmt.Sprintf("UPDATE %s SET created_at = ? WHERE tenant_id = ?", dbTable), edited, tenantID); err != nil {
+		t.Fatalf("修改时间失败: %v", err)
+	}
+	if _, err := sqliteDB.Exec(fmt.Sprintf(`UPDATE %[1]s SET created_at = ? WHERE id = (SELECT MIN(id) FROM %[1]s WHERE tenant_id = ?)`, dbTable),
+		created, tenantID); err != nil {
+		t.Fatalf("修改创建时间失败: %v", err)
+	}
+
+	cutoff, _ := retentionCutoff(10)
+	if _, err := pruneNoteHistory(cutoff); err != nil {
+		t.Fatalf("清理历史版本失败: %v", err)
+	}
+
+	var remaining int
+	if err := sqliteDB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE tenant_id = ?", dbTable), tenantID).Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 2 {
+		t.Errorf("清理后剩余 %d 条记录，期望保留第一条和最新的 2 条", remaining)
+	}
+
+	creations, err := ListNoteCreations(tenantID)
+	if err != nil {
+		t.Fatalf("查询创建时间失败: %v", err)
+	}
+	if len(creations) != 1 {
+		t.Fatalf("返回 %d 条创建记录，期望 1 条", len(creations))
+	}
+	if got := creations[0].CreatedAt.UTC().Format(sqliteTimeLayout); got != created {
+		t.Errorf("清理后创建时间为 %s，期望 %s", got, created)
+	}
+	record, err := SearchByNoteID(tenantID, "n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Content != "第三版" {
+		t.Errorf("最新内容为 %q，期望 %q", record.Content, "第三版")
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryAfterMetaKey 结果元数据中建议重试等待秒数的键名
+const retryAfterMetaKey = "retry_after_seconds"
+
+// 墨问API返回429但没有Retry-After响应头时建议等待的时长
+const defaultRetryAfter = 30 * time.Second
+
+// retryAfterHints 按请求ID记录本次工具调用遇到的限流等待时长，由addTool在返回结果前取出
+var retryAfterHints sync.Map
+
+// parseRetryAfter 解析Retry-After响应头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// noteThrottled 根据响应判断是否被限流并记录等待时长：429总是视为限流，503只在带有Retry-After时视为限流
+func noteThrottled(requestID string, resp *http.Response) {
+	wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests && !ok:
+		wait, ok = defaultRetryAfter, true
+	case resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable:
+		ok = false
+	}
+	if ok {
+		noteRetryAfter(requestID, wait)
+	}
+}
+
+// noteRetryAfter 记录工具调用需要等待后重试，同一次调用多次限流时保留最长的等待时长
+// 本地限流推迟调用时也通过它告知调用方
+func noteRetryAfter(requestID string, wait time.Duration) {
+	if requestID == "" {
+		return
+	}
+	for {
+		previous, loaded := retryAfterHints.LoadOrStore(requestID, wait)
+		if !loaded || previous.(time.Duration) >= wait || retryAfterHints.CompareAndSwap(requestID, previous, wait) {
+			return
+		}
+	}
+}
+
+// takeRetryAfter 取出并清除工具调用记录的等待时长
+func takeRetryAfter(requestID string) (time.Duration, bool) {
+	value, ok := retryAfterHints.LoadAndDelete(requestID)
+	if !ok {
+		return 0, false
+	}
+	return value.(time.Duration), true
+}
+
+// retryAfterSeconds 等待时长向上取整为秒，至少1秒
+func retryAfterSeconds(wait time.Duration) int {
+	return max(int(math.Ceil(wait.Seconds())), 1)
+}
+
+// retryAfterText 错误结果中提示的重试时间
+func retryAfterText(wait time.Duration) string {
+	return fmt.Sprintf("，建议 %d 秒后重试", retryAfterSeconds(wait))
+}
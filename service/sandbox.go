@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 本地文件沙箱目录环境变量，多个目录以逗号或系统路径分隔符分隔
+const AllowedDirsEnvVar = "MOWEN_ALLOWED_DIRS"
+
+// 向客户端获取根目录的超时时间
+const clientRootsTimeout = 5 * time.Second
+
+var (
+	sandboxDirs     []string
+	sandboxDirsOnce sync.Once
+)
+
+// allowedDirs 返回规范化后的沙箱目录列表
+func allowedDirs() []string {
+	sandboxDirsOnce.Do(func() {
+		for _, dir := range envList(AllowedDirsEnvVar) {
+			abs, err := canonicalPath(expandPath(context.Background(), dir))
+			if err != nil {
+				logger.Warnf("忽略无效的沙箱目录 %s: %v", dir, err)
+				continue
+			}
+			sandboxDirs = append(sandboxDirs, abs)
+		}
+	})
+	return sandboxDirs
+}
+
+// clientRoots 返回会话客户端声明的本地根目录，调用方需先确认客户端支持roots
+// 获取成功的结果缓存在会话上；获取失败时返回错误且不缓存，下次访问文件时重新获取
+func clientRoots(ctx context.Context) ([]string, error) {
+	session := sessionFromContext(ctx)
+	if dirs, loaded := session.cachedRoots(); loaded {
+		return dirs, nil
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, clientRootsTimeout)
+	defer cancel()
+	raw, err := session.Request(reqCtx, "roots/list", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	var result mcp.ListRootsResult
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("解析根目录列表失败: %w", err)
+	}
+	var dirs []string
+	for _, root := range result.Roots {
+		dir, err := rootURIPath(root.URI)
+		if err != nil {
+			logger.Warnf("忽略客户端根目录 %s: %v", root.URI, err)
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	session.setRoots(dirs)
+	return dirs, nil
+}
+
+// rootURIPath 把file://形式的根目录URI转换为规范化的本地路径
+func rootURIPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("不是本地目录")
+	}
+	path := u.Path
+	// file:///C:/Users 解析出的路径为 /C:/Users
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return canonicalPath(filepath.FromSlash(path))
+}
+
+// checkClientRoots 客户端声明了roots能力时，路径还必须位于其中之一，与沙箱目录取交集
+// 客户端声明了roots却获取不到或没有本地根目录时拒绝访问，不退化为不限制
+func checkClientRoots(ctx context.Context, resolved, path string) error {
+	session := sessionFromContext(ctx)
+	if !session.SupportsRoots() {
+		return nil
+	}
+	roots, err := clientRoots(ctx)
+	if err != nil {
+		logger.Warnf("获取客户端根目录失败，会话: %s, error: %v", session.ID, err)
+		return withErrorCode(ErrPermissionDenied, fmt.Errorf("无法获取客户端声明的根目录，拒绝访问 %s: %w", path, err))
+	}
+	for _, root := range roots {
+		if isWithinDir(resolved, root) {
+			return nil
+		}
+	}
+	if len(roots) == 0 {
+		return withErrorCode(ErrPermissionDenied, fmt.Errorf("客户端没有声明本地根目录，拒绝访问 %s", path))
+	}
+	return withErrorCode(ErrPermissionDenied, fmt.Errorf("路径 %s 不在客户端声明的根目录中", path))
+}
+
+// canonicalPath 规范化客户端传入的路径，返回绝对路径并解析符号链接，防止通过软链接逃逸沙箱
+func canonicalPath(path string) (string, error) {
+	path, err := normalizeLocalPath(path)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(resolved), nil
+}
+
+// checkLocalPath 校验本地文件路径是否位于沙箱内，返回规范化后的路径
+// 未配置沙箱时：stdio模式不限制，HTTP多租户模式禁止访问本地文件
+// 客户端声明了roots能力时，路径还必须位于其声明的根目录内，获取不到根目录时拒绝访问
+func checkLocalPath(ctx context.Context, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("文件路径不能为空")
+	}
+
+	resolved, err := resolveLocalPath(ctx, path)
+	if err == nil {
+		resolved, err = canonicalPath(resolved)
+	}
+	if err != nil {
+		return "", fmt.Errorf("无法解析文件路径 %s: %w", path, err)
+	}
+
+	dirs := allowedDirs()
+	if len(dirs) == 0 {
+		if sessionFromContext(ctx) != defaultSession {
+			return "", fmt.Errorf("HTTP模式下未配置 %s，禁止访问本地文件", AllowedDirsEnvVar)
+		}
+		if err := checkClientRoots(ctx, resolved, path); err != nil {
+			return "", err
+		}
+		return resolved, nil
+	}
+
+	for _, dir := range dirs {
+		if isWithinDir(resolved, dir) {
+			if err := checkClientRoots(ctx, resolved, path); err != nil {
+				return "", err
+			}
+			return resolved, nil
+		}
+	}
+	return "", withErrorCode(ErrPermissionDenied, fmt.Errorf("文件 %s 不在允许访问的目录中", path))
+}
+
+// isWithinDir 判断路径是否位于目录内（含目录本身）
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// checkWritePath 校验写入目标路径是否位于沙箱内，目标文件可以不存在，根目录的限制同checkLocalPath
+func checkWritePath(ctx context.Context, path string) (string, error) {
+	path, err := resolveLocalPath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := canonicalPath(filepath.Dir(path))
+	if err != nil {
+		return "", fmt.Errorf("目标目录不存在 %s: %w", filepath.Dir(path), err)
+	}
+	resolved := filepath.Join(dir, filepath.Base(path))
+
+	dirs := allowedDirs()
+	if len(dirs) == 0 {
+		if sessionFromContext(ctx) != defaultSession {
+			return "", fmt.Errorf("HTTP模式下未配置 %s，禁止写入本地文件", AllowedDirsEnvVar)
+		}
+		if err := checkClientRoots(ctx, resolved, path); err != nil {
+			return "", err
+		}
+		return resolved, nil
+	}
+
+	for _, allowed := range dirs {
+		if isWithinDir(resolved, allowed) {
+			if err := checkClientRoots(ctx, resolved, path); err != nil {
+				return "", err
+			}
+			return resolved, nil
+		}
+	}
+	return "", withErrorCode(ErrPermissionDenied, fmt.Errorf("路径 %s 不在允许访问的目录中", path))
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// apiUploadFile 直传OSS的上传回调响应，不是墨问API路径，只用于区分响应格式
+const apiUploadFile = "oss:upload"
+
+// schemaField 响应中必须存在的字段，Path为点分隔的字段路径
+type schemaField struct {
+	Path string
+	Kind string // string, object
+}
+
+// apiResponseSchemas 关键接口成功响应的预期格式
+// 只列出本服务实际读取的字段，墨问新增字段不视为变化
+var apiResponseSchemas = map[string][]schemaField{
+	APICreateNote:      {{Path: "noteId", Kind: "string"}},
+	APIGetNote:         {{Path: "note", Kind: "object"}, {Path: "note.body", Kind: "object"}},
+	APIUploadPrepare:   {{Path: "form", Kind: "object"}, {Path: "form.endpoint", Kind: "string"}},
+	APIUploadFileByURL: {{Path: "file", Kind: "object"}, {Path: "file.fileId", Kind: "string"}},
+	apiUploadFile:      {{Path: "file", Kind: "object"}, {Path: "file.fileId", Kind: "string"}},
+}
+
+// schemaDrift 接口响应格式与预期不符的记录
+type schemaDrift struct {
+	Path   string
+	Issues []string
+	Count  int
+	LastAt time.Time
+}
+
+var (
+	schemaDriftMu sync.Mutex
+	schemaDrifts  = make(map[string]*schemaDrift)
+)
+
+// validateResponseSchema 按预期格式检查响应，返回不符合的地方，没有登记格式的接口不检查
+func validateResponseSchema(path string, resp *APIResponse) []string {
+	fields, ok := apiResponseSchemas[path]
+	if !ok {
+		return nil
+	}
+	if resp.Body == nil {
+		return []string{fmt.Sprintf("响应不是JSON对象: %s", truncateRunes(resp.RawBody, 200))}
+	}
+
+	var issues []string
+	for _, field := range fields {
+		value, found := lookupField(resp.Body, field.Path)
+		if !found {
+			issues = append(issues, fmt.Sprintf("缺少字段 %s", field.Path))
+			continue
+		}
+		if kind := valueKind(value); kind != field.Kind {
+			issues = append(issues, fmt.Sprintf("字段 %s 类型为 %s，预期为 %s", field.Path, kind, field.Kind))
+		}
+	}
+	return issues
+}
+
+// lookupField 按点分隔的路径读取字段
+func lookupField(body map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = body
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// lookupString 读取字符串字段，不存在或类型不符时返回空字符串
+func lookupString(body map[string]interface{}, path string) string {
+	value, _ := lookupField(body, path)
+	s, _ := value.(string)
+	return s
+}
+
+// valueKind JSON值的类型名称
+func valueKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// checkResponseSchema 检查成功响应的格式，不符合时记录格式变化并写警告日志
+func checkResponseSchema(ctx context.Context, path string, resp *APIResponse) []string {
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	issues := validateResponseSchema(path, resp)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	schemaDriftMu.Lock()
+	drift := schemaDrifts[path]
+	if drift == nil {
+		drift = &schemaDrift{Path: path}
+		schemaDrifts[path] = drift
+	}
+	drift.Issues = issues
+	drift.Count++
+	drift.LastAt = time.Now()
+	schemaDriftMu.Unlock()
+
+	logger.CtxWarnf(ctx, "墨问API响应格式变化 %s: %s，原始响应: %s", path, strings.Join(issues, "; "), truncateRunes(resp.RawBody, 500))
+	return issues
+}
+
+// schemaDriftError 响应格式不符合预期时返回的错误
+func schemaDriftError(path string, issues []string) error {
+	return withErrorCode(ErrAPIChanged, fmt.Errorf("墨问API响应格式与预期不符（%s: %s），接口可能已更新，可调用check_api_compat检查", path, strings.Join(issues, "; ")))
+}
+
+// listSchemaDrifts 本进程运行以来记录的格式变化，按接口路径排序
+func listSchemaDrifts() []schemaDrift {
+	schemaDriftMu.Lock()
+	defer schemaDriftMu.Unlock()
+
+	drifts := make([]schemaDrift, 0, len(schemaDrifts))
+	for _, drift := range schemaDrifts {
+		drifts = append(drifts, *drift)
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Path < drifts[j].Path })
+	return drifts
+}
+
+// CheckAPICompat 用不产生数据的请求检查墨问API的响应格式，并汇报运行中发现的格式变化
+// 只调用获取上传授权接口，不会创建笔记或上传文件
+func CheckAPICompat(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	var sb strings.Builder
+	compatible := true
+
+	resp, err := client.PostRequest(APIUploadPrepare, &UploadPrepareRequest{FileType: 1, FileName: "compat-check.png"})
+	switch {
+	case err != nil:
+		compatible = false
+		sb.WriteString(fmt.Sprintf("❌ %s: 请求失败: %v\n", APIUploadPrepare, err))
+	case resp.StatusCode != http.StatusOK:
+		compatible = false
+		sb.WriteString(fmt.Sprintf("❌ %s: 状态码 %d，响应: %s\n", APIUploadPrepare, resp.StatusCode, truncateRunes(resp.RawBody, 200)))
+	default:
+		// PostRequest已检查格式，发现的问题同时计入下面的运行记录
+		if len(resp.SchemaIssues) > 0 {
+			compatible = false
+			sb.WriteString(fmt.Sprintf("❌ %s: %s\n", APIUploadPrepare, strings.Join(resp.SchemaIssues, "; ")))
+		} else {
+			sb.WriteString(fmt.Sprintf("✅ %s: 响应格式符合预期\n", APIUploadPrepare))
+		}
+	}
+
+	// 其余接口会产生数据，不主动调用，只汇报实际调用中发现的问题
+	if drifts := listSchemaDrifts(); len(drifts) > 0 {
+		compatible = false
+		sb.WriteString("\n📝 运行中发现的响应格式变化：\n")
+		for _, drift := range drifts {
+			sb.WriteString(fmt.Sprintf("- %s（%d 次，最近 %s）: %s\n",
+				drift.Path, drift.Count, drift.LastAt.Format("2006-01-02 15:04:05"), strings.Join(drift.Issues, "; ")))
+		}
+	}
+
+	if compatible {
+		return mcp.NewToolResultText("✅ 墨问API兼容性检查通过\n\n" + strings.TrimRight(sb.String(), "\n")), nil
+	}
+	return mcp.NewToolResultText("❌ 墨问API可能已发生变化\n\n" + strings.TrimRight(sb.String(), "\n")), nil
+}
+
+// API兼容性检查工具
+var CheckAPICompatTool = mcp.NewTool("check_api_compat",
+	mcp.WithDescription("检查墨问API的响应格式是否与本服务预期一致。只调用获取上传授权接口（不创建笔记、不上传文件），并汇报运行以来在其他接口上发现的响应格式变化。"),
+)
+
+func checkAPICompatHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return CheckAPICompat(ctx, request)
+}
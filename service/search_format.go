@@ -0,0 +1,168 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// search_note返回正文的详细程度
+const (
+	includeContentNone    = "none"
+	includeContentSummary = "summary"
+	includeContentFull    = "full"
+)
+
+// 查询结果的默认元数据字段
+var defaultResultFields = []string{"title", "created_at", "keywords"}
+
+// 摘要为空时预览正文的长度
+const contentPreviewLength = 100
+
+// 查询结果分页：默认和最大的每页条数
+const (
+	defaultSearchPageSize = 50
+	maxSearchPageSize     = 200
+)
+
+// searchCursor 翻页游标，Query为查询参数的摘要，防止游标用于其他查询
+type searchCursor struct {
+	Offset int    `json:"o"`
+	Query  uint64 `json:"q"`
+}
+
+// searchQueryHash 计算查询参数的摘要，不含分页参数和内部参数
+func searchQueryHash(args map[string]interface{}) uint64 {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		if key == "cursor" || key == "page_size" || strings.HasPrefix(key, "__") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%v\x00", key, args[key])
+	}
+	return h.Sum64()
+}
+
+// encodeSearchCursor 生成下一页的游标
+func encodeSearchCursor(cursor searchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSearchCursor 解析游标，并校验是否属于当前查询
+func decodeSearchCursor(raw string, queryHash uint64) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return 0, fmt.Errorf("无效的cursor")
+	}
+	var cursor searchCursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.Offset < 0 {
+		return 0, fmt.Errorf("无效的cursor")
+	}
+	if cursor.Query != queryHash {
+		return 0, fmt.Errorf("cursor与当前查询条件不符，请使用相同的查询参数翻页")
+	}
+	return cursor.Offset, nil
+}
+
+// searchPage 从参数中解析本页的起始位置和条数
+func searchPage(args map[string]interface{}) (offset, size int, err error) {
+	size = defaultSearchPageSize
+	if v, ok := args["page_size"].(float64); ok && v >= 1 {
+		size = min(int(v), maxSearchPageSize)
+	}
+	if raw, _ := args["cursor"].(string); raw != "" {
+		if offset, err = decodeSearchCursor(raw, searchQueryHash(args)); err != nil {
+			return 0, 0, err
+		}
+	}
+	return offset, size, nil
+}
+
+// parseResultFields 解析fields参数，未传时使用默认字段
+func parseResultFields(raw string) map[string]bool {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(fields) == 0 {
+		fields = defaultResultFields
+	}
+	selected := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		selected[strings.ToLower(f)] = true
+	}
+	return selected
+}
+
+// notePlainText 提取本地记录的纯文本正文
+func notePlainText(content string) string {
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(content), &blocks); err != nil {
+		return content
+	}
+	return strings.TrimSpace(blocksText(blocks))
+}
+
+// formatSearchResults 按字段选择和正文详细程度格式化查询结果
+// 只格式化从offset开始的size条，结果较多时返回下一页的游标，避免一次生成过长的文本
+func formatSearchResults(tenantID string, results []NoteRecord, fields map[string]bool, includeContent string, offset, size int, queryHash uint64) string {
+	var sb strings.Builder
+	if offset >= len(results) {
+		return fmt.Sprintf("📝 找到 %d 条笔记，已没有更多结果", len(results))
+	}
+	end := min(offset+size, len(results))
+	if offset == 0 && end == len(results) {
+		sb.WriteString(fmt.Sprintf("📝 找到 %d 条笔记:\n\n", len(results)))
+	} else {
+		sb.WriteString(fmt.Sprintf("📝 找到 %d 条笔记，本页为第 %d-%d 条:\n\n", len(results), offset+1, end))
+	}
+
+	for i := offset; i < end; i++ {
+		note := results[i]
+		sb.WriteString(fmt.Sprintf("**%d. 笔记 %s**\n", i+1, note.NoteID))
+		if fields["title"] {
+			sb.WriteString(fmt.Sprintf("标题: %s\n", noteTitle(note.Content)))
+		}
+		if fields["created_at"] {
+			sb.WriteString(fmt.Sprintf("创建时间: %s\n", note.CreatedAt))
+		}
+		sb.WriteString(cacheStatusLines(noteCachedAt(tenantID, &note)))
+		if fields["keywords"] && note.Keywords != "" {
+			sb.WriteString(fmt.Sprintf("关键词: %s\n", note.Keywords))
+		}
+		if fields["tags"] {
+			if tags, err := GetNoteTags(tenantID, note.NoteID); err == nil && len(tags) > 0 {
+				sb.WriteString(fmt.Sprintf("标签: %s\n", strings.Join(tags, ", ")))
+			}
+		}
+
+		switch includeContent {
+		case includeContentNone:
+			// 只返回元数据
+		case includeContentFull:
+			sb.WriteString(fmt.Sprintf("内容:\n%s\n", notePlainText(note.Content)))
+		default:
+			if note.Summary != "" {
+				sb.WriteString(fmt.Sprintf("总结: %s\n", note.Summary))
+			} else {
+				sb.WriteString(fmt.Sprintf("内容摘要: %s\n", truncateRunes(notePlainText(note.Content), contentPreviewLength)))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if end < len(results) {
+		next := encodeSearchCursor(searchCursor{Offset: end, Query: queryHash})
+		sb.WriteString(fmt.Sprintf("📥 还有 %d 条结果，使用相同的查询参数并传入 cursor=\"%s\" 获取下一页\n", len(results)-end, next))
+	}
+	return sb.String()
+}
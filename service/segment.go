@@ -0,0 +1,52 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/go-ego/gse"
+)
+
+var (
+	segmenter     gse.Segmenter
+	segmenterOnce sync.Once
+	segmenterErr  error
+)
+
+// getSegmenter 获取中文分词器，首次使用时加载内置词典（约1~2秒）
+func getSegmenter() (*gse.Segmenter, error) {
+	segmenterOnce.Do(func() {
+		if segmenterErr = segmenter.LoadDictEmbed(); segmenterErr != nil {
+			logger.Errorf("加载分词词典失败: %v", segmenterErr)
+			return
+		}
+		logger.Info("分词词典加载完成")
+	})
+	return &segmenter, segmenterErr
+}
+
+// segmentText 对文本分词，用于全文索引和查询
+// 使用搜索引擎模式，长词会同时输出其中的短词，例如"面试官"输出"面试"和"面试官"
+// 词典加载失败时退化为按空白和标点切分
+func segmentText(text string) []string {
+	var words []string
+	if seg, err := getSegmenter(); err == nil {
+		words = seg.CutSearch(text, true)
+	} else {
+		words = strings.FieldsFunc(text, func(r rune) bool {
+			return unicode.IsSpace(r) || unicode.IsPunct(r)
+		})
+	}
+
+	tokens := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || strings.IndexFunc(w, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		tokens = append(tokens, w)
+	}
+	return tokens
+}
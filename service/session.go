@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sessionArgKey HTTP传输层注入到工具参数中的会话ID键名
+// 工具处理函数只能拿到arguments，因此通过该键把会话信息传递给适配器
+const sessionArgKey = "__mowen_session_id"
+
+// Session 表示一个MCP会话（HTTP模式下每个连接一个）
+type Session struct {
+	ID string
+
+	mu            sync.RWMutex
+	apiKey        string
+	currentNoteID string
+	subscriptions map[string]struct{}
+	sender        func(message interface{}) error
+	sampling      bool
+	roots         bool
+
+	// 客户端声明的根目录，首次访问本地文件时通过roots/list获取，客户端通知变更后重新获取
+	rootDirs    []string
+	rootsLoaded bool
+
+	// 服务端发往客户端的请求，按请求ID等待响应
+	nextRequestID int64
+	pendingMu     sync.Mutex
+	pending       map[string]chan clientResponse
+}
+
+// clientResponse 客户端对服务端请求的响应
+type clientResponse struct {
+	Result json.RawMessage
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+}
+
+// stdio模式下的默认会话，整个进程共享
+var defaultSession = NewSession("stdio", "")
+
+// NewSession 创建会话
+func NewSession(id, apiKey string) *Session {
+	return &Session{
+		ID:            id,
+		apiKey:        apiKey,
+		subscriptions: make(map[string]struct{}),
+		pending:       make(map[string]chan clientResponse),
+	}
+}
+
+// APIKey 返回会话绑定的API密钥
+func (s *Session) APIKey() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.apiKey
+}
+
+// SetAPIKey 更新会话绑定的API密钥
+func (s *Session) SetAPIKey(apiKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apiKey = apiKey
+}
+
+// CurrentNoteID 返回当前正在处理的笔记ID
+func (s *Session) CurrentNoteID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.currentNoteID
+}
+
+// SetCurrentNoteID 设置当前正在处理的笔记ID
+func (s *Session) SetCurrentNoteID(noteID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentNoteID = noteID
+}
+
+// SetSender 设置向客户端发送消息的函数，由传输层提供
+func (s *Session) SetSender(sender func(message interface{}) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sender = sender
+}
+
+// send 向客户端发送一条JSON-RPC消息
+func (s *Session) send(message interface{}) error {
+	s.mu.RLock()
+	sender := s.sender
+	s.mu.RUnlock()
+
+	if sender == nil {
+		return fmt.Errorf("会话 %s 不支持推送消息", s.ID)
+	}
+	return sender(message)
+}
+
+// Notify 向客户端推送通知
+func (s *Session) Notify(method string, params interface{}) error {
+	return s.send(newNotification(method, params))
+}
+
+// Request 向客户端发送请求并等待响应，例如sampling/createMessage
+func (s *Session) Request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id := fmt.Sprintf("mowen-%d", atomic.AddInt64(&s.nextRequestID, 1))
+	ch := make(chan clientResponse, 1)
+
+	s.pendingMu.Lock()
+	s.pending[id] = ch
+	s.pendingMu.Unlock()
+	defer func() {
+		s.pendingMu.Lock()
+		delete(s.pending, id)
+		s.pendingMu.Unlock()
+	}()
+
+	if err := s.send(map[string]interface{}{
+		"jsonrpc": mcp.JSONRPC_VERSION,
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待客户端响应 %s 超时: %w", method, ctx.Err())
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("客户端返回错误 %d: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return resp.Result, nil
+	}
+}
+
+// deliverResponse 把客户端的响应交给等待中的请求，返回是否有对应的请求
+func (s *Session) deliverResponse(id string, resp clientResponse) bool {
+	s.pendingMu.Lock()
+	ch, ok := s.pending[id]
+	s.pendingMu.Unlock()
+	if ok {
+		ch <- resp
+	}
+	return ok
+}
+
+// SupportsSampling 客户端是否声明了sampling能力
+func (s *Session) SupportsSampling() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sampling
+}
+
+// SetSupportsSampling 记录客户端是否支持sampling
+func (s *Session) SetSupportsSampling(sampling bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampling = sampling
+}
+
+// SupportsRoots 客户端是否声明了roots能力
+func (s *Session) SupportsRoots() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.roots
+}
+
+// SetSupportsRoots 记录客户端是否支持roots，并清空已获取的根目录
+func (s *Session) SetSupportsRoots(roots bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roots = roots
+	s.rootDirs, s.rootsLoaded = nil, false
+}
+
+// cachedRoots 返回已获取的根目录，loaded为false时需要重新获取
+func (s *Session) cachedRoots() (dirs []string, loaded bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rootDirs, s.rootsLoaded
+}
+
+// setRoots 保存获取到的根目录
+func (s *Session) setRoots(dirs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rootDirs, s.rootsLoaded = dirs, true
+}
+
+// invalidateRoots 客户端根目录变更后清空缓存，下次访问本地文件时重新获取
+func (s *Session) invalidateRoots() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rootDirs, s.rootsLoaded = nil, false
+}
+
+// Subscribe 订阅资源变更
+func (s *Session) Subscribe(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[uri] = struct{}{}
+}
+
+// Unsubscribe 取消订阅资源变更
+func (s *Session) Unsubscribe(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscriptions, uri)
+}
+
+// IsSubscribed 判断是否订阅了指定资源
+func (s *Session) IsSubscribed(uri string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.subscriptions[uri]
+	return ok
+}
+
+// TenantID 根据API密钥计算租户ID
+// 只保存哈希值，避免在数据库中落地明文密钥
+func (s *Session) TenantID() string {
+	apiKey := s.APIKey()
+	if apiKey == "" {
+		return ""
+	}
+	return tenantIDForKey(apiKey)
+}
+
+// tenantIDForKey 计算API密钥对应的租户ID
+func tenantIDForKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// 会话存储
+var sessions sync.Map
+
+func init() {
+	RegisterSession(defaultSession)
+}
+
+// RegisterSession 注册会话
+func RegisterSession(session *Session) {
+	sessions.Store(session.ID, session)
+}
+
+// UnregisterSession 注销会话
+func UnregisterSession(sessionID string) {
+	sessions.Delete(sessionID)
+}
+
+// GetSession 根据ID获取会话
+func GetSession(sessionID string) (*Session, bool) {
+	v, ok := sessions.Load(sessionID)
+	if !ok {
+		return nil, false
+	}
+	return v.(*Session), true
+}
+
+type sessionContextKey struct{}
+
+// sessionFromContext 从上下文中获取会话，stdio模式下返回默认会话
+func sessionFromContext(ctx context.Context) *Session {
+	if session, ok := ctx.Value(sessionContextKey{}).(*Session); ok {
+		return session
+	}
+	return defaultSession
+}
+
+// tenantFromContext 从上下文中获取租户ID，stdio模式下为空字符串
+func tenantFromContext(ctx context.Context) string {
+	return sessionFromContext(ctx).TenantID()
+}
+
+// resolveNoteID 解析工具参数中的笔记ID
+// 未传入note_id时回退到会话的当前笔记
+func resolveNoteID(ctx context.Context, args map[string]interface{}) (string, bool) {
+	if noteID, ok := args["note_id"].(string); ok && noteID != "" {
+		return noteID, true
+	}
+	noteID := sessionFromContext(ctx).CurrentNoteID()
+	return noteID, noteID != ""
+}
+
+// contextFromArguments 取出传输层注入的会话ID，并把会话放入上下文
+func contextFromArguments(arguments map[string]interface{}) context.Context {
+	ctx := context.Background()
+	if sessionID, ok := arguments[sessionArgKey].(string); ok {
+		delete(arguments, sessionArgKey)
+		if session, ok := GetSession(sessionID); ok {
+			ctx = context.WithValue(ctx, sessionContextKey{}, session)
+		}
+	}
+	if requestID, ok := arguments[requestIDArgKey].(string); ok {
+		delete(arguments, requestIDArgKey)
+		ctx = context.WithValue(ctx, requestIDContextKey{}, requestID)
+	}
+	if value, ok := arguments[uploadRateArgKey]; ok {
+		if rate, ok := parseUploadRateArg(value); ok {
+			ctx = context.WithValue(ctx, uploadRateContextKey{}, rate)
+		}
+	}
+	if debug, _ := arguments[debugArgKey].(bool); debug {
+		ctx = context.WithValue(ctx, apiRecorderContextKey{}, &apiRecorder{})
+	}
+	return ctx
+}
+
+// newToolRequest 将arguments转换为工具请求，并把会话信息放入上下文
+func newToolRequest(arguments map[string]interface{}) (context.Context, mcp.CallToolRequest) {
+	ctx := contextFromArguments(arguments)
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = arguments
+	return ctx, request
+}
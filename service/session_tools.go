@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// SetCurrentNote 设置会话的当前笔记
+func SetCurrentNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	noteID, ok := request.Params.Arguments["note_id"].(string)
+	if !ok || noteID == "" {
+		return mcp.NewToolResultText("❌ 笔记ID不能为空"), nil
+	}
+
+	sessionFromContext(ctx).SetCurrentNoteID(noteID)
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 当前笔记已设置为: %s", noteID)), nil
+}
+
+// GetCurrentNote 获取会话的当前笔记
+func GetCurrentNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	noteID := sessionFromContext(ctx).CurrentNoteID()
+	if noteID == "" {
+		return mcp.NewToolResultText("📝 当前会话尚未设置笔记"), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("📝 当前笔记ID: %s", noteID)), nil
+}
+
+// 设置当前笔记工具
+var SetCurrentNoteTool = mcp.NewTool("set_current_note",
+	mcp.WithDescription("设置当前会话正在处理的笔记。设置后，edit_note、set_note_privacy等工具可以省略note_id参数。创建笔记成功后会自动设置。"),
+	mcp.WithString("note_id",
+		mcp.Required(),
+		mcp.Description("笔记ID"),
+	),
+)
+
+// 获取当前笔记工具
+var GetCurrentNoteTool = mcp.NewTool("get_current_note",
+	mcp.WithDescription("获取当前会话正在处理的笔记ID"),
+)
+
+func setCurrentNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return SetCurrentNote(ctx, request)
+}
+
+func getCurrentNoteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return GetCurrentNote(ctx, request)
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 静态站点导出时包含所有本地笔记的上限
+const maxSiteNotes = 10000
+
+// siteNote 站点目录中的一篇笔记
+type siteNote struct {
+	NoteID string
+	Title  string
+	Date   string
+	Href   template.URL
+	Tags   []siteTag
+	time   time.Time
+}
+
+// siteTag 站点中的一个标签页
+type siteTag struct {
+	Name  string
+	Href  template.URL
+	Notes []siteNote
+}
+
+// siteMonth 目录页中按月份分组的笔记
+type siteMonth struct {
+	Month string
+	Notes []siteNote
+}
+
+// siteIndex 目录页和标签页模板的数据
+type siteIndex struct {
+	Title      string
+	Home       template.URL // 返回目录页的地址，目录页自身为空
+	Months     []siteMonth
+	Tags       []siteTag
+	ExportedAt string
+}
+
+var siteIndexTemplate = template.Must(template.New("site").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 720px; margin: 40px auto; padding: 0 20px; font-family: -apple-system, "PingFang SC", "Noto Sans CJK SC", "Microsoft YaHei", sans-serif; line-height: 1.8; color: #222; }
+header, footer { color: #888; font-size: 13px; }
+h2 { font-size: 18px; border-bottom: 1px solid #eee; padding-bottom: 4px; margin-top: 32px; }
+ul { list-style: none; padding: 0; }
+.date { color: #888; font-size: 13px; margin-right: 8px; }
+.tag { display: inline-block; font-size: 12px; color: #555; background: #f0f0f0; border-radius: 3px; padding: 0 6px; margin-left: 6px; text-decoration: none; }
+</style>
+</head>
+<body>
+{{if .Home}}<header><a href="{{.Home}}">← 返回目录</a></header>{{end}}
+<h1>{{.Title}}</h1>
+{{range .Months}}<h2>{{.Month}}</h2>
+<ul>{{range .Notes}}
+<li><span class="date">{{.Date}}</span><a href="{{.Href}}">{{.Title}}</a>{{range .Tags}}<a class="tag" href="{{.Href}}">{{.Name}}</a>{{end}}</li>{{end}}
+</ul>
+{{end}}{{if .Tags}}<h2>标签</h2>
+<p>{{range .Tags}}<a class="tag" href="{{.Href}}">{{.Name}} ({{len .Notes}})</a>{{end}}</p>
+{{end}}<footer>导出于 {{.ExportedAt}}</footer>
+</body>
+</html>
+`))
+
+// siteAssets 把本地文件复制到站点的assets目录，同一文件只复制一次
+type siteAssets struct {
+	ctx    context.Context
+	dir    string
+	copied map[string]string
+	errs   []string
+}
+
+// src 文件块在笔记页面中的地址：网络文件使用原地址，本地文件复制后使用相对地址
+func (a *siteAssets) src(block ContentBlock) template.URL {
+	if block.SourceType == "url" {
+		return exportFileSrc(block)
+	}
+	path, err := checkLocalPath(a.ctx, block.SourcePath)
+	if err != nil {
+		a.errs = append(a.errs, err.Error())
+		return "#"
+	}
+	if name, ok := a.copied[path]; ok {
+		return template.URL("../assets/" + name)
+	}
+
+	name := fmt.Sprintf("%d-%s", len(a.copied)+1, sanitizeFileName(filepath.Base(path)))
+	if err := copyLocalFile(path, filepath.Join(a.dir, name)); err != nil {
+		a.errs = append(a.errs, fmt.Sprintf("复制 %s 失败: %v", path, err))
+		return "#"
+	}
+	a.copied[path] = name
+	return template.URL("../assets/" + name)
+}
+
+// copyLocalFile 复制本地文件
+func copyLocalFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// siteTagFile 标签页的文件名
+func siteTagFile(tag string) string {
+	name := sanitizeFileName(tag)
+	if name == "" {
+		name = "_"
+	}
+	return name + ".html"
+}
+
+// groupSiteMonths 按月份分组，笔记和月份都按时间倒序
+func groupSiteMonths(notes []siteNote) []siteMonth {
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].time.After(notes[j].time) })
+	var months []siteMonth
+	for _, note := range notes {
+		month := "未知日期"
+		if !note.time.IsZero() {
+			month = note.time.Format("2006年01月")
+		}
+		if len(months) == 0 || months[len(months)-1].Month != month {
+			months = append(months, siteMonth{Month: month})
+		}
+		months[len(months)-1].Notes = append(months[len(months)-1].Notes, note)
+	}
+	return months
+}
+
+// writeSitePage 渲染目录页或标签页
+func writeSitePage(path string, data siteIndex) error {
+	var buf bytes.Buffer
+	if err := siteIndexTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("渲染页面失败: %w", err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// ExportSite 把已公开的笔记导出为静态HTML站点
+func ExportSite(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	output, _ := args["output_dir"].(string)
+	if strings.TrimSpace(output) == "" {
+		return mcp.NewToolResultText("❌ 输出目录不能为空"), nil
+	}
+	siteTitle, _ := args["title"].(string)
+	if siteTitle == "" {
+		siteTitle = "我的墨问笔记"
+	}
+	includeAll, _ := args["include_all"].(bool)
+
+	dir, err := checkWritePath(ctx, output)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	for _, sub := range []string{"notes", "tags", "assets"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return errorResult("创建目录失败", err), nil
+		}
+	}
+
+	tenantID := tenantFromContext(ctx)
+	var noteIDs []string
+	if includeAll {
+		records, err := ListLatestNotes(tenantID, maxSiteNotes)
+		if err != nil {
+			return errorResult("查询笔记失败", err), nil
+		}
+		for _, record := range records {
+			noteIDs = append(noteIDs, record.NoteID)
+		}
+	} else {
+		noteIDs, err = ListPublishedNoteIDs(tenantID)
+		if err != nil {
+			return errorResult("查询已公开笔记失败", err), nil
+		}
+	}
+	if len(noteIDs) == 0 {
+		return mcp.NewToolResultText("📝 没有可导出的笔记：只有创建时直接发布或设置为公开的笔记会被导出，可传入include_all导出全部笔记"), nil
+	}
+
+	exported := make(map[string]bool, len(noteIDs))
+	for _, noteID := range noteIDs {
+		exported[noteID] = true
+	}
+	assets := &siteAssets{ctx: ctx, dir: filepath.Join(dir, "assets"), copied: make(map[string]string)}
+	linker := &exportLinker{
+		// 内链和笔记链接指向已导出的页面，未导出的笔记不加链接
+		NoteHref: func(noteID string) string {
+			if exported[noteID] {
+				return noteID + ".html"
+			}
+			return ""
+		},
+		FileSrc: assets.src,
+	}
+
+	var notes []siteNote
+	var skipped []string
+	tags := make(map[string]*siteTag)
+	for _, noteID := range noteIDs {
+		page, title, err := renderNoteHTML(ctx, noteID, linker, "../index.html")
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", noteID, err))
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "notes", noteID+".html"), []byte(page), 0o644); err != nil {
+			return errorResult("写入文件失败", err), nil
+		}
+
+		note := siteNote{NoteID: noteID, Title: title, Href: template.URL("notes/" + noteID + ".html")}
+		if record, err := GetNoteCached(tenantID, noteID); err == nil {
+			if t, err := parseDBTime(record.CreatedAt); err == nil {
+				note.time = t.Local()
+				note.Date = note.time.Format("01-02")
+			}
+		}
+		noteTags, _ := GetNoteTags(tenantID, noteID)
+		for _, tag := range noteTags {
+			note.Tags = append(note.Tags, siteTag{Name: tag, Href: template.URL("tags/" + siteTagFile(tag))})
+			if tags[tag] == nil {
+				tags[tag] = &siteTag{Name: tag}
+			}
+		}
+		for _, tag := range noteTags {
+			tags[tag].Notes = append(tags[tag].Notes, note)
+		}
+		notes = append(notes, note)
+	}
+	if len(notes) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 没有成功导出的笔记:\n%s", strings.Join(skipped, "\n"))), nil
+	}
+
+	exportedAt := time.Now().Format("2006-01-02 15:04")
+	var tagList []siteTag
+	for _, tag := range tags {
+		tagList = append(tagList, siteTag{Name: tag.Name, Href: template.URL("tags/" + siteTagFile(tag.Name)), Notes: tag.Notes})
+	}
+	sort.Slice(tagList, func(i, j int) bool { return tagList[i].Name < tagList[j].Name })
+
+	// 标签页位于tags目录下，链接需要加上上级目录
+	for _, tag := range tagList {
+		var tagNotes []siteNote
+		for _, note := range tag.Notes {
+			note.Href = "../" + note.Href
+			var noteTags []siteTag
+			for _, t := range note.Tags {
+				noteTags = append(noteTags, siteTag{Name: t.Name, Href: template.URL(siteTagFile(t.Name))})
+			}
+			note.Tags = noteTags
+			tagNotes = append(tagNotes, note)
+		}
+		data := siteIndex{Title: "标签：" + tag.Name, Home: "../index.html", Months: groupSiteMonths(tagNotes), ExportedAt: exportedAt}
+		if err := writeSitePage(filepath.Join(dir, "tags", siteTagFile(tag.Name)), data); err != nil {
+			return errorResult("写入标签页失败", err), nil
+		}
+	}
+	index := siteIndex{Title: siteTitle, Months: groupSiteMonths(notes), Tags: tagList, ExportedAt: exportedAt}
+	if err := writeSitePage(filepath.Join(dir, "index.html"), index); err != nil {
+		return errorResult("写入目录页失败", err), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("✅ 静态站点导出完成！\n\n")
+	sb.WriteString(fmt.Sprintf("输出目录: %s\n首页: %s\n笔记: %d 篇\n标签: %d 个\n附件: %d 个\n",
+		dir, filepath.Join(dir, "index.html"), len(notes), len(tagList), len(assets.copied)))
+	if len(skipped) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ 跳过 %d 篇笔记:\n%s\n", len(skipped), strings.Join(skipped, "\n")))
+	}
+	if len(assets.errs) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⚠️ %d 个附件未能复制:\n%s\n", len(assets.errs), strings.Join(assets.errs, "\n")))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 导出静态站点工具
+var ExportSiteTool = mcp.NewTool("export_site",
+	mcp.WithDescription("把已公开的笔记（创建时直接发布或设置为公开的笔记）导出为静态HTML站点：目录页按月份和标签索引，笔记之间的内链和墨问笔记链接指向导出的页面，本地附件复制到assets目录。"),
+	mcp.WithString("output_dir",
+		mcp.Required(),
+		mcp.Description("输出目录，例如 ~/Sites/notes，不存在时自动创建，已有的同名文件会被覆盖"),
+	),
+	mcp.WithString("title",
+		mcp.Description("站点标题，默认为“我的墨问笔记”"),
+	),
+	mcp.WithBoolean("include_all",
+		mcp.Description("是否导出全部本地笔记而不只是已公开的笔记，默认false"),
+	),
+)
+
+func exportSiteHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ExportSite(ctx, request)
+}
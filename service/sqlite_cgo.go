@@ -0,0 +1,24 @@
+//go:build !nocgo
+
+package service
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 默认使用mattn/go-sqlite3驱动，需要CGO和C编译器
+const sqliteDriverName = "sqlite3"
+
+// 全文索引使用FTS4
+const (
+	sqliteFTSTable  = "notes_fts"
+	sqliteFTSSchema = `CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts4(
+		tenant_id, note_id, tokens,
+		notindexed=tenant_id, notindexed=note_id
+	)`
+)
+
+// sqliteDSN 开启WAL并设置busy_timeout的连接串
+func sqliteDSN(uri string) string {
+	return uri + "?_journal_mode=WAL&_busy_timeout=5000"
+}
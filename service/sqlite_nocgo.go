@@ -0,0 +1,25 @@
+//go:build nocgo
+
+package service
+
+import (
+	_ "modernc.org/sqlite"
+)
+
+// 使用 -tags nocgo 构建时改用纯Go实现的modernc.org/sqlite驱动，
+// 不需要C编译器，可以用 CGO_ENABLED=0 交叉编译静态二进制
+const sqliteDriverName = "sqlite"
+
+// modernc只内置了FTS5，全文索引使用单独的表名，
+// 与默认构建共用同一个数据库文件时互不影响，索引为空时会从笔记重建
+const (
+	sqliteFTSTable  = "notes_fts5"
+	sqliteFTSSchema = `CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts5 USING fts5(
+		tenant_id UNINDEXED, note_id UNINDEXED, tokens
+	)`
+)
+
+// sqliteDSN 开启WAL并设置busy_timeout的连接串，modernc驱动通过_pragma参数设置
+func sqliteDSN(uri string) string {
+	return uri + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+}
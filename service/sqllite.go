@@ -5,86 +5,391 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/bytedance/gopkg/util/logger"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 // NoteRecord 定义笔记记录结构体
 type NoteRecord struct {
 	ID        int    `json:"id"`
+	TenantID  string `json:"tenant_id"`
 	NoteID    string `json:"note_id"`
 	Content   string `json:"content"`
 	Summary   string `json:"summary"`
+	Keywords  string `json:"keywords"` // 保存时提取的关键词，逗号分隔
 	CreatedAt string `json:"created_at"`
 }
 
+// extraTableSchemas 主表之外的其他数据表
+var extraTableSchemas = []string{
+	// 具名笔记：日志、收件箱等由工具自动维护的笔记，按名称映射到笔记ID
+	`CREATE TABLE IF NOT EXISTS named_notes (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		note_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (tenant_id, name)
+	)`,
+	// 收件箱：capture工具收集的条目，processed标记是否已整理
+	`CREATE TABLE IF NOT EXISTS inbox_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		kind TEXT NOT NULL,
+		content TEXT NOT NULL,
+		note_id TEXT NOT NULL,
+		processed INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// 笔记标签：标签独立存储，编辑笔记生成新记录时不会丢失
+	`CREATE TABLE IF NOT EXISTS note_tags (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		note_id TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (tenant_id, note_id, tag)
+	)`,
+	// 置顶笔记：用于目录笔记的置顶分组
+	`CREATE TABLE IF NOT EXISTS pinned_notes (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		note_id TEXT NOT NULL,
+		pinned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (tenant_id, note_id)
+	)`,
+	// 自动标签规则：创建笔记时内容匹配关键词或正则则自动添加标签
+	`CREATE TABLE IF NOT EXISTS tag_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		pattern TEXT NOT NULL,
+		is_regex INTEGER NOT NULL DEFAULT 0,
+		tag TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// 附件：上传成功的文件大小，size为-1表示大小未知
+	`CREATE TABLE IF NOT EXISTS attachments (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		file_id TEXT NOT NULL,
+		file_type TEXT NOT NULL,
+		file_name TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL DEFAULT -1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (tenant_id, file_id)
+	)`,
+	// 提醒：remind_at为UTC时间，delivered_at为空表示尚未送达
+	`CREATE TABLE IF NOT EXISTS reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		note_id TEXT NOT NULL,
+		remind_at DATETIME NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		delivered_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders (delivered_at, remind_at)`,
+	// 工时记录：log_work_session记录的时间段，started_at和ended_at为UTC时间
+	`CREATE TABLE IF NOT EXISTS time_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME NOT NULL,
+		tag TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		note_id TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_time_entries_start ON time_entries (tenant_id, started_at)`,
+	// 习惯打卡：day为本地日期 YYYY-MM-DD，每个习惯每天最多一条
+	`CREATE TABLE IF NOT EXISTS habit_checkins (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		habit TEXT NOT NULL,
+		day TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (tenant_id, habit, day)
+	)`,
+	// 记账：amount以分为单位，spent_on为本地日期 YYYY-MM-DD
+	`CREATE TABLE IF NOT EXISTS expenses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant_id TEXT NOT NULL DEFAULT '',
+		amount INTEGER NOT NULL,
+		currency TEXT NOT NULL DEFAULT 'CNY',
+		category TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		spent_on TEXT NOT NULL,
+		note_id TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_expenses_day ON expenses (tenant_id, spent_on)`,
+	// 发布状态：published为创建时是否直接发布，privacy为最近一次设置的隐私类型，空表示未设置
+	`CREATE TABLE IF NOT EXISTS note_publish (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		note_id TEXT NOT NULL,
+		published INTEGER NOT NULL DEFAULT 0,
+		privacy TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (tenant_id, note_id)
+	)`,
+	// 核对时间：最近一次确认本地记录与墨问内容一致的时间，用于提示可能过期的本地内容
+	// etag和last_modified为当时墨问返回的校验信息，再次拉取时用于条件请求
+	`CREATE TABLE IF NOT EXISTS note_verified (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		note_id TEXT NOT NULL,
+		verified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		etag TEXT NOT NULL DEFAULT '',
+		last_modified TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tenant_id, note_id)
+	)`,
+	// API用量：本服务每天向墨问发出的请求次数，day为本地日期 YYYY-MM-DD，throttled为被限流的次数
+	`CREATE TABLE IF NOT EXISTS api_usage (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		day TEXT NOT NULL,
+		path TEXT NOT NULL,
+		calls INTEGER NOT NULL DEFAULT 0,
+		failures INTEGER NOT NULL DEFAULT 0,
+		throttled INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (tenant_id, day, path)
+	)`,
+	// 全文索引：每篇笔记一行，tokens为分词后以空格连接的正文，表结构随驱动不同
+	sqliteFTSSchema,
+}
+
 var (
-	dbName        = "mowen.db" // 修改为不带路径前缀的文件名
-	dbTable       = "mowen"
-	sqliteDB      *sql.DB
-	sqliteOnce    sync.Once
-	sqliteInitErr error
+	dbName   = "mowen.db" // 修改为不带路径前缀的文件名
+	dbTable  = "mowen"
+	sqliteDB *sql.DB
+
+	// 初始化状态：失败后不再是永久错误，之后的调用和后台任务会重试
+	sqliteMu          sync.Mutex
+	sqliteInitErr     error
+	sqliteLastAttempt time.Time
 )
 
+// 初始化失败后，两次重试之间的最短间隔，避免每次调用都重新打开数据库
+const sqliteRetryInterval = 5 * time.Second
+
 // InitSQLite 初始化SQLite数据库连接
+// 已初始化时直接返回；上次失败不久时返回上次的错误，否则重新尝试
 func InitSQLite() error {
-	currentDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	sqliteMu.Lock()
+	defer sqliteMu.Unlock()
+
+	if sqliteDB != nil {
+		return nil
+	}
+	if sqliteInitErr != nil && time.Since(sqliteLastAttempt) < sqliteRetryInterval {
+		return sqliteInitErr
+	}
+	sqliteLastAttempt = time.Now()
+
+	db, err := openSQLite()
 	if err != nil {
-		return fmt.Errorf("获取当前工作目录失败: %v", err)
+		sqliteInitErr = err
+		return err
 	}
+	sqliteInitErr = nil
 
-	dbPath := filepath.Join(currentDir, dbName)
+	sqliteDB = db
+	startWriteBatcher(db)
+	logger.Info("SQLite数据库初始化成功")
 
-	sqliteOnce.Do(func() {
-		// 确保数据库文件所在目录存在
-		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-			f, err := os.Create(dbPath)
-			if err != nil {
-				sqliteInitErr = fmt.Errorf("创建空数据库文件失败: %v", err)
-				return
-			}
-			f.Close()
-			logger.Infof("创建空数据库文件成功: %s", dbPath)
-		}
+	// 旧版本数据库没有全文索引，后台补建
+	go rebuildSearchIndexIfEmpty()
+	startMaintenance()
+	startReminderDelivery()
+	return nil
+}
+
+// StartSQLite 启动时初始化数据库，失败时不退出而是在后台按退避间隔重试
+// 数据库不可用期间服务以降级模式运行：可以调用墨问API，但本地记录和搜索不可用
+func StartSQLite() {
+	err := InitSQLite()
+	if err == nil {
+		return
+	}
+	logger.Errorf("数据库初始化失败，以降级模式启动，将在后台重试: %v", err)
 
-		var db *sql.DB
-		db, sqliteInitErr = sql.Open("sqlite3", dbPath)
-		if sqliteInitErr != nil {
-			sqliteInitErr = fmt.Errorf("打开SQLite数据库失败: %v", sqliteInitErr)
+	go func() {
+		delay := sqliteRetryInterval
+		for {
+			time.Sleep(delay)
+			if err := InitSQLite(); err != nil {
+				delay = min(delay*2, time.Minute)
+				logger.Warnf("数据库初始化重试失败，%v 后再试: %v", delay, err)
+				continue
+			}
+			logger.Info("数据库已恢复，退出降级模式")
 			return
 		}
+	}()
+}
 
-		// 测试连接
-		sqliteInitErr = db.Ping()
-		if sqliteInitErr != nil {
-			return
+// sqliteStatus 数据库当前状态：是否可用，以及不可用时最近一次的错误
+func sqliteStatus() (bool, error, time.Time) {
+	sqliteMu.Lock()
+	defer sqliteMu.Unlock()
+	return sqliteDB != nil, sqliteInitErr, sqliteLastAttempt
+}
+
+// openSQLite 打开数据库并创建或升级表结构
+func openSQLite() (*sql.DB, error) {
+	dbPath, err := databasePath()
+	if err != nil {
+		return nil, err
+	}
+
+	// 确保数据库文件所在目录存在
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		f, err := os.Create(dbPath)
+		if err != nil {
+			return nil, fmt.Errorf("创建空数据库文件失败: %v", err)
 		}
+		f.Close()
+		logger.Infof("创建空数据库文件成功: %s", dbPath)
+	}
 
-		// 创建表（如果不存在）
-		_, sqliteInitErr = db.Exec(fmt.Sprintf(`
-			CREATE TABLE IF NOT EXISTS %s (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				note_id TEXT NOT NULL,
-				content TEXT NOT NULL,
-				summary TEXT,
-				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-			)`, dbTable))
-		if sqliteInitErr != nil {
-			sqliteInitErr = fmt.Errorf("创建表失败: %v", sqliteInitErr)
-			return
+	// WAL模式下读写互不阻塞，busy_timeout避免并发写入时直接报 database is locked
+	db, err := sql.Open(sqliteDriverName, sqliteDSN(sqliteFileURI(dbPath)))
+	if err != nil {
+		return nil, fmt.Errorf("打开SQLite数据库失败: %v", err)
+	}
+	if err = migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrateSQLite 测试连接，创建表并补充旧版本缺少的字段
+func migrateSQLite(db *sql.DB) error {
+	// 测试连接
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("连接SQLite数据库失败: %v", err)
+	}
+
+	// 创建表（如果不存在）
+	_, err := db.Exec(fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			note_id TEXT NOT NULL,
+			content TEXT NOT NULL,
+			summary TEXT,
+			keywords TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`, dbTable))
+	if err != nil {
+		return fmt.Errorf("创建表失败: %v", err)
+	}
+
+	// 兼容旧版本数据库：补充租户字段
+	if err = ensureColumn(db, dbTable, "tenant_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// 补充关键词字段
+	if err = ensureColumn(db, dbTable, "keywords", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// 补充内容清理标记：按保留策略清理后只剩元数据
+	if err = ensureColumn(db, dbTable, "pruned", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// 补充拉取标记：从墨问拉取保存的记录，created_at是拉取时间而不是创建或编辑时间
+	if err = ensureColumn(db, dbTable, "fetched", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	for _, schema := range extraTableSchemas {
+		if _, err = db.Exec(schema); err != nil {
+			return fmt.Errorf("创建表失败: %v", err)
 		}
+	}
+	// 补充笔记内容的校验信息，用于条件请求
+	if err = ensureColumn(db, "note_verified", "etag", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err = ensureColumn(db, "note_verified", "last_modified", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return nil
+}
 
-		sqliteDB = db
-		logger.Info("SQLite数据库初始化成功")
-	})
+// databasePath 数据库文件路径，位于可执行文件所在目录
+// 通过PATH启动时os.Args[0]只有程序名，优先使用os.Executable获取实际路径
+func databasePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	currentDir, err := filepath.Abs(filepath.Dir(exe))
+	if err != nil {
+		return "", fmt.Errorf("获取当前工作目录失败: %v", err)
+	}
+	return filepath.Join(currentDir, dbName), nil
+}
 
-	return sqliteInitErr
+// sqliteFileURI 把数据库文件路径转换为SQLite的URI文件名
+// 盘符路径写作 file:///C:/...，UNC路径写作 file:////server/share/...，路径中的 ? # % 需要转义
+func sqliteFileURI(path string) string {
+	path = strings.NewReplacer("%", "%25", "?", "%3F", "#", "%23").Replace(filepath.ToSlash(path))
+	switch {
+	case strings.HasPrefix(path, "//"):
+		return "file://" + path
+	case isDrivePath(path):
+		return "file:///" + path
+	}
+	return "file:" + path
+}
+
+// ensureColumn 检查表中是否存在指定字段，不存在则添加
+func ensureColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("读取表结构失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("读取表结构失败: %v", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取表结构失败: %v", err)
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("添加字段 %s 失败: %v", column, err)
+	}
+	logger.Infof("数据表 %s 已添加字段: %s", table, column)
+	return nil
 }
 
 // SaveNoteToSQLite 将笔记数据保存到SQLite数据库
-func SaveNoteToSQLite(noteID, content, summary string) (bool, error) {
+// tenantID 为空表示单用户（stdio）模式
+func SaveNoteToSQLite(tenantID, noteID, content, summary string) (bool, error) {
+	return saveNoteRecord(tenantID, noteID, content, summary, false)
+}
+
+// SaveFetchedNote 保存从墨问拉取的笔记内容
+// 记录标记为拉取，不计入按日期的搜索、笔记统计和连续写作天数
+func SaveFetchedNote(tenantID, noteID, content, summary string) (bool, error) {
+	return saveNoteRecord(tenantID, noteID, content, summary, true)
+}
+
+// saveNoteRecord 插入一条笔记记录并更新全文索引
+func saveNoteRecord(tenantID, noteID, content, summary string, fetched bool) (bool, error) {
 	if err := InitSQLite(); err != nil {
 		return false, fmt.Errorf("SQLite初始化失败: %v", err)
 	}
@@ -95,29 +400,57 @@ func SaveNoteToSQLite(noteID, content, summary string) (bool, error) {
 	}
 
 	// 构建插入SQL语句
-	insertSQL := fmt.Sprintf("INSERT INTO %s (note_id, content, summary) VALUES (?, ?, ?)", dbTable)
+	insertSQL := fmt.Sprintf("INSERT INTO %s (tenant_id, note_id, content, summary, keywords, fetched) VALUES (?, ?, ?, ?, ?, ?)", dbTable)
 
-	// 执行插入
-	_, err := sqliteDB.Exec(insertSQL, noteID, content, summary)
+	// 执行插入，与全文索引在同一事务中提交；索引失败不影响保存
+	keywords := keywordsFromContent(content)
+	err := execWrite(func(tx *sql.Tx) error {
+		stmt, err := txStmt(tx, insertSQL)
+		if err != nil {
+			return err
+		}
+		if _, err = stmt.Exec(tenantID, noteID, content, summary, keywords, boolToInt(fetched)); err != nil {
+			return fmt.Errorf("保存笔记数据失败: %v", err)
+		}
+		if err := withSavepoint(tx, "note_index", func(tx *sql.Tx) error {
+			return indexNoteTokens(tx, tenantID, noteID, content)
+		}); err != nil {
+			logger.Warnf("更新全文索引失败，noteID: %s, error: %v", noteID, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return false, fmt.Errorf("保存笔记数据失败: %v", err)
+		return false, err
 	}
 
 	logger.Infof("成功保存笔记数据到SQLite，noteID: %s, contentLength: %d", noteID, len(content))
 	return true, nil
 }
 
+// noteByCreationQuery 按创建时间查询笔记的公共部分，每条笔记一行
+// 编辑和追加会插入新记录：内容取最新的一条记录，时间取第一条非拉取记录的创建时间
+// 只有拉取记录的笔记不知道真实的创建时间，不返回
+const noteByCreationQuery = `SELECT l.id, l.tenant_id, l.note_id, l.content, l.summary, l.keywords, f.created_at
+	FROM (SELECT MIN(CASE WHEN fetched = 0 THEN id END) AS first_id, MAX(id) AS last_id
+		FROM %[1]s WHERE tenant_id = ? GROUP BY note_id) g
+	JOIN %[1]s f ON f.id = g.first_id
+	JOIN %[1]s l ON l.id = g.last_id`
+
 // SearchByDateRange 根据时间段查询
-func SearchByDateRange(startDate, endDate string) ([]NoteRecord, error) {
+func SearchByDateRange(tenantID, startDate, endDate string) ([]NoteRecord, error) {
 	if err := InitSQLite(); err != nil {
 		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
 	}
 
-	// 构建查询语句
-	query := fmt.Sprintf("SELECT id, note_id, content, summary, created_at FROM %s WHERE created_at BETWEEN ? AND ? ORDER BY created_at DESC", dbTable)
+	// 构建查询语句，同一笔记只返回一行，按创建时间过滤
+	query := fmt.Sprintf(noteByCreationQuery+" WHERE f.created_at BETWEEN ? AND ? ORDER BY f.created_at DESC", dbTable)
 
 	// 执行查询
-	rows, err := sqliteDB.Query(query, startDate, endDate)
+	stmt, err := preparedStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(tenantID, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("查询失败: %v", err)
 	}
@@ -126,7 +459,7 @@ func SearchByDateRange(startDate, endDate string) ([]NoteRecord, error) {
 	var results []NoteRecord
 	for rows.Next() {
 		var record NoteRecord
-		err = rows.Scan(&record.ID, &record.NoteID, &record.Content, &record.Summary, &record.CreatedAt)
+		err = rows.Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary, &record.Keywords, &record.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("扫描结果失败: %v", err)
 		}
@@ -141,16 +474,20 @@ func SearchByDateRange(startDate, endDate string) ([]NoteRecord, error) {
 }
 
 // SearchByDate 根据日期查询
-func SearchByDate(date string) ([]NoteRecord, error) {
+func SearchByDate(tenantID, date string) ([]NoteRecord, error) {
 	if err := InitSQLite(); err != nil {
 		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
 	}
 
-	// 构建查询语句，支持日期模糊匹配
-	query := fmt.Sprintf("SELECT id, note_id, content, summary, created_at FROM %s WHERE DATE(created_at) = DATE(?) ORDER BY created_at DESC", dbTable)
+	// 构建查询语句，支持日期模糊匹配，同一笔记只返回一行，按创建时间过滤
+	query := fmt.Sprintf(noteByCreationQuery+" WHERE DATE(f.created_at) = DATE(?) ORDER BY f.created_at DESC", dbTable)
 
 	// 执行查询
-	rows, err := sqliteDB.Query(query, date)
+	stmt, err := preparedStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(tenantID, date)
 	if err != nil {
 		return nil, fmt.Errorf("查询失败: %v", err)
 	}
@@ -159,7 +496,7 @@ func SearchByDate(date string) ([]NoteRecord, error) {
 	var results []NoteRecord
 	for rows.Next() {
 		var record NoteRecord
-		err = rows.Scan(&record.ID, &record.NoteID, &record.Content, &record.Summary, &record.CreatedAt)
+		err = rows.Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary, &record.Keywords, &record.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("扫描结果失败: %v", err)
 		}
@@ -174,15 +511,19 @@ func SearchByDate(date string) ([]NoteRecord, error) {
 }
 
 // SearchByCreateDt 根据具体时间查询
-func SearchByCreateDt(cdt string) (*NoteRecord, error) {
+func SearchByCreateDt(tenantID, cdt string) (*NoteRecord, error) {
 	if err := InitSQLite(); err != nil {
 		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
 	}
 	// 构建查询语句
-	query := fmt.Sprintf("SELECT id, note_id, content, summary, created_at FROM %s WHERE created_at = ?", dbTable)
+	query := fmt.Sprintf("SELECT id, tenant_id, note_id, content, summary, keywords, created_at FROM %s WHERE tenant_id = ? AND created_at = ?", dbTable)
 	// 执行查询
 	var record NoteRecord
-	err := sqliteDB.QueryRow(query, cdt).Scan(&record.ID, &record.NoteID, &record.Content, &record.Summary, &record.CreatedAt)
+	stmt, err := preparedStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	err = stmt.QueryRow(tenantID, cdt).Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary, &record.Keywords, &record.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("未找到匹配的记录")
@@ -192,8 +533,427 @@ func SearchByCreateDt(cdt string) (*NoteRecord, error) {
 	return &record, nil
 }
 
+// SearchByNoteID 根据笔记ID查询最新的一条记录
+func SearchByNoteID(tenantID, noteID string) (*NoteRecord, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+	// 构建查询语句
+	query := fmt.Sprintf("SELECT id, tenant_id, note_id, content, summary, keywords, created_at FROM %s WHERE tenant_id = ? AND note_id = ? ORDER BY id DESC LIMIT 1", dbTable)
+	// 执行查询
+	var record NoteRecord
+	stmt, err := preparedStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	err = stmt.QueryRow(tenantID, noteID).Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary, &record.Keywords, &record.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("未找到笔记 %s 的本地记录", noteID)
+		}
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	return &record, nil
+}
+
+// ListLatestNotes 查询最近更新的笔记，同一笔记只返回最新的一条记录
+func ListLatestNotes(tenantID string, limit int) ([]NoteRecord, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	// 构建查询语句
+	query := fmt.Sprintf(`SELECT id, tenant_id, note_id, content, summary, keywords, created_at FROM %s
+		WHERE id IN (SELECT MAX(id) FROM %s WHERE tenant_id = ? GROUP BY note_id)
+		ORDER BY id DESC LIMIT ?`, dbTable, dbTable)
+
+	// 执行查询
+	stmt, err := preparedStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var results []NoteRecord
+	for rows.Next() {
+		var record NoteRecord
+		err = rows.Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary, &record.Keywords, &record.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		results = append(results, record)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+
+	return results, nil
+}
+
+// ListNotesWithoutSummary 查询摘要为空的记录，用于补全摘要
+func ListNotesWithoutSummary(tenantID string, limit int) ([]NoteRecord, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	query := fmt.Sprintf(`SELECT id, tenant_id, note_id, content, '', keywords, created_at FROM %s
+		WHERE tenant_id = ? AND (summary IS NULL OR summary = '') ORDER BY id DESC LIMIT ?`, dbTable)
+	rows, err := sqliteDB.Query(query, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var results []NoteRecord
+	for rows.Next() {
+		var record NoteRecord
+		err = rows.Scan(&record.ID, &record.TenantID, &record.NoteID, &record.Content, &record.Summary, &record.Keywords, &record.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		results = append(results, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return results, nil
+}
+
+// UpdateNoteSummary 更新指定记录的摘要
+func UpdateNoteSummary(tenantID string, id int, summary string) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	updateSQL := fmt.Sprintf("UPDATE %s SET summary = ? WHERE tenant_id = ? AND id = ?", dbTable)
+	if _, err := sqliteDB.Exec(updateSQL, summary, tenantID, id); err != nil {
+		return fmt.Errorf("更新摘要失败: %v", err)
+	}
+	return nil
+}
+
+// UpdateLatestNoteSummary 更新笔记最新记录的摘要
+// 只有内容未被后续编辑覆盖时才更新，避免把旧内容的摘要写到新记录上
+func UpdateLatestNoteSummary(tenantID, noteID, content, summary string) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	updateSQL := fmt.Sprintf(`UPDATE %s SET summary = ?
+		WHERE id = (SELECT MAX(id) FROM %s WHERE tenant_id = ? AND note_id = ?) AND content = ?`, dbTable, dbTable)
+	if _, err := sqliteDB.Exec(updateSQL, summary, tenantID, noteID, content); err != nil {
+		return fmt.Errorf("更新摘要失败: %v", err)
+	}
+	return nil
+}
+
+// SetNoteTags 设置笔记的标签，覆盖原有标签
+func SetNoteTags(tenantID, noteID string, tags []string) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	tx, err := sqliteDB.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM note_tags WHERE tenant_id = ? AND note_id = ?", tenantID, noteID); err != nil {
+		return fmt.Errorf("清除标签失败: %v", err)
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		if _, err = tx.Exec("INSERT OR IGNORE INTO note_tags (tenant_id, note_id, tag) VALUES (?, ?, ?)", tenantID, noteID, tag); err != nil {
+			return fmt.Errorf("保存标签失败: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetNoteTags 查询笔记的标签
+func GetNoteTags(tenantID, noteID string) ([]string, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT tag FROM note_tags WHERE tenant_id = ? AND note_id = ? ORDER BY tag", tenantID, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err = rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ListAllTags 查询租户使用过的全部标签及对应的笔记ID
+func ListAllTags(tenantID string) (map[string][]string, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT tag, note_id FROM note_tags WHERE tenant_id = ? ORDER BY tag, note_id", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var tag, noteID string
+		if err = rows.Scan(&tag, &noteID); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		tags[tag] = append(tags[tag], noteID)
+	}
+	return tags, rows.Err()
+}
+
+// SetNotePinned 置顶或取消置顶笔记
+func SetNotePinned(tenantID, noteID string, pinned bool) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	var err error
+	if pinned {
+		_, err = sqliteDB.Exec("INSERT OR IGNORE INTO pinned_notes (tenant_id, note_id) VALUES (?, ?)", tenantID, noteID)
+	} else {
+		_, err = sqliteDB.Exec("DELETE FROM pinned_notes WHERE tenant_id = ? AND note_id = ?", tenantID, noteID)
+	}
+	if err != nil {
+		return fmt.Errorf("更新置顶状态失败: %v", err)
+	}
+	return nil
+}
+
+// ListPinnedNotes 查询置顶笔记ID，按置顶时间排序
+func ListPinnedNotes(tenantID string) ([]string, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT note_id FROM pinned_notes WHERE tenant_id = ? ORDER BY pinned_at", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var noteIDs []string
+	for rows.Next() {
+		var noteID string
+		if err = rows.Scan(&noteID); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		noteIDs = append(noteIDs, noteID)
+	}
+	return noteIDs, rows.Err()
+}
+
+// SetNotePublished 记录笔记在创建时已直接发布
+func SetNotePublished(tenantID, noteID string) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	err := execWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO note_publish (tenant_id, note_id, published) VALUES (?, ?, 1)
+			ON CONFLICT(tenant_id, note_id) DO UPDATE SET published = 1, updated_at = CURRENT_TIMESTAMP`, tenantID, noteID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存发布状态失败: %v", err)
+	}
+	return nil
+}
+
+// SetNotePrivacyType 记录笔记最近一次设置的隐私类型
+func SetNotePrivacyType(tenantID, noteID, privacy string) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	err := execWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO note_publish (tenant_id, note_id, privacy) VALUES (?, ?, ?)
+			ON CONFLICT(tenant_id, note_id) DO UPDATE SET privacy = excluded.privacy, updated_at = CURRENT_TIMESTAMP`, tenantID, noteID, privacy)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存隐私设置失败: %v", err)
+	}
+	return nil
+}
+
+// ListPublishedNoteIDs 查询已公开的笔记ID：设置为公开或规则公开，或创建时直接发布且之后未设置为私有
+func ListPublishedNoteIDs(tenantID string) ([]string, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query(`SELECT note_id FROM note_publish WHERE tenant_id = ?
+		AND (privacy IN ('public', 'rule') OR (published = 1 AND privacy != 'private')) ORDER BY note_id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var noteIDs []string
+	for rows.Next() {
+		var noteID string
+		if err = rows.Scan(&noteID); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		noteIDs = append(noteIDs, noteID)
+	}
+	return noteIDs, rows.Err()
+}
+
+// IsNotePublished 笔记是否已公开，判断条件与ListPublishedNoteIDs相同
+func IsNotePublished(tenantID, noteID string) (bool, error) {
+	if err := InitSQLite(); err != nil {
+		return false, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	var count int
+	err := sqliteDB.QueryRow(`SELECT COUNT(*) FROM note_publish WHERE tenant_id = ? AND note_id = ?
+		AND (privacy IN ('public', 'rule') OR (published = 1 AND privacy != 'private'))`, tenantID, noteID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("查询发布状态失败: %v", err)
+	}
+	return count > 0, nil
+}
+
+// ListNamedNoteIDs 查询所有具名笔记的笔记ID
+func ListNamedNoteIDs(tenantID string) (map[string]bool, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT note_id FROM named_notes WHERE tenant_id = ?", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	noteIDs := make(map[string]bool)
+	for rows.Next() {
+		var noteID string
+		if err = rows.Scan(&noteID); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		noteIDs[noteID] = true
+	}
+	return noteIDs, rows.Err()
+}
+
+// GetNamedNote 查询具名笔记对应的笔记ID，不存在时返回空字符串
+func GetNamedNote(tenantID, name string) (string, error) {
+	if err := InitSQLite(); err != nil {
+		return "", fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	var noteID string
+	err := sqliteDB.QueryRow("SELECT note_id FROM named_notes WHERE tenant_id = ? AND name = ?", tenantID, name).Scan(&noteID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("查询失败: %v", err)
+	}
+	return noteID, nil
+}
+
+// SetNamedNote 设置具名笔记对应的笔记ID
+func SetNamedNote(tenantID, name, noteID string) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	_, err := sqliteDB.Exec(`INSERT INTO named_notes (tenant_id, name, note_id) VALUES (?, ?, ?)
+		ON CONFLICT(tenant_id, name) DO UPDATE SET note_id = excluded.note_id`, tenantID, name, noteID)
+	if err != nil {
+		return fmt.Errorf("保存具名笔记失败: %v", err)
+	}
+	return nil
+}
+
+// AttachmentRecord 本地记录的附件信息
+type AttachmentRecord struct {
+	FileID   string
+	FileType string
+	FileName string
+	Size     int64 // 未知时为-1
+}
+
+// upsertAttachmentSQL 写入或更新附件记录
+const upsertAttachmentSQL = `INSERT INTO attachments (tenant_id, file_id, file_type, file_name, size) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(tenant_id, file_id) DO UPDATE SET file_type = excluded.file_type, file_name = excluded.file_name, size = excluded.size`
+
+// SaveAttachment 记录上传成功的附件
+func SaveAttachment(tenantID string, record AttachmentRecord) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	err := execWrite(func(tx *sql.Tx) error {
+		stmt, err := txStmt(tx, upsertAttachmentSQL)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Exec(tenantID, record.FileID, record.FileType, record.FileName, record.Size)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存附件失败: %v", err)
+	}
+	return nil
+}
+
+// ListAttachments 查询所有附件记录，按文件ID索引
+func ListAttachments(tenantID string) (map[string]AttachmentRecord, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT file_id, file_type, file_name, size FROM attachments WHERE tenant_id = ?", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	records := make(map[string]AttachmentRecord)
+	for rows.Next() {
+		var record AttachmentRecord
+		if err = rows.Scan(&record.FileID, &record.FileType, &record.FileName, &record.Size); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		records[record.FileID] = record
+	}
+	return records, rows.Err()
+}
+
 // CloseSQLite 关闭SQLite数据库连接
+// 关闭前先提交批量写入中尚未提交的数据
 func CloseSQLite() {
+	stopWriteBatcher()
+	closeStmtCache()
+	sqliteMu.Lock()
+	defer sqliteMu.Unlock()
 	if sqliteDB != nil {
 		logger.Info("关闭SQLite数据库连接")
 		sqliteDB.Close()
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestSearchByDateOneRowPerNote 编辑过的笔记在按日期搜索中只出现一次，按创建日期归档
+func TestSearchByDateOneRowPerNote(t *testing.T) {
+	if err := InitSQLite(); err != nil {
+		t.Skipf("SQLite不可用: %v", err)
+	}
+	tenantID := t.Name()
+	for _, content := range []string{"初稿", "初稿\n追加一行", "初稿\n追加一行\n再追加一行"} {
+		if _, err := SaveNoteToSQLite(tenantID, "n1", content, ""); err != nil {
+			t.Fatalf("保存笔记失败: %v", err)
+		}
+	}
+
+	// 把第一条记录改到昨天，模拟昨天创建、今天编辑的笔记
+	now := time.Now().UTC()
+	yesterday := now.AddDate(0, 0, -1)
+	if _, err := sqliteDB.Exec(fmt.Sprintf(`UPDATE %[1]s SET created_at = ? WHERE id = (SELECT MIN(id) FROM %[1]s WHERE tenant_id = ?)`, dbTable),
+		yesterday.Format(sqliteTimeLayout), tenantID); err != nil {
+		t.Fatalf("修改创建时间失败: %v", err)
+	}
+
+	results, err := SearchByDate(tenantID, yesterday.Format("2006-01-02"))
+	if err != nil {
+		t.Fatalf("按日期搜索失败: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("按创建日期搜索返回 %d 条，期望 1 条", len(results))
+	}
+	if want := "初稿\n追加一行\n再追加一行"; results[0].Content != want {
+		t.Errorf("内容为 %q，期望最新内容 %q", results[0].Content, want)
+	}
+	created, err := parseDBTime(results[0].CreatedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := created.Format("2006-01-02"), yesterday.Format("2006-01-02"); got != want {
+		t.Errorf("创建日期为 %s，期望 %s", got, want)
+	}
+
+	if results, err = SearchByDate(tenantID, now.Format("2006-01-02")); err != nil {
+		t.Fatalf("按日期搜索失败: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("编辑日期不应匹配，返回了 %d 条", len(results))
+	}
+
+	results, err = SearchByDateRange(tenantID, yesterday.AddDate(0, 0, -1).Format(sqliteTimeLayout), now.Add(time.Hour).Format(sqliteTimeLayout))
+	if err != nil {
+		t.Fatalf("按时间段搜索失败: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("按时间段搜索返回 %d 条，期望 1 条", len(results))
+	}
+}
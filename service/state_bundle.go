@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// 状态包的格式标识和版本
+	stateBundleFormat  = "mowen-mcp-state"
+	stateBundleVersion = 1
+	// 状态包文件的大小上限
+	maxStateBundleSize = 100 << 20
+)
+
+// stateTable 状态包中的一张表，不包含笔记内容、全文索引和附件大小等可以重新生成的数据
+type stateTable struct {
+	Name    string
+	Label   string
+	Columns []string // 导出的字段，不含tenant_id和自增id
+	Keyed   bool     // 有主键时按主键去重，否则全部字段相同才视为重复
+}
+
+// stateTables 可以导出和导入的本地状态，新增的本地配置表在这里登记
+var stateTables = []stateTable{
+	{Name: "tag_rules", Label: "自动标签规则", Columns: []string{"pattern", "is_regex", "tag", "created_at"}},
+	{Name: "named_notes", Label: "具名笔记", Columns: []string{"name", "note_id", "created_at"}, Keyed: true},
+	{Name: "pinned_notes", Label: "置顶笔记", Columns: []string{"note_id", "pinned_at"}, Keyed: true},
+	{Name: "note_tags", Label: "笔记标签", Columns: []string{"note_id", "tag"}, Keyed: true},
+	{Name: "note_publish", Label: "发布状态", Columns: []string{"note_id", "published", "privacy", "updated_at"}, Keyed: true},
+	{Name: "reminders", Label: "提醒", Columns: []string{"note_id", "remind_at", "message", "delivered_at", "created_at"}},
+	{Name: "habit_checkins", Label: "习惯打卡", Columns: []string{"habit", "day", "created_at"}, Keyed: true},
+	{Name: "time_entries", Label: "工时记录", Columns: []string{"started_at", "ended_at", "tag", "description", "note_id", "created_at"}},
+	{Name: "expenses", Label: "记账", Columns: []string{"amount", "currency", "category", "description", "spent_on", "note_id", "created_at"}},
+	{Name: "inbox_items", Label: "收件箱", Columns: []string{"kind", "content", "note_id", "processed", "created_at"}},
+}
+
+// stateBundle 可移植的本地状态包
+type stateBundle struct {
+	Format     string                              `json:"format"`
+	Version    int                                 `json:"version"`
+	ExportedAt string                              `json:"exported_at"`
+	Tables     map[string][]map[string]interface{} `json:"tables"`
+}
+
+// selectStateTables 按名称筛选状态表，names为空时返回全部
+func selectStateTables(names []string) ([]stateTable, error) {
+	if len(names) == 0 {
+		return stateTables, nil
+	}
+	var selected []stateTable
+	for _, name := range names {
+		found := false
+		for _, table := range stateTables {
+			if table.Name == name {
+				selected = append(selected, table)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("不支持的状态表: %s，支持的表: %s", name, strings.Join(stateTableNames(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+// stateTableNames 全部状态表的名称
+func stateTableNames() []string {
+	names := make([]string, len(stateTables))
+	for i, table := range stateTables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// stateValue 把数据库读出的值转换为可写入JSON、并能原样写回的值
+func stateValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(sqliteTimeLayout)
+	}
+	return value
+}
+
+// ExportStateTables 读取租户在指定表中的全部行
+func ExportStateTables(tenantID string, tables []stateTable) (map[string][]map[string]interface{}, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	result := make(map[string][]map[string]interface{}, len(tables))
+	for _, table := range tables {
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE tenant_id = ? ORDER BY rowid", strings.Join(table.Columns, ", "), table.Name)
+		rows, err := sqliteDB.Query(query, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("查询%s失败: %v", table.Label, err)
+		}
+		items := make([]map[string]interface{}, 0)
+		for rows.Next() {
+			values := make([]interface{}, len(table.Columns))
+			ptrs := make([]interface{}, len(values))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err = rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("扫描%s失败: %v", table.Label, err)
+			}
+			item := make(map[string]interface{}, len(values))
+			for i, column := range table.Columns {
+				item[column] = stateValue(values[i])
+			}
+			items = append(items, item)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("遍历%s失败: %v", table.Label, err)
+		}
+		result[table.Name] = items
+	}
+	return result, nil
+}
+
+// ImportStateTables 把状态包中的行写入租户的数据，replace为true时先清空对应表
+// 返回每张表新增的行数，已存在的行会被跳过
+func ImportStateTables(tenantID string, tables []stateTable, data map[string][]map[string]interface{}, replace bool) (map[string]int, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	added := make(map[string]int, len(tables))
+	err := execWrite(func(tx *sql.Tx) error {
+		for _, table := range tables {
+			if replace {
+				if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE tenant_id = ?", table.Name), tenantID); err != nil {
+					return fmt.Errorf("清空%s失败: %v", table.Label, err)
+				}
+			}
+
+			columns := strings.Join(table.Columns, ", ")
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(table.Columns)), ", ")
+			var query string
+			if table.Keyed {
+				query = fmt.Sprintf("INSERT OR IGNORE INTO %s (tenant_id, %s) VALUES (?, %s)", table.Name, columns, placeholders)
+			} else {
+				conditions := make([]string, len(table.Columns))
+				for i, column := range table.Columns {
+					conditions[i] = column + " IS ?"
+				}
+				query = fmt.Sprintf("INSERT INTO %s (tenant_id, %s) SELECT ?, %s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE tenant_id = ? AND %s)",
+					table.Name, columns, placeholders, table.Name, strings.Join(conditions, " AND "))
+			}
+
+			for i, item := range data[table.Name] {
+				values := make([]interface{}, len(table.Columns))
+				for j, column := range table.Columns {
+					value, ok := item[column]
+					if !ok {
+						return fmt.Errorf("%s第 %d 行缺少字段 %s", table.Label, i+1, column)
+					}
+					// JSON中的整数读出为float64，写回整数字段前还原
+					if f, ok := value.(float64); ok && f == math.Trunc(f) {
+						value = int64(f)
+					}
+					values[j] = value
+				}
+				args := append([]interface{}{tenantID}, values...)
+				if !table.Keyed {
+					args = append(append(args, tenantID), values...)
+				}
+				result, err := tx.Exec(query, args...)
+				if err != nil {
+					return fmt.Errorf("写入%s第 %d 行失败: %v", table.Label, i+1, err)
+				}
+				if n, err := result.RowsAffected(); err == nil {
+					added[table.Name] += int(n)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// parseStateTablesArg 解析tables参数，JSON字符串数组
+func parseStateTablesArg(args map[string]interface{}) ([]stateTable, error) {
+	var names []string
+	if tablesStr, _ := args["tables"].(string); tablesStr != "" {
+		if err := json.Unmarshal([]byte(tablesStr), &names); err != nil {
+			return nil, fmt.Errorf("tables格式错误，应为JSON字符串数组: %v", err)
+		}
+	}
+	return selectStateTables(names)
+}
+
+// ExportState 把本地状态导出为JSON状态包
+func ExportState(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	outputPath, _ := args["output_path"].(string)
+	if outputPath == "" {
+		return mcp.NewToolResultText("❌ 输出路径不能为空"), nil
+	}
+	tables, err := parseStateTablesArg(args)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	path, err := checkWritePath(ctx, outputPath)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	data, err := ExportStateTables(tenantFromContext(ctx), tables)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	bundle := stateBundle{
+		Format:     stateBundleFormat,
+		Version:    stateBundleVersion,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Tables:     data,
+	}
+	content, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return errorResult("序列化状态包失败", err), nil
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return errorResult("写入文件失败", err), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✅ 本地状态已导出到 %s\n\n", path))
+	for _, table := range tables {
+		sb.WriteString(fmt.Sprintf("- %s（%s）: %d 条\n", table.Label, table.Name, len(data[table.Name])))
+	}
+	sb.WriteString("\n笔记内容保存在墨问中，不包含在状态包内；在新机器上用import_state导入后即可继续使用原有配置")
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// ImportState 从JSON状态包导入本地状态
+func ImportState(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	filePath, _ := args["file_path"].(string)
+	if filePath == "" {
+		return mcp.NewToolResultText("❌ 文件路径不能为空"), nil
+	}
+	mode, _ := args["mode"].(string)
+	if mode == "" {
+		mode = "merge"
+	}
+	if mode != "merge" && mode != "replace" {
+		return mcp.NewToolResultText("❌ mode必须是 'merge' 或 'replace'"), nil
+	}
+	dryRun, _ := args["dry_run"].(bool)
+	tables, err := parseStateTablesArg(args)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	path, err := checkLocalPath(ctx, filePath)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return errorResult("读取文件失败", err), nil
+	}
+	if info.Size() > maxStateBundleSize {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 文件超过大小上限 %d MB", maxStateBundleSize>>20)), nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errorResult("读取文件失败", err), nil
+	}
+	var bundle stateBundle
+	if err := json.Unmarshal(content, &bundle); err != nil {
+		return errorResult("解析状态包失败", err), nil
+	}
+	if bundle.Format != stateBundleFormat {
+		return mcp.NewToolResultText("❌ 文件不是export_state导出的状态包"), nil
+	}
+	if bundle.Version > stateBundleVersion {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 状态包版本 %d 高于当前支持的版本 %d，请升级服务后再导入", bundle.Version, stateBundleVersion)), nil
+	}
+
+	// 只处理状态包中包含的表，避免replace模式清空未导出的数据
+	var present []stateTable
+	for _, table := range tables {
+		if _, ok := bundle.Tables[table.Name]; ok {
+			present = append(present, table)
+		}
+	}
+	if len(present) == 0 {
+		return mcp.NewToolResultText("❌ 状态包中没有可导入的数据"), nil
+	}
+
+	var sb strings.Builder
+	if dryRun {
+		sb.WriteString(fmt.Sprintf("📝 预览：状态包导出于 %s，导入方式 %s\n\n", bundle.ExportedAt, mode))
+		for _, table := range present {
+			sb.WriteString(fmt.Sprintf("- %s（%s）: %d 条\n", table.Label, table.Name, len(bundle.Tables[table.Name])))
+		}
+		if mode == "replace" {
+			sb.WriteString("\n⚠️ replace会先清空以上各表的现有数据")
+		}
+		return mcp.NewToolResultText(sb.String()), nil
+	}
+
+	added, err := ImportStateTables(tenantFromContext(ctx), present, bundle.Tables, mode == "replace")
+	if err != nil {
+		return errorResult("导入失败，未写入任何数据", err), nil
+	}
+	sb.WriteString(fmt.Sprintf("✅ 本地状态导入完成（%s）\n\n", mode))
+	for _, table := range present {
+		total := len(bundle.Tables[table.Name])
+		sb.WriteString(fmt.Sprintf("- %s（%s）: 新增 %d 条，跳过已存在 %d 条\n", table.Label, table.Name, added[table.Name], total-added[table.Name]))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 导出本地状态工具
+var ExportStateTool = mcp.NewTool("export_state",
+	mcp.WithDescription("把本服务的本地状态（自动标签规则、具名笔记映射、置顶、标签、发布状态、提醒、习惯打卡、工时、记账、收件箱等）导出为一个JSON状态包，用于迁移到另一台机器或备份配置。笔记内容保存在墨问中，不包含在内。"),
+	mcp.WithString("output_path",
+		mcp.Required(),
+		mcp.Description("状态包的输出路径，例如 ~/mowen-state.json"),
+	),
+	mcp.WithString("tables",
+		mcp.Description("只导出指定的表，JSON字符串数组，例如 [\"tag_rules\", \"named_notes\"]，不传时导出全部"),
+	),
+)
+
+// 导入本地状态工具
+var ImportStateTool = mcp.NewTool("import_state",
+	mcp.WithDescription("从export_state导出的JSON状态包恢复本地状态。默认合并导入，已存在的记录会被跳过，可以重复执行；全部写入在一个事务中完成，出错时不会留下部分数据。"),
+	mcp.WithString("file_path",
+		mcp.Required(),
+		mcp.Description("状态包文件路径"),
+	),
+	mcp.WithString("tables",
+		mcp.Description("只导入指定的表，JSON字符串数组，不传时导入状态包中的全部表"),
+	),
+	mcp.WithString("mode",
+		mcp.Description("导入方式：merge(默认，与现有数据合并), replace(先清空对应表的现有数据)"),
+		mcp.Enum("merge", "replace"),
+	),
+	mcp.WithBoolean("dry_run",
+		mcp.Description("为true时只显示状态包中各表的条数，不写入"),
+	),
+)
+
+func exportStateHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ExportState(ctx, request)
+}
+
+func importStateHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ImportState(ctx, request)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// StdioServer 基于标准输入输出的MCP服务
+// 与mcp-go自带的实现相比，支持资源订阅和服务端主动推送通知
+type StdioServer struct {
+	server *server.MCPServer
+
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// NewStdioServer 创建stdio传输服务
+func NewStdioServer(s *server.MCPServer) *StdioServer {
+	return &StdioServer{server: s}
+}
+
+// Listen 从输入中逐行读取JSON-RPC消息并写出响应，直到输入结束或上下文取消
+func (s *StdioServer) Listen(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	s.writer = stdout
+	defaultSession.SetSender(s.write)
+	defer defaultSession.SetSender(nil)
+
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				errs <- err
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	// 每条消息独立处理，避免耗时的上传阻塞后续的查询请求
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("读取输入失败: %w", err)
+		case line := <-lines:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.processMessage(ctx, line); err != nil {
+					logger.Errorf("处理消息失败: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// processMessage 处理单条消息并写出响应
+func (s *StdioServer) processMessage(ctx context.Context, line string) error {
+	var message map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &message); err != nil {
+		return s.write(newJSONRPCError(nil, mcp.PARSE_ERROR, "Parse error"))
+	}
+
+	response, handled := preprocessMessage(defaultSession, message)
+	if handled {
+		if response != nil {
+			return s.write(response)
+		}
+		return nil
+	}
+
+	raw, _ := json.Marshal(message)
+	if response = s.server.HandleMessage(ctx, raw); response != nil {
+		return s.write(response)
+	}
+	return nil
+}
+
+// write 写出一条JSON消息，响应与通知可能并发写入，需要加锁
+func (s *StdioServer) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.writer, "%s\n", data); err != nil {
+		return fmt.Errorf("写出响应失败: %w", err)
+	}
+	return nil
+}
+
+// ServeStdio 使用标准输入输出启动服务，收到SIGTERM或SIGINT时退出
+func ServeStdio(s *server.MCPServer) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	logger.Info("stdio传输已就绪")
+	return NewStdioServer(s).Listen(ctx, os.Stdin, os.Stdout)
+}
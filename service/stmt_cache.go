@@ -0,0 +1,49 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// 预编译语句缓存：常用查询只解析一次，按SQL文本复用
+var (
+	stmtCache   = make(map[string]*sql.Stmt)
+	stmtCacheMu sync.Mutex
+)
+
+// preparedStmt 返回SQL对应的预编译语句，首次使用时编译并缓存
+func preparedStmt(query string) (*sql.Stmt, error) {
+	stmtCacheMu.Lock()
+	defer stmtCacheMu.Unlock()
+
+	if stmt, ok := stmtCache[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := sqliteDB.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("编译SQL失败: %v", err)
+	}
+	stmtCache[query] = stmt
+	return stmt, nil
+}
+
+// txStmt 在事务中使用缓存的预编译语句
+func txStmt(tx *sql.Tx, query string) (*sql.Stmt, error) {
+	stmt, err := preparedStmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return tx.Stmt(stmt), nil
+}
+
+// closeStmtCache 关闭并清空所有缓存的语句，在关闭数据库前调用
+func closeStmtCache() {
+	stmtCacheMu.Lock()
+	defer stmtCacheMu.Unlock()
+
+	for query, stmt := range stmtCache {
+		stmt.Close()
+		delete(stmtCache, query)
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 输出的最长间断数量
+const maxStreakGaps = 5
+
+// dateRange 连续的日期区间
+type dateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// days 区间包含的天数
+func (r dateRange) days() int {
+	return daysBetween(r.Start, r.End) + 1
+}
+
+// dayOf 取本地日期的零点
+func dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// daysBetween 两个日期相差的天数，按日历计算，不受夏令时影响
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
+
+// writingStreaks 把有记录的日期合并为连续区间，并计算区间之间的间断
+func writingStreaks(creations []noteCreation) (streaks []dateRange, gaps []dateRange) {
+	var days []time.Time
+	seen := make(map[time.Time]bool)
+	for _, c := range creations {
+		day := dayOf(c.CreatedAt)
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	for i, day := range days {
+		if i > 0 && daysBetween(days[i-1], day) == 1 {
+			streaks[len(streaks)-1].End = day
+			continue
+		}
+		if i > 0 {
+			gaps = append(gaps, dateRange{Start: days[i-1].AddDate(0, 0, 1), End: day.AddDate(0, 0, -1)})
+		}
+		streaks = append(streaks, dateRange{Start: day, End: day})
+	}
+	return streaks, gaps
+}
+
+// WritingStreak 统计连续写作天数
+func WritingStreak(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	tag, _ := request.Params.Arguments["tag"].(string)
+	tenantID := tenantFromContext(ctx)
+
+	creations, err := ListNoteCreations(tenantID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	scope := "全部笔记"
+	if tag != "" {
+		tags, err := ListAllTags(tenantID)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		tagged := make(map[string]bool)
+		for _, noteID := range tags[tag] {
+			tagged[noteID] = true
+		}
+		filtered := creations[:0]
+		for _, c := range creations {
+			if tagged[c.NoteID] {
+				filtered = append(filtered, c)
+			}
+		}
+		creations = filtered
+		scope = fmt.Sprintf("标签\"%s\"", tag)
+	}
+
+	streaks, gaps := writingStreaks(creations)
+	if len(streaks) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("📝 %s暂无记录", scope)), nil
+	}
+
+	// 当前连续天数：最后一段区间截止到今天或昨天才算未中断
+	today := dayOf(time.Now())
+	last := streaks[len(streaks)-1]
+	current := 0
+	if daysBetween(last.End, today) <= 1 {
+		current = last.days()
+	}
+
+	longest := streaks[0]
+	activeDays := 0
+	for _, s := range streaks {
+		activeDays += s.days()
+		if s.days() > longest.days() {
+			longest = s
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔥 %s的写作连续记录\n\n", scope))
+	sb.WriteString(fmt.Sprintf("当前连续: %d 天", current))
+	if current > 0 && last.End.Before(today) {
+		sb.WriteString("（今天还没有记录）")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("最长连续: %d 天（%s ~ %s）\n", longest.days(), longest.Start.Format("2006-01-02"), longest.End.Format("2006-01-02")))
+	sb.WriteString(fmt.Sprintf("有记录的天数: %d 天（自 %s 起）\n", activeDays, streaks[0].Start.Format("2006-01-02")))
+	if current == 0 {
+		sb.WriteString(fmt.Sprintf("距上次记录: %d 天\n", daysBetween(last.End, today)))
+	}
+
+	if len(gaps) > 0 {
+		sort.SliceStable(gaps, func(i, j int) bool { return gaps[i].days() > gaps[j].days() })
+		if len(gaps) > maxStreakGaps {
+			gaps = gaps[:maxStreakGaps]
+		}
+		sb.WriteString("\n最长的间断:\n")
+		for _, g := range gaps {
+			sb.WriteString(fmt.Sprintf("- %s ~ %s: %d 天\n", g.Start.Format("2006-01-02"), g.End.Format("2006-01-02"), g.days()))
+		}
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 写作连续记录工具
+var WritingStreakTool = mcp.NewTool("writing_streak",
+	mcp.WithDescription("根据笔记的创建时间统计连续写作天数：当前连续天数、最长连续记录和最长的间断。可以按标签统计，例如\"日记\"的连续天数，用于养成习惯。"),
+	mcp.WithString("tag",
+		mcp.Description("只统计带有该标签的笔记（可选）"),
+	),
+)
+
+func writingStreakHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return WritingStreak(ctx, request)
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 摘要生成环境变量
+const (
+	// 摘要策略：first_n(默认), extractive, sampling, none
+	SummaryStrategyEnvVar = "MOWEN_SUMMARY_STRATEGY"
+	// 摘要最大长度（字符数），默认120
+	SummaryLengthEnvVar = "MOWEN_SUMMARY_LENGTH"
+	// 通过客户端sampling生成摘要的超时时间，默认30秒
+	SummaryTimeoutEnvVar = "MOWEN_SUMMARY_TIMEOUT"
+)
+
+// 摘要策略
+const (
+	summaryFirstN     = "first_n"    // 取开头若干字符
+	summaryExtractive = "extractive" // 抽取关键句
+	summarySampling   = "sampling"   // 请求客户端的大模型生成
+	summaryNone       = "none"       // 不生成摘要
+)
+
+// summaryStrategy 读取配置的摘要策略，无法识别的值按first_n处理
+func summaryStrategy() string {
+	return normalizeSummaryStrategy(envString(SummaryStrategyEnvVar, summaryFirstN))
+}
+
+// normalizeSummaryStrategy 校验摘要策略名称
+func normalizeSummaryStrategy(strategy string) string {
+	switch strategy = strings.ToLower(strings.TrimSpace(strategy)); strategy {
+	case summaryExtractive, summarySampling, summaryNone:
+		return strategy
+	default:
+		return summaryFirstN
+	}
+}
+
+// summaryLength 摘要最大长度
+func summaryLength() int {
+	if n := envInt(SummaryLengthEnvVar, 120); n > 0 {
+		return n
+	}
+	return 120
+}
+
+// summarizeBlocks 按指定策略生成摘要
+func summarizeBlocks(ctx context.Context, blocks []ContentBlock, strategy string) (string, error) {
+	text := strings.TrimSpace(blocksText(blocks))
+	if text == "" {
+		return "", nil
+	}
+
+	switch strategy {
+	case summaryNone:
+		return "", nil
+	case summaryExtractive:
+		return extractiveSummary(text, summaryLength()), nil
+	case summarySampling:
+		return samplingSummary(ctx, text, summaryLength())
+	default:
+		return firstNSummary(text, summaryLength()), nil
+	}
+}
+
+// summarizeForSave 生成保存记录时使用的摘要
+// sampling策略耗时较长，先以抽取式摘要保存，再在后台生成并更新
+func summarizeForSave(ctx context.Context, tenantID, noteID, content string, blocks []ContentBlock) string {
+	strategy := summaryStrategy()
+	if strategy != summarySampling {
+		summary, _ := summarizeBlocks(ctx, blocks, strategy)
+		return summary
+	}
+
+	go func() {
+		summary, err := summarizeBlocks(ctx, blocks, summarySampling)
+		if err != nil {
+			logger.Warnf("生成笔记摘要失败，noteID: %s, error: %v", noteID, err)
+			return
+		}
+		if err = UpdateLatestNoteSummary(tenantID, noteID, content, summary); err != nil {
+			logger.Warnf("%v", err)
+		}
+	}()
+	summary, _ := summarizeBlocks(ctx, blocks, summaryExtractive)
+	return summary
+}
+
+// firstNSummary 取开头若干字符，换行合并为空格
+func firstNSummary(text string, limit int) string {
+	return truncateRunes(strings.Join(strings.Fields(text), " "), limit)
+}
+
+// truncateRunes 按字符截断，超出时添加省略号
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// splitSentences 按中英文句末标点和换行切分句子
+func splitSentences(text string) []string {
+	var sentences []string
+	var sb strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(sb.String()); s != "" {
+			sentences = append(sentences, s)
+		}
+		sb.Reset()
+	}
+	for _, r := range text {
+		if r == '\n' {
+			flush()
+			continue
+		}
+		sb.WriteRune(r)
+		if strings.ContainsRune("。！？!?；;", r) {
+			flush()
+		}
+	}
+	flush()
+	return sentences
+}
+
+// summaryTerms 提取句子中的词项：汉字取相邻二元组，其他文字按单词切分
+func summaryTerms(sentence string) []string {
+	var terms []string
+	var han []rune
+	var word strings.Builder
+
+	flushHan := func() {
+		if len(han) == 1 {
+			terms = append(terms, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			terms = append(terms, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if w := word.String(); len(w) >= 2 {
+			terms = append(terms, strings.ToLower(w))
+		}
+		word.Reset()
+	}
+
+	for _, r := range sentence {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word.WriteRune(r)
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+	return terms
+}
+
+// extractiveSummary 抽取式摘要：按词频给句子打分，选出高分句子并保持原文顺序
+func extractiveSummary(text string, limit int) string {
+	sentences := splitSentences(text)
+	if len(sentences) <= 1 {
+		return firstNSummary(text, limit)
+	}
+
+	freq := make(map[string]int)
+	sentenceTerms := make([][]string, len(sentences))
+	for i, s := range sentences {
+		sentenceTerms[i] = summaryTerms(s)
+		for _, t := range sentenceTerms[i] {
+			freq[t]++
+		}
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+	scores := make([]scored, len(sentences))
+	for i, terms := range sentenceTerms {
+		total := 0
+		for _, t := range terms {
+			total += freq[t]
+		}
+		score := 0.0
+		if len(terms) > 0 {
+			score = float64(total) / math.Sqrt(float64(len(terms)))
+		}
+		// 开头的句子通常交代主题，略微加权
+		if i == 0 {
+			score *= 1.2
+		}
+		scores[i] = scored{index: i, score: score}
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].score > scores[j].score })
+
+	selected := make([]bool, len(sentences))
+	length := 0
+	for _, s := range scores {
+		n := len([]rune(sentences[s.index]))
+		if length > 0 && length+n > limit {
+			continue
+		}
+		selected[s.index] = true
+		length += n
+		if length >= limit {
+			break
+		}
+	}
+
+	var parts []string
+	for i, s := range sentences {
+		if selected[i] {
+			parts = append(parts, s)
+		}
+	}
+	return truncateRunes(strings.Join(parts, " "), limit)
+}
+
+// samplingSummary 通过MCP sampling请求客户端的大模型生成摘要
+func samplingSummary(ctx context.Context, text string, limit int) (string, error) {
+	session := sessionFromContext(ctx)
+	if !session.SupportsSampling() {
+		return "", fmt.Errorf("客户端不支持sampling")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, envDuration(SummaryTimeoutEnvVar, 30*time.Second))
+	defer cancel()
+
+	params := map[string]interface{}{
+		"messages": []mcp.SamplingMessage{{
+			Role:    mcp.RoleUser,
+			Content: mcp.NewTextContent(truncateRunes(text, 8000)),
+		}},
+		"systemPrompt": fmt.Sprintf("请用不超过%d个字概括用户提供的笔记内容，只输出摘要本身。", limit),
+		"maxTokens":    limit * 2,
+	}
+	raw, err := session.Request(ctx, "sampling/createMessage", params)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Content struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("解析sampling结果失败: %w", err)
+	}
+	if result.Content.Type != "text" || strings.TrimSpace(result.Content.Text) == "" {
+		return "", fmt.Errorf("sampling未返回文本内容")
+	}
+	return truncateRunes(strings.TrimSpace(result.Content.Text), limit), nil
+}
+
+// RegenerateSummaries 为摘要为空的本地记录补全摘要
+func RegenerateSummaries(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	limit := 50
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = int(v)
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	strategy := summaryStrategy()
+	if v, ok := args["strategy"].(string); ok && v != "" {
+		strategy = normalizeSummaryStrategy(v)
+	}
+	if strategy == summaryNone {
+		return mcp.NewToolResultText("❌ 摘要策略为none，无需补全"), nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	records, err := ListNotesWithoutSummary(tenantID, limit)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if len(records) == 0 {
+		return mcp.NewToolResultText("📝 没有需要补全摘要的记录"), nil
+	}
+
+	updated, skipped := 0, 0
+	var failures []string
+	for _, record := range records {
+		var blocks []ContentBlock
+		if err := json.Unmarshal([]byte(record.Content), &blocks); err != nil {
+			failures = append(failures, fmt.Sprintf("记录 %d: 解析内容失败", record.ID))
+			continue
+		}
+		summary, err := summarizeBlocks(ctx, blocks, strategy)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("记录 %d: %v", record.ID, err))
+			continue
+		}
+		if summary == "" {
+			skipped++
+			continue
+		}
+		if err := UpdateNoteSummary(tenantID, record.ID, summary); err != nil {
+			failures = append(failures, fmt.Sprintf("记录 %d: %v", record.ID, err))
+			continue
+		}
+		updated++
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✅ 摘要补全完成（策略: %s）\n\n已更新: %d 条\n无文字内容: %d 条\n失败: %d 条\n",
+		strategy, updated, skipped, len(failures)))
+	for _, f := range failures {
+		sb.WriteString("❌ " + f + "\n")
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 补全摘要工具
+var RegenerateSummariesTool = mcp.NewTool("regenerate_summaries",
+	mcp.WithDescription("为本地保存的摘要为空的笔记记录生成摘要。默认使用环境变量MOWEN_SUMMARY_STRATEGY配置的策略。"),
+	mcp.WithNumber("limit",
+		mcp.Description("本次最多处理的记录数，默认50，最大500"),
+	),
+	mcp.WithString("strategy",
+		mcp.Description("摘要策略：first_n(取开头)、extractive(抽取关键句)、sampling(请求客户端的大模型生成，需客户端支持)"),
+		mcp.Enum(summaryFirstN, summaryExtractive, summarySampling),
+	),
+)
+
+func regenerateSummariesHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return RegenerateSummaries(ctx, request)
+}
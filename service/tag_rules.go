@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TagRule 自动标签规则，内容匹配pattern时添加tag
+type TagRule struct {
+	ID        int    `json:"id"`
+	Pattern   string `json:"pattern"`
+	IsRegex   bool   `json:"is_regex"`
+	Tag       string `json:"tag"`
+	CreatedAt string `json:"created_at"`
+}
+
+// matches 判断文本是否命中规则，关键词匹配不区分大小写
+func (r TagRule) matches(text string) (bool, error) {
+	if !r.IsRegex {
+		return strings.Contains(strings.ToLower(text), strings.ToLower(r.Pattern)), nil
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return false, fmt.Errorf("规则 %d 的正则表达式无效: %w", r.ID, err)
+	}
+	return re.MatchString(text), nil
+}
+
+// SaveTagRule 保存自动标签规则，返回规则ID
+func SaveTagRule(tenantID, pattern string, isRegex bool, tag string) (int64, error) {
+	if err := InitSQLite(); err != nil {
+		return 0, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	result, err := sqliteDB.Exec("INSERT INTO tag_rules (tenant_id, pattern, is_regex, tag) VALUES (?, ?, ?, ?)",
+		tenantID, pattern, isRegex, tag)
+	if err != nil {
+		return 0, fmt.Errorf("保存标签规则失败: %v", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetTagRules 查询租户的全部自动标签规则
+func GetTagRules(tenantID string) ([]TagRule, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT id, pattern, is_regex, tag, created_at FROM tag_rules WHERE tenant_id = ? ORDER BY id", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []TagRule
+	for rows.Next() {
+		var rule TagRule
+		if err = rows.Scan(&rule.ID, &rule.Pattern, &rule.IsRegex, &rule.Tag, &rule.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return rules, nil
+}
+
+// blocksText 拼接内容块中的全部文字，用于规则匹配
+func blocksText(blocks []ContentBlock) string {
+	var sb strings.Builder
+	for _, block := range blocks {
+		for _, text := range blockTextNodes(&block) {
+			sb.WriteString(text.Text)
+		}
+		// 图片的替代文本也参与搜索和摘要，只有图片的笔记也能被找到
+		if alt, ok := block.Metadata["alt"].(string); ok && block.Type == "file" {
+			sb.WriteString(alt)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// applyTagRules 按规则为笔记补充标签，已有的标签不会重复添加
+func applyTagRules(rules []TagRule, blocks []ContentBlock, tags []string) []string {
+	text := blocksText(blocks)
+	existing := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		existing[tag] = true
+	}
+
+	for _, rule := range rules {
+		if existing[rule.Tag] {
+			continue
+		}
+		matched, err := rule.matches(text)
+		if err != nil {
+			logger.Warnf("%v", err)
+			continue
+		}
+		if matched {
+			tags = append(tags, rule.Tag)
+			existing[rule.Tag] = true
+		}
+	}
+	return tags
+}
+
+// autoTagHook 创建笔记前按规则自动添加标签
+func autoTagHook(ctx context.Context, event *HookEvent) error {
+	rules, err := GetTagRules(event.TenantID)
+	if err != nil {
+		// 规则读取失败不影响创建笔记
+		logger.Warnf("读取标签规则失败: %v", err)
+		return nil
+	}
+	if len(rules) > 0 {
+		event.Tags = applyTagRules(rules, event.Blocks, event.Tags)
+	}
+	return nil
+}
+
+func init() {
+	RegisterHook(HookBeforeCreate, autoTagHook)
+}
+
+// AddTagRule 添加自动标签规则
+func AddTagRule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	pattern, _ := args["pattern"].(string)
+	tag, _ := args["tag"].(string)
+	isRegex, _ := args["is_regex"].(bool)
+
+	pattern = strings.TrimSpace(pattern)
+	tag = strings.TrimSpace(tag)
+	if pattern == "" || tag == "" {
+		return mcp.NewToolResultText("❌ pattern和tag不能为空"), nil
+	}
+	if isRegex {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errorResult("正则表达式无效", err), nil
+		}
+	}
+
+	id, err := SaveTagRule(tenantFromContext(ctx), pattern, isRegex, tag)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	kind := "关键词"
+	if isRegex {
+		kind = "正则"
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 标签规则已添加！\n\n规则ID: %d\n%s: %s\n标签: %s\n\n之后创建的笔记内容匹配时会自动添加该标签", id, kind, pattern, tag)), nil
+}
+
+// ListTagRules 列出自动标签规则
+func ListTagRules(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rules, err := GetTagRules(tenantFromContext(ctx))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if len(rules) == 0 {
+		return mcp.NewToolResultText("📝 暂无标签规则，可通过add_tag_rule添加"), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📝 共有 %d 条标签规则\n\n", len(rules)))
+	for _, rule := range rules {
+		kind := "关键词"
+		if rule.IsRegex {
+			kind = "正则"
+		}
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s → %s\n", rule.ID, kind, rule.Pattern, rule.Tag))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 添加标签规则工具
+var AddTagRuleTool = mcp.NewTool("add_tag_rule",
+	mcp.WithDescription("添加自动标签规则：之后创建的笔记内容包含指定关键词或匹配正则表达式时，自动添加对应标签。例如pattern为\"面试\"、tag为\"招聘\"。"),
+	mcp.WithString("pattern",
+		mcp.Required(),
+		mcp.Description("匹配的关键词（不区分大小写）或正则表达式"),
+	),
+	mcp.WithString("tag",
+		mcp.Required(),
+		mcp.Description("命中时添加的标签"),
+	),
+	mcp.WithBoolean("is_regex",
+		mcp.Description("为true时pattern按正则表达式匹配，默认按关键词匹配"),
+	),
+)
+
+// 查看标签规则工具
+var ListTagRulesTool = mcp.NewTool("list_tag_rules",
+	mcp.WithDescription("列出全部自动标签规则"),
+)
+
+func addTagRuleHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return AddTagRule(ctx, request)
+}
+
+func listTagRulesHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ListTagRules(ctx, request)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// openTask 笔记中未完成的待办
+type openTask struct {
+	NoteID string
+	Title  string
+	Index  int // 待办在笔记中的段落序号，从1开始
+	Text   string
+}
+
+// ID 待办ID，格式为 笔记ID#段落序号，用于complete_task
+func (t openTask) ID() string {
+	return fmt.Sprintf("%s#%d", t.NoteID, t.Index)
+}
+
+// noteOpenTasks 提取一篇笔记中未完成的待办
+func noteOpenTasks(record NoteRecord) []openTask {
+	var blocks []ContentBlock
+	if err := json.Unmarshal([]byte(record.Content), &blocks); err != nil {
+		return nil
+	}
+	var tasks []openTask
+	title := noteTitle(record.Content)
+	for i, block := range blocks {
+		if block.Type != "todo" || block.Checked {
+			continue
+		}
+		tasks = append(tasks, openTask{
+			NoteID: record.NoteID,
+			Title:  title,
+			Index:  i + 1,
+			Text:   strings.TrimSpace(blocksText([]ContentBlock{block})),
+		})
+	}
+	return tasks
+}
+
+// parseTaskID 解析 笔记ID#段落序号 格式的待办ID
+func parseTaskID(taskID string) (string, int, error) {
+	noteID, index, ok := strings.Cut(strings.TrimSpace(taskID), "#")
+	n, err := strconv.Atoi(index)
+	if !ok || noteID == "" || err != nil || n < 1 {
+		return "", 0, fmt.Errorf("待办ID格式错误: %s，应为 笔记ID#段落序号", taskID)
+	}
+	return noteID, n, nil
+}
+
+// ListOpenTasks 汇总本地笔记中未完成的待办
+func ListOpenTasks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	tenantID := tenantFromContext(ctx)
+	noteID, _ := args["note_id"].(string)
+	query, _ := args["query"].(string)
+	query = strings.ToLower(strings.TrimSpace(query))
+	limit := 50
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+
+	var records []NoteRecord
+	if noteID != "" {
+		record, err := GetNoteCached(tenantID, noteID)
+		if err != nil {
+			return errorResult("", err), nil
+		}
+		records = []NoteRecord{*record}
+	} else {
+		var err error
+		if records, err = ListLatestNotes(tenantID, maxSearchAllNotes); err != nil {
+			return errorResult("", err), nil
+		}
+	}
+
+	var sb strings.Builder
+	total, notes, printed := 0, 0, 0
+	for _, record := range records {
+		var matched []openTask
+		for _, task := range noteOpenTasks(record) {
+			if query == "" || strings.Contains(strings.ToLower(task.Text), query) {
+				matched = append(matched, task)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		notes++
+		total += len(matched)
+		if printed >= limit {
+			continue
+		}
+
+		title := matched[0].Title
+		if title == "" {
+			title = "无标题"
+		}
+		sb.WriteString(fmt.Sprintf("**%s**（笔记ID: %s）\n", title, record.NoteID))
+		for _, task := range matched[:min(len(matched), limit-printed)] {
+			sb.WriteString(fmt.Sprintf("%s%s  [%s]\n", todoUncheckedMark, task.Text, task.ID()))
+			printed++
+		}
+		sb.WriteString("\n")
+	}
+
+	if total == 0 {
+		return mcp.NewToolResultText("✅ 没有未完成的待办"), nil
+	}
+	header := fmt.Sprintf("📝 未完成的待办 %d 项，分布在 %d 篇笔记中", total, notes)
+	if total > printed {
+		header += fmt.Sprintf("，只列出前 %d 项", printed)
+	}
+	return mcp.NewToolResultText(header + ":\n\n" + sb.String() + "完成后使用complete_task传入方括号中的待办ID勾选。"), nil
+}
+
+// CompleteTask 勾选笔记中的待办，并同步到墨问
+func CompleteTask(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	taskID, _ := args["task_id"].(string)
+	noteID, index, err := parseTaskID(taskID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	tenantID := tenantFromContext(ctx)
+	unlock := lockNote(tenantID, noteID)
+	defer unlock()
+
+	// 在锁内读取最新内容，避免覆盖并发的编辑
+	InvalidateNote(tenantID, noteID)
+	blocks, err := loadNoteBlocks(tenantID, noteID)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	if index > len(blocks) || blocks[index-1].Type != "todo" {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 笔记 %s 的第 %d 段不是待办，笔记可能已被编辑，请重新调用list_open_tasks", noteID, index)), nil
+	}
+	task := &blocks[index-1]
+	text := strings.TrimSpace(blocksText([]ContentBlock{*task}))
+	if task.Checked {
+		return mcp.NewToolResultText(fmt.Sprintf("✅ 待办已经是完成状态\n\n%s%s", todoCheckedMark, text)), nil
+	}
+
+	task.Checked = true
+	if err = writeNoteBlocks(ctx, client, noteID, blocks); err != nil {
+		return errorResult("", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("✅ 待办已完成！\n\n%s%s\n笔记ID: %s", todoCheckedMark, text, noteID)), nil
+}
+
+// 汇总待办工具
+var ListOpenTasksTool = mcp.NewTool("list_open_tasks",
+	mcp.WithDescription("汇总本地笔记中所有未完成的待办（type为todo的段落），按笔记分组列出，附带笔记ID和待办ID。"),
+	mcp.WithString("note_id",
+		mcp.Description("只列出这篇笔记中的待办，不传时汇总全部笔记"),
+	),
+	mcp.WithString("query",
+		mcp.Description("只列出包含该关键词的待办"),
+	),
+	mcp.WithNumber("limit",
+		mcp.Description("最多列出的待办数量，默认50"),
+	),
+)
+
+// 完成待办工具
+var CompleteTaskTool = mcp.NewTool("complete_task",
+	mcp.WithDescription("勾选一项待办，更新所在笔记并同步到墨问。"),
+	mcp.WithString("task_id",
+		mcp.Required(),
+		mcp.Description("list_open_tasks返回的待办ID，格式为 笔记ID#段落序号"),
+	),
+)
+
+func listOpenTasksHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return ListOpenTasks(ctx, request)
+}
+
+func completeTaskHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return CompleteTask(ctx, request)
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 单次工时记录的最长时长
+const maxWorkSession = 24 * time.Hour
+
+// TimeEntry 一条工时记录
+type TimeEntry struct {
+	ID          int
+	StartedAt   time.Time
+	EndedAt     time.Time
+	Tag         string
+	Description string
+	NoteID      string
+}
+
+// Duration 记录的时长
+func (e TimeEntry) Duration() time.Duration {
+	return e.EndedAt.Sub(e.StartedAt)
+}
+
+// SaveTimeEntry 保存工时记录
+func SaveTimeEntry(tenantID string, entry TimeEntry) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	err := execWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO time_entries (tenant_id, started_at, ended_at, tag, description, note_id) VALUES (?, ?, ?, ?, ?, ?)",
+			tenantID, entry.StartedAt.UTC().Format(sqliteTimeLayout), entry.EndedAt.UTC().Format(sqliteTimeLayout),
+			entry.Tag, entry.Description, entry.NoteID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("保存工时记录失败: %v", err)
+	}
+	return nil
+}
+
+// ListTimeEntries 查询开始时间在 [from, to) 内的工时记录
+func ListTimeEntries(tenantID string, from, to time.Time) ([]TimeEntry, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT id, started_at, ended_at, tag, description, note_id FROM time_entries WHERE tenant_id = ? AND started_at >= ? AND started_at < ? ORDER BY started_at, id",
+		tenantID, from.UTC().Format(sqliteTimeLayout), to.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("查询工时记录失败: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []TimeEntry
+	for rows.Next() {
+		var entry TimeEntry
+		var startedAt, endedAt string
+		if err = rows.Scan(&entry.ID, &startedAt, &endedAt, &entry.Tag, &entry.Description, &entry.NoteID); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		if entry.StartedAt, err = parseDBTime(startedAt); err != nil {
+			return nil, err
+		}
+		if entry.EndedAt, err = parseDBTime(endedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果失败: %v", err)
+	}
+	return entries, nil
+}
+
+// parseWorkTime 解析工时的开始或结束时间：提醒时间支持的绝对时间格式，或只有时分（当天）
+func parseWorkTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range remindTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("15:04", value, time.Local); err == nil {
+		return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), nil
+	}
+	return time.Time{}, fmt.Errorf("时间格式错误: %s，应为 HH:MM（今天）、YYYY-MM-DD HH:MM（本地时间）或RFC3339", value)
+}
+
+// formatWorkDuration 以 1小时25分钟 的形式显示时长
+func formatWorkDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%d分钟", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%d小时", hours)
+	default:
+		return fmt.Sprintf("%d小时%d分钟", hours, minutes)
+	}
+}
+
+// workSessionRange 根据start、end、duration参数计算工时的起止时间
+// end默认为当前时间；只传duration时视为刚刚结束的一段工作（如一个番茄钟）
+func workSessionRange(args map[string]interface{}, now time.Time) (time.Time, time.Time, error) {
+	startStr, _ := args["start"].(string)
+	endStr, _ := args["end"].(string)
+	durationStr, _ := args["duration"].(string)
+
+	var duration time.Duration
+	if durationStr != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(durationStr))
+		if err != nil || d <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("时长格式错误: %s，应为 25m、1h30m 等", durationStr)
+		}
+		duration = d
+	}
+
+	var start, end time.Time
+	var err error
+	if endStr != "" {
+		if end, err = parseWorkTime(endStr, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	switch {
+	case startStr != "":
+		if start, err = parseWorkTime(startStr, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if end.IsZero() {
+			end = now
+			if duration > 0 {
+				end = start.Add(duration)
+			}
+		}
+	case duration > 0:
+		if end.IsZero() {
+			end = now
+		}
+		start = end.Add(-duration)
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("start和duration至少需要传入一个")
+	}
+
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("结束时间 %s 不晚于开始时间 %s", end.Format("2006-01-02 15:04"), start.Format("2006-01-02 15:04"))
+	}
+	if end.Sub(start) > maxWorkSession {
+		return time.Time{}, time.Time{}, fmt.Errorf("单次工时不能超过 %s", formatWorkDuration(maxWorkSession))
+	}
+	return start.Truncate(time.Minute), end.Truncate(time.Minute), nil
+}
+
+// LogWorkSession 记录一段工作时间，追加到当月的工时笔记
+func LogWorkSession(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	start, end, err := workSessionRange(args, time.Now())
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	tag, _ := args["tag"].(string)
+	description, _ := args["description"].(string)
+	entry := TimeEntry{
+		StartedAt:   start,
+		EndedAt:     end,
+		Tag:         strings.TrimSpace(tag),
+		Description: strings.TrimSpace(description),
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+
+	texts := []TextNode{
+		{Text: fmt.Sprintf("%s–%s", start.Format("01-02 15:04"), end.Format("15:04")), Bold: true},
+		{Text: fmt.Sprintf("（%s）", formatWorkDuration(entry.Duration()))},
+	}
+	if entry.Tag != "" {
+		texts = append(texts, TextNode{Text: " #" + entry.Tag, Highlight: true})
+	}
+	if entry.Description != "" {
+		texts = append(texts, TextNode{Text: " " + entry.Description})
+	}
+
+	// 按开始时间所在的月份归档
+	month := start.Format("2006-01")
+	noteID, created, err := appendToNamedNote(ctx, client, "timelog:"+month, "⏱ 工时记录 "+month, []ContentBlock{{Texts: texts}})
+	if err != nil {
+		return errorResult("追加工时记录失败", err), nil
+	}
+	entry.NoteID = noteID
+	if err = SaveTimeEntry(tenantFromContext(ctx), entry); err != nil {
+		return mcp.NewToolResultText(fmt.Sprintf("❌ 工时已写入笔记 %s，但%v，time_report不会统计这条记录", noteID, err)), nil
+	}
+
+	resultText := fmt.Sprintf("✅ 工时已记录！\n\n时间: %s – %s（%s）\n笔记ID: %s",
+		start.Format("2006-01-02 15:04"), end.Format("15:04"), formatWorkDuration(entry.Duration()), noteID)
+	if entry.Tag != "" {
+		resultText += "\n项目: " + entry.Tag
+	}
+	if created {
+		resultText += "\n（本月的工时笔记不存在，已自动创建）"
+	}
+	return mcp.NewToolResultText(resultText), nil
+}
+
+// TimeReport 按项目标签汇总工时
+func TimeReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	fromStr, _ := args["from"].(string)
+	toStr, _ := args["to"].(string)
+	tagFilter, _ := args["tag"].(string)
+	tagFilter = strings.TrimSpace(tagFilter)
+	days := 30
+	if v, ok := args["days"].(float64); ok && v > 0 {
+		days = int(v)
+	}
+
+	to := dayOf(time.Now())
+	if toStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", toStr, time.Local)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 结束日期格式错误: %s，应为 YYYY-MM-DD", toStr)), nil
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, 1-days)
+	if fromStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", fromStr, time.Local)
+		if err != nil {
+			return mcp.NewToolResultText(fmt.Sprintf("❌ 开始日期格式错误: %s，应为 YYYY-MM-DD", fromStr)), nil
+		}
+		from = t
+	}
+	if to.Before(from) {
+		return mcp.NewToolResultText("❌ 结束日期不能早于开始日期"), nil
+	}
+
+	entries, err := ListTimeEntries(tenantFromContext(ctx), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	type tagTotal struct {
+		Tag      string
+		Total    time.Duration
+		Sessions int
+	}
+	totals := make(map[string]*tagTotal)
+	var total time.Duration
+	sessions := 0
+	for _, entry := range entries {
+		tag := entry.Tag
+		if tag == "" {
+			tag = untaggedGroup
+		}
+		if tagFilter != "" && tag != tagFilter {
+			continue
+		}
+		if totals[tag] == nil {
+			totals[tag] = &tagTotal{Tag: tag}
+		}
+		totals[tag].Total += entry.Duration()
+		totals[tag].Sessions++
+		total += entry.Duration()
+		sessions++
+	}
+
+	period := fmt.Sprintf("%s 至 %s", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if sessions == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("📊 %s 没有工时记录", period)), nil
+	}
+
+	sorted := make([]*tagTotal, 0, len(totals))
+	for _, t := range totals {
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Total != sorted[j].Total {
+			return sorted[i].Total > sorted[j].Total
+		}
+		return sorted[i].Tag < sorted[j].Tag
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 工时统计 %s\n\n共 %d 次，合计 %.1f 小时（%s）\n\n", period, sessions, total.Hours(), formatWorkDuration(total)))
+	for _, t := range sorted {
+		sb.WriteString(fmt.Sprintf("**%s**: %.1f 小时（%d 次，占 %.0f%%）\n", t.Tag, t.Total.Hours(), t.Sessions, float64(t.Total)*100/float64(total)))
+	}
+	return mcp.NewToolResultText(strings.TrimRight(sb.String(), "\n")), nil
+}
+
+// 记录工时工具
+var LogWorkSessionTool = mcp.NewTool("log_work_session",
+	mcp.WithDescription("记录一段工作时间（如一个番茄钟），追加到当月的\"工时记录\"笔记并保存到本地，之后可以用time_report按项目汇总。只传duration时视为刚刚结束的一段工作。"),
+	mcp.WithString("start",
+		mcp.Description("开始时间：HH:MM（今天）、YYYY-MM-DD HH:MM（本地时间）或RFC3339"),
+	),
+	mcp.WithString("end",
+		mcp.Description("结束时间，格式同start，默认为当前时间；传了start和duration时为start加duration"),
+	),
+	mcp.WithString("duration",
+		mcp.Description("时长，如 25m、1h30m，与start二选一或同时传入"),
+	),
+	mcp.WithString("tag",
+		mcp.Description("项目标签，time_report按标签汇总"),
+	),
+	mcp.WithString("description",
+		mcp.Description("工作内容"),
+	),
+)
+
+// 工时报告工具
+var TimeReportTool = mcp.NewTool("time_report",
+	mcp.WithDescription("按项目标签汇总log_work_session记录的工时，列出每个标签的小时数、次数和占比。数据来自本地记录。"),
+	mcp.WithString("from",
+		mcp.Description("开始日期 YYYY-MM-DD，默认为结束日期前days天"),
+	),
+	mcp.WithString("to",
+		mcp.Description("结束日期 YYYY-MM-DD（含当天），默认为今天"),
+	),
+	mcp.WithNumber("days",
+		mcp.Description("未传from时统计的天数，默认30"),
+	),
+	mcp.WithString("tag",
+		mcp.Description("只统计该标签，未打标签的记录归入\"未分类\""),
+	),
+)
+
+func logWorkSessionHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := LogWorkSession(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
+
+func timeReportHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return TimeReport(ctx, request)
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 保存时翻译环境变量
+const (
+	// 默认的目标语言，例如 en、中文，设置后所有新建笔记都会翻译；未设置时只翻译传入translate_to的笔记
+	TranslateToEnvVar = "MOWEN_TRANSLATE_TO"
+	// 翻译结果的保存方式：bilingual(默认，原文后附译文), replace(只保存译文)
+	TranslateOutputEnvVar = "MOWEN_TRANSLATE_OUTPUT"
+	// 翻译方式：sampling(默认，请求客户端的大模型), endpoint(调用配置的翻译服务)
+	TranslateProviderEnvVar = "MOWEN_TRANSLATE_PROVIDER"
+	// 翻译服务地址，endpoint方式下使用
+	TranslateURLEnvVar = "MOWEN_TRANSLATE_URL"
+	// 翻译服务的Bearer令牌（可选）
+	TranslateTokenEnvVar = "MOWEN_TRANSLATE_TOKEN"
+	// 翻译的超时时间，默认60秒
+	TranslateTimeoutEnvVar = "MOWEN_TRANSLATE_TIMEOUT"
+)
+
+// 翻译结果的保存方式
+const (
+	translateBilingual = "bilingual"
+	translateReplace   = "replace"
+)
+
+// translateOptions 单次创建笔记的翻译设置，覆盖环境变量
+type translateOptions struct {
+	Target string
+	Output string
+}
+
+type translateOptionsKey struct{}
+
+// withTranslateOptions 在上下文中设置本次创建笔记的翻译选项
+func withTranslateOptions(ctx context.Context, opts translateOptions) context.Context {
+	return context.WithValue(ctx, translateOptionsKey{}, opts)
+}
+
+// resolveTranslateOptions 合并上下文和环境变量中的翻译设置，目标语言为空表示不翻译
+func resolveTranslateOptions(ctx context.Context) translateOptions {
+	opts, _ := ctx.Value(translateOptionsKey{}).(translateOptions)
+	if opts.Target == "" {
+		opts.Target = strings.TrimSpace(envString(TranslateToEnvVar, ""))
+	}
+	if opts.Output == "" {
+		opts.Output = envString(TranslateOutputEnvVar, translateBilingual)
+	}
+	if strings.ToLower(strings.TrimSpace(opts.Output)) == translateReplace {
+		opts.Output = translateReplace
+	} else {
+		opts.Output = translateBilingual
+	}
+	return opts
+}
+
+// translateRequest 提交给翻译服务的请求
+type translateRequest struct {
+	Texts  []string `json:"texts"`
+	Target string   `json:"target"`
+}
+
+// translateResponse 翻译服务的返回，译文与原文按顺序一一对应
+type translateResponse struct {
+	Texts []string `json:"texts"`
+}
+
+// translatableBlock 是否翻译该内容块：只翻译有文字的普通段落和引用，分隔线和公式保持原样
+func translatableBlock(block ContentBlock) bool {
+	if block.Type != "" && block.Type != "paragraph" && block.Type != "quote" {
+		return false
+	}
+	if isMarkdownRule(block.Texts) {
+		return false
+	}
+	if _, ok := mathFormula(block.Texts); ok {
+		return false
+	}
+	for _, text := range block.Texts {
+		if strings.TrimSpace(text.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// translateHook 创建笔记前按设置翻译内容块
+func translateHook(ctx context.Context, event *HookEvent) error {
+	opts := resolveTranslateOptions(ctx)
+	if opts.Target == "" {
+		return nil
+	}
+
+	var indexes []int
+	var texts []string
+	for i, block := range event.Blocks {
+		if !translatableBlock(block) {
+			continue
+		}
+		var sb strings.Builder
+		for _, text := range block.Texts {
+			sb.WriteString(text.Text)
+		}
+		indexes = append(indexes, i)
+		texts = append(texts, sb.String())
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, envDuration(TranslateTimeoutEnvVar, 60*time.Second))
+	defer cancel()
+	translated, err := translateTexts(ctx, texts, opts.Target)
+	if err != nil {
+		return fmt.Errorf("翻译为 %s 失败: %w", opts.Target, err)
+	}
+
+	// 译文不保留行内格式，整段只有一个链接时保留链接
+	translatedBlock := func(block ContentBlock, text string) ContentBlock {
+		node := TextNode{Text: text}
+		if len(block.Texts) == 1 {
+			node.Bold, node.Highlight, node.Link = block.Texts[0].Bold, block.Texts[0].Highlight, block.Texts[0].Link
+		}
+		return ContentBlock{Type: block.Type, Texts: []TextNode{node}}
+	}
+	if opts.Output == translateReplace {
+		// 复制内容块，避免修改调用方的数据
+		blocks := append([]ContentBlock(nil), event.Blocks...)
+		for j, i := range indexes {
+			blocks[i].Texts = translatedBlock(blocks[i], translated[j]).Texts
+		}
+		event.Blocks = blocks
+		return nil
+	}
+
+	blocks := make([]ContentBlock, 0, len(event.Blocks)+len(indexes))
+	j := 0
+	for i, block := range event.Blocks {
+		blocks = append(blocks, block)
+		if j < len(indexes) && indexes[j] == i {
+			blocks = append(blocks, translatedBlock(block, translated[j]))
+			j++
+		}
+	}
+	event.Blocks = blocks
+	return nil
+}
+
+// translateTexts 按配置的方式翻译一组文字，返回顺序一致的译文
+func translateTexts(ctx context.Context, texts []string, target string) ([]string, error) {
+	var translated []string
+	var err error
+	if strings.ToLower(envString(TranslateProviderEnvVar, "sampling")) == "endpoint" {
+		translated, err = endpointTranslate(ctx, texts, target)
+	} else {
+		translated, err = samplingTranslate(ctx, texts, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(translated) != len(texts) {
+		return nil, fmt.Errorf("译文数量（%d）与原文（%d）不一致", len(translated), len(texts))
+	}
+	for i := range translated {
+		translated[i] = strings.TrimSpace(translated[i])
+	}
+	return translated, nil
+}
+
+// samplingTranslate 通过MCP sampling请求客户端的大模型翻译
+func samplingTranslate(ctx context.Context, texts []string, target string) ([]string, error) {
+	session := sessionFromContext(ctx)
+	if !session.SupportsSampling() {
+		return nil, fmt.Errorf("客户端不支持sampling，可配置 %s=endpoint 使用翻译服务", TranslateProviderEnvVar)
+	}
+
+	input, err := json.Marshal(texts)
+	if err != nil {
+		return nil, fmt.Errorf("序列化原文失败: %w", err)
+	}
+	params := map[string]interface{}{
+		"messages": []mcp.SamplingMessage{{
+			Role:    mcp.RoleUser,
+			Content: mcp.NewTextContent(string(input)),
+		}},
+		"systemPrompt": fmt.Sprintf("把用户提供的JSON字符串数组中的每一项翻译为%s，保留专有名词、网址和数字。只输出一个JSON字符串数组，长度和顺序与输入一致，不要输出其他内容。", target),
+		"maxTokens":    len([]rune(string(input)))*2 + 256,
+	}
+	raw, err := session.Request(ctx, "sampling/createMessage", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Content struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err = json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("解析sampling结果失败: %w", err)
+	}
+	if result.Content.Type != "text" {
+		return nil, fmt.Errorf("sampling未返回文本内容")
+	}
+	// 模型可能把结果包在代码块中，取第一个 [ 到最后一个 ] 之间的内容
+	output := result.Content.Text
+	start, end := strings.Index(output, "["), strings.LastIndex(output, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("sampling未返回JSON数组")
+	}
+	var translated []string
+	if err = json.Unmarshal([]byte(output[start:end+1]), &translated); err != nil {
+		return nil, fmt.Errorf("解析译文失败: %w", err)
+	}
+	return translated, nil
+}
+
+// endpointTranslate 调用配置的翻译服务
+func endpointTranslate(ctx context.Context, texts []string, target string) ([]string, error) {
+	endpoint := envString(TranslateURLEnvVar, "")
+	if endpoint == "" {
+		return nil, fmt.Errorf("未配置 %s", TranslateURLEnvVar)
+	}
+
+	body, err := json.Marshal(translateRequest{Texts: texts, Target: target})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := envString(TranslateTokenEnvVar, ""); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求翻译服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取翻译结果失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("翻译服务返回状态码 %d: %s", resp.StatusCode, respBody)
+	}
+
+	var result translateResponse
+	if err = json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("解析翻译结果失败: %w", err)
+	}
+	return result.Texts, nil
+}
+
+func init() {
+	RegisterHook(HookBeforeCreate, translateHook)
+}
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// API密钥请求头名称
+const APIKeyHeader = "X-Mowen-Api-Key"
+
+// HTTPServer 基于SSE的多租户MCP服务
+// 与mcp-go自带的SSEServer协议一致，额外为每个会话绑定墨问API密钥
+type HTTPServer struct {
+	server  *server.MCPServer
+	baseURL string
+	streams sync.Map
+	srv     *http.Server
+}
+
+// sseStream 表示一条活跃的SSE连接
+type sseStream struct {
+	mu      sync.Mutex
+	writer  http.ResponseWriter
+	flusher http.Flusher
+	done    chan struct{}
+}
+
+// send 向SSE连接写入一条事件
+func (st *sseStream) send(event string, data []byte) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	select {
+	case <-st.done:
+		return fmt.Errorf("会话已关闭")
+	default:
+	}
+	fmt.Fprintf(st.writer, "event: %s\ndata: %s\n\n", event, data)
+	st.flusher.Flush()
+	return nil
+}
+
+// NewHTTPServer 创建HTTP传输服务
+func NewHTTPServer(s *server.MCPServer, baseURL string) *HTTPServer {
+	return &HTTPServer{
+		server:  s,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// Start 在指定地址上启动HTTP服务
+func (h *HTTPServer) Start(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", h.handleSSE)
+	mux.HandleFunc("/message", h.handleMessage)
+
+	h.srv = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+	return h.srv.ListenAndServe()
+}
+
+// Shutdown 关闭所有会话并停止HTTP服务
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
+	if h.srv == nil {
+		return nil
+	}
+	h.streams.Range(func(key, value interface{}) bool {
+		h.streams.Delete(key)
+		UnregisterSession(key.(string))
+		return true
+	})
+	return h.srv.Shutdown(ctx)
+}
+
+// handleSSE 建立SSE连接并创建会话
+// 连接时可以不带密钥（允许在initialize中提供），但在绑定密钥之前会话发送的消息都会被拒绝，见rejectKeylessSession
+func (h *HTTPServer) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	sessionID := uuid.New().String()
+	stream := &sseStream{
+		writer:  w,
+		flusher: flusher,
+		done:    make(chan struct{}),
+	}
+	h.streams.Store(sessionID, stream)
+	session := NewSession(sessionID, apiKeyFromHeader(r))
+	session.SetSender(func(message interface{}) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return stream.send("message", data)
+	})
+	RegisterSession(session)
+	logger.Infof("新建会话: %s", sessionID)
+
+	defer func() {
+		h.streams.Delete(sessionID)
+		UnregisterSession(sessionID)
+		logger.Infof("会话已断开: %s", sessionID)
+	}()
+
+	endpoint := fmt.Sprintf("%s/message?sessionId=%s", h.baseURL, sessionID)
+	_ = stream.send("endpoint", []byte(endpoint))
+
+	<-r.Context().Done()
+	stream.mu.Lock()
+	close(stream.done)
+	stream.mu.Unlock()
+}
+
+// handleMessage 处理客户端发送的JSON-RPC消息
+func (h *HTTPServer) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONRPCError(w, nil, mcp.INVALID_REQUEST, "Method not allowed")
+		return
+	}
+
+	sessionID := r.URL.Query().Get("sessionId")
+	streamI, ok := h.streams.Load(sessionID)
+	if !ok {
+		writeJSONRPCError(w, nil, mcp.INVALID_PARAMS, "Invalid session ID")
+		return
+	}
+	stream := streamI.(*sseStream)
+	session, ok := GetSession(sessionID)
+	if !ok {
+		writeJSONRPCError(w, nil, mcp.INVALID_PARAMS, "Invalid session ID")
+		return
+	}
+
+	var message map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		writeJSONRPCError(w, nil, mcp.PARSE_ERROR, "Parse error")
+		return
+	}
+
+	// 请求头中的密钥优先级高于建立连接时的密钥
+	if apiKey := apiKeyFromHeader(r); apiKey != "" {
+		session.SetAPIKey(apiKey)
+	}
+
+	// 没有密钥的会话只能在initialize中提供密钥，其余消息一律拒绝
+	if response, rejected := rejectKeylessSession(session, message); rejected {
+		writeJSONRPCResponse(w, stream, response)
+		return
+	}
+
+	if response, handled := preprocessMessage(session, message); handled {
+		if response == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		writeJSONRPCResponse(w, stream, response)
+		return
+	}
+
+	raw, _ := json.Marshal(message)
+	response := h.server.HandleMessage(r.Context(), raw)
+	if response == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	writeJSONRPCResponse(w, stream, response)
+}
+
+// rejectKeylessSession HTTP会话必须绑定自己的墨问API密钥，不会回退到服务端的MOWEN_API_KEY
+// 密钥可以在建立连接或发送消息时通过请求头提供，也可以放在initialize的experimental能力中
+func rejectKeylessSession(session *Session, message map[string]json.RawMessage) (mcp.JSONRPCMessage, bool) {
+	if session.APIKey() != "" {
+		return nil, false
+	}
+	var method string
+	_ = json.Unmarshal(message["method"], &method)
+	if method == "initialize" && apiKeyFromInitialize(message["params"]) != "" {
+		return nil, false
+	}
+	var id interface{}
+	_ = json.Unmarshal(message["id"], &id)
+	logger.Warnf("拒绝未提供API密钥的会话消息: %s %s", session.ID, method)
+	return newJSONRPCError(id, mcp.INVALID_REQUEST,
+		fmt.Sprintf("Missing Mowen API key: set the %s header, Authorization: Bearer, or capabilities.experimental.mowen.apiKey in initialize", APIKeyHeader)), true
+}
+
+// writeJSONRPCResponse 通过SSE连接和HTTP响应同时返回结果
+func writeJSONRPCResponse(w http.ResponseWriter, stream *sseStream, response mcp.JSONRPCMessage) {
+	eventData, _ := json.Marshal(response)
+	_ = stream.send("message", eventData)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// preprocessMessage 在交给MCPServer之前预处理消息，handled为true时不再交给MCPServer
+// mcp-go不支持资源订阅和服务端请求，也不会把会话信息传给处理函数，这里统一补齐：
+// - 客户端对服务端请求的响应交给等待中的请求
+// - resources/subscribe、resources/unsubscribe 直接在会话上处理并返回响应
+// - tools/call、resources/read 注入会话ID参数
+// - initialize 读取客户端携带的API密钥和能力
+// - notifications/roots/list_changed 清空会话缓存的根目录
+func preprocessMessage(session *Session, message map[string]json.RawMessage) (mcp.JSONRPCMessage, bool) {
+	var method string
+	_ = json.Unmarshal(message["method"], &method)
+	var id interface{}
+	_ = json.Unmarshal(message["id"], &id)
+
+	if method == "" && id != nil {
+		var resp clientResponse
+		resp.Result = message["result"]
+		_ = json.Unmarshal(message["error"], &resp.Error)
+		if !session.deliverResponse(fmt.Sprint(id), resp) {
+			logger.Warnf("收到未知请求的响应: %v", id)
+		}
+		return nil, true
+	}
+
+	switch method {
+	case "initialize":
+		if apiKey := apiKeyFromInitialize(message["params"]); apiKey != "" {
+			session.SetAPIKey(apiKey)
+		}
+		var params struct {
+			Capabilities mcp.ClientCapabilities `json:"capabilities"`
+		}
+		if err := json.Unmarshal(message["params"], &params); err == nil {
+			session.SetSupportsSampling(params.Capabilities.Sampling != nil)
+			session.SetSupportsRoots(params.Capabilities.Roots != nil)
+		}
+	case "notifications/roots/list_changed":
+		session.invalidateRoots()
+		return nil, true
+	case "resources/subscribe", "resources/unsubscribe":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(message["params"], &params); err != nil || params.URI == "" {
+			return newJSONRPCError(id, mcp.INVALID_PARAMS, "Invalid subscribe request"), true
+		}
+		if method == "resources/subscribe" {
+			session.Subscribe(params.URI)
+		} else {
+			session.Unsubscribe(params.URI)
+		}
+		return mcp.JSONRPCResponse{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      id,
+			Result:  mcp.EmptyResult{},
+		}, true
+	case "tools/call", "resources/read":
+		params, err := injectSessionID(message["params"], session.ID)
+		if err != nil {
+			return newJSONRPCError(id, mcp.INVALID_PARAMS, "Invalid request params"), true
+		}
+		message["params"] = params
+	}
+	return nil, false
+}
+
+// apiKeyFromHeader 从请求头中读取墨问API密钥
+// 支持 X-Mowen-Api-Key 和 Authorization: Bearer 两种方式
+func apiKeyFromHeader(r *http.Request) string {
+	if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+		return apiKey
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
+
+// apiKeyFromInitialize 从initialize请求的experimental能力中读取API密钥
+// 格式：{"capabilities": {"experimental": {"mowen": {"apiKey": "..."}}}}
+func apiKeyFromInitialize(params json.RawMessage) string {
+	var p struct {
+		Capabilities struct {
+			Experimental struct {
+				Mowen struct {
+					APIKey string `json:"apiKey"`
+				} `json:"mowen"`
+			} `json:"experimental"`
+		} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return ""
+	}
+	return p.Capabilities.Experimental.Mowen.APIKey
+}
+
+// injectSessionID 将会话ID写入请求的arguments中，覆盖客户端传入的同名参数
+func injectSessionID(params json.RawMessage, sessionID string) (json.RawMessage, error) {
+	var p map[string]interface{}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	args, _ := p["arguments"].(map[string]interface{})
+	if args == nil {
+		args = make(map[string]interface{})
+	}
+	args[sessionArgKey] = sessionID
+	p["arguments"] = args
+	return json.Marshal(p)
+}
+
+// newJSONRPCError 构建JSON-RPC错误响应
+func newJSONRPCError(id interface{}, code int, message string) mcp.JSONRPCError {
+	response := mcp.JSONRPCError{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      id,
+	}
+	response.Error.Code = code
+	response.Error.Message = message
+	return response
+}
+
+// writeJSONRPCError 写入JSON-RPC错误响应
+func writeJSONRPCError(w http.ResponseWriter, id interface{}, code int, message string) {
+	response := newJSONRPCError(id, code, message)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(response)
+}
+
+// newNotification 构建JSON-RPC通知消息
+func newNotification(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"jsonrpc": mcp.JSONRPC_VERSION,
+		"method":  method,
+		"params":  params,
+	}
+}
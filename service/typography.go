@@ -0,0 +1,147 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 为true时在转换时整理排版：直引号改为弯引号，-- 改为破折号，中文与英文、数字之间加空格，默认false
+const TypographyEnvVar = "MOWEN_TYPOGRAPHY"
+
+// typographyProtected 排版时保持原样的片段：行内代码、网址和邮箱、命令行参数（例如 --verbose 和 -v）
+// 命令行参数只匹配第1个分组，前面的空白或括号仍按正文处理
+var typographyProtected = regexp.MustCompile("`[^`\n]*`|" + linkifyPattern.String() + `|(?:^|[\s(（])(--?[A-Za-z][\w./=-]*)`)
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// isLatinOrDigit 判断是否为英文字母或数字，盘古之白只在这两类字符与中文之间加空格
+func isLatinOrDigit(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// typographer 在一个段落的多个文本节点之间保持引号的开闭状态和上一个字符
+type typographer struct {
+	prev       rune
+	doubleOpen bool
+	singleOpen bool
+}
+
+// apply 整理一段文字的排版，行内代码、网址和命令行参数保持原样
+func (t *typographer) apply(text string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range typographyProtected.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[0], m[1]
+		if m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		t.applyPlain(&sb, text[last:start])
+		t.writeProtected(&sb, text[start:end])
+		last = end
+	}
+	t.applyPlain(&sb, text[last:])
+	return sb.String()
+}
+
+// writeProtected 原样写入不整理的片段，只在与中文相邻时补充空格
+func (t *typographer) writeProtected(sb *strings.Builder, text string) {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return
+	}
+	if (isCJK(t.prev) && isLatinOrDigit(runes[0])) || (isLatinOrDigit(t.prev) && isCJK(runes[0])) {
+		sb.WriteRune(' ')
+	}
+	sb.WriteString(text)
+	t.prev = runes[len(runes)-1]
+}
+
+// isDashBoundary 破折号两侧必须是空白或文字，其他位置的连字符保持原样
+func isDashBoundary(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// applyPlain 整理正文：引号、破折号和中英文之间的空格
+// 两个或三个连字符作为整体处理，只有位于空白或文字之间时才改为破折号，更长的连字符保持原样
+func (t *typographer) applyPlain(sb *strings.Builder, text string) {
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		if r == '-' && next == '-' {
+			n := 2
+			for i+n < len(runes) && runes[i+n] == '-' {
+				n++
+			}
+			after := rune(0)
+			if i+n < len(runes) {
+				after = runes[i+n]
+			}
+			if n <= 3 && isDashBoundary(t.prev) && isDashBoundary(after) {
+				sb.WriteRune('—')
+				t.prev = '—'
+			} else {
+				sb.WriteString(strings.Repeat("-", n))
+				t.prev = '-'
+			}
+			i += n - 1
+			continue
+		}
+		switch {
+		case r == '"':
+			if t.doubleOpen {
+				r = '”'
+			} else {
+				r = '“'
+			}
+			t.doubleOpen = !t.doubleOpen
+		case r == '\'':
+			switch {
+			// 单词中间的是撇号，例如 don't
+			case unicode.IsLetter(t.prev) && unicode.IsLetter(next):
+				r = '’'
+			case t.singleOpen:
+				r = '’'
+				t.singleOpen = false
+			// 词尾和省略的年代，例如 students' 和 '90s
+			case unicode.IsLetter(t.prev) || unicode.IsDigit(t.prev) || unicode.IsDigit(next):
+				r = '’'
+			default:
+				r = '‘'
+				t.singleOpen = true
+			}
+		}
+		if (isCJK(t.prev) && isLatinOrDigit(r)) || (isLatinOrDigit(t.prev) && isCJK(r)) {
+			sb.WriteRune(' ')
+		}
+		sb.WriteRune(r)
+		t.prev = r
+	}
+}
+
+// typographyTexts 整理段落中文本节点的排版，带链接的节点文字保持不变，需要的空格加在前一个节点末尾
+func typographyTexts(texts []TextNode) []TextNode {
+	var t typographer
+	result := make([]TextNode, len(texts))
+	for i, text := range texts {
+		if text.Link != "" {
+			if runes := []rune(text.Text); len(runes) > 0 {
+				if first := runes[0]; i > 0 && ((isCJK(t.prev) && isLatinOrDigit(first)) || (isLatinOrDigit(t.prev) && isCJK(first))) {
+					result[i-1].Text += " "
+				}
+				t.prev = runes[len(runes)-1]
+			}
+		} else {
+			text.Text = t.apply(text.Text)
+		}
+		result[i] = text
+	}
+	return result
+}
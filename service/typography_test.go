@@ -0,0 +1,34 @@
+package service
+
+import "testing"
+
+func TestTypographerApply(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"单词之间的两个连字符", "a--b", "a—b"},
+		{"空格之间的两个连字符", "a -- b", "a — b"},
+		{"三个连字符作为整体", "a---b", "a—b"},
+		{"更长的连字符保持原样", "a----b", "a----b"},
+		{"行首的连字符保持原样", "-- 作者", "-- 作者"},
+		{"命令行参数", "加上 --verbose 查看详情", "加上 --verbose 查看详情"},
+		{"命令中的参数", "运行 git commit --amend 修改", "运行 git commit --amend 修改"},
+		{"短参数和引号", `用 -v 输出 "详细" 日志`, `用 -v 输出 “详细” 日志`},
+		{"网址", "见 https://example.com/a--b?q=1 --", "见 https://example.com/a--b?q=1 --"},
+		{"网址与中文之间加空格", "见https://example.com/a--b，好", "见 https://example.com/a--b，好"},
+		{"行内代码", "it's `don't --x` ok", "it’s `don't --x` ok"},
+		{"双引号", `say "hi"`, `say “hi”`},
+		{"撇号", "don't", "don’t"},
+		{"中英文之间加空格", "中文English中文", "中文 English 中文"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tp typographer
+			if got := tp.apply(tt.in); got != tt.want {
+				t.Errorf("apply(%q) = %q, 期望 %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
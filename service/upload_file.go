@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// uploadSourceType 判断上传来源：http(s)地址为url，其余为本地路径
+func uploadSourceType(source string) string {
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return "url"
+	}
+	return "local"
+}
+
+// detectUploadFileType 根据扩展名判断文件类型，URL取路径部分的扩展名
+func detectUploadFileType(source, sourceType string) (string, error) {
+	name := source
+	if sourceType == "url" {
+		if u, err := url.Parse(source); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+	// HEIC照片上传时会先转换为JPEG
+	switch strings.ToLower(path.Ext(name)) {
+	case ".heic", ".heif":
+		return "image", nil
+	}
+	fileType, err := getFileTypeFromPath(name)
+	if err != nil {
+		return "", err
+	}
+	return fileTypeKeys[fileType], nil
+}
+
+// UploadFile 上传本地文件或URL指向的文件，返回可在create_note、edit_note等工具中直接引用的file_id
+func UploadFile(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	source, _ := args["source_path"].(string)
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return mcp.NewToolResultText("❌ source_path不能为空"), nil
+	}
+	sourceType := uploadSourceType(source)
+
+	fileType, _ := args["file_type"].(string)
+	if fileType == "" {
+		detected, err := detectUploadFileType(source, sourceType)
+		if err != nil {
+			return errorResult("无法根据扩展名判断文件类型，请传入file_type", err), nil
+		}
+		fileType = detected
+	}
+	if _, ok := fileTypeNames[fileType]; !ok {
+		return mcp.NewToolResultText("❌ file_type必须是 'image', 'audio' 或 'pdf'"), nil
+	}
+
+	block := ContentBlock{Type: "file", FileType: fileType, SourceType: sourceType, SourcePath: source}
+	if fileName, _ := args["file_name"].(string); strings.TrimSpace(fileName) != "" {
+		block.setMetadata(fileNameMetadataKey, strings.TrimSpace(fileName))
+	}
+	if convert, ok := args["convert"].(bool); ok {
+		block.setMetadata(convertMetadataKey, convert)
+	}
+
+	client, err := NewMowenClientFromContext(ctx)
+	if err != nil {
+		return errorResult("创建客户端失败", err), nil
+	}
+	fileID, err := resolveFileID(ctx, client, &block)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	// 返回只带file_id的内容块，引用时不会重复上传
+	reference := ContentBlock{Type: "file", FileType: fileType, FileID: fileID}
+	if name, ok := block.Metadata[fileNameMetadataKey]; ok {
+		reference.setMetadata(fileNameMetadataKey, name)
+	}
+	data, _ := json.Marshal(reference)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✅ %s文件上传成功！\n\n", fileTypeNames[fileType]))
+	sb.WriteString(fmt.Sprintf("文件ID: %s\n", fileID))
+	sb.WriteString(fmt.Sprintf("文件名: %s\n", uploadFileName(&block)))
+	sb.WriteString(fmt.Sprintf("\n内容块JSON（可直接放入create_note、edit_note、append_to_note的paragraphs）:\n%s\n", data))
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 上传文件工具
+var UploadFileTool = mcp.NewTool("upload_file",
+	mcp.WithDescription("单独上传一个图片、音频或PDF文件，返回文件ID。之后在create_note、edit_note等工具的文件段落中传入file_id即可引用，不会重复上传；适合同一个文件要放进多篇笔记，或先上传再组织内容的场景"),
+	mcp.WithString("source_path",
+		mcp.Required(),
+		mcp.Description("本地文件路径（需位于允许访问的目录中，支持 ~/ 和 $VAR）或 http(s) 地址"),
+	),
+	mcp.WithString("file_type",
+		mcp.Description("文件类型：image、audio、pdf，不传时根据扩展名判断"),
+		mcp.Enum("image", "audio", "pdf"),
+	),
+	mcp.WithString("file_name",
+		mcp.Description("上传后显示的文件名，默认使用本地文件名或URL路径的最后一段"),
+	),
+	mcp.WithBoolean("convert",
+		mcp.Description("本地的HEIC照片和动态WebP是否先转换为JPEG和GIF再上传，默认true"),
+	),
+)
+
+func uploadFileHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	result, err := UploadFile(ctx, request)
+	return withAPIDebug(ctx, result), err
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 上传文件到OSS失败时自动重试的次数，默认2，设为0时不重试
+// 上传使用upload/prepare返回的OSS表单，只能整个文件一次提交，不支持分片续传，重试时从头上传
+const UploadRetriesEnvVar = "MOWEN_UPLOAD_RETRIES"
+
+// 第一次重试前的等待时间，之后每次翻倍
+var uploadRetryBaseDelay = time.Second
+
+// uploadFileWithRetry 上传文件到OSS，网络中断或OSS返回5xx时用同一份表单从头重新上传
+// 等待重试期间请求被取消时立即返回
+func uploadFileWithRetry(ctx context.Context, client *MowenClient, form UploadPrepareResponseForm, filePath string) (*APIResponse, error) {
+	retries := envInt(UploadRetriesEnvVar, 2)
+	for attempt := 0; ; attempt++ {
+		resp, err := client.UploadFile(form, filePath)
+		if attempt >= retries || !retryableUpload(resp, err) {
+			return resp, err
+		}
+
+		delay := uploadRetryDelay(attempt)
+		if err != nil {
+			logger.CtxWarnf(client.logContext(), "上传文件 %s 失败，%v 后第 %d 次重试: %v", filepath.Base(filePath), delay, attempt+1, err)
+		} else {
+			logger.CtxWarnf(client.logContext(), "上传文件 %s 失败，状态码: %d，%v 后第 %d 次重试", filepath.Base(filePath), resp.StatusCode, delay, attempt+1)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("等待重试上传时请求已取消: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// retryableUpload 判断上传失败是否值得重试：连接中断、超时等网络错误和OSS的5xx错误
+// 打开文件失败等本地错误和4xx响应重试也不会成功
+func retryableUpload(resp *APIResponse, err error) bool {
+	if err != nil {
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+	return resp != nil && resp.StatusCode >= http.StatusInternalServerError
+}
+
+// uploadRetryDelay 第attempt次重试前的等待时间
+func uploadRetryDelay(attempt int) time.Duration {
+	return uploadRetryBaseDelay << attempt
+}
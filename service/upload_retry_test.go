@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUploadFileWithRetry(t *testing.T) {
+	uploadRetryBaseDelay = time.Millisecond
+	defer func() { uploadRetryBaseDelay = time.Second }()
+
+	file := filepath.Join(t.TempDir(), "episode.mp3")
+	if err := os.WriteFile(file, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		retries   string
+		statuses  []int // 依次返回的状态码，用完后重复最后一个
+		wantCalls int
+		wantCode  int
+	}{
+		{"5xx后重试成功", "", []int{http.StatusServiceUnavailable, http.StatusOK}, 2, http.StatusOK},
+		{"4xx不重试", "", []int{http.StatusForbidden}, 1, http.StatusForbidden},
+		{"重试次数用完", "", []int{http.StatusBadGateway}, 3, http.StatusBadGateway},
+		{"关闭重试", "0", []int{http.StatusServiceUnavailable, http.StatusOK}, 1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(UploadRetriesEnvVar, tt.retries)
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(calls, len(tt.statuses)-1)]
+				calls++
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte(`{"file": {"fileId": "f1"}}`))
+				}
+			}))
+			defer server.Close()
+
+			resp, err := uploadFileWithRetry(context.Background(), newMowenClientWithKey("test"), UploadPrepareResponseForm{"endpoint": server.URL}, file)
+			if err != nil {
+				t.Fatalf("上传返回错误: %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("上传了 %d 次，期望 %d 次", calls, tt.wantCalls)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("状态码为 %d，期望 %d", resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestUploadFileWithRetryCanceled(t *testing.T) {
+	uploadRetryBaseDelay = time.Hour
+	defer func() { uploadRetryBaseDelay = time.Second }()
+
+	file := filepath.Join(t.TempDir(), "episode.mp3")
+	if err := os.WriteFile(file, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		cancel()
+	}))
+	defer server.Close()
+
+	_, err := uploadFileWithRetry(ctx, newMowenClientWithKey("test"), UploadPrepareResponseForm{"endpoint": server.URL}, file)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("取消后返回 %v，期望 context.Canceled", err)
+	}
+}
+
+func TestRetryableUpload(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *APIResponse
+		err  error
+		want bool
+	}{
+		{"网络错误", nil, fmt.Errorf("发送上传请求失败: %w", &url.Error{Op: "Post", URL: "https://oss.example.com", Err: errors.New("connection reset")}), true},
+		{"本地错误", nil, errors.New("打开文件失败"), false},
+		{"5xx", &APIResponse{StatusCode: http.StatusInternalServerError}, nil, true},
+		{"4xx", &APIResponse{StatusCode: http.StatusBadRequest}, nil, false},
+		{"成功", &APIResponse{StatusCode: http.StatusOK}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := retryableUpload(tt.resp, tt.err); got != tt.want {
+			t.Errorf("%s: retryableUpload = %v, 期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 是否在远程上传前探测URL，默认开启；个别站点拒绝HEAD或限制抓取时可以关闭
+const URLProbeEnvVar = "MOWEN_URL_PROBE"
+
+// 各文件类型允许的最大大小
+var maxRemoteFileSize = map[string]int64{
+	"image": 50 << 20,
+	"audio": 200 << 20,
+	"pdf":   100 << 20,
+}
+
+// 嗅探内容类型时读取的字节数
+const sniffBytes = 512
+
+// remoteFileInfo 探测到的远程文件信息
+type remoteFileInfo struct {
+	ContentType string
+	Size        int64 // 未知时为-1
+}
+
+// probeRemoteFile 在提交远程上传前检查URL是否可访问，以及大小和内容类型是否与声明的文件类型一致
+// 返回探测到的文件信息，关闭探测时返回nil
+func probeRemoteFile(ctx context.Context, fileURL, fileType string) (*remoteFileInfo, error) {
+	// 回放模式下不访问网络
+	if !envBool(URLProbeEnvVar, true) || mockReplaying() {
+		return nil, nil
+	}
+
+	info, err := fetchRemoteFileInfo(ctx, fileURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit := maxRemoteFileSize[fileType]; info.Size > limit {
+		return nil, fmt.Errorf("文件过大（%.1f MB），%s文件最大 %d MB", float64(info.Size)/1024/1024, fileTypeNames[fileType], limit>>20)
+	}
+	if !contentTypeMatches(fileType, info.ContentType) {
+		return nil, fmt.Errorf("URL的内容类型是 %s，与声明的文件类型 %s 不符，请检查链接或file_type", info.ContentType, fileType)
+	}
+	return info, nil
+}
+
+// fetchRemoteFileInfo 先用HEAD获取文件信息，HEAD不可用或类型不明确时读取开头少量字节嗅探
+func fetchRemoteFileInfo(ctx context.Context, fileURL string) (*remoteFileInfo, error) {
+	client := newSafeHTTPClient(15 * time.Second)
+
+	info := &remoteFileInfo{Size: -1}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("无效的URL %s: %w", fileURL, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("无法访问 %s: %w", fileURL, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, fmt.Errorf("文件不存在（状态码 %d）: %s", resp.StatusCode, fileURL)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("文件需要授权才能访问（状态码 %d），墨问服务器无法抓取: %s", resp.StatusCode, fileURL)
+	case resp.StatusCode < 400:
+		info.ContentType = mediaType(resp.Header.Get("Content-Type"))
+		info.Size = resp.ContentLength
+	}
+	// 部分站点不支持HEAD（405等），或者返回的是通用二进制类型，读取开头嗅探
+	if info.ContentType != "" && info.ContentType != "application/octet-stream" && info.ContentType != "binary/octet-stream" {
+		return info, nil
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("无效的URL %s: %w", fileURL, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sniffBytes-1))
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("无法访问 %s: %w", fileURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			return nil, fmt.Errorf("文件不存在（状态码 %d）: %s", resp.StatusCode, fileURL)
+		}
+		return nil, fmt.Errorf("无法访问文件（状态码 %d）: %s", resp.StatusCode, fileURL)
+	}
+	head, err := io.ReadAll(io.LimitReader(resp.Body, sniffBytes))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	info.ContentType = mediaType(http.DetectContentType(head))
+	if info.Size < 0 {
+		info.Size = responseTotalSize(resp)
+	}
+	return info, nil
+}
+
+// responseTotalSize 从Content-Range或Content-Length中获取文件总大小，未知时返回-1
+func responseTotalSize(resp *http.Response) int64 {
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			if total, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+				return total
+			}
+		}
+		return -1
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.ContentLength
+	}
+	return -1
+}
+
+// mediaType 去掉Content-Type中的参数部分
+func mediaType(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return strings.ToLower(t)
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// contentTypeMatches 判断内容类型是否与声明的文件类型一致，无法判断的通用类型放行
+func contentTypeMatches(fileType, contentType string) bool {
+	switch contentType {
+	case "", "application/octet-stream", "binary/octet-stream":
+		return true
+	}
+	switch fileType {
+	case "image":
+		return strings.HasPrefix(contentType, "image/")
+	case "audio":
+		// m4a等音频常被标记为video/mp4或application/ogg
+		return strings.HasPrefix(contentType, "audio/") || contentType == "video/mp4" || contentType == "application/ogg"
+	case "pdf":
+		return contentType == "application/pdf" || contentType == "application/x-pdf"
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// URL访问策略环境变量
+const (
+	// 允许访问的主机模式（如 *.example.com），设置后只允许匹配的主机
+	URLAllowEnvVar = "MOWEN_URL_ALLOW"
+	// 禁止访问的主机模式，优先级高于允许列表
+	URLDenyEnvVar = "MOWEN_URL_DENY"
+	// 是否允许访问内网、回环等私有地址，默认禁止
+	URLAllowPrivateEnvVar = "MOWEN_URL_ALLOW_PRIVATE"
+)
+
+// checkRemoteURL 校验远程URL是否符合访问策略
+// 所有由本服务发起或转交给墨问服务器抓取的URL都需要经过该校验
+func checkRemoteURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("无效的URL %s: %w", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("不支持的URL协议: %s", u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("URL缺少主机名: %s", rawURL)
+	}
+
+	for _, pattern := range envList(URLDenyEnvVar) {
+		if matchHost(pattern, host) {
+			return fmt.Errorf("主机 %s 在禁止访问列表中", host)
+		}
+	}
+
+	if allow := envList(URLAllowEnvVar); len(allow) > 0 {
+		allowed := false
+		for _, pattern := range allow {
+			if matchHost(pattern, host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("主机 %s 不在允许访问列表中", host)
+		}
+	}
+
+	// 回放模式下不访问网络，也不解析主机
+	if envBool(URLAllowPrivateEnvVar, false) || mockReplaying() {
+		return nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("解析主机 %s 失败: %w", host, err)
+	}
+	for _, ip := range ips {
+		if isPrivateIP(ip) {
+			return fmt.Errorf("禁止访问内网地址: %s (%s)", host, ip)
+		}
+	}
+	return nil
+}
+
+// matchHost 判断主机名是否匹配模式，支持通配符
+// 模式 *.example.com 同时匹配 example.com 本身
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if ok, _ := path.Match(pattern, host); ok {
+		return true
+	}
+	return strings.HasPrefix(pattern, "*.") && host == pattern[2:]
+}
+
+// reservedNets 标准库判断之外的非公网地址段
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8",       // 本网络
+	"100.64.0.0/10",   // 运营商级NAT
+	"192.0.0.0/24",    // IETF协议分配
+	"192.0.2.0/24",    // 文档示例 TEST-NET-1
+	"198.18.0.0/15",   // 网络测试
+	"198.51.100.0/24", // 文档示例 TEST-NET-2
+	"203.0.113.0/24",  // 文档示例 TEST-NET-3
+	"240.0.0.0/4",     // 保留地址和广播地址
+	"64:ff9b::/96",    // NAT64，可映射到内网IPv4
+	"64:ff9b:1::/48",  // 本地NAT64
+	"100::/64",        // 丢弃前缀
+	"2001:db8::/32",   // 文档示例
+	"2002::/16",       // 6to4，可映射到内网IPv4
+)
+
+// mustParseCIDRs 解析固定的地址段列表
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// isPrivateIP 判断是否为回环、内网、链路本地、组播、运营商级NAT等非公网地址
+func isPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() {
+		return true
+	}
+	for _, ipNet := range reservedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// newSafeHTTPClient 创建在建立连接时校验目标地址的HTTP客户端
+// 用于本服务直接抓取远程内容，防止DNS重绑定绕过checkRemoteURL
+func newSafeHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if envBool(URLAllowPrivateEnvVar, false) {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+				return fmt.Errorf("禁止访问内网地址: %s", ip)
+			}
+			return nil
+		},
+	}
+
+	// 不使用HTTP(S)_PROXY：经过代理时连接的是代理地址，连接时的校验看不到真正的目标
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("重定向次数过多")
+			}
+			return checkRemoteURL(req.URL.String())
+		},
+	}
+}
+
+// fetchRemoteBody 校验访问策略后抓取远程内容，超过maxBytes时返回错误
+func fetchRemoteBody(ctx context.Context, rawURL string, maxBytes int64) ([]byte, error) {
+	if err := checkRemoteURL(rawURL); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("无效的URL %s: %w", rawURL, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := newSafeHTTPClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("无法访问 %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("访问 %s 失败，状态码: %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", rawURL, err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("%s 的内容超过 %d MB", rawURL, maxBytes>>20)
+	}
+	return data, nil
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// 可选的每日配额，用于在用量统计中显示已用比例；墨问没有提供查询配额的接口，按账号实际额度配置
+const (
+	// 每天允许的API调用次数，0表示未配置
+	DailyCallQuotaEnvVar = "MOWEN_DAILY_CALL_QUOTA"
+	// 每天允许上传的文件大小，支持KB、MB、GB单位，例如 500MB；不设置表示未配置
+	DailyUploadQuotaEnvVar = "MOWEN_DAILY_UPLOAD_QUOTA"
+)
+
+// 已用比例达到多少时提示接近配额
+const usageWarnRatio = 0.8
+
+// get_usage 最多统计的天数
+const maxUsageDays = 90
+
+// dayLayout 本地日期格式
+const dayLayout = "2006-01-02"
+
+// apiUsageRow 某一天某个接口的调用统计
+type apiUsageRow struct {
+	Day       string
+	Path      string
+	Calls     int
+	Failures  int
+	Throttled int
+}
+
+// upsertAPIUsageSQL 累加一次API调用
+const upsertAPIUsageSQL = `INSERT INTO api_usage (tenant_id, day, path, calls, failures, throttled) VALUES (?, ?, ?, 1, ?, ?)
+	ON CONFLICT(tenant_id, day, path) DO UPDATE SET calls = calls + 1, failures = failures + excluded.failures, throttled = throttled + excluded.throttled`
+
+// recordUsage 记录一次API调用，失败只写日志，不影响调用结果
+func (c *MowenClient) recordUsage(path string, resp *APIResponse, err error) {
+	failed, throttled := err != nil, false
+	if resp != nil {
+		failed = failed || resp.StatusCode >= http.StatusBadRequest
+		throttled = resp.StatusCode == http.StatusTooManyRequests
+	}
+	if err := RecordAPIUsage(c.TenantID, path, failed, throttled); err != nil {
+		logger.CtxDebugf(c.logContext(), "记录API用量失败: %v", err)
+	}
+}
+
+// RecordAPIUsage 累加租户当天对某个接口的调用次数
+func RecordAPIUsage(tenantID, path string, failed, throttled bool) error {
+	if err := InitSQLite(); err != nil {
+		return fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+	day := time.Now().Format(dayLayout)
+	return execWrite(func(tx *sql.Tx) error {
+		stmt, err := txStmt(tx, upsertAPIUsageSQL)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Exec(tenantID, day, path, boolToInt(failed), boolToInt(throttled))
+		return err
+	})
+}
+
+// boolToInt 转换为SQLite中的0和1
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ListAPIUsage 查询从指定本地日期起的调用统计
+func ListAPIUsage(tenantID, sinceDay string) ([]apiUsageRow, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query(`SELECT day, path, calls, failures, throttled FROM api_usage
+		WHERE tenant_id = ? AND day >= ? ORDER BY day DESC, path`, tenantID, sinceDay)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	var result []apiUsageRow
+	for rows.Next() {
+		var row apiUsageRow
+		if err = rows.Scan(&row.Day, &row.Path, &row.Calls, &row.Failures, &row.Throttled); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ListUploadsByDay 按本地日期汇总从指定时间起上传的附件
+func ListUploadsByDay(tenantID string, since time.Time) (map[string]*attachmentUsage, error) {
+	if err := InitSQLite(); err != nil {
+		return nil, fmt.Errorf("SQLite初始化失败: %v", err)
+	}
+
+	rows, err := sqliteDB.Query("SELECT size, created_at FROM attachments WHERE tenant_id = ? AND created_at >= ?",
+		tenantID, since.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %v", err)
+	}
+	defer rows.Close()
+
+	uploads := make(map[string]*attachmentUsage)
+	for rows.Next() {
+		var size int64
+		var createdAt string
+		if err = rows.Scan(&size, &createdAt); err != nil {
+			return nil, fmt.Errorf("扫描结果失败: %v", err)
+		}
+		t, err := parseDBTime(createdAt)
+		if err != nil {
+			continue
+		}
+		day := t.Local().Format(dayLayout)
+		if uploads[day] == nil {
+			uploads[day] = &attachmentUsage{}
+		}
+		uploads[day].add(size)
+	}
+	return uploads, rows.Err()
+}
+
+// dailyUploadQuota 配置的每日上传配额（字节），0表示未配置
+func dailyUploadQuota() int64 {
+	value := envString(DailyUploadQuotaEnvVar, "")
+	if value == "" {
+		return 0
+	}
+	quota, err := parseByteRate(value)
+	if err != nil {
+		logger.Warnf("%s 格式错误，忽略: %s", DailyUploadQuotaEnvVar, value)
+		return 0
+	}
+	return quota
+}
+
+// quotaLine 配额的已用比例，接近或超过配额时附带提示
+func quotaLine(name, used, limit string, ratio float64) string {
+	line := fmt.Sprintf("  %s: %s / %s（%.1f%%）\n", name, used, limit, ratio*100)
+	switch {
+	case ratio >= 1:
+		line += fmt.Sprintf("  ⚠️ 今天的%s已用完，后续请求可能被拒绝\n", name)
+	case ratio >= usageWarnRatio:
+		line += fmt.Sprintf("  ⚠️ 今天的%s已用 %.0f%%，大批量导入前请注意剩余额度\n", name, ratio*100)
+	}
+	return line
+}
+
+// shortAPIPath 去掉墨问开放接口的公共前缀
+func shortAPIPath(path string) string {
+	return strings.TrimPrefix(path, "/api/open/api/v1/")
+}
+
+// GetUsage 统计本服务发出的API调用次数和上传的文件大小，对照配置的每日配额显示已用比例
+func GetUsage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	days := 7
+	if v, ok := args["days"].(float64); ok && v >= 1 {
+		days = min(int(v), maxUsageDays)
+	}
+
+	tenantID := tenantFromContext(ctx)
+	now := time.Now()
+	today := now.Format(dayLayout)
+	start := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, now.Location())
+
+	rows, err := ListAPIUsage(tenantID, start.Format(dayLayout))
+	if err != nil {
+		return errorResult("", err), nil
+	}
+	uploads, err := ListUploadsByDay(tenantID, start)
+	if err != nil {
+		return errorResult("", err), nil
+	}
+
+	calls := make(map[string]*apiUsageRow)
+	byPath := make(map[string]int)
+	for _, row := range rows {
+		day := calls[row.Day]
+		if day == nil {
+			day = &apiUsageRow{Day: row.Day}
+			calls[row.Day] = day
+		}
+		day.Calls += row.Calls
+		day.Failures += row.Failures
+		day.Throttled += row.Throttled
+		byPath[shortAPIPath(row.Path)] += row.Calls
+	}
+
+	todayCalls := apiUsageRow{Day: today}
+	if row := calls[today]; row != nil {
+		todayCalls = *row
+	}
+	todayUploads := attachmentUsage{}
+	if usage := uploads[today]; usage != nil {
+		todayUploads = *usage
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📊 API用量（本服务发出的请求，本地统计）\n\n")
+	sb.WriteString(fmt.Sprintf("今天（%s）\n", today))
+	sb.WriteString(fmt.Sprintf("  API调用: %d 次，失败 %d 次，被限流 %d 次\n", todayCalls.Calls, todayCalls.Failures, todayCalls.Throttled))
+	if quota := envInt(DailyCallQuotaEnvVar, 0); quota > 0 {
+		sb.WriteString(quotaLine("调用配额", fmt.Sprintf("%d", todayCalls.Calls), fmt.Sprintf("%d", quota), float64(todayCalls.Calls)/float64(quota)))
+	}
+	sb.WriteString(fmt.Sprintf("  上传文件: %s\n", todayUploads))
+	if quota := dailyUploadQuota(); quota > 0 {
+		sb.WriteString(quotaLine("上传配额", formatBytes(todayUploads.Bytes), formatBytes(quota), float64(todayUploads.Bytes)/float64(quota)))
+	}
+
+	if days > 1 {
+		sb.WriteString(fmt.Sprintf("\n最近 %d 天\n", days))
+		active := false
+		for day := now; !day.Before(start); day = day.AddDate(0, 0, -1) {
+			key := day.Format(dayLayout)
+			row, usage := calls[key], uploads[key]
+			if row == nil && usage == nil {
+				continue
+			}
+			active = true
+			var parts []string
+			if row != nil {
+				parts = append(parts, fmt.Sprintf("调用 %d 次", row.Calls), fmt.Sprintf("失败 %d 次", row.Failures))
+				if row.Throttled > 0 {
+					parts = append(parts, fmt.Sprintf("被限流 %d 次", row.Throttled))
+				}
+			}
+			if usage != nil {
+				parts = append(parts, fmt.Sprintf("上传 %s", usage))
+			}
+			sb.WriteString(fmt.Sprintf("  %s  %s\n", key, strings.Join(parts, "，")))
+		}
+		if !active {
+			sb.WriteString("  没有调用记录\n")
+		}
+	}
+
+	if len(byPath) > 0 {
+		paths := make([]string, 0, len(byPath))
+		for path := range byPath {
+			paths = append(paths, path)
+		}
+		sort.Slice(paths, func(i, j int) bool {
+			if byPath[paths[i]] != byPath[paths[j]] {
+				return byPath[paths[i]] > byPath[paths[j]]
+			}
+			return paths[i] < paths[j]
+		})
+		sb.WriteString(fmt.Sprintf("\n按接口（最近 %d 天）\n", days))
+		for _, path := range paths {
+			sb.WriteString(fmt.Sprintf("  %s: %d 次\n", path, byPath[path]))
+		}
+	}
+
+	sb.WriteString("\n统计只包含通过本服务发出的请求，网页、App和其他客户端的操作不计入。")
+	if envInt(DailyCallQuotaEnvVar, 0) <= 0 && dailyUploadQuota() <= 0 {
+		sb.WriteString(fmt.Sprintf("配置 %s 和 %s 后可显示配额的已用比例。", DailyCallQuotaEnvVar, DailyUploadQuotaEnvVar))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// 用量统计工具
+var GetUsageTool = mcp.NewTool("get_usage",
+	mcp.WithDescription("查看本服务最近发出的墨问API调用次数（含失败和被限流次数）和上传的文件大小，配置了每日配额时显示已用比例。大批量导入前先用它确认剩余额度"),
+	mcp.WithNumber("days",
+		mcp.Description("统计最近几天，默认7，最大90"),
+	),
+)
+
+func getUsageHandler(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	ctx, request := newToolRequest(arguments)
+	return GetUsage(ctx, request)
+}
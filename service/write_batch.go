@@ -0,0 +1,160 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bytedance/gopkg/util/logger"
+)
+
+// 批量写入环境变量
+const (
+	// 合并排队写入的最长收集时间，默认20ms；没有其他写入排队时立即提交，设为0时每次写入单独提交
+	SQLiteBatchIntervalEnvVar = "MOWEN_SQLITE_BATCH_INTERVAL"
+	// 单个事务最多合并的写入数，默认100
+	SQLiteBatchSizeEnvVar = "MOWEN_SQLITE_BATCH_SIZE"
+)
+
+// writeOp 一次待提交的写入
+type writeOp struct {
+	fn   func(tx *sql.Tx) error
+	done chan error
+}
+
+// writeBatcher 把并发的写入合并到同一个事务中提交，减少每行一次的fsync
+// 调用方仍然等待自己的写入提交后才返回，写入后立即读取能看到最新数据
+type writeBatcher struct {
+	ops      chan writeOp
+	interval time.Duration
+	size     int
+	wg       sync.WaitGroup
+}
+
+var (
+	batcher   *writeBatcher
+	batcherMu sync.RWMutex
+)
+
+// startWriteBatcher 按配置启动批量写入，间隔为0时不启动
+func startWriteBatcher(db *sql.DB) {
+	interval := envDuration(SQLiteBatchIntervalEnvVar, 20*time.Millisecond)
+	size := envInt(SQLiteBatchSizeEnvVar, 100)
+	if interval <= 0 || size <= 1 {
+		return
+	}
+
+	b := &writeBatcher{ops: make(chan writeOp, size), interval: interval, size: size}
+	b.wg.Add(1)
+	go b.run(db)
+
+	batcherMu.Lock()
+	batcher = b
+	batcherMu.Unlock()
+}
+
+// stopWriteBatcher 提交所有未完成的写入并停止批量写入
+func stopWriteBatcher() {
+	batcherMu.Lock()
+	b := batcher
+	batcher = nil
+	batcherMu.Unlock()
+	if b == nil {
+		return
+	}
+	close(b.ops)
+	b.wg.Wait()
+}
+
+// execWrite 执行一次写入：开启批量写入时与其他写入合并提交，否则单独开启事务
+// fn返回错误时只回滚本次写入，不影响同一事务中的其他写入
+func execWrite(fn func(tx *sql.Tx) error) error {
+	batcherMu.RLock()
+	b := batcher
+	if b != nil {
+		op := writeOp{fn: fn, done: make(chan error, 1)}
+		b.ops <- op
+		batcherMu.RUnlock()
+		return <-op.done
+	}
+	batcherMu.RUnlock()
+
+	tx, err := sqliteDB.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// run 收集写入并提交：队列中没有其他写入时立即提交，不等待
+// 有写入在排队时一并收集，直到队列取空、达到数量上限或收集超过间隔
+// 提交期间到达的写入会在下一轮合并，并发越高每次提交的写入越多
+func (b *writeBatcher) run(db *sql.DB) {
+	defer b.wg.Done()
+	for op := range b.ops {
+		batch := []writeOp{op}
+		timer := time.NewTimer(b.interval)
+	collect:
+		for len(batch) < b.size && len(b.ops) > 0 {
+			select {
+			case next, ok := <-b.ops:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		b.commit(db, batch)
+	}
+}
+
+// commit 在一个事务中执行一批写入，每次写入使用独立的保存点
+func (b *writeBatcher) commit(db *sql.DB, batch []writeOp) {
+	results := make([]error, len(batch))
+	err := func() error {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("开启事务失败: %v", err)
+		}
+		defer tx.Rollback()
+		for i, op := range batch {
+			results[i] = withSavepoint(tx, "batch_write", op.fn)
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		logger.Warnf("批量提交 %d 条写入失败: %v", len(batch), err)
+	}
+
+	for i, op := range batch {
+		if err != nil {
+			op.done <- err
+		} else {
+			op.done <- results[i]
+		}
+	}
+}
+
+// withSavepoint 在保存点中执行fn，失败时只回滚到保存点
+func withSavepoint(tx *sql.Tx, name string, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.Exec("SAVEPOINT " + name); err != nil {
+		return fmt.Errorf("创建保存点失败: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Exec("ROLLBACK TO " + name)
+		tx.Exec("RELEASE " + name)
+		return err
+	}
+	if _, err := tx.Exec("RELEASE " + name); err != nil {
+		return fmt.Errorf("释放保存点失败: %v", err)
+	}
+	return nil
+}